@@ -25,7 +25,6 @@ func handleShutdown(cancel context.CancelFunc) {
 
 func connectToDB() error {
 	// Connection string with _txlock=immediate for read
-	dbFile := "IGDB_Database.db"
 	connStr := fmt.Sprintf("file:%s?mode=ro&_txlock=immediate&cache=shared", dbFile)
 	var err error
 	readDB, err = sql.Open("sqlite", connStr)
@@ -105,7 +104,12 @@ func closeDB() error {
 func txWrite(fn func(tx *sql.Tx) error) error {
 	mu.Lock()
 	defer mu.Unlock()
-	tx, err := writeDB.Begin()
+	return txOn(writeDB, fn)
+}
+
+// Runs fn in a transaction on any database, txWrite adds the lock for the live one
+func txOn(db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
@@ -124,6 +128,21 @@ func txWrite(fn func(tx *sql.Tx) error) error {
 
 	return nil
 }
+
+// Rewrites the file so deleted rows don't linger in free pages, then empties the WAL that still
+// holds the old pages. VACUUM can't run inside a transaction.
+func vacuumDB(db *sql.DB) error {
+	_, err := db.Exec("VACUUM")
+	if err != nil {
+		return fmt.Errorf("error vacuuming database: %w", err)
+	}
+	_, err = db.Exec("PRAGMA wal_checkpoint(TRUNCATE)")
+	if err != nil {
+		return fmt.Errorf("error checkpointing database: %w", err)
+	}
+	return nil
+}
+
 func txBatchUpdate(tx *sql.Tx, query string, values [][]any) error {
 	stmt, err := tx.Prepare(query)
 	if err != nil {
@@ -140,18 +159,28 @@ func txBatchUpdate(tx *sql.Tx, query string, values [][]any) error {
 	return nil
 }
 
+// Migrates the live database, the app can't run on a half migrated schema so failures are fatal
 func handleDBVersion() {
+	err := migrateDatabase(readDB, txWrite, true)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Brings a database up to the current schema, write runs each step in a transaction.
+// live is false for imported copies whose files are not in place yet.
+func migrateDatabase(db *sql.DB, write func(fn func(tx *sql.Tx) error) error, live bool) error {
 	var version int
-	err := readDB.QueryRow("SELECT version FROM DBVersion").Scan(&version)
+	err := db.QueryRow("SELECT version FROM DBVersion").Scan(&version)
 	if err != nil {
-		log.Fatal("error querying db version :", err)
+		return fmt.Errorf("error querying db version: %w", err)
 	}
 
 	switch version {
 	case 1:
 		log.Println("migrating from db v1 to v2")
 
-		err = txWrite(func(tx *sql.Tx) error {
+		err = write(func(tx *sql.Tx) error {
 			_, err := tx.Exec("DROP TABLE IF EXISTS ScreenShots")
 			if err != nil {
 				return (fmt.Errorf("failed to drop screenshots table: %w", err))
@@ -165,8 +194,440 @@ func handleDBVersion() {
 
 		})
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
 		log.Println("Migration to v2 complete.")
+		fallthrough
+	case 2:
+		log.Println("migrating from db v2 to v3")
+
+		err = write(func(tx *sql.Tx) error {
+			queries := []string{
+				`CREATE TABLE IF NOT EXISTS "IgdbIds" (
+				"UID"	TEXT NOT NULL UNIQUE,
+				"IgdbID"	INTEGER NOT NULL,
+				PRIMARY KEY("UID")
+				);`,
+				`CREATE TABLE IF NOT EXISTS "Genres" (
+				"UUID"	INTEGER NOT NULL UNIQUE,
+				"UID"	TEXT NOT NULL,
+				"Name"	TEXT NOT NULL,
+				PRIMARY KEY("UUID")
+				);`,
+				`CREATE TABLE IF NOT EXISTS "GameModes" (
+				"UUID"	INTEGER NOT NULL UNIQUE,
+				"UID"	TEXT NOT NULL,
+				"Name"	TEXT NOT NULL,
+				PRIMARY KEY("UUID")
+				);`,
+				`CREATE TABLE IF NOT EXISTS "PlayerPerspectives" (
+				"UUID"	INTEGER NOT NULL UNIQUE,
+				"UID"	TEXT NOT NULL,
+				"Name"	TEXT NOT NULL,
+				PRIMARY KEY("UUID")
+				);`,
+				`CREATE TABLE IF NOT EXISTS "Themes" (
+				"UUID"	INTEGER NOT NULL UNIQUE,
+				"UID"	TEXT NOT NULL,
+				"Name"	TEXT NOT NULL,
+				PRIMARY KEY("UUID")
+				);`,
+				`CREATE TABLE IF NOT EXISTS "GameEngines" (
+				"UUID"	INTEGER NOT NULL UNIQUE,
+				"UID"	TEXT NOT NULL,
+				"Name"	TEXT NOT NULL,
+				PRIMARY KEY("UUID")
+				);`,
+				`CREATE TABLE IF NOT EXISTS "Franchises" (
+				"UUID"	INTEGER NOT NULL UNIQUE,
+				"UID"	TEXT NOT NULL,
+				"Name"	TEXT NOT NULL,
+				PRIMARY KEY("UUID")
+				);`,
+				`CREATE TABLE IF NOT EXISTS "Collections" (
+				"UUID"	INTEGER NOT NULL UNIQUE,
+				"UID"	TEXT NOT NULL,
+				"Name"	TEXT NOT NULL,
+				PRIMARY KEY("UUID")
+				);`,
+				`CREATE TABLE IF NOT EXISTS "AgeRatings" (
+				"UUID"	INTEGER NOT NULL UNIQUE,
+				"UID"	TEXT NOT NULL,
+				"Organization"	TEXT NOT NULL,
+				"Rating"	TEXT NOT NULL,
+				PRIMARY KEY("UUID")
+				);`,
+				`CREATE TABLE IF NOT EXISTS "Websites" (
+				"UUID"	INTEGER NOT NULL UNIQUE,
+				"UID"	TEXT NOT NULL,
+				"Category"	TEXT NOT NULL,
+				"URL"	TEXT NOT NULL,
+				PRIMARY KEY("UUID")
+				);`,
+				`CREATE TABLE IF NOT EXISTS "SimilarGames" (
+				"UUID"	INTEGER NOT NULL UNIQUE,
+				"UID"	TEXT NOT NULL,
+				"IgdbID"	INTEGER NOT NULL,
+				"Name"	TEXT NOT NULL,
+				PRIMARY KEY("UUID")
+				);`,
+			}
+			for _, query := range queries {
+				_, err := tx.Exec(query)
+				if err != nil {
+					return fmt.Errorf("failed to create igdb metadata tables: %w", err)
+				}
+			}
+
+			_, err := tx.Exec(`UPDATE DBVersion SET version = 3`)
+			if err != nil {
+				return (fmt.Errorf("failed to update DB version: %w", err))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		log.Println("Migration to v3 complete.")
+		fallthrough
+	case 3:
+		log.Println("migrating from db v3 to v4")
+
+		err = write(func(tx *sql.Tx) error {
+			_, err := tx.Exec(`CREATE TABLE IF NOT EXISTS "Settings" (
+				"Key"	TEXT NOT NULL UNIQUE,
+				"Value"	TEXT NOT NULL,
+				PRIMARY KEY("Key")
+				);`)
+			if err != nil {
+				return fmt.Errorf("failed to create settings table: %w", err)
+			}
+
+			_, err = tx.Exec(`CREATE TABLE IF NOT EXISTS "Artwork" (
+				"UID"	TEXT NOT NULL,
+				"Type"	TEXT NOT NULL,
+				"Path"	TEXT NOT NULL,
+				"SourceURL"	TEXT NOT NULL,
+				"Provider"	TEXT NOT NULL,
+				PRIMARY KEY("UID", "Type")
+				);`)
+			if err != nil {
+				return fmt.Errorf("failed to create artwork table: %w", err)
+			}
+
+			// Existing covers become the first typed asset of every game
+			_, err = tx.Exec(`INSERT OR IGNORE INTO Artwork (UID, Type, Path, SourceURL, Provider)
+				SELECT UID, 'cover', 'coverArt/' || UID || '/' || UID || '-0.webp', '', 'legacy' FROM GameMetaData`)
+			if err != nil {
+				return fmt.Errorf("failed to backfill artwork table: %w", err)
+			}
+
+			_, err = tx.Exec(`UPDATE DBVersion SET version = 4`)
+			if err != nil {
+				return (fmt.Errorf("failed to update DB version: %w", err))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		log.Println("Migration to v4 complete.")
+		fallthrough
+	case 4:
+		log.Println("migrating from db v4 to v5")
+
+		err = write(func(tx *sql.Tx) error {
+			_, err := tx.Exec(`CREATE TABLE IF NOT EXISTS "ImageFailures" (
+				"Path"	TEXT NOT NULL UNIQUE,
+				"UID"	TEXT NOT NULL,
+				"AssetType"	TEXT NOT NULL,
+				"SourceURL"	TEXT NOT NULL,
+				"Error"	TEXT NOT NULL,
+				"Attempts"	INTEGER NOT NULL,
+				"LastAttempt"	TEXT NOT NULL,
+				PRIMARY KEY("Path")
+				);`)
+			if err != nil {
+				return fmt.Errorf("failed to create image failures table: %w", err)
+			}
+
+			_, err = tx.Exec(`UPDATE DBVersion SET version = 5`)
+			if err != nil {
+				return (fmt.Errorf("failed to update DB version: %w", err))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		log.Println("Migration to v5 complete.")
+		fallthrough
+	case 5:
+		log.Println("migrating from db v5 to v6")
+
+		err = write(func(tx *sql.Tx) error {
+			queries := []string{`CREATE TABLE IF NOT EXISTS "Screenshots" (
+				"ID"	INTEGER NOT NULL,
+				"UID"	TEXT NOT NULL,
+				"Path"	TEXT NOT NULL UNIQUE,
+				"Source"	TEXT NOT NULL,
+				"CapturedAt"	TEXT NOT NULL,
+				"Width"	INTEGER NOT NULL,
+				"Height"	INTEGER NOT NULL,
+				"Caption"	TEXT NOT NULL DEFAULT '',
+				"Favorite"	INTEGER NOT NULL DEFAULT 0,
+				PRIMARY KEY("ID" AUTOINCREMENT)
+				);`,
+				`CREATE INDEX IF NOT EXISTS "ScreenshotsUID" ON "Screenshots" ("UID");`,
+				`CREATE TABLE IF NOT EXISTS "ScreenshotTags" (
+				"ScreenshotID"	INTEGER NOT NULL,
+				"Tag"	TEXT NOT NULL,
+				PRIMARY KEY("ScreenshotID", "Tag")
+				);`,
+				`CREATE TABLE IF NOT EXISTS "Albums" (
+				"ID"	INTEGER NOT NULL,
+				"Name"	TEXT NOT NULL UNIQUE,
+				"CreatedAt"	TEXT NOT NULL,
+				PRIMARY KEY("ID" AUTOINCREMENT)
+				);`,
+				`CREATE TABLE IF NOT EXISTS "AlbumScreenshots" (
+				"AlbumID"	INTEGER NOT NULL,
+				"ScreenshotID"	INTEGER NOT NULL,
+				PRIMARY KEY("AlbumID", "ScreenshotID")
+				);`,
+			}
+			for _, query := range queries {
+				_, err := tx.Exec(query)
+				if err != nil {
+					return fmt.Errorf("failed to create screenshot tables: %w", err)
+				}
+			}
+
+			_, err := tx.Exec(`UPDATE DBVersion SET version = 6`)
+			if err != nil {
+				return (fmt.Errorf("failed to update DB version: %w", err))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		log.Println("Migration to v6 complete.")
+
+		// Index the screenshots that already exist on disk
+		if live {
+			_, _, err = rescanScreenshots()
+			if err != nil {
+				log.Printf("initial screenshot scan failed: %v", err)
+			}
+		}
+		fallthrough
+	case 6:
+		log.Println("migrating from db v6 to v7")
+
+		err = write(func(tx *sql.Tx) error {
+			_, err := tx.Exec(`CREATE TABLE IF NOT EXISTS "SteamScreenshotImports" (
+				"SourcePath"	TEXT NOT NULL UNIQUE,
+				"Path"	TEXT NOT NULL,
+				"ImportedAt"	TEXT NOT NULL,
+				PRIMARY KEY("SourcePath")
+				);`)
+			if err != nil {
+				return fmt.Errorf("failed to create steam screenshot imports table: %w", err)
+			}
+
+			_, err = tx.Exec(`UPDATE DBVersion SET version = 7`)
+			if err != nil {
+				return (fmt.Errorf("failed to update DB version: %w", err))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		log.Println("Migration to v7 complete.")
+		fallthrough
+	case 7:
+		log.Println("migrating from db v7 to v8")
+
+		err = write(func(tx *sql.Tx) error {
+			// Full quality copies kept next to the webp preview
+			_, err := tx.Exec(`ALTER TABLE Screenshots ADD COLUMN "OriginalPath" TEXT NOT NULL DEFAULT ''`)
+			if err != nil {
+				return fmt.Errorf("failed to add original path column: %w", err)
+			}
+			_, err = tx.Exec(`ALTER TABLE Screenshots ADD COLUMN "MasterPath" TEXT NOT NULL DEFAULT ''`)
+			if err != nil {
+				return fmt.Errorf("failed to add master path column: %w", err)
+			}
+
+			_, err = tx.Exec(`UPDATE DBVersion SET version = 8`)
+			if err != nil {
+				return (fmt.Errorf("failed to update DB version: %w", err))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		log.Println("Migration to v8 complete.")
+		fallthrough
+	case 8:
+		log.Println("migrating from db v8 to v9")
+
+		// Credentials go to the secret store before they are cleared, imported copies only lose them
+		if live {
+			err = migrateLegacySecrets(db)
+			if err != nil {
+				return err
+			}
+		}
+		err = write(func(tx *sql.Tx) error {
+			_, err := tx.Exec(`UPDATE SteamCreds SET SteamAPIKey = ''`)
+			if err != nil {
+				return fmt.Errorf("failed to clear steam api key: %w", err)
+			}
+			_, err = tx.Exec(`DELETE FROM PlayStationNpsso`)
+			if err != nil {
+				return fmt.Errorf("failed to clear npsso: %w", err)
+			}
+			_, err = tx.Exec(`DELETE FROM Settings WHERE Key IN ('SteamGridDBAPIKey', 'BackupPassphrase')`)
+			if err != nil {
+				return fmt.Errorf("failed to clear secret settings: %w", err)
+			}
+
+			_, err = tx.Exec(`UPDATE DBVersion SET version = 9`)
+			if err != nil {
+				return (fmt.Errorf("failed to update DB version: %w", err))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		// The cleared credentials would otherwise stay readable in free pages and end up in backups.
+		// Live migrations go through the write connection, imported copies are opened writable.
+		if live {
+			mu.Lock()
+			err = vacuumDB(writeDB)
+			mu.Unlock()
+		} else {
+			err = vacuumDB(db)
+		}
+		if err != nil {
+			return err
+		}
+		log.Println("Migration to v9 complete.")
+		fallthrough
+	case 9:
+		log.Println("migrating from db v9 to v10")
+
+		err = write(func(tx *sql.Tx) error {
+			// Companion devices paired for remote control, only a hash of their token is kept
+			_, err := tx.Exec(`CREATE TABLE IF NOT EXISTS "RemoteDevices" (
+				"ID"	TEXT NOT NULL,
+				"Name"	TEXT NOT NULL,
+				"TokenHash"	TEXT NOT NULL UNIQUE,
+				"CreatedAt"	TEXT NOT NULL,
+				"LastSeenAt"	TEXT NOT NULL DEFAULT '',
+				PRIMARY KEY("ID")
+				);`)
+			if err != nil {
+				return fmt.Errorf("failed to create remote devices table: %w", err)
+			}
+
+			_, err = tx.Exec(`UPDATE DBVersion SET version = 10`)
+			if err != nil {
+				return (fmt.Errorf("failed to update DB version: %w", err))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		log.Println("Migration to v10 complete.")
+		fallthrough
+	case 10:
+		log.Println("migrating from db v10 to v11")
+
+		err = write(func(tx *sql.Tx) error {
+			// Background jobs and the items they work through, kept so a job can resume after a
+			// cancel, an error or a restart
+			_, err := tx.Exec(`CREATE TABLE IF NOT EXISTS "Jobs" (
+				"ID"	TEXT NOT NULL,
+				"Kind"	TEXT NOT NULL,
+				"Source"	TEXT NOT NULL,
+				"Params"	TEXT NOT NULL DEFAULT '{}',
+				"Status"	TEXT NOT NULL,
+				"ContinueOnError"	INTEGER NOT NULL DEFAULT 1,
+				"Planned"	INTEGER NOT NULL DEFAULT 0,
+				"Error"	TEXT NOT NULL DEFAULT '',
+				"CreatedAt"	TEXT NOT NULL,
+				"StartedAt"	TEXT NOT NULL DEFAULT '',
+				"FinishedAt"	TEXT NOT NULL DEFAULT '',
+				PRIMARY KEY("ID")
+				);`)
+			if err != nil {
+				return fmt.Errorf("failed to create jobs table: %w", err)
+			}
+
+			_, err = tx.Exec(`CREATE TABLE IF NOT EXISTS "JobItems" (
+				"JobID"	TEXT NOT NULL,
+				"Position"	INTEGER NOT NULL,
+				"Key"	TEXT NOT NULL,
+				"Name"	TEXT NOT NULL,
+				"Data"	TEXT NOT NULL DEFAULT '{}',
+				"Status"	TEXT NOT NULL DEFAULT 'pending',
+				"Error"	TEXT NOT NULL DEFAULT '',
+				PRIMARY KEY("JobID","Position"),
+				FOREIGN KEY("JobID") REFERENCES "Jobs"("ID") ON DELETE CASCADE
+				);`)
+			if err != nil {
+				return fmt.Errorf("failed to create job items table: %w", err)
+			}
+
+			// Steam apps without store metadata, the Steam import leaves them out
+			_, err = tx.Exec(`CREATE TABLE IF NOT EXISTS "SteamAppIdsSkip" (
+				"AppID"	INTEGER NOT NULL,
+				PRIMARY KEY("AppID")
+				);`)
+			if err != nil {
+				return fmt.Errorf("failed to create steam skip table: %w", err)
+			}
+
+			_, err = tx.Exec(`UPDATE DBVersion SET version = 11`)
+			if err != nil {
+				return (fmt.Errorf("failed to update DB version: %w", err))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		log.Println("Migration to v11 complete.")
+	}
+	return nil
+}
+
+func getSetting(key string) (string, error) {
+	var value string
+	err := readDB.QueryRow("SELECT Value FROM Settings WHERE Key = ?", key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("settings query error: %w", err)
 	}
+	return value, nil
+}
+
+func setSetting(key string, value string) error {
+	err := txWrite(func(tx *sql.Tx) error {
+		_, err := tx.Exec("INSERT OR REPLACE INTO Settings (Key, Value) VALUES (?, ?)", key, value)
+		if err != nil {
+			return fmt.Errorf("error updating setting %s: %w", key, err)
+		}
+		return nil
+	})
+	return err
 }
@@ -46,10 +46,10 @@ func SQLiteWriteConfig(dbFile string) (*sql.DB, error) {
 }
 
 func checkAndCreateDB() {
-	if _, err := os.Stat("IGDB_Database.db"); os.IsNotExist(err) {
+	if _, err := os.Stat(dbFile); os.IsNotExist(err) {
 		log.Println("Database not found. Creating the database...")
 		// Creates DB if not found
-		db, err := SQLiteWriteConfig("IGDB_Database.db")
+		db, err := SQLiteWriteConfig(dbFile)
 		if err != nil {
 			log.Printf("create DB write Error %v", err)
 		}
@@ -232,15 +232,9 @@ func initializeDefaultDBValues(db *sql.DB) {
 }
 
 func checkAndCreateFolders() {
-	exePath, err := os.Executable()
-	if err != nil {
-		log.Printf("failed to get executable path: %v", err)
-	}
-	exeDir := filepath.Dir(exePath)
-
-	// Define folder paths
-	coverArtPath := filepath.Join(exeDir, "coverArt")
-	screenshotsPath := filepath.Join(exeDir, "screenshots")
+	// Define folder paths, in the data directory like the database
+	coverArtPath := filepath.Join(config.DataDir, "coverArt")
+	screenshotsPath := filepath.Join(config.DataDir, "screenshots")
 
 	// Check and create "coverArt" if it doesn't exist
 	if _, err := os.Stat(coverArtPath); os.IsNotExist(err) {
@@ -257,7 +251,23 @@ func checkAndCreateFolders() {
 	}
 }
 
+// IGDB credentials from the secret store, else from .env
 func initAPIKeys() {
+	storedID, err := getSecret(secretIGDBClientID)
+	if err != nil {
+		log.Printf("error reading igdb credentials: %v", err)
+	}
+	storedSecret, err := getSecret(secretIGDBClientSecret)
+	if err != nil {
+		log.Printf("error reading igdb credentials: %v", err)
+	}
+	igdbCredsMu.Lock()
+	defer igdbCredsMu.Unlock()
+	if storedID != "" && storedSecret != "" {
+		clientID = storedID
+		clientSecret = storedSecret
+		return
+	}
 	if clientID == "" || clientSecret == "" {
 		err := godotenv.Load()
 		if err != nil {
@@ -268,3 +278,10 @@ func initAPIKeys() {
 		return
 	}
 }
+
+// /igdbCreds reloads the credentials while requests use them
+func getIGDBCreds() (string, string) {
+	igdbCredsMu.RLock()
+	defer igdbCredsMu.RUnlock()
+	return clientID, clientSecret
+}
@@ -2,17 +2,21 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"strconv"
 	"strings"
-	"sync"
 	"time"
 )
 
-func getAccessToken(clientID string, clientSecret string) (string, error) {
+func getAccessToken(ctx context.Context) (string, error) {
+	clientID, clientSecret := getIGDBCreds()
+
 	// Struct Holds AccessToken which expires in a few thousand seconds
 	var accessStruct struct {
 		AccessToken string `json:"access_token"`
@@ -22,7 +26,11 @@ func getAccessToken(clientID string, clientSecret string) (string, error) {
 	AuthenticationString := fmt.Sprintf("https://id.twitch.tv/oauth2/token?client_id=%s&client_secret=%s&grant_type=client_credentials", clientID, clientSecret)
 
 	//POST request
-	resp, err := http.Post(AuthenticationString, "", bytes.NewBuffer([]byte{}))
+	req, err := http.NewRequestWithContext(ctx, "POST", AuthenticationString, bytes.NewBuffer([]byte{}))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("failed to send request")
 	}
@@ -56,7 +64,7 @@ func getAccessToken(clientID string, clientSecret string) (string, error) {
 	return accessStruct.AccessToken, nil
 }
 
-func searchGame(accessToken string, gameTofind string) (igdbSearchResult, error) {
+func searchGame(ctx context.Context, accessToken string, gameTofind string) (igdbSearchResult, error) {
 
 	var igdbSearchResult igdbSearchResult
 
@@ -64,7 +72,7 @@ func searchGame(accessToken string, gameTofind string) (igdbSearchResult, error)
 	// Here Category 0,8,9 sets it as a search for main game, remakes and remasters
 	bodyString := fmt.Sprintf(`fields *; search "%s"; limit 20; where category=(0,8,9);`, gameTofind)
 
-	result, err := post(postString, bodyString, accessToken)
+	result, err := post(ctx, postString, bodyString, accessToken)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch game data: %w", err)
 	}
@@ -92,7 +100,7 @@ func returnFoundGames(gameStruct igdbSearchResult) map[int]map[string]interface{
 	return (foundGames)
 }
 
-func getMetaData(gameID int, igdbSearchResult igdbSearchResult, accessToken string, platform string) (map[string]interface{}, error) {
+func getMetaData(ctx context.Context, gameID int, igdbSearchResult igdbSearchResult, accessToken string, platform string) (map[string]interface{}, error) {
 	// Initialize the map to store metadata
 	metadataMap := make(map[string]interface{})
 
@@ -109,11 +117,6 @@ func getMetaData(gameID int, igdbSearchResult igdbSearchResult, accessToken stri
 	}
 
 	var involvedCompaniesStruct TagsStruct
-	var playerPerspectiveStruct TagsStruct
-	var genresStruct TagsStruct
-	var themeStruct TagsStruct
-	var gameModesStruct TagsStruct
-	var gameEngineStruct TagsStruct
 	var coverStruct ImgStruct
 	var screenshotStruct ImgStruct
 
@@ -142,7 +145,7 @@ func getMetaData(gameID int, igdbSearchResult igdbSearchResult, accessToken stri
 	if !exists {
 		// Seperate Cause it needs 2 API calls
 		metadataMap["involvedCompanies"] = make(map[int]string)
-		err = getMetaData_InvolvedCompanies(gameIndex, &involvedCompaniesStruct, igdbSearchResult, accessToken)
+		err = getMetaData_InvolvedCompanies(ctx, gameIndex, &involvedCompaniesStruct, igdbSearchResult, accessToken)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get Involved Companies: %w", err)
 		}
@@ -154,55 +157,30 @@ func getMetaData(gameID int, igdbSearchResult igdbSearchResult, accessToken stri
 		metadataMap["involvedCompanies"] = involvedCompaniesSlice
 
 		// Tags
-		var tagsSlice []string
-		err = getMetaData_TagsAndEngine(accessToken, "https://api.igdb.com/v4/player_perspectives", igdbSearchResult[gameIndex].PlayerPerspectives, &playerPerspectiveStruct)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get player perspectives: %w", err)
-		}
-		err = getMetaData_TagsAndEngine(accessToken, "https://api.igdb.com/v4/genres", igdbSearchResult[gameIndex].Genres, &genresStruct)
+		typedMetaData, err := getIgdbTypedMetaData(ctx, accessToken, gameIndex, igdbSearchResult)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get genres: %w", err)
-		}
-		err = getMetaData_TagsAndEngine(accessToken, "https://api.igdb.com/v4/themes", igdbSearchResult[gameIndex].Themes, &themeStruct)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get themes: %w", err)
-		}
-		err = getMetaData_TagsAndEngine(accessToken, "https://api.igdb.com/v4/game_modes", igdbSearchResult[gameIndex].GameModes, &gameModesStruct)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get game modes: %w", err)
-		}
-		err = getMetaData_TagsAndEngine(accessToken, "https://api.igdb.com/v4/game_engines", igdbSearchResult[gameIndex].GameEngines, &gameEngineStruct)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get game engine: %w", err)
+			return nil, fmt.Errorf("failed to get typed metadata: %w", err)
 		}
 
-		for _, item := range playerPerspectiveStruct {
-			tagsSlice = append(tagsSlice, item.Name)
-		}
-		for _, item := range genresStruct {
-			tagsSlice = append(tagsSlice, item.Name)
-		}
-		for _, item := range themeStruct {
-			tagsSlice = append(tagsSlice, item.Name)
-		}
-		for _, item := range gameModesStruct {
-			tagsSlice = append(tagsSlice, item.Name)
-		}
-		for _, item := range gameEngineStruct {
-			tagsSlice = append(tagsSlice, item.Name)
+		var tagsSlice []string
+		for _, group := range []TagsStruct{typedMetaData.PlayerPerspectives, typedMetaData.Genres, typedMetaData.Themes, typedMetaData.GameModes, typedMetaData.GameEngines} {
+			for _, item := range group {
+				tagsSlice = append(tagsSlice, item.Name)
+			}
 		}
 
 		metadataMap["tags"] = tagsSlice
+		metadataMap["igdb"] = newIgdbTypedInfo(typedMetaData)
 
 		//Images
 
-		err = getMetaData_Images(accessToken, "https://api.igdb.com/v4/covers", gameID, &coverStruct)
+		err = getMetaData_Images(ctx, accessToken, "https://api.igdb.com/v4/covers", gameID, &coverStruct)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get cover art: %w", err)
 		}
 		metadataMap["cover"] = coverStruct[0].URL
 
-		err = getMetaData_Images(accessToken, "https://api.igdb.com/v4/screenshots", gameID, &screenshotStruct)
+		err = getMetaData_Images(ctx, accessToken, "https://api.igdb.com/v4/screenshots", gameID, &screenshotStruct)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get screenshots: %w", err)
 		}
@@ -217,9 +195,9 @@ func getMetaData(gameID int, igdbSearchResult igdbSearchResult, accessToken stri
 
 	return metadataMap, nil
 }
-func getMetaData_Images(accessToken string, postString string, gameID int, GeneralStruct *ImgStruct) error {
+func getMetaData_Images(ctx context.Context, accessToken string, postString string, gameID int, GeneralStruct *ImgStruct) error {
 	bodyString := fmt.Sprintf(`fields url; where game=%d;`, gameID)
-	body, err := post(postString, bodyString, accessToken)
+	body, err := post(ctx, postString, bodyString, accessToken)
 	if err != nil {
 		return fmt.Errorf("failed to fetch images: %w", err)
 	}
@@ -233,7 +211,7 @@ func getMetaData_Images(accessToken string, postString string, gameID int, Gener
 	}
 	return nil
 }
-func getMetaData_TagsAndEngine(accessToken string, postString string, GeneralArray []int, GeneralStruct *TagsStruct) error {
+func getMetaData_TagsAndEngine(ctx context.Context, accessToken string, postString string, GeneralArray []int, GeneralStruct *TagsStruct) error {
 	if GeneralArray == nil {
 		return nil
 	}
@@ -253,7 +231,7 @@ func getMetaData_TagsAndEngine(accessToken string, postString string, GeneralArr
 	tempString := buffer.String()
 	tempString, _ = strings.CutSuffix(tempString, ",")
 	bodyString := tempString + ");"
-	body, err := post(postString, bodyString, accessToken)
+	body, err := post(ctx, postString, bodyString, accessToken)
 	if err != nil {
 		return fmt.Errorf("failed to fetch tags/engine: %w", err)
 	}
@@ -264,7 +242,7 @@ func getMetaData_TagsAndEngine(accessToken string, postString string, GeneralArr
 
 	return nil
 }
-func getMetaData_InvolvedCompanies(gameIndex int, involvedCompaniesStruct *TagsStruct, gameStruct igdbSearchResult, accessToken string) error {
+func getMetaData_InvolvedCompanies(ctx context.Context, gameIndex int, involvedCompaniesStruct *TagsStruct, gameStruct igdbSearchResult, accessToken string) error {
 	// This function will neeed 2 API calls to get an actual company name due to nested IDs
 	if gameStruct[gameIndex].InvolvedCompanies == nil {
 		body := `[{"id":-1 , "name":"Unknown"}]`
@@ -297,7 +275,7 @@ func getMetaData_InvolvedCompanies(gameIndex int, involvedCompaniesStruct *TagsS
 		tempString, _ = strings.CutSuffix(tempString, ",")
 		bodyString := tempString + ");"
 
-		body, err := post(postString, bodyString, accessToken)
+		body, err := post(ctx, postString, bodyString, accessToken)
 		if err != nil {
 			return fmt.Errorf("failed to fetch involved companies: %w", err)
 		}
@@ -324,7 +302,7 @@ func getMetaData_InvolvedCompanies(gameIndex int, involvedCompaniesStruct *TagsS
 		tempString = buffer.String()
 		tempString, _ = strings.CutSuffix(tempString, ",")
 		bodyString = tempString + ");"
-		body, err = post(postString, bodyString, accessToken)
+		body, err = post(ctx, postString, bodyString, accessToken)
 		if err != nil {
 			return fmt.Errorf("failed to fetch company names: %w", err)
 		}
@@ -335,7 +313,389 @@ func getMetaData_InvolvedCompanies(gameIndex int, involvedCompaniesStruct *TagsS
 	}
 	return nil
 }
-func addGameToDB(title string, releaseDate string, platform string, timePlayed string, rating string, devs []string, tags []string, descripton string, coverImage string, screenshots []string, isWishlist int) (bool, error) {
+
+// Fetches every IGDB lookup that is stored as its own typed table
+func getIgdbTypedMetaData(ctx context.Context, accessToken string, gameIndex int, gameStruct igdbSearchResult) (igdbMetaData, error) {
+	game := gameStruct[gameIndex]
+	typedMetaData := igdbMetaData{IgdbID: game.ID}
+
+	lookups := []struct {
+		postString string
+		ids        []int
+		target     *TagsStruct
+	}{
+		{"https://api.igdb.com/v4/player_perspectives", game.PlayerPerspectives, &typedMetaData.PlayerPerspectives},
+		{"https://api.igdb.com/v4/genres", game.Genres, &typedMetaData.Genres},
+		{"https://api.igdb.com/v4/themes", game.Themes, &typedMetaData.Themes},
+		{"https://api.igdb.com/v4/game_modes", game.GameModes, &typedMetaData.GameModes},
+		{"https://api.igdb.com/v4/game_engines", game.GameEngines, &typedMetaData.GameEngines},
+		{"https://api.igdb.com/v4/franchises", appendUniqueID(game.Franchises, game.Franchise), &typedMetaData.Franchises},
+		{"https://api.igdb.com/v4/collections", appendUniqueID(game.Collections, game.Collection), &typedMetaData.Collections},
+		{"https://api.igdb.com/v4/games", game.SimilarGames, &typedMetaData.SimilarGames},
+	}
+	for _, lookup := range lookups {
+		err := getMetaData_TagsAndEngine(ctx, accessToken, lookup.postString, lookup.ids, lookup.target)
+		if err != nil {
+			return igdbMetaData{}, fmt.Errorf("failed to get %s: %w", lookup.postString, err)
+		}
+	}
+
+	ageRatings, err := getMetaData_AgeRatings(ctx, accessToken, game.AgeRatings)
+	if err != nil {
+		return igdbMetaData{}, fmt.Errorf("failed to get age ratings: %w", err)
+	}
+	typedMetaData.AgeRatings = ageRatings
+
+	websites, err := getMetaData_Websites(ctx, accessToken, game.Websites)
+	if err != nil {
+		return igdbMetaData{}, fmt.Errorf("failed to get websites: %w", err)
+	}
+	typedMetaData.Websites = websites
+
+	return typedMetaData, nil
+}
+
+func appendUniqueID(ids []int, id int) []int {
+	if id == 0 {
+		return ids
+	}
+	for _, existing := range ids {
+		if existing == id {
+			return ids
+		}
+	}
+	return append(append([]int{}, ids...), id)
+}
+
+func igdbIDList(ids []int) string {
+	idStrings := make([]string, len(ids))
+	for i, id := range ids {
+		idStrings[i] = strconv.Itoa(id)
+	}
+	return strings.Join(idStrings, ",")
+}
+
+// IGDB age_ratings.category enum
+var igdbAgeRatingOrganizations = map[int]string{
+	1: "ESRB", 2: "PEGI", 3: "CERO", 4: "USK", 5: "GRAC", 6: "CLASS_IND", 7: "ACB",
+}
+
+// IGDB age_ratings.rating enum
+var igdbAgeRatingValues = map[int]string{
+	1: "3", 2: "7", 3: "12", 4: "16", 5: "18",
+	6: "RP", 7: "EC", 8: "E", 9: "E10", 10: "T", 11: "M", 12: "AO",
+	13: "A", 14: "B", 15: "C", 16: "D", 17: "Z",
+	18: "0", 19: "6", 20: "12", 21: "16", 22: "18",
+	23: "All", 24: "12", 25: "15", 26: "18", 27: "Testing",
+	28: "L", 29: "10", 30: "12", 31: "14", 32: "16", 33: "18",
+	34: "G", 35: "PG", 36: "M", 37: "MA15", 38: "R18", 39: "RC",
+}
+
+// IGDB websites.category enum
+var igdbWebsiteCategories = map[int]string{
+	1: "official", 2: "wikia", 3: "wikipedia", 4: "facebook", 5: "twitter", 6: "twitch",
+	8: "instagram", 9: "youtube", 10: "iphone", 11: "ipad", 12: "android", 13: "steam",
+	14: "reddit", 15: "itch", 16: "epicgames", 17: "gog", 18: "discord",
+}
+
+func getMetaData_AgeRatings(ctx context.Context, accessToken string, ids []int) ([]igdbAgeRating, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var ratingsStruct []struct {
+		ID       int `json:"id"`
+		Category int `json:"category"`
+		Rating   int `json:"rating"`
+	}
+	bodyString := fmt.Sprintf("fields category,rating; where id=(%s);", igdbIDList(ids))
+	body, err := post(ctx, "https://api.igdb.com/v4/age_ratings", bodyString, accessToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch age ratings: %w", err)
+	}
+	err = json.Unmarshal(body, &ratingsStruct)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal age ratings: %w", err)
+	}
+
+	var ageRatings []igdbAgeRating
+	for _, item := range ratingsStruct {
+		organization, ok := igdbAgeRatingOrganizations[item.Category]
+		if !ok {
+			organization = "Unknown"
+		}
+		rating, ok := igdbAgeRatingValues[item.Rating]
+		if !ok {
+			rating = strconv.Itoa(item.Rating)
+		}
+		ageRatings = append(ageRatings, igdbAgeRating{Organization: organization, Rating: rating})
+	}
+	return ageRatings, nil
+}
+
+func getMetaData_Websites(ctx context.Context, accessToken string, ids []int) ([]igdbWebsite, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var websitesStruct []struct {
+		ID       int    `json:"id"`
+		Category int    `json:"category"`
+		URL      string `json:"url"`
+	}
+	bodyString := fmt.Sprintf("fields category,url; where id=(%s);", igdbIDList(ids))
+	body, err := post(ctx, "https://api.igdb.com/v4/websites", bodyString, accessToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch websites: %w", err)
+	}
+	err = json.Unmarshal(body, &websitesStruct)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal websites: %w", err)
+	}
+
+	var websites []igdbWebsite
+	for _, item := range websitesStruct {
+		category, ok := igdbWebsiteCategories[item.Category]
+		if !ok {
+			category = "other"
+		}
+		websites = append(websites, igdbWebsite{Category: category, URL: item.URL})
+	}
+	return websites, nil
+}
+
+func newIgdbTypedInfo(typedMetaData igdbMetaData) igdbTypedInfo {
+	names := func(tags TagsStruct) []string {
+		list := []string{}
+		for _, item := range tags {
+			list = append(list, item.Name)
+		}
+		return list
+	}
+	similarGames := []igdbTypedSimilarGame{}
+	for _, item := range typedMetaData.SimilarGames {
+		similarGames = append(similarGames, igdbTypedSimilarGame{IgdbID: item.ID, Name: item.Name})
+	}
+
+	ageRatings := typedMetaData.AgeRatings
+	if ageRatings == nil {
+		ageRatings = []igdbAgeRating{}
+	}
+	websites := typedMetaData.Websites
+	if websites == nil {
+		websites = []igdbWebsite{}
+	}
+
+	return igdbTypedInfo{
+		IgdbID:             typedMetaData.IgdbID,
+		Genres:             names(typedMetaData.Genres),
+		GameModes:          names(typedMetaData.GameModes),
+		PlayerPerspectives: names(typedMetaData.PlayerPerspectives),
+		Themes:             names(typedMetaData.Themes),
+		GameEngines:        names(typedMetaData.GameEngines),
+		Franchises:         names(typedMetaData.Franchises),
+		Collections:        names(typedMetaData.Collections),
+		AgeRatings:         ageRatings,
+		Websites:           websites,
+		SimilarGames:       similarGames,
+	}
+}
+
+// Turns typed metadata sent back by a client into the form txInsertIgdbMetaData stores
+func (info igdbTypedInfo) metaData() igdbMetaData {
+	tags := func(names []string) TagsStruct {
+		var list TagsStruct
+		for _, name := range names {
+			list = append(list, TagsStruct{{Name: name}}...)
+		}
+		return list
+	}
+	var similarGames TagsStruct
+	for _, item := range info.SimilarGames {
+		similarGames = append(similarGames, TagsStruct{{ID: item.IgdbID, Name: item.Name}}...)
+	}
+
+	return igdbMetaData{
+		IgdbID:             info.IgdbID,
+		Genres:             tags(info.Genres),
+		GameModes:          tags(info.GameModes),
+		PlayerPerspectives: tags(info.PlayerPerspectives),
+		Themes:             tags(info.Themes),
+		GameEngines:        tags(info.GameEngines),
+		Franchises:         tags(info.Franchises),
+		Collections:        tags(info.Collections),
+		AgeRatings:         info.AgeRatings,
+		Websites:           info.Websites,
+		SimilarGames:       similarGames,
+	}
+}
+
+// Tables holding one IGDB name per row, keyed by UID
+var igdbNamedTables = []string{"Genres", "GameModes", "PlayerPerspectives", "Themes", "GameEngines", "Franchises", "Collections"}
+
+// Clears and rewrites the typed IGDB tables for a UID inside an open transaction
+func txInsertIgdbMetaData(tx *sql.Tx, UID string, typedMetaData igdbMetaData) error {
+	err := txDeleteIgdbMetaData(tx, UID)
+	if err != nil {
+		return err
+	}
+
+	if typedMetaData.IgdbID != 0 {
+		_, err = tx.Exec("INSERT OR REPLACE INTO IgdbIds (UID, IgdbID) VALUES (?,?)", UID, typedMetaData.IgdbID)
+		if err != nil {
+			return fmt.Errorf("error inserting into IgdbIds: %w", err)
+		}
+	}
+
+	namedValues := map[string]TagsStruct{
+		"Genres":             typedMetaData.Genres,
+		"GameModes":          typedMetaData.GameModes,
+		"PlayerPerspectives": typedMetaData.PlayerPerspectives,
+		"Themes":             typedMetaData.Themes,
+		"GameEngines":        typedMetaData.GameEngines,
+		"Franchises":         typedMetaData.Franchises,
+		"Collections":        typedMetaData.Collections,
+	}
+	for _, table := range igdbNamedTables {
+		var values [][]any
+		for _, item := range namedValues[table] {
+			values = append(values, []any{UID, item.Name})
+		}
+		if len(values) > 0 {
+			err = txBatchUpdate(tx, fmt.Sprintf("INSERT INTO %s (UID, Name) VALUES (?,?)", table), values)
+			if err != nil {
+				return fmt.Errorf("error inserting into %s: %w", table, err)
+			}
+		}
+	}
+
+	var values [][]any
+	for _, item := range typedMetaData.AgeRatings {
+		values = append(values, []any{UID, item.Organization, item.Rating})
+	}
+	if len(values) > 0 {
+		err = txBatchUpdate(tx, "INSERT INTO AgeRatings (UID, Organization, Rating) VALUES (?,?,?)", values)
+		if err != nil {
+			return fmt.Errorf("error inserting into AgeRatings: %w", err)
+		}
+	}
+
+	values = [][]any{}
+	for _, item := range typedMetaData.Websites {
+		values = append(values, []any{UID, item.Category, item.URL})
+	}
+	if len(values) > 0 {
+		err = txBatchUpdate(tx, "INSERT INTO Websites (UID, Category, URL) VALUES (?,?,?)", values)
+		if err != nil {
+			return fmt.Errorf("error inserting into Websites: %w", err)
+		}
+	}
+
+	values = [][]any{}
+	for _, item := range typedMetaData.SimilarGames {
+		values = append(values, []any{UID, item.ID, item.Name})
+	}
+	if len(values) > 0 {
+		err = txBatchUpdate(tx, "INSERT INTO SimilarGames (UID, IgdbID, Name) VALUES (?,?,?)", values)
+		if err != nil {
+			return fmt.Errorf("error inserting into SimilarGames: %w", err)
+		}
+	}
+	return nil
+}
+
+func txDeleteIgdbMetaData(tx *sql.Tx, UID string) error {
+	tables := append([]string{"IgdbIds", "AgeRatings", "Websites", "SimilarGames"}, igdbNamedTables...)
+	for _, table := range tables {
+		_, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE UID=?", table), UID)
+		if err != nil {
+			return fmt.Errorf("error deleting %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+func getIgdbGameByID(ctx context.Context, accessToken string, igdbID int) (igdbSearchResult, error) {
+	var gameStruct igdbSearchResult
+	bodyString := fmt.Sprintf(`fields *; where id=%d;`, igdbID)
+	result, err := post(ctx, "https://api.igdb.com/v4/games", bodyString, accessToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch game data: %w", err)
+	}
+	err = json.Unmarshal(result, &gameStruct)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse IGDB response: %w", err)
+	}
+	if len(gameStruct) == 0 {
+		return nil, fmt.Errorf("IGDB game %d not found", igdbID)
+	}
+	return gameStruct, nil
+}
+
+func getStoredIgdbID(uid string) (int, error) {
+	var igdbID int
+	err := readDB.QueryRow("SELECT IgdbID FROM IgdbIds WHERE UID = ?", uid).Scan(&igdbID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("IgdbIds query error: %w", err)
+	}
+	return igdbID, nil
+}
+
+// Fetches and stores typed IGDB metadata for a game already in the library
+func storeIgdbMetaDataForUID(ctx context.Context, uid string, igdbID int) error {
+	accessToken, err := getAccessToken(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting IGDB access token: %w", err)
+	}
+	gameStruct, err := getIgdbGameByID(ctx, accessToken, igdbID)
+	if err != nil {
+		return err
+	}
+	typedMetaData, err := getIgdbTypedMetaData(ctx, accessToken, 0, gameStruct)
+	if err != nil {
+		return err
+	}
+	return txWrite(func(tx *sql.Tx) error {
+		return txInsertIgdbMetaData(tx, uid, typedMetaData)
+	})
+}
+
+// Re-fetches typed IGDB metadata using the IGDB id stored for the UID
+func refreshIgdbMetaData(ctx context.Context, uid string) error {
+	igdbID, err := getStoredIgdbID(uid)
+	if err != nil {
+		return err
+	}
+	if igdbID == 0 {
+		return fmt.Errorf("no IGDB id stored for UID %s", uid)
+	}
+	return storeIgdbMetaDataForUID(ctx, uid, igdbID)
+}
+
+func getIgdbFacets() (map[string][]string, error) {
+	facets := make(map[string][]string)
+	for _, table := range igdbNamedTables {
+		rows, err := readDB.Query(fmt.Sprintf("SELECT DISTINCT Name FROM %s ORDER BY Name", table))
+		if err != nil {
+			return nil, fmt.Errorf("query error %s: %w", table, err)
+		}
+		names := []string{}
+		for rows.Next() {
+			var name string
+			if err := rows.Scan(&name); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("scan err %s: %w", table, err)
+			}
+			names = append(names, name)
+		}
+		rows.Close()
+		facets[table] = names
+	}
+	return facets, nil
+}
+
+func addGameToDB(title string, releaseDate string, platform string, timePlayed string, rating string, devs []string, tags []string, descripton string, coverImage string, screenshots []string, isWishlist int, typedMetaData igdbMetaData) (bool, error) {
 	releaseDate = strings.Split(releaseDate, "T")[0]
 	releaseYear := strings.Split(releaseDate, "-")[0]
 	UID := GetMD5Hash(title + releaseYear + platform)
@@ -350,33 +710,32 @@ func addGameToDB(title string, releaseDate string, platform string, timePlayed s
 
 	fmt.Println("Inserting", title)
 
-	//Download Screenshots concurrently
-	var wg sync.WaitGroup
-	if len(screenshots) > 0 {
-		for i, screenshot := range screenshots {
-			if screenshot != "" {
-				wg.Add(1)
-				go func(i int, screenshot string) {
-					defer wg.Done()
-					getString := screenshots[i]
-					location := fmt.Sprintf(`%s/%s/`, "screenshots", UID)
-					filename := fmt.Sprintf(`generic-%d.webp`, i)
-					getImageFromURL(getString, location, filename)
-				}(i, screenshot)
-			}
+	//Download Screenshots and Coverart concurrently outside transaction
+	var downloads []imageDownload
+	for i, screenshot := range screenshots {
+		if screenshot != "" {
+			downloads = append(downloads, imageDownload{
+				UID:       UID,
+				AssetType: "screenshot",
+				Origin:    screenshotSourceIGDB,
+				Source:    screenshot,
+				Location:  fmt.Sprintf(`%s/%s/`, "screenshots", UID),
+				Filename:  fmt.Sprintf(`generic-%d.webp`, i),
+			})
 		}
 	}
-
-	//Download Coverart
 	if coverImage != "" {
-		getString := coverImage
-		location := fmt.Sprintf(`%s/%s/`, "coverArt", UID)
-		filename := fmt.Sprintf(`%s-%d.webp`, UID, 0)
-		getImageFromURL(getString, location, filename)
+		downloads = append(downloads, imageDownload{
+			UID:       UID,
+			AssetType: artworkCover,
+			Source:    coverImage,
+			Location:  fmt.Sprintf(`%s/%s/`, "coverArt", UID),
+			Filename:  fmt.Sprintf(`%s-%d.webp`, UID, 0),
+		})
+	}
+	if failed := downloadImages(downloads); len(failed) > 0 {
+		log.Printf("%d images for %s could not be downloaded", len(failed), title)
 	}
-
-	//wait outside transaction till all downloads done
-	wg.Wait()
 
 	//create and store Screenshotpaths and cover-art path
 	ScreenshotPaths := make([]string, len(screenshots))
@@ -422,6 +781,18 @@ func addGameToDB(title string, releaseDate string, platform string, timePlayed s
 		} else {
 			_, err = tx.Exec("INSERT INTO Tags (UID, Tags) VALUES (?,?)", UID, "Unknown")
 		}
+		if coverImage != "" {
+			err = txRegisterArtwork(tx, UID, artworkCover, fmt.Sprintf(`coverArt/%s/%s-0.webp`, UID, UID), coverImage, "custom")
+			if err != nil {
+				return fmt.Errorf("DB write error - inserting artwork: %v", err)
+			}
+		}
+		if typedMetaData.IgdbID != 0 {
+			err = txInsertIgdbMetaData(tx, UID, typedMetaData)
+			if err != nil {
+				return fmt.Errorf("DB write error - inserting IGDB metadata: %v", err)
+			}
+		}
 		return nil
 	})
 	if err != nil {
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+// The launcher passes a fresh token in QUICKSAVE_API_TOKEN on every start. Without it the backend
+// keeps a per-install token in api.token. The token in use is always written to api.token, so the
+// quicksave CLI and scripts can authenticate against a backend the launcher started.
+const (
+	apiTokenEnv       = "QUICKSAVE_API_TOKEN"
+	apiTokenFile      = "api.token"
+	apiTokenHeader    = "X-Quicksave-Token"
+	allowedOriginsEnv = "QUICKSAVE_ALLOWED_ORIGINS"
+	electronOrigin    = "file://"
+)
+
+var apiToken string
+
+func initAPIToken() {
+	token, source, err := loadAPIToken()
+	if err != nil {
+		log.Fatalf("could not set up API token %v", err)
+	}
+	apiToken = token
+	log.Printf("[Auth] using API token from %s", source)
+}
+
+func loadAPIToken() (string, string, error) {
+	if token := strings.TrimSpace(os.Getenv(apiTokenEnv)); token != "" {
+		err := os.WriteFile(apiTokenFile, []byte(token+"\n"), 0600)
+		if err != nil {
+			return "", "", fmt.Errorf("error writing %s: %w", apiTokenFile, err)
+		}
+		return token, apiTokenEnv, nil
+	}
+	data, err := os.ReadFile(apiTokenFile)
+	if err == nil && strings.TrimSpace(string(data)) != "" {
+		return strings.TrimSpace(string(data)), apiTokenFile, nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return "", "", fmt.Errorf("error reading %s: %w", apiTokenFile, err)
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+	token := hex.EncodeToString(raw)
+	err = os.WriteFile(apiTokenFile, []byte(token+"\n"), 0600)
+	if err != nil {
+		return "", "", fmt.Errorf("error writing %s: %w", apiTokenFile, err)
+	}
+	return token, apiTokenFile, nil
+}
+
+// Takes the token from "Authorization: Bearer" or X-Quicksave-Token
+func requestToken(c *gin.Context) string {
+	if bearer, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(bearer)
+	}
+	return c.GetHeader(apiTokenHeader)
+}
+
+// Browsers signed in through /login send a session cookie instead, page loads without one are
+// sent to the login page. Paired remote devices have tokens of their own that only reach the
+// remote control routes.
+func requireAPIToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := requestToken(c)
+		if token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(apiToken)) == 1 {
+			c.Next()
+			return
+		}
+		if requestSession(c) || c.Request.URL.Path == "/login" || c.FullPath() == remotePairRoute {
+			c.Next()
+			return
+		}
+		if token != "" {
+			if device, ok := deviceForToken(token); ok {
+				if !remoteDeviceAllowed(c) {
+					c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "device tokens only reach the remote control API"})
+					return
+				}
+				c.Set(remoteDeviceKey, device)
+				c.Next()
+				return
+			}
+		}
+		if config.Web && c.Request.Method == http.MethodGet && strings.Contains(c.GetHeader("Accept"), "text/html") {
+			c.Redirect(http.StatusSeeOther, "/login")
+			c.Abort()
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing or invalid API token"})
+	}
+}
+
+// Only the Electron renderer, origins listed in QUICKSAVE_ALLOWED_ORIGINS (the Vite dev server) and
+// the web frontend served by the backend itself may call the API from a browser. Requests from
+// other origins are refused before any handler runs.
+func apiCORS() gin.HandlerFunc {
+	allowed := map[string]bool{electronOrigin: true}
+	for _, origin := range strings.Split(os.Getenv(allowedOriginsEnv), ",") {
+		origin = strings.TrimRight(strings.TrimSpace(origin), "/")
+		if origin != "" {
+			allowed[origin] = true
+		}
+	}
+	handler := cors.New(cors.Config{
+		AllowOriginFunc: func(origin string) bool {
+			return allowed[origin]
+		},
+		AllowMethods:  []string{http.MethodGet, http.MethodPost, http.MethodPatch, http.MethodDelete, http.MethodOptions},
+		AllowHeaders:  []string{"Origin", "Content-Type", "Authorization", apiTokenHeader},
+		ExposeHeaders: []string{"Content-Disposition"},
+		MaxAge:        12 * time.Hour,
+	})
+	return func(c *gin.Context) {
+		if sameOrigin(c) {
+			c.Next()
+			return
+		}
+		handler(c)
+	}
+}
+
+// Browsers also send Origin on their own POSTs, those aren't cross-origin requests
+func sameOrigin(c *gin.Context) bool {
+	scheme := "http://"
+	if c.Request.TLS != nil {
+		scheme = "https://"
+	}
+	return c.GetHeader("Origin") == scheme+c.Request.Host
+}
@@ -0,0 +1,692 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Error codes of the /api/v1 error envelope
+const (
+	apiErrInvalidRequest = "invalid_request"
+	apiErrUnauthorized   = "unauthorized"
+	apiErrNotFound       = "not_found"
+	apiErrConflict       = "conflict"
+	apiErrInternal       = "internal"
+)
+
+// Import sources accepted by POST /api/v1/imports
+const (
+	importSourceSteam       = "steam"
+	importSourcePlayStation = "playstation"
+)
+
+var errGameNotFound = errors.New("game not found")
+
+// Every /api/v1 error is {"error": {"code", "message", "details"}}
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Details string `json:"details,omitempty"`
+}
+
+type apiErrorResponse struct {
+	Error apiError `json:"error"`
+}
+
+type listResponse[T any] struct {
+	Items []T `json:"items"`
+	Total int `json:"total"`
+}
+
+type pagedResponse[T any] struct {
+	Items    []T `json:"items"`
+	Total    int `json:"total"`
+	Page     int `json:"page"`
+	PageSize int `json:"pageSize"`
+}
+
+// A game with user preferences applied, as shown in the library
+type gameSummary struct {
+	UID         string            `json:"uid"`
+	Name        string            `json:"name"`
+	ReleaseDate string            `json:"releaseDate"`
+	Platform    string            `json:"platform"`
+	IsDLC       bool              `json:"isDLC"`
+	TimePlayed  float64           `json:"timePlayed"`
+	Rating      float64           `json:"rating"`
+	InstallPath string            `json:"installPath"`
+	Hidden      bool              `json:"hidden"`
+	Cover       map[string]string `json:"cover"`
+}
+
+type gameDetail struct {
+	gameSummary
+	Description string                       `json:"description"`
+	Tags        []string                     `json:"tags"`
+	Companies   []string                     `json:"companies"`
+	Artwork     map[string]map[string]string `json:"artwork"`
+	Igdb        gameIgdbInfo                 `json:"igdb"`
+}
+
+type gameIgdbInfo struct {
+	ID                 int             `json:"id"`
+	Genres             []string        `json:"genres"`
+	GameModes          []string        `json:"gameModes"`
+	PlayerPerspectives []string        `json:"playerPerspectives"`
+	Themes             []string        `json:"themes"`
+	GameEngines        []string        `json:"gameEngines"`
+	Franchises         []string        `json:"franchises"`
+	Collections        []string        `json:"collections"`
+	AgeRatings         []igdbAgeRating `json:"ageRatings"`
+	Websites           []igdbWebsite   `json:"websites"`
+	SimilarGames       []similarGame   `json:"similarGames"`
+}
+
+type similarGame struct {
+	IgdbID int    `json:"igdbID"`
+	Name   string `json:"name"`
+}
+
+// Fields left out are not changed
+type gameUpdate struct {
+	Hidden      *bool   `json:"hidden"`
+	InstallPath *string `json:"installPath"`
+}
+
+type launchResult struct {
+	Launched bool `json:"launched"`
+}
+
+type importSource struct {
+	Source     string `json:"source"`
+	Configured bool   `json:"configured"`
+}
+
+// Credentials left empty reuse the stored ones. Imports continue past games that fail unless
+// continueOnError is false.
+type importRequest struct {
+	Source          string `json:"source" binding:"required"`
+	SteamID         string `json:"steamId"`
+	APIKey          string `json:"apiKey"`
+	Npsso           string `json:"npsso"`
+	ContinueOnError *bool  `json:"continueOnError"`
+}
+
+// Filters for GET /api/v1/games. Tags must all match, platforms and developers match any.
+type gameQuery struct {
+	Name       string
+	Tags       []string
+	Platforms  []string
+	Developers []string
+	Hidden     string
+	Sort       string
+	Order      string
+}
+
+// Sort keys of the v1 API and the columns of gameSummaryQuery they map to
+var gameSortColumns = map[string]string{
+	"name":        "Name COLLATE NOCASE",
+	"releaseDate": "ReleaseDate",
+	"timePlayed":  "TimePlayed",
+	"rating":      "Rating",
+	"platform":    "Platform COLLATE NOCASE",
+}
+
+const gameSummaryQuery = `
+	SELECT * FROM (
+		SELECT
+			gmd.UID AS UID,
+			CASE WHEN gp.useCustomTitle = 1 THEN gp.CustomTitle ELSE gmd.Name END AS Name,
+			CASE WHEN gp.UseCustomReleaseDate = 1 THEN gp.CustomReleaseDate ELSE gmd.ReleaseDate END AS ReleaseDate,
+			gmd.OwnedPlatform AS Platform,
+			gmd.isDLC AS isDLC,
+			CASE
+				WHEN gp.useCustomTime = 1 THEN gp.CustomTime
+				WHEN gp.UseCustomTimeOffset = 1 THEN (gp.CustomTimeOffset + gmd.TimePlayed)
+				ELSE gmd.TimePlayed
+			END AS TimePlayed,
+			CASE WHEN gp.useCustomRating = 1 THEN gp.CustomRating ELSE gmd.AggregatedRating END AS Rating,
+			COALESCE(gmd.InstallPath, '') AS InstallPath,
+			gmd.CoverArtPath AS CoverArtPath,
+			EXISTS (SELECT 1 FROM HiddenGames h WHERE h.UID = gmd.UID) AS Hidden
+		FROM GameMetaData gmd
+		LEFT JOIN GamePreferences gp ON gmd.UID = gp.UID
+	) games`
+
+func writeAPIError(c *gin.Context, status int, code string, message string, err error) {
+	body := apiError{Code: code, Message: message}
+	if err != nil {
+		body.Details = err.Error()
+		if status >= http.StatusInternalServerError {
+			log.Printf("[APIv1] ERROR %s %s : %v", c.Request.Method, c.FullPath(), err)
+		}
+	}
+	c.AbortWithStatusJSON(status, apiErrorResponse{Error: body})
+}
+
+func queryGameSummaries(where []string, args []any, orderBy string) ([]gameSummary, error) {
+	query := gameSummaryQuery
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY " + orderBy
+
+	rows, err := readDB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query error games: %w", err)
+	}
+	defer rows.Close()
+
+	coverPaths, err := getAllCoverPaths()
+	if err != nil {
+		return nil, err
+	}
+	games := []gameSummary{}
+	for rows.Next() {
+		var game gameSummary
+		var coverArtPath string
+		err := rows.Scan(&game.UID, &game.Name, &game.ReleaseDate, &game.Platform, &game.IsDLC, &game.TimePlayed, &game.Rating, &game.InstallPath, &coverArtPath, &game.Hidden)
+		if err != nil {
+			return nil, fmt.Errorf("scan error games: %w", err)
+		}
+		coverPath, ok := coverPaths[game.UID]
+		if !ok {
+			coverPath = "coverArt" + coverArtPath
+		}
+		game.Cover = imageURLs(coverPath)
+		games = append(games, game)
+	}
+	return games, rows.Err()
+}
+
+// Unlike /getBasicInfo this doesn't read or change the filter and sort state of the UI
+func listGames(q gameQuery) ([]gameSummary, error) {
+	column, ok := gameSortColumns[q.Sort]
+	if q.Sort == "" {
+		column, ok = gameSortColumns["name"], true
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown sort %q", q.Sort)
+	}
+	switch strings.ToLower(q.Order) {
+	case "", "asc":
+		column += " ASC"
+	case "desc":
+		column += " DESC"
+	default:
+		return nil, fmt.Errorf("order must be asc or desc")
+	}
+
+	var where []string
+	var args []any
+	switch q.Hidden {
+	case "", "exclude":
+		where = append(where, "Hidden = 0")
+	case "only":
+		where = append(where, "Hidden = 1")
+	case "include":
+	default:
+		return nil, fmt.Errorf("hidden must be exclude, include or only")
+	}
+	if q.Name != "" {
+		where = append(where, "Name LIKE ? || '%'")
+		args = append(args, q.Name)
+	}
+	for _, tag := range q.Tags {
+		where = append(where, "UID IN (SELECT UID FROM Tags WHERE Tags = ?)")
+		args = append(args, tag)
+	}
+	if len(q.Platforms) > 0 {
+		where = append(where, "Platform IN ("+placeholders(len(q.Platforms))+")")
+		args = append(args, anySlice(q.Platforms)...)
+	}
+	if len(q.Developers) > 0 {
+		where = append(where, "UID IN (SELECT UID FROM InvolvedCompanies WHERE Name IN ("+placeholders(len(q.Developers))+"))")
+		args = append(args, anySlice(q.Developers)...)
+	}
+	return queryGameSummaries(where, args, column+", UID")
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+func anySlice(values []string) []any {
+	out := make([]any, len(values))
+	for i, value := range values {
+		out[i] = value
+	}
+	return out
+}
+
+func getGameSummary(uid string) (gameSummary, error) {
+	games, err := queryGameSummaries([]string{"UID = ?"}, []any{uid}, "UID")
+	if err != nil {
+		return gameSummary{}, err
+	}
+	if len(games) == 0 {
+		return gameSummary{}, errGameNotFound
+	}
+	return games[0], nil
+}
+
+func getGameDetail(uid string) (gameDetail, error) {
+	summary, err := getGameSummary(uid)
+	if err != nil {
+		return gameDetail{}, err
+	}
+	detail := gameDetail{gameSummary: summary, Artwork: make(map[string]map[string]string)}
+	err = readDB.QueryRow("SELECT Description FROM GameMetaData WHERE UID = ?", uid).Scan(&detail.Description)
+	if err != nil {
+		return detail, fmt.Errorf("query error GameMetaData: %w", err)
+	}
+
+	detail.Tags, err = queryStrings("SELECT Tags FROM Tags WHERE UID = ?", uid)
+	if err != nil {
+		return detail, err
+	}
+	namedLists := map[string]*[]string{
+		"InvolvedCompanies":  &detail.Companies,
+		"Genres":             &detail.Igdb.Genres,
+		"GameModes":          &detail.Igdb.GameModes,
+		"PlayerPerspectives": &detail.Igdb.PlayerPerspectives,
+		"Themes":             &detail.Igdb.Themes,
+		"GameEngines":        &detail.Igdb.GameEngines,
+		"Franchises":         &detail.Igdb.Franchises,
+		"Collections":        &detail.Igdb.Collections,
+	}
+	for table, target := range namedLists {
+		*target, err = getGameDetailsNamedList(table, uid)
+		if err != nil {
+			return detail, err
+		}
+	}
+
+	detail.Igdb.ID, err = getStoredIgdbID(uid)
+	if err != nil {
+		return detail, err
+	}
+	detail.Igdb.AgeRatings, err = queryRows("SELECT Organization, Rating FROM AgeRatings WHERE UID = ?", uid, func(rows *sql.Rows) (igdbAgeRating, error) {
+		var rating igdbAgeRating
+		return rating, rows.Scan(&rating.Organization, &rating.Rating)
+	})
+	if err != nil {
+		return detail, err
+	}
+	detail.Igdb.Websites, err = queryRows("SELECT Category, URL FROM Websites WHERE UID = ?", uid, func(rows *sql.Rows) (igdbWebsite, error) {
+		var website igdbWebsite
+		return website, rows.Scan(&website.Category, &website.URL)
+	})
+	if err != nil {
+		return detail, err
+	}
+	detail.Igdb.SimilarGames, err = queryRows("SELECT IgdbID, Name FROM SimilarGames WHERE UID = ?", uid, func(rows *sql.Rows) (similarGame, error) {
+		var game similarGame
+		return game, rows.Scan(&game.IgdbID, &game.Name)
+	})
+	if err != nil {
+		return detail, err
+	}
+
+	artwork, err := getArtwork(uid)
+	if err != nil {
+		return detail, err
+	}
+	for assetType, path := range artwork {
+		detail.Artwork[assetType] = imageURLs(path)
+	}
+	return detail, nil
+}
+
+func queryRows[T any](query string, uid string, scan func(rows *sql.Rows) (T, error)) ([]T, error) {
+	rows, err := readDB.Query(query, uid)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+	items := []T{}
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func queryStrings(query string, uid string) ([]string, error) {
+	return queryRows(query, uid, func(rows *sql.Rows) (string, error) {
+		var value string
+		return value, rows.Scan(&value)
+	})
+}
+
+func updateGame(uid string, update gameUpdate) error {
+	game, err := getGameSummary(uid)
+	if err != nil {
+		return err
+	}
+	if update.Hidden != nil && *update.Hidden != game.Hidden {
+		if *update.Hidden {
+			err = hideGame(uid)
+		} else {
+			err = unhideGame(uid)
+		}
+		if err != nil {
+			return err
+		}
+		if *update.Hidden {
+			publishEvent(eventGameHidden, gameEvent{UID: uid})
+		} else {
+			publishEvent(eventGameUnhidden, gameEvent{UID: uid})
+		}
+	}
+	if update.InstallPath != nil {
+		err = setInstallPath(uid, *update.InstallPath)
+		if err != nil {
+			return err
+		}
+		publishEvent(eventGameUpdated, gameEvent{UID: uid})
+	}
+	return nil
+}
+
+// Stores the credentials of the request and starts an import job that uses them
+func startSourceImport(req importRequest) (jobInfo, error) {
+	var params importJobParams
+	switch req.Source {
+	case importSourceSteam:
+		_, err := useSteamCreds(req.SteamID, req.APIKey)
+		if err != nil {
+			return jobInfo{}, err
+		}
+		params.SteamID = req.SteamID
+	case importSourcePlayStation:
+		_, err := useNpsso(req.Npsso)
+		if err != nil {
+			return jobInfo{}, err
+		}
+	}
+	continueOnError := req.ContinueOnError == nil || *req.ContinueOnError
+	return jobs.startImport(req.Source, params, continueOnError)
+}
+
+func importCredentialsConfigured(req importRequest) (bool, error) {
+	if req.APIKey != "" || req.Npsso != "" {
+		return true, nil
+	}
+	sources, err := getImportSources()
+	if err != nil {
+		return false, err
+	}
+	for _, source := range sources {
+		if source.Source == req.Source {
+			return source.Configured, nil
+		}
+	}
+	return false, nil
+}
+
+func getImportSources() ([]importSource, error) {
+	status, err := getCredentialStatus()
+	if err != nil {
+		return nil, err
+	}
+	return []importSource{
+		{Source: importSourceSteam, Configured: status["steam"]},
+		{Source: importSourcePlayStation, Configured: status["playStation"] && status["igdb"]},
+	}, nil
+}
+
+// Looks up the game named by :uid, writing a 404 when it doesn't exist
+func gameFromPath(c *gin.Context) (gameSummary, bool) {
+	game, err := getGameSummary(c.Param("uid"))
+	if errors.Is(err, errGameNotFound) {
+		writeAPIError(c, http.StatusNotFound, apiErrNotFound, "game not found", nil)
+		return game, false
+	}
+	if err != nil {
+		writeAPIError(c, http.StatusInternalServerError, apiErrInternal, "failed to load game", err)
+		return game, false
+	}
+	return game, true
+}
+
+func stringListHandler(name string, list func() ([]string, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		items, err := list()
+		if err != nil {
+			writeAPIError(c, http.StatusInternalServerError, apiErrInternal, "failed to load "+name, err)
+			return
+		}
+		if items == nil {
+			items = []string{}
+		}
+		c.JSON(http.StatusOK, listResponse[string]{Items: items, Total: len(items)})
+	}
+}
+
+// The versioned API. Resources are plural nouns, reads are GET, changes are POST, PATCH and DELETE,
+// and errors always use the apiErrorResponse envelope. The legacy routes stay for the current UI.
+func setupAPIv1(r *gin.Engine) {
+	v1 := r.Group("/api/v1")
+
+	v1.GET("/games", func(c *gin.Context) {
+		games, err := listGames(gameQuery{
+			Name:       c.Query("name"),
+			Tags:       c.QueryArray("tag"),
+			Platforms:  c.QueryArray("platform"),
+			Developers: c.QueryArray("developer"),
+			Hidden:     c.Query("hidden"),
+			Sort:       c.Query("sort"),
+			Order:      c.Query("order"),
+		})
+		if err != nil {
+			writeAPIError(c, http.StatusBadRequest, apiErrInvalidRequest, "invalid game query", err)
+			return
+		}
+		c.JSON(http.StatusOK, listResponse[gameSummary]{Items: games, Total: len(games)})
+	})
+
+	v1.GET("/games/:uid", func(c *gin.Context) {
+		if _, ok := gameFromPath(c); !ok {
+			return
+		}
+		detail, err := getGameDetail(c.Param("uid"))
+		if err != nil {
+			writeAPIError(c, http.StatusInternalServerError, apiErrInternal, "failed to load game", err)
+			return
+		}
+		c.JSON(http.StatusOK, detail)
+	})
+
+	v1.PATCH("/games/:uid", func(c *gin.Context) {
+		var update gameUpdate
+		if err := c.ShouldBindJSON(&update); err != nil {
+			writeAPIError(c, http.StatusBadRequest, apiErrInvalidRequest, "invalid game update", err)
+			return
+		}
+		if _, ok := gameFromPath(c); !ok {
+			return
+		}
+		err := updateGame(c.Param("uid"), update)
+		if err != nil {
+			writeAPIError(c, http.StatusInternalServerError, apiErrInternal, "failed to update game", err)
+			return
+		}
+		game, ok := gameFromPath(c)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, game)
+	})
+
+	v1.DELETE("/games/:uid", func(c *gin.Context) {
+		if _, ok := gameFromPath(c); !ok {
+			return
+		}
+		err := deleteGameFromDB(c.Param("uid"))
+		if err != nil {
+			writeAPIError(c, http.StatusInternalServerError, apiErrInternal, "failed to delete game", err)
+			return
+		}
+		publishEvent(eventGameDeleted, gameEvent{UID: c.Param("uid")})
+		c.Status(http.StatusNoContent)
+	})
+
+	// Blocks until a game launched from its install path quits, like /LaunchGame
+	v1.POST("/games/:uid/launch", func(c *gin.Context) {
+		if _, ok := gameFromPath(c); !ok {
+			return
+		}
+		launched, err := launchGame(c.Param("uid"))
+		if err != nil {
+			writeAPIError(c, http.StatusInternalServerError, apiErrInternal, "failed to launch game", err)
+			return
+		}
+		if !launched {
+			writeAPIError(c, http.StatusConflict, apiErrConflict, "game has no install path", nil)
+			return
+		}
+		c.JSON(http.StatusOK, launchResult{Launched: true})
+	})
+
+	v1.GET("/games/:uid/screenshots", func(c *gin.Context) {
+		if _, ok := gameFromPath(c); !ok {
+			return
+		}
+		page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+		pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", strconv.Itoa(screenshotDefaultPageSize)))
+		if page < 1 || pageSize < 1 || pageSize > screenshotMaxPageSize {
+			writeAPIError(c, http.StatusBadRequest, apiErrInvalidRequest, fmt.Sprintf("page must be at least 1 and pageSize between 1 and %d", screenshotMaxPageSize), nil)
+			return
+		}
+		screenshots, total, err := listScreenshots(screenshotFilter{UID: c.Param("uid")}, page, pageSize)
+		if err != nil {
+			writeAPIError(c, http.StatusInternalServerError, apiErrInternal, "failed to list screenshots", err)
+			return
+		}
+		if screenshots == nil {
+			screenshots = []screenshotRecord{}
+		}
+		c.JSON(http.StatusOK, pagedResponse[screenshotRecord]{Items: screenshots, Total: total, Page: page, PageSize: pageSize})
+	})
+
+	v1.GET("/tags", stringListHandler("tags", getAllTags))
+	v1.GET("/platforms", stringListHandler("platforms", getPlatforms))
+	v1.GET("/developers", stringListHandler("developers", getAllDevelopers))
+
+	// Typed events with replay, type narrows them to types starting with the given prefixes
+	v1.GET("/events", func(c *gin.Context) {
+		streamEvents(c, eventTypeFilter(c.QueryArray("type")), nil)
+	})
+
+	v1.GET("/imports", func(c *gin.Context) {
+		sources, err := getImportSources()
+		if err != nil {
+			writeAPIError(c, http.StatusInternalServerError, apiErrInternal, "failed to load import sources", err)
+			return
+		}
+		c.JSON(http.StatusOK, listResponse[importSource]{Items: sources, Total: len(sources)})
+	})
+
+	v1.POST("/imports", func(c *gin.Context) {
+		var req importRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			writeAPIError(c, http.StatusBadRequest, apiErrInvalidRequest, "invalid import request", err)
+			return
+		}
+		if req.Source != importSourceSteam && req.Source != importSourcePlayStation {
+			writeAPIError(c, http.StatusBadRequest, apiErrInvalidRequest, "source must be steam or playstation", nil)
+			return
+		}
+		if req.Source == importSourceSteam && req.SteamID == "" {
+			writeAPIError(c, http.StatusBadRequest, apiErrInvalidRequest, "steamId is required", nil)
+			return
+		}
+		configured, err := importCredentialsConfigured(req)
+		if err != nil {
+			writeAPIError(c, http.StatusInternalServerError, apiErrInternal, "failed to read credentials", err)
+			return
+		}
+		if !configured {
+			writeAPIError(c, http.StatusBadRequest, apiErrInvalidRequest, "no stored credentials for "+req.Source+", pass them in the request", nil)
+			return
+		}
+		job, err := startSourceImport(req)
+		if errors.Is(err, errJobRunning) {
+			writeAPIError(c, http.StatusConflict, apiErrConflict, "a "+req.Source+" import is already running as job "+job.ID, nil)
+			return
+		}
+		if err != nil {
+			writeAPIError(c, http.StatusInternalServerError, apiErrInternal, "failed to start "+req.Source+" import", err)
+			return
+		}
+		c.JSON(http.StatusAccepted, job)
+	})
+
+	v1.GET("/jobs", func(c *gin.Context) {
+		list, err := listJobs()
+		if err != nil {
+			writeAPIError(c, http.StatusInternalServerError, apiErrInternal, "failed to load jobs", err)
+			return
+		}
+		c.JSON(http.StatusOK, listResponse[jobInfo]{Items: list, Total: len(list)})
+	})
+
+	v1.GET("/jobs/:id", func(c *gin.Context) {
+		job, err := getJobDetail(c.Param("id"))
+		if err != nil {
+			writeJobError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, job)
+	})
+
+	// Both answer before the job changes, it stops after its current item or starts in the background
+	v1.POST("/jobs/:id/cancel", func(c *gin.Context) {
+		job, err := jobs.cancel(c.Param("id"))
+		if err != nil {
+			writeJobError(c, err)
+			return
+		}
+		c.JSON(http.StatusAccepted, job)
+	})
+
+	v1.POST("/jobs/:id/resume", func(c *gin.Context) {
+		job, err := jobs.resume(c.Param("id"))
+		if err != nil {
+			writeJobError(c, err)
+			return
+		}
+		c.JSON(http.StatusAccepted, job)
+	})
+}
+
+func writeJobError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errJobNotFound):
+		writeAPIError(c, http.StatusNotFound, apiErrNotFound, "job not found", nil)
+	case errors.Is(err, errJobRunning), errors.Is(err, errJobNotRunning), errors.Is(err, errJobComplete):
+		writeAPIError(c, http.StatusConflict, apiErrConflict, err.Error(), nil)
+	default:
+		writeAPIError(c, http.StatusInternalServerError, apiErrInternal, "job request failed", err)
+	}
+}
+
+// Unknown /api/v1 routes get the error envelope, anything else gin's plain 404
+func apiNotFound(c *gin.Context) {
+	if strings.HasPrefix(c.Request.URL.Path, "/api/v1/") {
+		writeAPIError(c, http.StatusNotFound, apiErrNotFound, "no such endpoint", nil)
+		return
+	}
+	c.String(http.StatusNotFound, "404 page not found")
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Typed artwork assets every game can have
+const (
+	artworkCover = "cover"
+	artworkHero  = "hero"
+	artworkLogo  = "logo"
+	artworkIcon  = "icon"
+)
+
+var artworkTypes = []string{artworkCover, artworkHero, artworkLogo, artworkIcon}
+
+type artworkCandidate struct {
+	URL      string `json:"url"`
+	Thumb    string `json:"thumb"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	Style    string `json:"style"`
+	Author   string `json:"author"`
+	Score    int    `json:"score"`
+	Provider string `json:"provider"`
+}
+
+// An artworkProvider finds alternative images for one asset type of a game
+type artworkProvider interface {
+	Name() string
+	FindArtwork(uid string, assetType string, providerGameID int) ([]artworkCandidate, error)
+}
+
+func isArtworkType(assetType string) bool {
+	for _, t := range artworkTypes {
+		if t == assetType {
+			return true
+		}
+	}
+	return false
+}
+
+// Covers keep their historic location so existing paths stay valid
+func artworkLocation(uid string, assetType string) (string, string) {
+	if assetType == artworkCover {
+		return fmt.Sprintf(`%s/%s/`, "coverArt", uid), fmt.Sprintf(`%s-%d.webp`, uid, 0)
+	}
+	return fmt.Sprintf(`%s/%s/`, "artwork", uid), assetType + ".webp"
+}
+
+func setArtwork(uid string, assetType string, source string, provider string) (string, error) {
+	if !isArtworkType(assetType) {
+		return "", fmt.Errorf("unknown artwork type %s", assetType)
+	}
+	location, filename := artworkLocation(uid, assetType)
+	err := downloadImage(imageDownload{UID: uid, AssetType: assetType, Source: source, Location: location, Filename: filename})
+	if err != nil {
+		return "", fmt.Errorf("error downloading %s artwork: %w", assetType, err)
+	}
+
+	path := location + filename
+	sourceURL := source
+	if strings.HasPrefix(sourceURL, "data:image") {
+		sourceURL = ""
+	}
+
+	err = txWrite(func(tx *sql.Tx) error {
+		return txRegisterArtwork(tx, uid, assetType, path, sourceURL, provider)
+	})
+	if err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func removeArtwork(uid string, assetType string) error {
+	location, filename := artworkLocation(uid, assetType)
+	err := os.Remove(filepath.Join(location, filename))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error removing %s artwork: %w", assetType, err)
+	}
+	return txWrite(func(tx *sql.Tx) error {
+		_, err := tx.Exec("DELETE FROM Artwork WHERE UID = ? AND Type = ?", uid, assetType)
+		if err != nil {
+			return fmt.Errorf("error deleting Artwork: %w", err)
+		}
+		return nil
+	})
+}
+
+func getArtwork(uid string) (map[string]string, error) {
+	rows, err := readDB.Query("SELECT Type, Path FROM Artwork WHERE UID = ?", uid)
+	if err != nil {
+		return nil, fmt.Errorf("query error Artwork: %w", err)
+	}
+	defer rows.Close()
+
+	artwork := make(map[string]string)
+	for rows.Next() {
+		var assetType, path string
+		err := rows.Scan(&assetType, &path)
+		if err != nil {
+			return nil, fmt.Errorf("scan error Artwork: %w", err)
+		}
+		artwork[assetType] = path
+	}
+	return artwork, nil
+}
+
+// Records an asset that an importer already wrote to disk
+func txRegisterArtwork(tx *sql.Tx, uid string, assetType string, path string, sourceURL string, provider string) error {
+	_, err := tx.Exec("INSERT OR REPLACE INTO Artwork (UID, Type, Path, SourceURL, Provider) VALUES (?,?,?,?,?)",
+		uid, assetType, path, sourceURL, provider)
+	if err != nil {
+		return fmt.Errorf("error inserting into Artwork: %w", err)
+	}
+	return nil
+}
+
+func txDeleteArtwork(tx *sql.Tx, uid string) error {
+	_, err := tx.Exec("DELETE FROM Artwork WHERE UID=?", uid)
+	if err != nil {
+		return fmt.Errorf("error deleting Artwork: %w", err)
+	}
+	return nil
+}
+
+func findArtworkCandidates(uid string, assetType string, providerGameID int) ([]artworkCandidate, error) {
+	if !isArtworkType(assetType) {
+		return nil, fmt.Errorf("unknown artwork type %s", assetType)
+	}
+	var provider artworkProvider
+	provider, err := newSteamGridDBProvider()
+	if err != nil {
+		return nil, err
+	}
+	return provider.FindArtwork(uid, assetType, providerGameID)
+}
+
+// Fills every missing asset type with the provider's best scored candidate
+func autoFillArtwork(uid string) (map[string]string, error) {
+	existing, err := getArtwork(uid)
+	if err != nil {
+		return nil, err
+	}
+	provider, err := newSteamGridDBProvider()
+	if err != nil {
+		return nil, err
+	}
+
+	providerGameID, err := provider.gameIDForUID(uid)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, assetType := range artworkTypes {
+		if _, ok := existing[assetType]; ok {
+			continue
+		}
+		candidates, err := provider.FindArtwork(uid, assetType, providerGameID)
+		if err != nil {
+			log.Printf("error finding %s artwork for %s: %v", assetType, uid, err)
+			continue
+		}
+		if len(candidates) == 0 {
+			continue
+		}
+		path, err := setArtwork(uid, assetType, candidates[0].URL, provider.Name())
+		if err != nil {
+			return nil, err
+		}
+		existing[assetType] = path
+	}
+	return existing, nil
+}
+
+func getAllCoverPaths() (map[string]string, error) {
+	rows, err := readDB.Query("SELECT UID, Path FROM Artwork WHERE Type = ?", artworkCover)
+	if err != nil {
+		return nil, fmt.Errorf("query error Artwork: %w", err)
+	}
+	defer rows.Close()
+
+	coverPaths := make(map[string]string)
+	for rows.Next() {
+		var uid, path string
+		err := rows.Scan(&uid, &path)
+		if err != nil {
+			return nil, fmt.Errorf("scan error Artwork: %w", err)
+		}
+		coverPaths[uid] = path
+	}
+	return coverPaths, nil
+}
@@ -1,81 +1,571 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"encoding/json"
 	"fmt"
 	"io"
-	"io/fs"
+	"log"
+	"net/http"
 	"os"
 	"path/filepath"
-	"strings"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"modernc.org/sqlite"
+)
+
+// Settings keys for backup retention
+const (
+	backupKeepDailySetting  = "BackupKeepDaily"
+	backupKeepWeeklySetting = "BackupKeepWeekly"
 )
 
-func doBackup() error {
+const (
+	backupDefaultKeepDaily  = 7
+	backupDefaultKeepWeekly = 4
+	backupDBFile            = dbFile
+	backupMetaFile          = "backup.json"
+	backupIDLayout          = "20060102-150405"
+)
+
+// Each snapshot is a folder under snapshots/ holding a consistent copy of the DB and the image folders.
+// Images unchanged since the previous snapshot are hard links, so snapshots only cost what changed.
+// An encrypted snapshot stores the DB as IGDB_Database.db.enc, images stay plain so they can be linked.
+type backupInfo struct {
+	ID              string    `json:"id"`
+	CreatedAt       time.Time `json:"createdAt"`
+	Reason          string    `json:"reason"`
+	DBVersion       int       `json:"dbVersion"`
+	DBSize          int64     `json:"dbSize"`
+	ImageFiles      int       `json:"imageFiles"`
+	Encrypted       bool      `json:"encrypted"`
+	SecretsExcluded bool      `json:"secretsExcluded"`
+}
+
+type backupRetention struct {
+	KeepDaily  int `json:"keepDaily"`
+	KeepWeekly int `json:"keepWeekly"`
+}
+
+// Only one backup or restore runs at a time
+var backupLock sync.Mutex
+
+// Set while a restore swaps the DB and images, requests are turned away until it is done
+var maintenanceMode atomic.Bool
+
+// Turns requests away with 503 while a restore is running
+func maintenanceGate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if maintenanceMode.Load() {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "a backup restore is in progress"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// The configured destination, or quicksaveBackup next to the data directory
+func backupRoot() (string, error) {
+	destination, err := getSetting(backupDestinationSetting)
+	if err != nil {
+		return "", err
+	}
+	if destination != "" {
+		return destination, nil
+	}
+	return filepath.Join(filepath.Dir(config.DataDir), "quicksaveBackup"), nil
+}
 
-	exePath, err := os.Executable()
+func snapshotsDir() (string, error) {
+	root, err := backupRoot()
 	if err != nil {
-		return fmt.Errorf("error getting executable path: %w", err)
+		return "", err
+	}
+	return filepath.Join(root, "snapshots"), nil
+}
 
+func createSnapshot(reason string) (backupInfo, error) {
+	backupLock.Lock()
+	defer backupLock.Unlock()
+
+	dir, err := snapshotsDir()
+	if err != nil {
+		return backupInfo{}, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return backupInfo{}, fmt.Errorf("error creating backup folder: %w", err)
+	}
+	previous, err := listBackups()
+	if err != nil {
+		return backupInfo{}, err
 	}
 
-	baseDir := filepath.Dir(exePath)
-	backupDir := filepath.Join(baseDir, "..", "quicksaveBackup")
+	info := backupInfo{CreatedAt: time.Now(), Reason: reason}
+	info.ID = info.CreatedAt.Format(backupIDLayout)
+	for i := 2; ; i++ {
+		if _, err := os.Stat(filepath.Join(dir, info.ID)); os.IsNotExist(err) {
+			break
+		}
+		info.ID = fmt.Sprintf("%s-%d", info.CreatedAt.Format(backupIDLayout), i)
+	}
 
-	if err := os.MkdirAll(backupDir, 0755); err != nil {
-		return fmt.Errorf("error creating backup folder: %w", err)
+	// Built under a temp name so a half written snapshot never shows up in the list
+	tmpDir := filepath.Join(dir, ".tmp-"+info.ID)
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+		return backupInfo{}, fmt.Errorf("error creating snapshot folder: %w", err)
 	}
+	defer os.RemoveAll(tmpDir)
 
-	// Folders to copy entirely
-	folders := []string{"screenshots", "coverArt"}
-	for _, folder := range folders {
-		src := filepath.Join(baseDir, folder)
-		dest := filepath.Join(backupDir, folder)
-		err := copyDir(src, dest)
+	dbPath := filepath.Join(tmpDir, backupDBFile)
+	_, err = readDB.Exec("VACUUM INTO ?", dbPath)
+	if err != nil {
+		return backupInfo{}, fmt.Errorf("error snapshotting database: %w", err)
+	}
+	protection, err := getBackupProtection()
+	if err != nil {
+		return backupInfo{}, err
+	}
+	if protection.ExcludeSecrets {
+		err = stripSecrets(dbPath)
+		if err != nil {
+			return backupInfo{}, fmt.Errorf("error removing secrets from snapshot: %w", err)
+		}
+		info.SecretsExcluded = true
+	}
+	if protection.Encrypt {
+		passphrase, err := getBackupPassphrase()
 		if err != nil {
-			return fmt.Errorf("error copying folder %s: %w", folder, err)
+			return backupInfo{}, err
 		}
+		err = encryptFile(dbPath, dbPath+encryptedExt, passphrase)
+		if err != nil {
+			return backupInfo{}, fmt.Errorf("error encrypting snapshot: %w", err)
+		}
+		os.Remove(dbPath)
+		dbPath += encryptedExt
+		info.Encrypted = true
+	}
+	stat, err := os.Stat(dbPath)
+	if err != nil {
+		return backupInfo{}, err
+	}
+	info.DBSize = stat.Size()
+	info.DBVersion, err = getDBVersion(readDB)
+	if err != nil {
+		return backupInfo{}, err
+	}
 
-		if err := syncDelete(src, dest); err != nil {
-			return fmt.Errorf("error syncing deletions for %s: %w", folder, err)
+	previousDir := ""
+	if len(previous) > 0 {
+		previousDir = filepath.Join(dir, previous[0].ID)
+	}
+	for _, root := range imageRoots {
+		count, err := linkOrCopyDir(root, filepath.Join(tmpDir, root), filepath.Join(previousDir, root), previousDir != "")
+		if err != nil {
+			return backupInfo{}, fmt.Errorf("error copying folder %s: %w", root, err)
 		}
+		info.ImageFiles += count
 	}
 
-	// Copy all DB files in baseDir
-	err = filepath.Walk(baseDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil || info.IsDir() {
-			return nil
+	meta, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return backupInfo{}, err
+	}
+	err = os.WriteFile(filepath.Join(tmpDir, backupMetaFile), meta, 0644)
+	if err != nil {
+		return backupInfo{}, fmt.Errorf("error writing backup metadata: %w", err)
+	}
+	err = os.Rename(tmpDir, filepath.Join(dir, info.ID))
+	if err != nil {
+		return backupInfo{}, fmt.Errorf("error finalizing snapshot: %w", err)
+	}
+	log.Printf("[Backup] snapshot %s created", info.ID)
+	return info, nil
+}
+
+// Newest first
+func listBackups() ([]backupInfo, error) {
+	dir, err := snapshotsDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []backupInfo{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading backups: %w", err)
+	}
+
+	backups := []backupInfo{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
 		}
-		if strings.HasSuffix(info.Name(), ".db") || strings.HasSuffix(info.Name(), ".sqlite") {
-			dest := filepath.Join(backupDir, info.Name())
-			return copyFile(path, dest)
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name(), backupMetaFile))
+		if err != nil {
+			continue
+		}
+		var info backupInfo
+		if err := json.Unmarshal(data, &info); err != nil || info.ID != entry.Name() {
+			continue
 		}
-		return nil
+		backups = append(backups, info)
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
 	})
+	return backups, nil
+}
+
+func getBackupRetention() (backupRetention, error) {
+	retention := backupRetention{KeepDaily: backupDefaultKeepDaily, KeepWeekly: backupDefaultKeepWeekly}
+	for key, target := range map[string]*int{backupKeepDailySetting: &retention.KeepDaily, backupKeepWeeklySetting: &retention.KeepWeekly} {
+		value, err := getSetting(key)
+		if err != nil {
+			return retention, err
+		}
+		if n, err := strconv.Atoi(value); err == nil {
+			*target = n
+		}
+	}
+	return retention, nil
+}
 
+func setBackupRetention(retention backupRetention) error {
+	if retention.KeepDaily < 0 || retention.KeepWeekly < 0 {
+		return fmt.Errorf("retention counts can't be negative")
+	}
+	err := setSetting(backupKeepDailySetting, strconv.Itoa(retention.KeepDaily))
 	if err != nil {
-		return fmt.Errorf("error copying DB files: %w", err)
+		return err
+	}
+	return setSetting(backupKeepWeeklySetting, strconv.Itoa(retention.KeepWeekly))
+}
+
+// Keeps the newest snapshot of each of the last KeepDaily days and KeepWeekly weeks that have one,
+// the newest snapshot overall is never removed
+func pruneSnapshots() error {
+	retention, err := getBackupRetention()
+	if err != nil {
+		return err
+	}
+
+	backupLock.Lock()
+	defer backupLock.Unlock()
+	backups, err := listBackups()
+	if err != nil || len(backups) == 0 {
+		return err
+	}
+
+	keep := map[string]bool{backups[0].ID: true}
+	days := make(map[string]bool)
+	weeks := make(map[string]bool)
+	for _, backup := range backups {
+		local := backup.CreatedAt.Local()
+		day := local.Format("2006-01-02")
+		if !days[day] && len(days) < retention.KeepDaily {
+			days[day] = true
+			keep[backup.ID] = true
+		}
+		year, week := local.ISOWeek()
+		weekKey := fmt.Sprintf("%d-%d", year, week)
+		if !weeks[weekKey] && len(weeks) < retention.KeepWeekly {
+			weeks[weekKey] = true
+			keep[backup.ID] = true
+		}
+	}
+
+	dir, err := snapshotsDir()
+	if err != nil {
+		return err
+	}
+	for _, backup := range backups {
+		if keep[backup.ID] {
+			continue
+		}
+		err := os.RemoveAll(filepath.Join(dir, backup.ID))
+		if err != nil {
+			return fmt.Errorf("error removing snapshot %s: %w", backup.ID, err)
+		}
+		log.Printf("[Backup] snapshot %s removed by retention", backup.ID)
 	}
 	return nil
 }
 
-func copyDir(src, dest string) error {
-	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+// Replaces the DB and image folders with a snapshot. A pre-restore snapshot is taken first so the
+// restore itself can be undone, older schema versions are migrated afterwards.
+func restoreBackup(id string, passphrase string) error {
+	dir, err := snapshotsDir()
+	if err != nil {
+		return err
+	}
+	backups, err := listBackups()
+	if err != nil {
+		return err
+	}
+	var target *backupInfo
+	for i := range backups {
+		if backups[i].ID == id {
+			target = &backups[i]
+		}
+	}
+	if target == nil {
+		return fmt.Errorf("backup %s not found", id)
+	}
+	currentVersion, err := getDBVersion(readDB)
+	if err != nil {
+		return err
+	}
+	if target.DBVersion > currentVersion {
+		return fmt.Errorf("backup %s is from a newer version of quicksave (db v%d)", id, target.DBVersion)
+	}
+
+	// Decrypted before anything changes, so a wrong passphrase fails the restore up front
+	snapshotDir := filepath.Join(dir, id)
+	dbPath, cleanup, err := snapshotDatabase(snapshotDir, *target, passphrase)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	_, err = createSnapshot("pre-restore")
+	if err != nil {
+		return fmt.Errorf("error taking pre-restore snapshot: %w", err)
+	}
+
+	backupLock.Lock()
+	defer backupLock.Unlock()
+	maintenanceMode.Store(true)
+	defer maintenanceMode.Store(false)
+	jobs.stopAll()
+
+	// A backup without credentials keeps the accounts of the live library
+	var liveSecrets map[string]liveTableRows
+	if target.SecretsExcluded {
+		liveSecrets, err = readLiveSecrets()
 		if err != nil {
 			return err
 		}
+	}
+
+	err = restoreSnapshotFiles(dbPath, snapshotDir, imageRoots)
+	if err != nil {
+		return err
+	}
+
+	// Migrations take the write lock themselves
+	err = migrateDatabase(readDB, txWrite, true)
+	if err != nil {
+		return fmt.Errorf("error migrating restored database: %w", err)
+	}
+	if liveSecrets != nil {
+		err = carryLiveSecrets(liveSecrets)
+		if err != nil {
+			return fmt.Errorf("error keeping live credentials: %w", err)
+		}
+	}
+	err = interruptStaleJobs()
+	if err != nil {
+		log.Printf("[Restore] ERROR : %v", err)
+	}
+	_, _, err = rescanScreenshots()
+	if err != nil {
+		log.Printf("[Restore] ERROR rescanning screenshots: %v", err)
+	}
+	log.Printf("[Restore] restored snapshot %s", id)
+	return nil
+}
 
+// Plain snapshot DBs are used in place, encrypted ones are decrypted to a temp file that cleanup removes
+func snapshotDatabase(snapshotDir string, info backupInfo, passphrase string) (string, func(), error) {
+	if !info.Encrypted {
+		return filepath.Join(snapshotDir, backupDBFile), func() {}, nil
+	}
+	var err error
+	if passphrase == "" {
+		passphrase, err = getBackupPassphrase()
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	decrypted, err := os.CreateTemp(filepath.Dir(snapshotDir), ".restore-*.db")
+	if err != nil {
+		return "", nil, err
+	}
+	decrypted.Close()
+	cleanup := func() { os.Remove(decrypted.Name()) }
+	err = decryptFile(filepath.Join(snapshotDir, backupDBFile+encryptedExt), decrypted.Name(), passphrase)
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("error decrypting backup %s: %w", info.ID, err)
+	}
+	return decrypted.Name(), cleanup, nil
+}
+
+// Holds the write lock so nothing writes to the DB or images while they are swapped.
+// Only the given image roots are replaced, the others keep their live files.
+func restoreSnapshotFiles(dbPath string, imagesDir string, roots []string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	err := restoreDatabase(dbPath)
+	if err != nil {
+		return err
+	}
+
+	// Live folders are moved aside first and put back if copying the snapshot fails
+	var swapped []string
+	rollback := func() {
+		for _, root := range swapped {
+			os.RemoveAll(root)
+			os.Rename(root+".restore-old", root)
+		}
+	}
+	for _, root := range roots {
+		os.RemoveAll(root + ".restore-old")
+		err := os.Rename(root, root+".restore-old")
+		if err != nil && !os.IsNotExist(err) {
+			rollback()
+			return fmt.Errorf("error moving %s aside: %w", root, err)
+		}
+		swapped = append(swapped, root)
+		_, err = linkOrCopyDir(filepath.Join(imagesDir, root), root, "", false)
+		if err == nil {
+			err = os.MkdirAll(root, 0755)
+		}
+		if err != nil {
+			rollback()
+			return fmt.Errorf("error restoring %s: %w", root, err)
+		}
+	}
+	for _, root := range roots {
+		os.RemoveAll(root + ".restore-old")
+	}
+	// Variants are rebuilt on demand from the restored originals
+	os.RemoveAll(imageVariantsDir)
+	return nil
+}
+
+// Copies the snapshot into the live database through SQLite's backup API, open connections
+// see the restored data on their next read
+func restoreDatabase(snapshotDB string) error {
+	if _, err := os.Stat(snapshotDB); err != nil {
+		return fmt.Errorf("snapshot database missing: %w", err)
+	}
+	source, err := matchPageSize(snapshotDB)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(source)
+
+	conn, err := writeDB.Conn(context.Background())
+	if err != nil {
+		return fmt.Errorf("error getting db connection: %w", err)
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		restorer, ok := driverConn.(interface {
+			NewRestore(srcUri string) (*sqlite.Backup, error)
+		})
+		if !ok {
+			return fmt.Errorf("sqlite driver does not support restore")
+		}
+		restore, err := restorer.NewRestore("file:" + filepath.ToSlash(source) + "?mode=ro")
+		if err != nil {
+			return fmt.Errorf("error starting restore: %w", err)
+		}
+		for {
+			more, err := restore.Step(-1)
+			if err != nil {
+				restore.Finish()
+				return fmt.Errorf("error restoring database: %w", err)
+			}
+			if !more {
+				break
+			}
+		}
+		return restore.Finish()
+	})
+}
+
+// A WAL database only accepts a restore with its own page size, so the snapshot is rewritten
+// to a temp copy with the live page size first
+func matchPageSize(snapshotDB string) (string, error) {
+	var pageSize int
+	err := readDB.QueryRow("PRAGMA page_size").Scan(&pageSize)
+	if err != nil {
+		return "", fmt.Errorf("error querying page size: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(snapshotDB), ".restore-*.db")
+	if err != nil {
+		return "", err
+	}
+	tmp.Close()
+	err = copyFile(snapshotDB, tmp.Name())
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+
+	db, err := sql.Open("sqlite", tmp.Name())
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec(fmt.Sprintf("PRAGMA page_size = %d; VACUUM;", pageSize))
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("error preparing snapshot database: %w", err)
+	}
+	return tmp.Name(), nil
+}
+
+// Mirrors src into dest. With link set, files unchanged since the previous copy are hard linked
+// from there. A missing src is an empty folder. Returns the number of files.
+func linkOrCopyDir(src string, dest string, previous string, link bool) (int, error) {
+	count := 0
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == src {
+				return filepath.SkipDir
+			}
+			return err
+		}
 		relPath, err := filepath.Rel(src, path)
 		if err != nil {
 			return err
 		}
-
 		destPath := filepath.Join(dest, relPath)
-
 		if info.IsDir() {
-			return os.MkdirAll(destPath, info.Mode())
+			return os.MkdirAll(destPath, 0755)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		count++
+		if link {
+			prevPath := filepath.Join(previous, relPath)
+			prevInfo, err := os.Stat(prevPath)
+			if err == nil && prevInfo.Size() == info.Size() && prevInfo.ModTime().Equal(info.ModTime()) {
+				if os.Link(prevPath, destPath) == nil {
+					return nil
+				}
+			}
 		}
-
 		return copyFile(path, destPath)
 	})
+	return count, err
 }
 
 func copyFile(src, dest string) error {
@@ -84,15 +574,6 @@ func copyFile(src, dest string) error {
 		return err
 	}
 
-	// Check if destination file exists
-	if destInfo, err := os.Stat(dest); err == nil {
-		// If same size and mod time, skip copying
-		if destInfo.Size() == srcInfo.Size() &&
-			destInfo.ModTime().Equal(srcInfo.ModTime()) {
-			return nil // skip copy
-		}
-	}
-
 	srcFile, err := os.Open(src)
 	if err != nil {
 		return err
@@ -123,42 +604,11 @@ func copyFile(src, dest string) error {
 	return os.Chtimes(dest, srcInfo.ModTime(), srcInfo.ModTime())
 }
 
-func syncDelete(srcDir, destDir string) error {
-	var paths []string
-
-	// Collect all paths first
-	err := filepath.Walk(destDir, func(path string, info fs.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		paths = append(paths, path)
-		return nil
-	})
+func getDBVersion(db *sql.DB) (int, error) {
+	var version int
+	err := db.QueryRow("SELECT version FROM DBVersion").Scan(&version)
 	if err != nil {
-		return err
+		return 0, fmt.Errorf("error querying db version: %w", err)
 	}
-
-	// Delete in reverse order (files first, then dirs)
-	for i := len(paths) - 1; i >= 0; i-- {
-		path := paths[i]
-		relPath, err := filepath.Rel(destDir, path)
-		if err != nil {
-			return err
-		}
-		srcPath := filepath.Join(srcDir, relPath)
-
-		if _, err := os.Stat(srcPath); os.IsNotExist(err) {
-			info, _ := os.Stat(path)
-			if info != nil && info.IsDir() {
-				if rmErr := os.RemoveAll(path); rmErr != nil {
-					return rmErr
-				}
-			} else {
-				if rmErr := os.Remove(path); rmErr != nil {
-					return rmErr
-				}
-			}
-		}
-	}
-	return nil
+	return version, nil
 }
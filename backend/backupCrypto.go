@@ -0,0 +1,414 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Settings keys for backup protection, the passphrase is kept in the secret store
+const (
+	backupEncryptSetting        = "BackupEncrypt"
+	backupExcludeSecretsSetting = "BackupExcludeSecrets"
+)
+
+// Encrypted files start with encryptedMagic, the scrypt salt and cost, and a nonce prefix. The data
+// follows as AES-256-GCM sealed chunks, the last chunk is marked through its additional data so a
+// truncated file fails to decrypt.
+const (
+	encryptedMagic     = "QSENC1\n"
+	encryptedExt       = ".enc"
+	encryptChunkSize   = 64 * 1024
+	encryptSaltSize    = 16
+	encryptNonceSize   = 8
+	encryptScryptLogN  = 15
+	encryptScryptR     = 8
+	encryptScryptP     = 1
+	minPassphraseChars = 8
+)
+
+// Account tables that "exclude secrets" empties in backups and exports. Credentials themselves
+// live in the secret store, what is left here identifies the accounts and paired devices.
+var secretTables = []string{"SteamCreds", "PlayStationNpsso", "RemoteDevices"}
+
+func deriveEncryptionKey(passphrase string, salt []byte, logN byte) (cipher.AEAD, error) {
+	if logN < 10 || logN > 20 {
+		return nil, fmt.Errorf("unsupported key derivation cost")
+	}
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<logN, encryptScryptR, encryptScryptP, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+type encryptWriter struct {
+	w           io.Writer
+	aead        cipher.AEAD
+	noncePrefix []byte
+	counter     uint32
+	buf         []byte
+}
+
+func checkPassphrase(passphrase string) error {
+	if len(passphrase) < minPassphraseChars {
+		return fmt.Errorf("passphrase must be at least %d characters", minPassphraseChars)
+	}
+	return nil
+}
+
+// Data written is only complete once Close seals the last chunk
+func newEncryptWriter(w io.Writer, passphrase string) (io.WriteCloser, error) {
+	if err := checkPassphrase(passphrase); err != nil {
+		return nil, err
+	}
+	header := make([]byte, encryptSaltSize+encryptNonceSize)
+	_, err := rand.Read(header)
+	if err != nil {
+		return nil, err
+	}
+	salt, noncePrefix := header[:encryptSaltSize], header[encryptSaltSize:]
+	aead, err := deriveEncryptionKey(passphrase, salt, encryptScryptLogN)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = io.WriteString(w, encryptedMagic)
+	if err == nil {
+		_, err = w.Write(append(append([]byte{}, salt...), encryptScryptLogN))
+	}
+	if err == nil {
+		_, err = w.Write(noncePrefix)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &encryptWriter{w: w, aead: aead, noncePrefix: noncePrefix, buf: make([]byte, 0, encryptChunkSize)}, nil
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		// A full chunk is only sealed once more data arrives, the last one is sealed by Close
+		if len(e.buf) == encryptChunkSize {
+			err := e.seal(false)
+			if err != nil {
+				return written, err
+			}
+		}
+		n := min(len(p), encryptChunkSize-len(e.buf))
+		e.buf = append(e.buf, p[:n]...)
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (e *encryptWriter) Close() error {
+	return e.seal(true)
+}
+
+func (e *encryptWriter) seal(last bool) error {
+	sealed := e.aead.Seal(nil, chunkNonce(e.noncePrefix, e.counter), e.buf, chunkAD(last))
+	e.counter++
+	e.buf = e.buf[:0]
+	_, err := e.w.Write(sealed)
+	return err
+}
+
+func chunkNonce(prefix []byte, counter uint32) []byte {
+	nonce := make([]byte, 12)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[encryptNonceSize:], counter)
+	return nonce
+}
+
+func chunkAD(last bool) []byte {
+	if last {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+type decryptReader struct {
+	r           *bufio.Reader
+	aead        cipher.AEAD
+	noncePrefix []byte
+	counter     uint32
+	plain       []byte
+	done        bool
+}
+
+func newDecryptReader(r io.Reader, passphrase string) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, len(encryptedMagic)+encryptSaltSize+1+encryptNonceSize)
+	_, err := io.ReadFull(br, header)
+	if err != nil || string(header[:len(encryptedMagic)]) != encryptedMagic {
+		return nil, fmt.Errorf("not an encrypted quicksave file")
+	}
+	header = header[len(encryptedMagic):]
+	aead, err := deriveEncryptionKey(passphrase, header[:encryptSaltSize], header[encryptSaltSize])
+	if err != nil {
+		return nil, err
+	}
+	return &decryptReader{r: br, aead: aead, noncePrefix: header[encryptSaltSize+1:]}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		err := d.open()
+		if err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+func (d *decryptReader) open() error {
+	sealed := make([]byte, encryptChunkSize+d.aead.Overhead())
+	n, err := io.ReadFull(d.r, sealed)
+	last := false
+	switch {
+	case err == io.ErrUnexpectedEOF:
+		last = true
+	case err == io.EOF:
+		return fmt.Errorf("encrypted file is truncated")
+	case err != nil:
+		return err
+	default:
+		_, err := d.r.Peek(1)
+		last = err == io.EOF
+	}
+	plain, err := d.aead.Open(nil, chunkNonce(d.noncePrefix, d.counter), sealed[:n], chunkAD(last))
+	if err != nil {
+		if d.counter == 0 {
+			return fmt.Errorf("wrong passphrase or damaged file")
+		}
+		return fmt.Errorf("encrypted file is damaged")
+	}
+	d.counter++
+	d.plain = plain
+	d.done = last
+	return nil
+}
+
+func isEncryptedFile(filePath string) bool {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	magic := make([]byte, len(encryptedMagic))
+	_, err = io.ReadFull(file, magic)
+	return err == nil && bytes.Equal(magic, []byte(encryptedMagic))
+}
+
+func encryptFile(src string, dest string, passphrase string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	return writeFileAtomic(dest, func(w io.Writer) error {
+		return writeMaybeEncrypted(w, passphrase, func(w io.Writer) error {
+			_, err := io.Copy(w, in)
+			return err
+		})
+	})
+}
+
+func decryptFile(src string, dest string, passphrase string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	dec, err := newDecryptReader(in, passphrase)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(dest, func(w io.Writer) error {
+		_, err := io.Copy(w, dec)
+		return err
+	})
+}
+
+// Writes through an encrypting writer when a passphrase is given
+func writeMaybeEncrypted(w io.Writer, passphrase string, write func(w io.Writer) error) error {
+	if passphrase == "" {
+		return write(w)
+	}
+	enc, err := newEncryptWriter(w, passphrase)
+	if err != nil {
+		return err
+	}
+	err = write(enc)
+	if err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// The passphrase is stored so scheduled backups can run, it is never returned
+type backupProtection struct {
+	Encrypt        bool   `json:"encrypt"`
+	ExcludeSecrets bool   `json:"excludeSecrets"`
+	HasPassphrase  bool   `json:"hasPassphrase"`
+	Passphrase     string `json:"passphrase,omitempty"`
+}
+
+func getBackupProtection() (backupProtection, error) {
+	var protection backupProtection
+	for key, target := range map[string]*bool{backupEncryptSetting: &protection.Encrypt, backupExcludeSecretsSetting: &protection.ExcludeSecrets} {
+		value, err := getSetting(key)
+		if err != nil {
+			return protection, err
+		}
+		*target, _ = strconv.ParseBool(value)
+	}
+	var err error
+	protection.HasPassphrase, err = secretConfigured(secretBackupPassphrase)
+	return protection, err
+}
+
+func getBackupPassphrase() (string, error) {
+	return getSecret(secretBackupPassphrase)
+}
+
+// An empty passphrase keeps the stored one
+func setBackupProtection(protection backupProtection) error {
+	if protection.Passphrase != "" {
+		if err := checkPassphrase(protection.Passphrase); err != nil {
+			return err
+		}
+	}
+	if protection.Encrypt && protection.Passphrase == "" {
+		stored, err := getBackupPassphrase()
+		if err != nil {
+			return err
+		}
+		if stored == "" {
+			return fmt.Errorf("a passphrase is required to encrypt backups")
+		}
+	}
+	if protection.Passphrase != "" {
+		err := setSecret(secretBackupPassphrase, protection.Passphrase)
+		if err != nil {
+			return err
+		}
+	}
+	err := setSetting(backupEncryptSetting, strconv.FormatBool(protection.Encrypt))
+	if err != nil {
+		return err
+	}
+	return setSetting(backupExcludeSecretsSetting, strconv.FormatBool(protection.ExcludeSecrets))
+}
+
+// Empties the account tables of a DB copy before it leaves the library
+func stripSecrets(dbPath string) error {
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	err = txOn(db, func(tx *sql.Tx) error {
+		for _, table := range secretTables {
+			_, err := tx.Exec("DELETE FROM " + table)
+			if err != nil {
+				return fmt.Errorf("error clearing %s: %w", table, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// Deleted rows would otherwise linger in free pages
+	_, err = db.Exec("VACUUM")
+	return err
+}
+
+// Live rows of an account table, kept while a DB without them is swapped in
+type liveTableRows struct {
+	columns []string
+	values  [][]any
+}
+
+func readLiveSecrets() (map[string]liveTableRows, error) {
+	live := make(map[string]liveTableRows)
+	for _, table := range secretTables {
+		rows, err := readDB.Query("SELECT * FROM " + table)
+		if err != nil {
+			return nil, fmt.Errorf("query error %s: %w", table, err)
+		}
+		columns, err := rows.Columns()
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		saved := liveTableRows{columns: columns}
+		for rows.Next() {
+			values := make([]any, len(columns))
+			pointers := make([]any, len(columns))
+			for i := range values {
+				pointers[i] = &values[i]
+			}
+			if err := rows.Scan(pointers...); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("scan error %s: %w", table, err)
+			}
+			saved.values = append(saved.values, values)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+		live[table] = saved
+	}
+	return live, nil
+}
+
+// Puts the live account rows back into a restored DB whose copies were excluded. Runs after the
+// restored DB is migrated so its tables match the live columns.
+func carryLiveSecrets(live map[string]liveTableRows) error {
+	return txWrite(func(tx *sql.Tx) error {
+		for table, saved := range live {
+			_, err := tx.Exec("DELETE FROM " + table)
+			if err != nil {
+				return fmt.Errorf("error clearing %s: %w", table, err)
+			}
+			if len(saved.values) == 0 {
+				continue
+			}
+			insert := fmt.Sprintf("INSERT INTO %s (%s) VALUES (?%s)", table, strings.Join(saved.columns, ", "), strings.Repeat(", ?", len(saved.columns)-1))
+			err = txBatchUpdate(tx, insert, saved.values)
+			if err != nil {
+				return fmt.Errorf("error copying %s: %w", table, err)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+)
+
+const testPassphrase = "correct horse battery"
+
+func encryptBytes(t *testing.T, plain []byte) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	enc, err := newEncryptWriter(&out, testPassphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Odd sized writes so chunks don't line up with the calls
+	for rest := plain; len(rest) > 0; {
+		n := min(len(rest), 10007)
+		if _, err := enc.Write(rest[:n]); err != nil {
+			t.Fatal(err)
+		}
+		rest = rest[n:]
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func decryptBytes(data []byte, passphrase string) ([]byte, error) {
+	dec, err := newDecryptReader(bytes.NewReader(data), passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(dec)
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	for _, size := range []int{0, 1, encryptChunkSize - 1, encryptChunkSize, encryptChunkSize + 1, 3*encryptChunkSize + 5} {
+		plain := make([]byte, size)
+		rand.Read(plain)
+		encrypted := encryptBytes(t, plain)
+		if bytes.Contains(encrypted, plain) && size > 0 {
+			t.Errorf("size %d: plaintext visible in the encrypted output", size)
+		}
+		decrypted, err := decryptBytes(encrypted, testPassphrase)
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if !bytes.Equal(decrypted, plain) {
+			t.Errorf("size %d: round trip changed the data", size)
+		}
+	}
+}
+
+func TestDecryptRejectsTruncatedFiles(t *testing.T) {
+	plain := make([]byte, 2*encryptChunkSize+100)
+	rand.Read(plain)
+	encrypted := encryptBytes(t, plain)
+	header := len(encryptedMagic) + encryptSaltSize + 1 + encryptNonceSize
+	fullChunk := encryptChunkSize + 16
+
+	cuts := map[string]int{
+		"last chunk dropped":      header + 2*fullChunk,
+		"two chunks dropped":      header + fullChunk,
+		"last chunk cut short":    len(encrypted) - 10,
+		"middle of first chunk":   header + 100,
+		"only the header is left": header,
+	}
+	for name, length := range cuts {
+		_, err := decryptBytes(encrypted[:length], testPassphrase)
+		if err == nil {
+			t.Errorf("%s: truncated file decrypted without an error", name)
+		}
+	}
+}
+
+func TestDecryptWrongPassphrase(t *testing.T) {
+	encrypted := encryptBytes(t, []byte("library database"))
+	_, err := decryptBytes(encrypted, "not the passphrase")
+	if err == nil {
+		t.Fatal("decrypted with the wrong passphrase")
+	}
+}
+
+func TestDecryptRejectsPlainFiles(t *testing.T) {
+	_, err := newDecryptReader(bytes.NewReader([]byte("SQLite format 3\x00 and more bytes")), testPassphrase)
+	if err == nil {
+		t.Fatal("plain file accepted as encrypted")
+	}
+}
+
+func TestEncryptNeedsLongPassphrase(t *testing.T) {
+	_, err := newEncryptWriter(io.Discard, "short")
+	if err == nil {
+		t.Fatal("short passphrase accepted")
+	}
+}
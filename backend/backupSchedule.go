@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Settings keys for automatic backups
+const (
+	backupDestinationSetting     = "BackupDestination"
+	backupIntervalSetting        = "BackupIntervalHours"
+	backupOnExitSetting          = "BackupOnExit"
+	backupBeforeUpdateSetting    = "BackupBeforeUpdate"
+	backupAfterImportSetting     = "BackupAfterImport"
+	backupImportThresholdSetting = "BackupImportThreshold"
+)
+
+const (
+	backupDefaultIntervalHours   = 24
+	backupDefaultImportThreshold = 10
+	backupSchedulerTick          = 10 * time.Minute
+)
+
+// An empty Destination keeps snapshots next to the install, IntervalHours 0 turns the timer off
+type backupSchedule struct {
+	Destination     string `json:"destination"`
+	IntervalHours   int    `json:"intervalHours"`
+	OnExit          bool   `json:"onExit"`
+	BeforeUpdate    bool   `json:"beforeUpdate"`
+	AfterImport     bool   `json:"afterImport"`
+	ImportThreshold int    `json:"importThreshold"`
+}
+
+func getBackupSchedule() (backupSchedule, error) {
+	schedule := backupSchedule{
+		IntervalHours:   backupDefaultIntervalHours,
+		OnExit:          true,
+		BeforeUpdate:    true,
+		AfterImport:     true,
+		ImportThreshold: backupDefaultImportThreshold,
+	}
+	var err error
+	schedule.Destination, err = getSetting(backupDestinationSetting)
+	if err != nil {
+		return schedule, err
+	}
+	for key, target := range map[string]*int{backupIntervalSetting: &schedule.IntervalHours, backupImportThresholdSetting: &schedule.ImportThreshold} {
+		value, err := getSetting(key)
+		if err != nil {
+			return schedule, err
+		}
+		if n, err := strconv.Atoi(value); err == nil {
+			*target = n
+		}
+	}
+	for key, target := range map[string]*bool{backupOnExitSetting: &schedule.OnExit, backupBeforeUpdateSetting: &schedule.BeforeUpdate, backupAfterImportSetting: &schedule.AfterImport} {
+		value, err := getSetting(key)
+		if err != nil {
+			return schedule, err
+		}
+		if b, err := strconv.ParseBool(value); err == nil {
+			*target = b
+		}
+	}
+	return schedule, nil
+}
+
+// The destination must be an absolute, writable folder, it is created when missing
+func setBackupSchedule(schedule backupSchedule) error {
+	if schedule.IntervalHours < 0 || schedule.ImportThreshold < 1 {
+		return fmt.Errorf("interval can't be negative and the import threshold must be at least 1")
+	}
+	if schedule.Destination != "" {
+		if !filepath.IsAbs(schedule.Destination) {
+			return fmt.Errorf("backup destination must be an absolute path")
+		}
+		err := os.MkdirAll(schedule.Destination, 0755)
+		if err != nil {
+			return fmt.Errorf("error creating backup destination: %w", err)
+		}
+		probe, err := os.CreateTemp(schedule.Destination, ".write-test-*")
+		if err != nil {
+			return fmt.Errorf("backup destination is not writable: %w", err)
+		}
+		probe.Close()
+		os.Remove(probe.Name())
+	}
+
+	values := map[string]string{
+		backupDestinationSetting:     schedule.Destination,
+		backupIntervalSetting:        strconv.Itoa(schedule.IntervalHours),
+		backupOnExitSetting:          strconv.FormatBool(schedule.OnExit),
+		backupBeforeUpdateSetting:    strconv.FormatBool(schedule.BeforeUpdate),
+		backupAfterImportSetting:     strconv.FormatBool(schedule.AfterImport),
+		backupImportThresholdSetting: strconv.Itoa(schedule.ImportThreshold),
+	}
+	for key, value := range values {
+		err := setSetting(key, value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Takes a snapshot, applies retention and reports the outcome over SSE
+func runBackup(reason string) (backupInfo, error) {
+	info, err := createSnapshot(reason)
+	if err == nil {
+		err = pruneSnapshots()
+	}
+	if err != nil {
+		log.Printf("[Backup] ERROR (%s): %v", reason, err)
+		publishEvent(eventBackupFailed, failedEvent{Source: reason, Error: err.Error()})
+		return info, err
+	}
+	publishEvent(eventBackupDone, backupEvent{ID: info.ID, Reason: reason})
+	return info, nil
+}
+
+// Checks on a short tick rather than sleeping a whole interval, so suspend and restarts don't skip backups
+func startBackupScheduler() {
+	go func() {
+		ticker := time.NewTicker(backupSchedulerTick)
+		defer ticker.Stop()
+		for {
+			runDueBackup()
+			<-ticker.C
+		}
+	}()
+}
+
+func runDueBackup() {
+	schedule, err := getBackupSchedule()
+	if err != nil {
+		log.Printf("[Backup] ERROR reading schedule: %v", err)
+		return
+	}
+	if schedule.IntervalHours == 0 {
+		return
+	}
+	backups, err := listBackups()
+	if err != nil {
+		log.Printf("[Backup] ERROR listing backups: %v", err)
+		return
+	}
+	if len(backups) > 0 && time.Since(backups[0].CreatedAt) < time.Duration(schedule.IntervalHours)*time.Hour {
+		return
+	}
+	runBackup("scheduled")
+}
+
+func backupOnExit() {
+	schedule, err := getBackupSchedule()
+	if err != nil || !schedule.OnExit {
+		return
+	}
+	runBackup("exit")
+}
+
+// Imports that add at least ImportThreshold items are followed by a snapshot
+func backupAfterImport(added int) {
+	schedule, err := getBackupSchedule()
+	if err != nil || !schedule.AfterImport || added < schedule.ImportThreshold {
+		return
+	}
+	runBackup("import")
+}
+
+func countGames() (int, error) {
+	var count int
+	err := readDB.QueryRow("SELECT COUNT(*) FROM GameMetaData").Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("count error GameMetaData: %w", err)
+	}
+	return count, nil
+}
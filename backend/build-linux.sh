@@ -12,7 +12,21 @@ export GOOS=linux
 export GOARCH=amd64
 export CGO_ENABLED=0
 
+# Embed the web frontend when it has been built (vite build)
+rm -rf web/dist
+if [ -d "../dist" ]; then
+  cp -r ../dist web/dist
+else
+  echo "No frontend build in ../dist, the web frontend will not be available"
+fi
+
+# Every route needs an OpenAPI description
+go test -run TestOpenAPICoversAllRoutes . || exit 1
+
 # Build
 go build -ldflags="-s -w -X 'main.clientID=${IGDB_API_KEY}' -X 'main.clientSecret=${IGDB_SECRET_KEY}'" -o "$OUTPUT"
 
-echo "Linux static build complete: $OUTPUT"
+# Command line client
+go build -ldflags="-s -w" -o cli/quicksave ./cli
+
+echo "Linux static build complete: $OUTPUT, cli/quicksave"
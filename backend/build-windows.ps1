@@ -15,7 +15,22 @@ $OUTPUT = "quicksaveService.exe"
 $clientID = [System.Environment]::GetEnvironmentVariable("IGDB_API_KEY")
 $clientSecret = [System.Environment]::GetEnvironmentVariable("IGDB_SECRET_KEY")
 
+# Embed the web frontend when it has been built (vite build)
+if (Test-Path web/dist) { Remove-Item -Recurse -Force web/dist }
+if (Test-Path ../dist) {
+    Copy-Item -Recurse ../dist web/dist
+} else {
+    Write-Host "No frontend build in ../dist, the web frontend will not be available"
+}
+
+# Every route needs an OpenAPI description
+go test -run TestOpenAPICoversAllRoutes .
+if ($LASTEXITCODE -ne 0) { exit 1 }
+
 # Build with embedded API keys
 go build -ldflags "-X 'main.clientID=$clientID' -X 'main.clientSecret=$clientSecret'" -o $OUTPUT
 
-Write-Host "✅ Build complete: $OUTPUT"
\ No newline at end of file
+# Command line client
+go build -o cli/quicksave.exe ./cli
+
+Write-Host "✅ Build complete: $OUTPUT, cli/quicksave.exe"
\ No newline at end of file
@@ -0,0 +1,105 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/BurntSushi/xgb"
+	"github.com/BurntSushi/xgb/xinerama"
+	"github.com/BurntSushi/xgb/xproto"
+)
+
+// Monitor bounds in root window coordinates, a single screen when Xinerama is unavailable
+func monitorRects() ([]image.Rectangle, error) {
+	c, err := xgb.NewConn()
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to X server: %w", err)
+	}
+	defer c.Close()
+
+	screen := xproto.Setup(c).DefaultScreen(c)
+	full := image.Rect(0, 0, int(screen.WidthInPixels), int(screen.HeightInPixels))
+
+	if err := xinerama.Init(c); err != nil {
+		return []image.Rectangle{full}, nil
+	}
+	reply, err := xinerama.QueryScreens(c).Reply()
+	if err != nil || len(reply.ScreenInfo) == 0 {
+		return []image.Rectangle{full}, nil
+	}
+	var rects []image.Rectangle
+	for _, info := range reply.ScreenInfo {
+		rects = append(rects, image.Rect(int(info.XOrg), int(info.YOrg),
+			int(info.XOrg)+int(info.Width), int(info.YOrg)+int(info.Height)))
+	}
+	return rects, nil
+}
+
+// Bounds of the game's window, found through _NET_WM_PID or else the focused window
+func gameWindowRect(pid int) (image.Rectangle, error) {
+	c, err := xgb.NewConn()
+	if err != nil {
+		return image.Rectangle{}, fmt.Errorf("error connecting to X server: %w", err)
+	}
+	defer c.Close()
+
+	root := xproto.Setup(c).DefaultScreen(c).Root
+
+	var window xproto.Window
+	if pid != 0 {
+		clients, err := windowListProperty(c, root, "_NET_CLIENT_LIST")
+		if err == nil {
+			for _, client := range clients {
+				windowPIDs, err := windowListProperty(c, client, "_NET_WM_PID")
+				if err == nil && len(windowPIDs) == 1 && int(windowPIDs[0]) == pid {
+					window = client
+					break
+				}
+			}
+		}
+	}
+	if window == 0 {
+		active, err := windowListProperty(c, root, "_NET_ACTIVE_WINDOW")
+		if err != nil || len(active) == 0 || active[0] == 0 {
+			return image.Rectangle{}, fmt.Errorf("no game window found")
+		}
+		window = active[0]
+	}
+
+	geometry, err := xproto.GetGeometry(c, xproto.Drawable(window)).Reply()
+	if err != nil {
+		return image.Rectangle{}, fmt.Errorf("error getting window geometry: %w", err)
+	}
+	origin, err := xproto.TranslateCoordinates(c, window, root, 0, 0).Reply()
+	if err != nil {
+		return image.Rectangle{}, fmt.Errorf("error translating window coordinates: %w", err)
+	}
+	x, y := int(origin.DstX), int(origin.DstY)
+	return image.Rect(x, y, x+int(geometry.Width), y+int(geometry.Height)), nil
+}
+
+// Reads a 32 bit list property such as a window list or a PID
+func windowListProperty(c *xgb.Conn, window xproto.Window, name string) ([]xproto.Window, error) {
+	atom, err := xproto.InternAtom(c, true, uint16(len(name)), name).Reply()
+	if err != nil {
+		return nil, err
+	}
+	if atom.Atom == xproto.AtomNone {
+		return nil, fmt.Errorf("atom %s not supported", name)
+	}
+	reply, err := xproto.GetProperty(c, false, window, atom.Atom, xproto.GetPropertyTypeAny, 0, 1024).Reply()
+	if err != nil {
+		return nil, err
+	}
+	if reply.Format != 32 {
+		return nil, fmt.Errorf("property %s not set", name)
+	}
+	values := make([]xproto.Window, 0, len(reply.Value)/4)
+	for i := 0; i+4 <= len(reply.Value); i += 4 {
+		values = append(values, xproto.Window(xgb.Get32(reply.Value[i:])))
+	}
+	return values, nil
+}
@@ -0,0 +1,24 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/vova616/screenshot"
+)
+
+// Only the primary screen can be captured outside X11
+func monitorRects() ([]image.Rectangle, error) {
+	rect, err := screenshot.ScreenRect()
+	if err != nil {
+		return nil, err
+	}
+	return []image.Rectangle{rect}, nil
+}
+
+func gameWindowRect(_ int) (image.Rectangle, error) {
+	return image.Rectangle{}, fmt.Errorf("window capture is only supported on X11")
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const (
+	defaultBackendURL = "http://localhost:50001"
+	apiTokenFile      = "api.token"
+)
+
+// Talks to a running quicksave backend
+type client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+func newClient(baseURL string, token string) (*client, error) {
+	if token == "" {
+		var err error
+		token, err = findToken()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		token:   token,
+		http:    &http.Client{},
+	}, nil
+}
+
+// The backend writes its token to api.token in its data folder. That is QUICKSAVE_DATA_DIR when set,
+// the backend folder the CLI is installed in, or the XDG data folder for new Linux installs.
+func findToken() (string, error) {
+	var candidates []string
+	if dir := os.Getenv("QUICKSAVE_DATA_DIR"); dir != "" {
+		candidates = append(candidates, filepath.Join(dir, apiTokenFile))
+	}
+	if exePath, err := os.Executable(); err == nil {
+		dir := filepath.Dir(exePath)
+		candidates = append(candidates, filepath.Join(dir, apiTokenFile), filepath.Join(dir, "..", apiTokenFile))
+	}
+	if runtime.GOOS == "linux" {
+		dataHome := os.Getenv("XDG_DATA_HOME")
+		if home, err := os.UserHomeDir(); dataHome == "" && err == nil {
+			dataHome = filepath.Join(home, ".local", "share")
+		}
+		if dataHome != "" {
+			candidates = append(candidates, filepath.Join(dataHome, "quicksave", apiTokenFile))
+		}
+	}
+	candidates = append(candidates, apiTokenFile)
+	for _, candidate := range candidates {
+		data, err := os.ReadFile(candidate)
+		if err == nil && strings.TrimSpace(string(data)) != "" {
+			return strings.TrimSpace(string(data)), nil
+		}
+	}
+	return "", fmt.Errorf("no API token found, pass --token or set QUICKSAVE_API_TOKEN")
+}
+
+func (c *client) newRequest(method string, path string, query url.Values, body any) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, nil
+}
+
+// Sends a JSON request and decodes the JSON response into out, returning the raw body for --json
+func (c *client) call(method string, path string, query url.Values, body any, out any) (json.RawMessage, error) {
+	req, err := c.newRequest(method, path, query, body)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("quicksave backend is not reachable at %s: %w", c.baseURL, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		return nil, responseError(resp.StatusCode, data)
+	}
+	if out != nil && len(data) > 0 {
+		err = json.Unmarshal(data, out)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected response from %s: %w", path, err)
+		}
+	}
+	return data, nil
+}
+
+// Understands both the /api/v1 error envelope and the {"error", "details"} of the older routes
+func responseError(status int, data []byte) error {
+	var v1 struct {
+		Error struct {
+			Message string `json:"message"`
+			Details string `json:"details"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(data, &v1) == nil && v1.Error.Message != "" {
+		return joinError(status, v1.Error.Message, v1.Error.Details)
+	}
+	var legacy struct {
+		Error   string `json:"error"`
+		Details string `json:"details"`
+	}
+	if json.Unmarshal(data, &legacy) == nil && legacy.Error != "" {
+		return joinError(status, legacy.Error, legacy.Details)
+	}
+	return fmt.Errorf("%s: %s", http.StatusText(status), strings.TrimSpace(string(data)))
+}
+
+func joinError(status int, message string, details string) error {
+	if details != "" {
+		return fmt.Errorf("%s: %s (%d)", message, details, status)
+	}
+	return fmt.Errorf("%s (%d)", message, status)
+}
+
+func (c *client) download(path string, dest string) error {
+	req, err := c.newRequest(http.MethodGet, path, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		data, _ := io.ReadAll(resp.Body)
+		return responseError(resp.StatusCode, data)
+	}
+	file, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, resp.Body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// An event of the backend's event stream, Data is the payload of its type
+type streamEvent struct {
+	ID   uint64          `json:"id"`
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Events for tasks that report progress and completion over SSE
+type eventStream struct {
+	body   io.ReadCloser
+	events chan streamEvent
+}
+
+// Subscribes to the events whose type starts with one of the prefixes
+func (c *client) events(prefixes ...string) (*eventStream, error) {
+	req, err := c.newRequest(http.MethodGet, "/api/v1/events", url.Values{"type": prefixes}, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("quicksave backend is not reachable at %s: %w", c.baseURL, err)
+	}
+	if resp.StatusCode >= 400 {
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, responseError(resp.StatusCode, data)
+	}
+	stream := &eventStream{body: resp.Body, events: make(chan streamEvent)}
+	go func() {
+		defer close(stream.events)
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			data, ok := strings.CutPrefix(scanner.Text(), "data:")
+			if !ok {
+				continue
+			}
+			// Stream notices like "connected" carry no event object
+			var event streamEvent
+			if json.Unmarshal([]byte(strings.TrimSpace(data)), &event) == nil && event.Type != "" {
+				stream.events <- event
+			}
+		}
+	}()
+	return stream, nil
+}
+
+// Hands every event to handle until it reports done or an error
+func (s *eventStream) waitFor(handle func(event streamEvent) (bool, error), timeout time.Duration) error {
+	deadline := time.After(timeout)
+	for {
+		select {
+		case event, ok := <-s.events:
+			if !ok {
+				return fmt.Errorf("backend closed the event stream")
+			}
+			done, err := handle(event)
+			if done || err != nil {
+				return err
+			}
+		case <-deadline:
+			return fmt.Errorf("timed out after %s", timeout)
+		}
+	}
+}
+
+func (s *eventStream) Close() error {
+	return s.body.Close()
+}
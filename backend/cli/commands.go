@@ -0,0 +1,801 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	exportTimeout = 6 * time.Hour
+	importTimeout = 24 * time.Hour
+)
+
+type gameSummary struct {
+	UID         string  `json:"uid"`
+	Name        string  `json:"name"`
+	ReleaseDate string  `json:"releaseDate"`
+	Platform    string  `json:"platform"`
+	IsDLC       bool    `json:"isDLC"`
+	TimePlayed  float64 `json:"timePlayed"`
+	Rating      float64 `json:"rating"`
+	InstallPath string  `json:"installPath"`
+	Hidden      bool    `json:"hidden"`
+}
+
+type gameDetail struct {
+	gameSummary
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+	Companies   []string `json:"companies"`
+	Igdb        struct {
+		ID     int      `json:"id"`
+		Genres []string `json:"genres"`
+	} `json:"igdb"`
+}
+
+type backupInfo struct {
+	ID              string    `json:"id"`
+	CreatedAt       time.Time `json:"createdAt"`
+	Reason          string    `json:"reason"`
+	DBSize          int64     `json:"dbSize"`
+	ImageFiles      int       `json:"imageFiles"`
+	Encrypted       bool      `json:"encrypted"`
+	SecretsExcluded bool      `json:"secretsExcluded"`
+}
+
+type remoteDevice struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	CreatedAt  string `json:"createdAt"`
+	LastSeenAt string `json:"lastSeenAt"`
+}
+
+type job struct {
+	ID              string    `json:"id"`
+	Source          string    `json:"source"`
+	Status          string    `json:"status"`
+	ContinueOnError bool      `json:"continueOnError"`
+	Error           string    `json:"error"`
+	Total           int       `json:"total"`
+	Done            int       `json:"done"`
+	Failed          int       `json:"failed"`
+	Added           int       `json:"added"`
+	CreatedAt       string    `json:"createdAt"`
+	Items           []jobItem `json:"items"`
+}
+
+type jobItem struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error"`
+}
+
+type exportStarted struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+func (a *app) table(header string, rows func(w *tabwriter.Writer)) error {
+	w := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, header)
+	rows(w)
+	return w.Flush()
+}
+
+func runList(a *app, args []string) error {
+	return listGames(a, "list", args)
+}
+
+func runSearch(a *app, args []string) error {
+	return listGames(a, "search", args)
+}
+
+func listGames(a *app, name string, args []string) error {
+	flags := commandFlags(name)
+	var tags, platforms, developers stringList
+	namePrefix := flags.String("name", "", "name prefix")
+	flags.Var(&tags, "tag", "tag the games must have, repeatable")
+	flags.Var(&platforms, "platform", "platform, repeatable")
+	flags.Var(&developers, "developer", "developer or publisher, repeatable")
+	hidden := flags.String("hidden", "exclude", "exclude, include or only hidden games")
+	sortKey := flags.String("sort", "name", "name, releaseDate, timePlayed, rating or platform")
+	order := flags.String("order", "asc", "asc or desc")
+	positional, err := parseInterspersed(flags, args)
+	if err != nil {
+		return err
+	}
+	if name == "search" {
+		if len(positional) != 1 {
+			flags.Usage()
+			return fmt.Errorf("expected a name to search for")
+		}
+		*namePrefix = positional[0]
+	} else if len(positional) > 0 {
+		flags.Usage()
+		return fmt.Errorf("unexpected argument %q", positional[0])
+	}
+
+	query := url.Values{"hidden": {*hidden}, "sort": {*sortKey}, "order": {*order}}
+	if *namePrefix != "" {
+		query.Set("name", *namePrefix)
+	}
+	query["tag"] = tags
+	query["platform"] = platforms
+	query["developer"] = developers
+
+	var games struct {
+		Items []gameSummary `json:"items"`
+		Total int           `json:"total"`
+	}
+	raw, err := a.client.call(http.MethodGet, "/api/v1/games", query, nil, &games)
+	if err != nil {
+		return err
+	}
+	if a.json {
+		return a.printJSON(raw)
+	}
+	return a.table("UID\tNAME\tPLATFORM\tHOURS\tRATING\tRELEASED", func(w *tabwriter.Writer) {
+		for _, game := range games.Items {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%.1f\t%.0f\t%s\n", game.UID, game.Name, game.Platform, game.TimePlayed, game.Rating, game.ReleaseDate)
+		}
+	})
+}
+
+func runShow(a *app, args []string) error {
+	flags := commandFlags("show")
+	positional, err := parseInterspersed(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected a game uid")
+	}
+	var game gameDetail
+	raw, err := a.client.call(http.MethodGet, "/api/v1/games/"+url.PathEscape(positional[0]), nil, nil, &game)
+	if err != nil {
+		return err
+	}
+	if a.json {
+		return a.printJSON(raw)
+	}
+	w := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	fields := [][2]string{
+		{"UID", game.UID},
+		{"Name", game.Name},
+		{"Platform", game.Platform},
+		{"Released", game.ReleaseDate},
+		{"Hours played", strconv.FormatFloat(game.TimePlayed, 'f', 1, 64)},
+		{"Rating", strconv.FormatFloat(game.Rating, 'f', 0, 64)},
+		{"Install path", game.InstallPath},
+		{"Hidden", strconv.FormatBool(game.Hidden)},
+		{"Tags", strings.Join(game.Tags, ", ")},
+		{"Companies", strings.Join(game.Companies, ", ")},
+		{"Genres", strings.Join(game.Igdb.Genres, ", ")},
+	}
+	if game.Igdb.ID != 0 {
+		fields = append(fields, [2]string{"IGDB ID", strconv.Itoa(game.Igdb.ID)})
+	}
+	for _, field := range fields {
+		fmt.Fprintf(w, "%s:\t%s\n", field[0], field[1])
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if game.Description != "" {
+		fmt.Fprintf(a.out, "\n%s\n", game.Description)
+	}
+	return nil
+}
+
+type igdbCandidate struct {
+	Name  string `json:"name"`
+	Date  string `json:"date"`
+	AppID int    `json:"appid"`
+}
+
+type labelValue struct {
+	Value string `json:"value"`
+	Label string `json:"label"`
+}
+
+func labelValues(values []string) []labelValue {
+	out := []labelValue{}
+	for _, value := range values {
+		out = append(out, labelValue{Value: value, Label: value})
+	}
+	return out
+}
+
+// Same steps as the add dialog of the UI: search, pick a result, fetch its metadata, insert
+func runAdd(a *app, args []string) error {
+	flags := commandFlags("add")
+	igdbID := flags.Int("igdb-id", 0, "IGDB game to add, needed when the name matches several games")
+	platform := flags.String("platform", "PC", "platform the game is owned on")
+	hours := flags.Float64("hours", 0, "hours played")
+	wishlist := flags.Bool("wishlist", false, "add to the wishlist")
+	positional, err := parseInterspersed(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected a game name")
+	}
+
+	var search struct {
+		FoundGames string `json:"foundGames"`
+	}
+	_, err = a.client.call(http.MethodPost, "/IGDBsearch", nil, map[string]string{"NameToSearch": positional[0]}, &search)
+	if err != nil {
+		return err
+	}
+	found := map[string]igdbCandidate{}
+	if err := json.Unmarshal([]byte(search.FoundGames), &found); err != nil {
+		return fmt.Errorf("unexpected IGDB search result: %w", err)
+	}
+	candidates := make([]igdbCandidate, 0, len(found))
+	for _, candidate := range found {
+		candidates = append(candidates, candidate)
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].AppID < candidates[j].AppID })
+
+	if *igdbID == 0 {
+		if len(candidates) != 1 {
+			if a.json {
+				a.printValue(candidates)
+			} else {
+				a.table("IGDB ID\tNAME\tRELEASED", func(w *tabwriter.Writer) {
+					for _, candidate := range candidates {
+						fmt.Fprintf(w, "%d\t%s\t%s\n", candidate.AppID, candidate.Name, candidate.Date)
+					}
+				})
+			}
+			return fmt.Errorf("%d games match, pick one with --igdb-id", len(candidates))
+		}
+		*igdbID = candidates[0].AppID
+	}
+
+	var info struct {
+		Metadata struct {
+			Name              string   `json:"name"`
+			ReleaseDate       string   `json:"releaseDate"`
+			AggregatedRating  float64  `json:"aggregatedRating"`
+			Description       string   `json:"description"`
+			InvolvedCompanies []string `json:"involvedCompanies"`
+			Tags              []string `json:"tags"`
+			Cover             string   `json:"cover"`
+			Screenshots       []string `json:"screenshots"`
+			// Passed back untouched so the server can store it without asking IGDB again
+			Igdb json.RawMessage `json:"igdb"`
+		} `json:"metadata"`
+	}
+	_, err = a.client.call(http.MethodPost, "/GetIgdbInfo", nil, map[string]int{"key": *igdbID}, &info)
+	if err != nil {
+		return err
+	}
+	metadata := info.Metadata
+	isWishlist := 0
+	if *wishlist {
+		isWishlist = 1
+	}
+	game := map[string]any{
+		"title":             metadata.Name,
+		"releaseDate":       metadata.ReleaseDate,
+		"selectedPlatforms": labelValues([]string{*platform}),
+		"timePlayed":        strconv.FormatFloat(*hours, 'f', -1, 64),
+		"rating":            strconv.FormatFloat(metadata.AggregatedRating, 'f', 0, 64),
+		"selectedDevs":      labelValues(metadata.InvolvedCompanies),
+		"selectedTags":      labelValues(metadata.Tags),
+		"description":       metadata.Description,
+		"coverImage":        metadata.Cover,
+		"ssImage":           metadata.Screenshots,
+		"isWishlist":        isWishlist,
+		"igdbID":            *igdbID,
+		"igdb":              metadata.Igdb,
+	}
+	var result struct {
+		InsertionStatus bool `json:"insertionStatus"`
+	}
+	raw, err := a.client.call(http.MethodPost, "/addGameToDB", nil, game, &result)
+	if err != nil {
+		return err
+	}
+	if a.json {
+		return a.printJSON(raw)
+	}
+	if !result.InsertionStatus {
+		return fmt.Errorf("%s is already in the library", metadata.Name)
+	}
+	fmt.Fprintf(a.out, "Added %s (%s) on %s\n", metadata.Name, metadata.ReleaseDate, *platform)
+	return nil
+}
+
+func runSetPath(a *app, args []string) error {
+	flags := commandFlags("set-path")
+	positional, err := parseInterspersed(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		flags.Usage()
+		return fmt.Errorf("expected a game uid and a path")
+	}
+	path := positional[1]
+	if path != "" {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+	}
+	var game gameSummary
+	raw, err := a.client.call(http.MethodPatch, "/api/v1/games/"+url.PathEscape(positional[0]), nil, map[string]string{"installPath": path}, &game)
+	if err != nil {
+		return err
+	}
+	if a.json {
+		return a.printJSON(raw)
+	}
+	if game.InstallPath == "" {
+		fmt.Fprintf(a.out, "Cleared the install path of %s\n", game.Name)
+		return nil
+	}
+	fmt.Fprintf(a.out, "%s launches %s\n", game.Name, game.InstallPath)
+	return nil
+}
+
+// The backend records the play session, for games launched from a path it returns once the game quits
+func runLaunch(a *app, args []string) error {
+	flags := commandFlags("launch")
+	positional, err := parseInterspersed(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected a game uid")
+	}
+	started := time.Now()
+	raw, err := a.client.call(http.MethodPost, "/api/v1/games/"+url.PathEscape(positional[0])+"/launch", nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	if a.json {
+		return a.printJSON(raw)
+	}
+	fmt.Fprintf(a.out, "Launched, session ended after %s\n", time.Since(started).Round(time.Second))
+	return nil
+}
+
+func runImport(a *app, args []string) error {
+	flags := commandFlags("import")
+	steamID := flags.String("steam-id", "", "Steam ID, for steam")
+	apiKey := flags.String("api-key", os.Getenv("QUICKSAVE_STEAM_API_KEY"), "Steam API key, the stored one when empty")
+	npsso := flags.String("npsso", os.Getenv("QUICKSAVE_NPSSO"), "PlayStation NPSSO token, the stored one when empty")
+	stopOnError := flags.Bool("stop-on-error", false, "stop at the first game that fails instead of going on")
+	detach := flags.Bool("detach", false, "print the job id instead of following the import")
+	positional, err := parseInterspersed(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected steam or psn")
+	}
+
+	req := map[string]any{"continueOnError": !*stopOnError}
+	switch positional[0] {
+	case "steam":
+		if *steamID == "" {
+			var creds struct {
+				SteamID string `json:"steamId"`
+			}
+			_, err := a.client.call(http.MethodGet, "/SteamCreds", nil, nil, &creds)
+			if err != nil {
+				return err
+			}
+			*steamID = creds.SteamID
+		}
+		req["source"], req["steamId"], req["apiKey"] = "steam", *steamID, *apiKey
+	case "psn", "playstation":
+		req["source"], req["npsso"] = "playstation", *npsso
+	default:
+		flags.Usage()
+		return fmt.Errorf("unknown source %q", positional[0])
+	}
+	return a.followJob("/api/v1/imports", req, *detach)
+}
+
+// Starts or resumes a job and follows its events until it stops. Interrupting the command leaves the
+// job running on the backend.
+func (a *app) followJob(path string, body any, detach bool) error {
+	var events *eventStream
+	if !detach {
+		// Subscribe first so the end of a short job can't be missed
+		var err error
+		events, err = a.client.events("import.")
+		if err != nil {
+			return err
+		}
+		defer events.Close()
+	}
+
+	var started job
+	raw, err := a.client.call(http.MethodPost, path, nil, body, &started)
+	if err != nil {
+		return err
+	}
+	if detach {
+		if a.json {
+			return a.printJSON(raw)
+		}
+		fmt.Fprintf(a.out, "Started %s import job %s\n", started.Source, started.ID)
+		return nil
+	}
+
+	err = events.waitFor(func(event streamEvent) (bool, error) {
+		var data struct {
+			ID     string `json:"id"`
+			JobID  string `json:"jobId"`
+			Done   int    `json:"done"`
+			Failed int    `json:"failed"`
+			Total  int    `json:"total"`
+		}
+		if json.Unmarshal(event.Data, &data) != nil {
+			return false, nil
+		}
+		switch event.Type {
+		case "import.progress":
+			if data.JobID == started.ID && !a.json {
+				fmt.Fprintf(os.Stderr, "\rImporting %s: %d/%d, %d failed", started.Source, data.Done, data.Total, data.Failed)
+			}
+		case "import.done", "import.failed", "import.cancelled":
+			return data.ID == started.ID, nil
+		}
+		return false, nil
+	}, importTimeout)
+	if !a.json {
+		fmt.Fprintln(os.Stderr)
+	}
+	if err != nil {
+		return err
+	}
+
+	finished, err := a.showJob(started.ID)
+	if err != nil {
+		return err
+	}
+	switch finished.Status {
+	case "failed":
+		return fmt.Errorf("%s import failed: %s", finished.Source, finished.Error)
+	case "cancelled", "interrupted":
+		return fmt.Errorf("%s import %s", finished.Source, finished.Status)
+	}
+	return nil
+}
+
+func (a *app) showJob(id string) (job, error) {
+	var result job
+	raw, err := a.client.call(http.MethodGet, "/api/v1/jobs/"+url.PathEscape(id), nil, nil, &result)
+	if err != nil {
+		return result, err
+	}
+	if a.json {
+		return result, a.printJSON(raw)
+	}
+
+	fmt.Fprintf(a.out, "Job %s: %s import %s, %d of %d games done\n", result.ID, result.Source, result.Status, result.Done, result.Total)
+	if result.Error != "" {
+		fmt.Fprintf(a.out, "Error: %s\n", result.Error)
+	}
+	fmt.Fprintf(a.out, "Added %d games\n", result.Added)
+	var notMatched []string
+	for _, item := range result.Items {
+		if item.Status == "unmatched" {
+			notMatched = append(notMatched, item.Name)
+		}
+	}
+	if len(notMatched) > 0 {
+		fmt.Fprintf(a.out, "Not found on IGDB: %s\n", strings.Join(notMatched, ", "))
+	}
+	for _, item := range result.Items {
+		if item.Status == "failed" {
+			fmt.Fprintf(a.out, "Failed: %s: %s\n", item.Name, item.Error)
+		}
+	}
+	if result.Failed > 0 || result.Status == "failed" || result.Status == "cancelled" || result.Status == "interrupted" {
+		fmt.Fprintf(a.out, "Run quicksave jobs resume %s to retry what is left\n", result.ID)
+	}
+	return result, nil
+}
+
+func runJobs(a *app, args []string) error {
+	flags := commandFlags("jobs")
+	detach := flags.Bool("detach", false, "resume: print the job instead of following it")
+	positional, err := parseInterspersed(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		flags.Usage()
+		return fmt.Errorf("expected list, show, cancel or resume")
+	}
+	if positional[0] != "list" && len(positional) != 2 {
+		flags.Usage()
+		return fmt.Errorf("expected a job id")
+	}
+
+	switch positional[0] {
+	case "list":
+		var result struct {
+			Items []job `json:"items"`
+		}
+		raw, err := a.client.call(http.MethodGet, "/api/v1/jobs", nil, nil, &result)
+		if err != nil {
+			return err
+		}
+		if a.json {
+			return a.printJSON(raw)
+		}
+		return a.table("ID\tSOURCE\tSTATUS\tDONE\tFAILED\tADDED\tCREATED", func(w *tabwriter.Writer) {
+			for _, j := range result.Items {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%d/%d\t%d\t%d\t%s\n", j.ID, j.Source, j.Status, j.Done, j.Total, j.Failed, j.Added, j.CreatedAt)
+			}
+		})
+	case "show":
+		_, err := a.showJob(positional[1])
+		return err
+	case "cancel":
+		raw, err := a.client.call(http.MethodPost, "/api/v1/jobs/"+url.PathEscape(positional[1])+"/cancel", nil, nil, nil)
+		if err != nil {
+			return err
+		}
+		if a.json {
+			return a.printJSON(raw)
+		}
+		fmt.Fprintf(a.out, "Job %s stops after its current game\n", positional[1])
+		return nil
+	case "resume":
+		return a.followJob("/api/v1/jobs/"+url.PathEscape(positional[1])+"/resume", nil, *detach)
+	}
+	flags.Usage()
+	return fmt.Errorf("unknown jobs command %q", positional[0])
+}
+
+func runBackup(a *app, args []string) error {
+	flags := commandFlags("backup")
+	passphrase := flags.String("passphrase", os.Getenv("QUICKSAVE_PASSPHRASE"), "passphrase of an encrypted backup, the stored one when empty")
+	positional, err := parseInterspersed(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		flags.Usage()
+		return fmt.Errorf("expected create, list or restore")
+	}
+
+	switch positional[0] {
+	case "create":
+		var result struct {
+			Backup backupInfo `json:"backup"`
+		}
+		raw, err := a.client.call(http.MethodPost, "/backupNow", nil, nil, &result)
+		if err != nil {
+			return err
+		}
+		if a.json {
+			return a.printJSON(raw)
+		}
+		fmt.Fprintf(a.out, "Created backup %s\n", result.Backup.ID)
+		return nil
+	case "list":
+		var result struct {
+			Backups []backupInfo `json:"backups"`
+		}
+		raw, err := a.client.call(http.MethodGet, "/backups", nil, nil, &result)
+		if err != nil {
+			return err
+		}
+		if a.json {
+			return a.printJSON(raw)
+		}
+		return a.table("ID\tCREATED\tREASON\tDB SIZE\tIMAGES\tENCRYPTED", func(w *tabwriter.Writer) {
+			for _, backup := range result.Backups {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%t\n", backup.ID, backup.CreatedAt.Local().Format(time.DateTime), backup.Reason, formatSize(backup.DBSize), backup.ImageFiles, backup.Encrypted)
+			}
+		})
+	case "restore":
+		if len(positional) != 2 {
+			flags.Usage()
+			return fmt.Errorf("expected a backup id")
+		}
+		raw, err := a.client.call(http.MethodPost, "/restoreBackup", nil, map[string]string{"id": positional[1], "passphrase": *passphrase}, nil)
+		if err != nil {
+			return err
+		}
+		if a.json {
+			return a.printJSON(raw)
+		}
+		fmt.Fprintf(a.out, "Restored backup %s\n", positional[1])
+		return nil
+	}
+	flags.Usage()
+	return fmt.Errorf("unknown backup command %q", positional[0])
+}
+
+// Pairing prints a code the device sends to POST /api/v1/remote/pair with its name
+func runDevices(a *app, args []string) error {
+	flags := commandFlags("devices")
+	positional, err := parseInterspersed(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		flags.Usage()
+		return fmt.Errorf("expected pair, list or revoke")
+	}
+
+	switch positional[0] {
+	case "pair":
+		var pairing struct {
+			Code      string    `json:"code"`
+			ExpiresAt time.Time `json:"expiresAt"`
+		}
+		raw, err := a.client.call(http.MethodPost, "/api/v1/remote/pairings", nil, nil, &pairing)
+		if err != nil {
+			return err
+		}
+		if a.json {
+			return a.printJSON(raw)
+		}
+		fmt.Fprintf(a.out, "Pairing code %s-%s, valid until %s\n", pairing.Code[:4], pairing.Code[4:], pairing.ExpiresAt.Local().Format(time.TimeOnly))
+		return nil
+	case "list":
+		var result struct {
+			Items []remoteDevice `json:"items"`
+		}
+		raw, err := a.client.call(http.MethodGet, "/api/v1/remote/devices", nil, nil, &result)
+		if err != nil {
+			return err
+		}
+		if a.json {
+			return a.printJSON(raw)
+		}
+		return a.table("ID\tNAME\tPAIRED\tLAST SEEN", func(w *tabwriter.Writer) {
+			for _, device := range result.Items {
+				lastSeen := device.LastSeenAt
+				if lastSeen == "" {
+					lastSeen = "never"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", device.ID, device.Name, device.CreatedAt, lastSeen)
+			}
+		})
+	case "revoke":
+		if len(positional) != 2 {
+			flags.Usage()
+			return fmt.Errorf("expected a device id")
+		}
+		_, err := a.client.call(http.MethodDelete, "/api/v1/remote/devices/"+url.PathEscape(positional[1]), nil, nil, nil)
+		if err != nil {
+			return err
+		}
+		if !a.json {
+			fmt.Fprintf(a.out, "Revoked device %s\n", positional[1])
+		}
+		return nil
+	}
+	flags.Usage()
+	return fmt.Errorf("unknown devices command %q", positional[0])
+}
+
+func formatSize(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
+// Exports run in the background on the backend, so this follows the event stream until the file
+// is ready and then downloads it
+func runExport(a *app, args []string) error {
+	flags := commandFlags("export")
+	includeScreenshots := flags.Bool("include-screenshots", false, "library: include screenshots")
+	excludeSecrets := flags.Bool("exclude-secrets", false, "library: leave account details out")
+	uid := flags.String("uid", "", "screenshots: only this game")
+	album := flags.Int64("album", 0, "screenshots: only this album")
+	format := flags.String("format", "", "screenshots: convert to this format")
+	quality := flags.Int("quality", 0, "screenshots: quality of converted images")
+	passphrase := flags.String("passphrase", os.Getenv("QUICKSAVE_PASSPHRASE"), "encrypt the export with this passphrase")
+	output := flags.String("output", "", "file to write, the export name in the current folder by default")
+	positional, err := parseInterspersed(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected library or screenshots")
+	}
+
+	var path string
+	var body map[string]any
+	switch positional[0] {
+	case "library":
+		path = "/exportLibrary"
+		body = map[string]any{"includeScreenshots": *includeScreenshots, "excludeSecrets": *excludeSecrets, "passphrase": *passphrase}
+	case "screenshots":
+		path = "/exportScreenshots"
+		body = map[string]any{"uid": *uid, "albumId": *album, "format": *format, "quality": *quality, "passphrase": *passphrase}
+	default:
+		flags.Usage()
+		return fmt.Errorf("unknown export %q", positional[0])
+	}
+
+	// Subscribe first so the completion event can't be missed
+	events, err := a.client.events("export.")
+	if err != nil {
+		return err
+	}
+	defer events.Close()
+
+	var started exportStarted
+	_, err = a.client.call(http.MethodPost, path, nil, body, &started)
+	if err != nil {
+		return err
+	}
+	err = events.waitFor(func(event streamEvent) (bool, error) {
+		var data struct {
+			Name  string `json:"name"`
+			Done  int    `json:"done"`
+			Total int    `json:"total"`
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(event.Data, &data) != nil || data.Name != started.Name {
+			return false, nil
+		}
+		switch event.Type {
+		case "export.progress":
+			if !a.json {
+				fmt.Fprintf(os.Stderr, "\rExporting %s: %d/%d", positional[0], data.Done, data.Total)
+			}
+		case "export.done":
+			return true, nil
+		case "export.failed":
+			return false, fmt.Errorf("%s", data.Error)
+		}
+		return false, nil
+	}, exportTimeout)
+	if !a.json {
+		fmt.Fprintln(os.Stderr)
+	}
+	if err != nil {
+		return err
+	}
+
+	dest := *output
+	if dest == "" {
+		dest = started.Name
+	}
+	err = a.client.download(started.URL, dest)
+	if err != nil {
+		return err
+	}
+	if a.json {
+		return a.printValue(map[string]string{"name": started.Name, "file": dest})
+	}
+	fmt.Fprintf(a.out, "Wrote %s\n", dest)
+	return nil
+}
@@ -0,0 +1,170 @@
+// quicksave is the command line client of the quicksave backend, for scripting library maintenance
+// and headless machines. It needs a running backend and authenticates with the backend's API token.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(app *app, args []string) error
+}
+
+// State shared by all commands
+type app struct {
+	client *client
+	json   bool
+	out    io.Writer
+}
+
+var commands []command
+
+// Set in init because the commands look up their own usage line
+func init() {
+	commands = []command{
+		{name: "list", usage: "list [--name prefix] [--tag t]... [--platform p]... [--developer d]... [--hidden exclude|include|only] [--sort key] [--order asc|desc]", summary: "List and filter games", run: runList},
+		{name: "search", usage: "search <name> [list flags]", summary: "List games whose name starts with <name>", run: runSearch},
+		{name: "show", usage: "show <uid>", summary: "Show one game", run: runShow},
+		{name: "add", usage: "add <name> [--igdb-id id] [--platform name] [--hours n] [--wishlist]", summary: "Add a game with metadata from IGDB", run: runAdd},
+		{name: "set-path", usage: "set-path <uid> <path>", summary: "Set the executable a game is launched from, \"\" clears it", run: runSetPath},
+		{name: "launch", usage: "launch <uid>", summary: "Launch a game and track its play session", run: runLaunch},
+		{name: "import", usage: "import steam [--steam-id id] [--api-key key] | import psn [--npsso token], both with [--stop-on-error] [--detach]", summary: "Import a Steam or PlayStation library", run: runImport},
+		{name: "jobs", usage: "jobs list | jobs show <id> | jobs cancel <id> | jobs resume <id> [--detach]", summary: "List, cancel and resume import jobs", run: runJobs},
+		{name: "backup", usage: "backup create | backup list | backup restore <id> [--passphrase p]", summary: "Create, list and restore backups", run: runBackup},
+		{name: "export", usage: "export library [--include-screenshots] [--exclude-secrets] | export screenshots [--uid u] [--album id] [--format f] [--quality q], both with [--passphrase p] [--output file]", summary: "Export the library or screenshots and download the file", run: runExport},
+		{name: "devices", usage: "devices pair | devices list | devices revoke <id>", summary: "Pair, list and revoke remote control devices", run: runDevices},
+	}
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: quicksave [--url url] [--token token] [--json] <command> [args]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-9s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "The backend URL and token default to QUICKSAVE_URL and QUICKSAVE_API_TOKEN, the token")
+	fmt.Fprintln(w, "otherwise comes from the api.token file the backend writes to its data folder.")
+	fmt.Fprintln(w, "Run quicksave <command> --help for the flags of a command.")
+}
+
+func main() {
+	flags := flag.NewFlagSet("quicksave", flag.ContinueOnError)
+	flags.Usage = func() { usage(os.Stderr) }
+	baseURL := flags.String("url", envOr("QUICKSAVE_URL", defaultBackendURL), "backend URL")
+	token := flags.String("token", os.Getenv("QUICKSAVE_API_TOKEN"), "API token")
+	jsonOutput := flags.Bool("json", false, "print JSON instead of text")
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		os.Exit(2)
+	}
+	if flags.NArg() == 0 {
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+
+	name := flags.Arg(0)
+	if name == "help" {
+		usage(os.Stdout)
+		return
+	}
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == name {
+			cmd = &commands[i]
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "quicksave: unknown command %q\n\n", name)
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+
+	c, err := newClient(*baseURL, *token)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "quicksave:", err)
+		os.Exit(1)
+	}
+	err = cmd.run(&app{client: c, json: *jsonOutput, out: os.Stdout}, flags.Args()[1:])
+	if err == flag.ErrHelp {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "quicksave %s: %v\n", cmd.name, err)
+		os.Exit(1)
+	}
+}
+
+func envOr(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// Flag set of a subcommand, flags may come before or after positional arguments
+func commandFlags(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(os.Stderr)
+	for _, cmd := range commands {
+		if cmd.name == strings.Fields(name)[0] {
+			flags.Usage = func() {
+				fmt.Fprintf(os.Stderr, "Usage: quicksave %s\n", cmd.usage)
+				flags.PrintDefaults()
+			}
+		}
+	}
+	return flags
+}
+
+func parseInterspersed(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// Repeatable string flag
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+func (a *app) printJSON(raw json.RawMessage) error {
+	var value any
+	if err := json.Unmarshal(raw, &value); err != nil {
+		_, err = a.out.Write(raw)
+		return err
+	}
+	encoder := json.NewEncoder(a.out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+func (a *app) printValue(value any) error {
+	encoder := json.NewEncoder(a.out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
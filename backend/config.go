@@ -0,0 +1,281 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Settings that have to be known before the database is opened. Each comes from the first of
+// command line flag, environment variable, config file and default that sets it.
+type serverConfig struct {
+	DataDir string `json:"dataDir"`
+	Listen  string `json:"listen"`
+	LogFile string `json:"logFile"`
+	// Serve the embedded frontend for browsers
+	Web bool `json:"web"`
+	// Allow listening on other interfaces than loopback, a loopback listen address becomes all interfaces
+	LAN bool `json:"lan"`
+}
+
+const (
+	configDirName     = "quicksave"
+	configFileName    = "config.json"
+	defaultListen     = "localhost:50001"
+	logFileName       = "server.log"
+	dbFile            = "IGDB_Database.db"
+	configFileEnv     = "QUICKSAVE_CONFIG"
+	configDataDirEnv  = "QUICKSAVE_DATA_DIR"
+	configListenEnv   = "QUICKSAVE_LISTEN"
+	configLogFileEnv  = "QUICKSAVE_LOG_FILE"
+	configWebEnv      = "QUICKSAVE_WEB"
+	configLANEnv      = "QUICKSAVE_LAN"
+	configDefaultFrom = "default"
+)
+
+var config serverConfig
+
+// Where each setting came from, for the startup log
+var configSources = map[string]string{}
+
+type configFlags struct {
+	configFile *string
+	dataDir    *string
+	listen     *string
+	logFile    *string
+	web        *bool
+	lan        *bool
+}
+
+func registerConfigFlags() configFlags {
+	return configFlags{
+		configFile: flag.String("config", "", "config file, "+configFileName+" in the user config folder by default"),
+		dataDir:    flag.String("data-dir", "", "folder for the database, images, secrets and exports"),
+		listen:     flag.String("listen", "", "address to listen on, host:port or a port on localhost (default "+defaultListen+")"),
+		logFile:    flag.String("log-file", "", "log file, "+logFileName+" in the data folder by default"),
+		web:        flag.Bool("web", false, "serve the web frontend, browsers sign in with the API token or web password"),
+		lan:        flag.Bool("lan", false, "listen on the local network instead of only this machine"),
+	}
+}
+
+// Resolves the config and changes into the data directory. The database, images, secrets and
+// exports are opened by paths relative to the data directory, so this runs before any of them.
+func initConfig(flags configFlags) {
+	var err error
+	config, err = loadConfig(flags)
+	if err != nil {
+		log.Fatalf("could not load config %v", err)
+	}
+	err = os.MkdirAll(config.DataDir, 0755)
+	if err != nil {
+		log.Fatalf("could not create data directory %v", err)
+	}
+	err = os.Chdir(config.DataDir)
+	if err != nil {
+		log.Fatalf("could not use data directory %v", err)
+	}
+}
+
+func logConfig() {
+	log.Printf("[Config] data directory %s (%s)", config.DataDir, configSources["dataDir"])
+	log.Printf("[Config] log file %s (%s)", config.LogFile, configSources["logFile"])
+	log.Printf("[Config] listening on %s (%s)", config.Listen, configSources["listen"])
+	if config.Web {
+		log.Printf("[Config] serving the web frontend (%s)", configSources["web"])
+	}
+}
+
+func loadConfig(flags configFlags) (serverConfig, error) {
+	var cfg serverConfig
+
+	path, explicit := *flags.configFile, true
+	if path == "" {
+		path = os.Getenv(configFileEnv)
+	}
+	if path == "" {
+		path, explicit = defaultConfigFile(), false
+	}
+	if path != "" {
+		fileCfg, err := readConfigFile(path)
+		if err != nil && (explicit || !os.IsNotExist(err)) {
+			return cfg, err
+		}
+		// Relative paths in the file are relative to the file
+		dir := filepath.Dir(path)
+		setConfigValue(&cfg.DataDir, "dataDir", resolveFrom(dir, fileCfg.DataDir), path)
+		setConfigValue(&cfg.Listen, "listen", fileCfg.Listen, path)
+		setConfigValue(&cfg.LogFile, "logFile", resolveFrom(dir, fileCfg.LogFile), path)
+		setConfigBool(&cfg.Web, "web", fileCfg.Web, path)
+		setConfigBool(&cfg.LAN, "lan", fileCfg.LAN, path)
+	}
+
+	setConfigValue(&cfg.DataDir, "dataDir", os.Getenv(configDataDirEnv), configDataDirEnv)
+	setConfigValue(&cfg.Listen, "listen", os.Getenv(configListenEnv), configListenEnv)
+	setConfigValue(&cfg.LogFile, "logFile", os.Getenv(configLogFileEnv), configLogFileEnv)
+	for _, env := range []struct {
+		field *bool
+		name  string
+		key   string
+	}{{&cfg.Web, "web", configWebEnv}, {&cfg.LAN, "lan", configLANEnv}} {
+		if value := os.Getenv(env.key); value != "" {
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return cfg, fmt.Errorf("invalid %s %q: %w", env.key, value, err)
+			}
+			setConfigBool(env.field, env.name, enabled, env.key)
+		}
+	}
+
+	setConfigValue(&cfg.DataDir, "dataDir", *flags.dataDir, "-data-dir")
+	setConfigValue(&cfg.Listen, "listen", *flags.listen, "-listen")
+	setConfigValue(&cfg.LogFile, "logFile", *flags.logFile, "-log-file")
+	setConfigBool(&cfg.Web, "web", *flags.web, "-web")
+	setConfigBool(&cfg.LAN, "lan", *flags.lan, "-lan")
+
+	if cfg.DataDir == "" {
+		cfg.DataDir, configSources["dataDir"] = defaultDataDir()
+	}
+	if cfg.LogFile == "" {
+		cfg.LogFile, configSources["logFile"] = defaultLogFile(cfg.DataDir)
+	}
+	if cfg.Listen == "" {
+		cfg.Listen, configSources["listen"] = defaultListen, configDefaultFrom
+	}
+
+	var err error
+	cfg.DataDir, err = filepath.Abs(cfg.DataDir)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.LogFile, err = filepath.Abs(cfg.LogFile)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Listen, err = normalizeListen(cfg.Listen, cfg.LAN)
+	if err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// Flags and variables can only turn a setting on, so a false value leaves the earlier one
+func setConfigBool(field *bool, name string, value bool, source string) {
+	if !value {
+		return
+	}
+	*field = true
+	configSources[name] = source
+}
+
+func setConfigValue(field *string, name string, value string, source string) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return
+	}
+	*field = value
+	configSources[name] = source
+}
+
+func readConfigFile(path string) (serverConfig, error) {
+	var cfg serverConfig
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	err = json.Unmarshal(data, &cfg)
+	if err != nil {
+		return cfg, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+func resolveFrom(dir string, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+func defaultConfigFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, configDirName, configFileName)
+}
+
+// Installs from before the data directory was configurable keep their data in the backend folder,
+// which the launcher starts the backend in. New Linux installs follow the XDG base directories,
+// other platforms keep using the backend folder.
+func defaultDataDir() (string, string) {
+	if _, err := os.Stat(dbFile); err == nil {
+		return ".", "existing database in working directory"
+	}
+	if runtime.GOOS == "linux" {
+		if dir := xdgDir("XDG_DATA_HOME", ".local/share"); dir != "" {
+			return filepath.Join(dir, configDirName), "XDG_DATA_HOME"
+		}
+	}
+	return ".", "working directory"
+}
+
+func defaultLogFile(dataDir string) (string, string) {
+	if runtime.GOOS == "linux" && configSources["dataDir"] == "XDG_DATA_HOME" {
+		if dir := xdgDir("XDG_STATE_HOME", ".local/state"); dir != "" {
+			return filepath.Join(dir, configDirName, logFileName), "XDG_STATE_HOME"
+		}
+	}
+	return filepath.Join(dataDir, logFileName), "data directory"
+}
+
+// An XDG base directory, or its default under the home folder
+func xdgDir(env string, fallback string) string {
+	if dir := os.Getenv(env); filepath.IsAbs(dir) {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, fallback)
+}
+
+// Accepts host:port, :port for all interfaces, or a bare port on localhost. Addresses reachable
+// from other machines need lan, with lan a loopback address is widened to all interfaces.
+func normalizeListen(listen string, lan bool) (string, error) {
+	if !strings.Contains(listen, ":") {
+		listen = "localhost:" + listen
+	}
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return "", fmt.Errorf("invalid listen address %q: %w", listen, err)
+	}
+	if _, err := net.LookupPort("tcp", port); err != nil {
+		return "", fmt.Errorf("invalid listen address %q: %w", listen, err)
+	}
+	if lan {
+		if isLoopbackHost(host) {
+			host = ""
+		}
+		return net.JoinHostPort(host, port), nil
+	}
+	if !isLoopbackHost(host) {
+		return "", fmt.Errorf("listen address %q is reachable from other machines, turn on lan to allow it", listen)
+	}
+	return listen, nil
+}
+
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
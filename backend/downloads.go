@@ -0,0 +1,434 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/HugoSmits86/nativewebp"
+)
+
+// Limits for every image fetched from a remote source
+const (
+	maxImageBytes        = 25 << 20
+	imageDownloadRetries = 3
+	imageDownloadWorkers = 6
+)
+
+var imageHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// Shared by all imports so concurrent importers can't flood the network
+var imageDownloadSlots = make(chan struct{}, imageDownloadWorkers)
+
+// One image to fetch and store, AssetType is an artwork type or "screenshot".
+// Origin is the screenshot source recorded in the screenshot index.
+type imageDownload struct {
+	UID       string
+	AssetType string
+	Origin    string
+	Source    string
+	Location  string
+	Filename  string
+}
+
+type imageFailure struct {
+	Path        string `json:"path"`
+	UID         string `json:"uid"`
+	AssetType   string `json:"assetType"`
+	SourceURL   string `json:"sourceURL"`
+	Error       string `json:"error"`
+	Attempts    int    `json:"attempts"`
+	LastAttempt string `json:"lastAttempt"`
+}
+
+type missingCover struct {
+	UID       string `json:"uid"`
+	Name      string `json:"name"`
+	Path      string `json:"path"`
+	SourceURL string `json:"sourceURL"`
+}
+
+// Errors worth another attempt, anything else fails the download right away
+type retryableError struct {
+	err error
+}
+
+func (e retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e retryableError) Unwrap() error {
+	return e.err
+}
+
+// Reads a data URL, a http(s) URL or a local file path
+func fetchImageBytes(source string) ([]byte, error) {
+	if strings.HasPrefix(source, "data:image") {
+		parts := strings.SplitN(source, ",", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("malformed data url")
+		}
+		data, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("error decoding data url: %w", err)
+		}
+		return data, nil
+	}
+
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		var err error
+		for attempt := 0; attempt < imageDownloadRetries; attempt++ {
+			if attempt > 0 {
+				time.Sleep(time.Duration(attempt*attempt) * time.Second)
+			}
+			var data []byte
+			data, err = fetchRemoteImage(source)
+			if err == nil {
+				return data, nil
+			}
+			var retryErr retryableError
+			if !errors.As(err, &retryErr) {
+				return nil, err
+			}
+		}
+		return nil, fmt.Errorf("giving up after %d attempts: %w", imageDownloadRetries, err)
+	}
+
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, fmt.Errorf("error reading image file: %w", err)
+	}
+	if info.Size() > maxImageBytes {
+		return nil, fmt.Errorf("image file too large: %d bytes", info.Size())
+	}
+	return os.ReadFile(source)
+}
+
+func fetchRemoteImage(source string) ([]byte, error) {
+	resp, err := imageHTTPClient.Get(source)
+	if err != nil {
+		return nil, retryableError{fmt.Errorf("failed to send request: %w", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return nil, retryableError{fmt.Errorf("unexpected status code: %d", resp.StatusCode)}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType != "" && !strings.HasPrefix(contentType, "image/") && !strings.HasPrefix(contentType, "application/octet-stream") {
+		return nil, fmt.Errorf("unexpected content type: %s", contentType)
+	}
+	if resp.ContentLength > maxImageBytes {
+		return nil, fmt.Errorf("image too large: %d bytes", resp.ContentLength)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImageBytes+1))
+	if err != nil {
+		return nil, retryableError{fmt.Errorf("failed to read response body: %w", err)}
+	}
+	if len(data) > maxImageBytes {
+		return nil, fmt.Errorf("image larger than %d bytes", maxImageBytes)
+	}
+	return data, nil
+}
+
+// Fetches, decodes and stores an image as webp, the destination is only replaced once encoding succeeded
+func getImageFromURL(getURL string, location string, filename string) error {
+	_, _, err := fetchAndStoreImage(getURL, location, filename)
+	return err
+}
+
+// Like getImageFromURL but hands back the source bytes and decoded image for further copies
+func fetchAndStoreImage(getURL string, location string, filename string) ([]byte, image.Image, error) {
+	data, err := fetchImageBytes(getURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	img, err := storeImageBytes(data, location, filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, img, nil
+}
+
+func storeImageBytes(data []byte, location string, filename string) (image.Image, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding image: %w", err)
+	}
+
+	err = os.MkdirAll(location, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("error creating image folder: %w", err)
+	}
+	tmp, err := os.CreateTemp(location, ".download-*")
+	if err != nil {
+		return nil, fmt.Errorf("error creating temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	err = nativewebp.Encode(tmp, img, nil)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error encoding image: %w", err)
+	}
+	// Temp files are private, the final image keeps the usual permissions
+	err = os.Chmod(tmp.Name(), 0644)
+	if err != nil {
+		return nil, fmt.Errorf("error setting image permissions: %w", err)
+	}
+	err = os.Rename(tmp.Name(), filepath.Join(location, filename))
+	if err != nil {
+		return nil, fmt.Errorf("error moving image into place: %w", err)
+	}
+
+	err = generateImageVariants(location+filename, img)
+	if err != nil {
+		log.Printf("error generating image variants: %v", err)
+	}
+	return img, nil
+}
+
+// Runs one download inside the shared worker pool and records the outcome
+func downloadImage(d imageDownload) error {
+	imageDownloadSlots <- struct{}{}
+	data, img, err := fetchAndStoreImage(d.Source, d.Location, d.Filename)
+	<-imageDownloadSlots
+
+	if err != nil {
+		log.Printf("[Downloads] ERROR %s %s: %v", d.UID, d.Location+d.Filename, err)
+		if recordErr := recordImageFailure(d, err); recordErr != nil {
+			log.Printf("[Downloads] ERROR : %v", recordErr)
+		}
+		return err
+	}
+	if clearErr := clearImageFailure(d.Location + d.Filename); clearErr != nil {
+		log.Printf("[Downloads] ERROR : %v", clearErr)
+	}
+	if d.AssetType == "screenshot" {
+		origin := d.Origin
+		if origin == "" {
+			origin = guessScreenshotSource(d.UID, d.Filename)
+		}
+		err = registerScreenshot(d.UID, d.Location+d.Filename, origin, time.Now())
+		if err == nil {
+			err = storeScreenshotCopies(d.Location+d.Filename, img, data)
+		}
+		if err != nil {
+			log.Printf("[Downloads] ERROR : %v", err)
+		}
+	}
+	return nil
+}
+
+// Downloads a batch concurrently, returning the downloads that failed
+func downloadImages(downloads []imageDownload) []imageDownload {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var failed []imageDownload
+	for _, d := range downloads {
+		wg.Add(1)
+		go func(d imageDownload) {
+			defer wg.Done()
+			if err := downloadImage(d); err != nil {
+				mu.Lock()
+				failed = append(failed, d)
+				mu.Unlock()
+			}
+		}(d)
+	}
+	wg.Wait()
+	return failed
+}
+
+func recordImageFailure(d imageDownload, downloadErr error) error {
+	// Inline images can't be fetched again so only the failure itself is kept
+	sourceURL := d.Source
+	if strings.HasPrefix(sourceURL, "data:image") {
+		sourceURL = ""
+	}
+	return txWrite(func(tx *sql.Tx) error {
+		_, err := tx.Exec(`INSERT INTO ImageFailures (Path, UID, AssetType, SourceURL, Error, Attempts, LastAttempt)
+			VALUES (?,?,?,?,?,1,?)
+			ON CONFLICT(Path) DO UPDATE SET SourceURL=excluded.SourceURL, Error=excluded.Error,
+			Attempts=Attempts+1, LastAttempt=excluded.LastAttempt`,
+			d.Location+d.Filename, d.UID, d.AssetType, sourceURL, downloadErr.Error(), time.Now().Format(time.RFC3339))
+		if err != nil {
+			return fmt.Errorf("error inserting into ImageFailures: %w", err)
+		}
+		return nil
+	})
+}
+
+func clearImageFailure(path string) error {
+	var exists bool
+	err := readDB.QueryRow("SELECT EXISTS(SELECT 1 FROM ImageFailures WHERE Path = ?)", path).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("query error ImageFailures: %w", err)
+	}
+	if !exists {
+		return nil
+	}
+	return txWrite(func(tx *sql.Tx) error {
+		_, err := tx.Exec("DELETE FROM ImageFailures WHERE Path = ?", path)
+		if err != nil {
+			return fmt.Errorf("error deleting ImageFailures: %w", err)
+		}
+		return nil
+	})
+}
+
+func txDeleteImageFailures(tx *sql.Tx, uid string) error {
+	_, err := tx.Exec("DELETE FROM ImageFailures WHERE UID = ?", uid)
+	if err != nil {
+		return fmt.Errorf("error deleting ImageFailures: %w", err)
+	}
+	return nil
+}
+
+func getImageFailures() ([]imageFailure, error) {
+	rows, err := readDB.Query(`SELECT Path, UID, AssetType, SourceURL, Error, Attempts, LastAttempt
+		FROM ImageFailures ORDER BY LastAttempt DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("query error ImageFailures: %w", err)
+	}
+	defer rows.Close()
+
+	failures := []imageFailure{}
+	for rows.Next() {
+		var f imageFailure
+		err := rows.Scan(&f.Path, &f.UID, &f.AssetType, &f.SourceURL, &f.Error, &f.Attempts, &f.LastAttempt)
+		if err != nil {
+			return nil, fmt.Errorf("scan error ImageFailures: %w", err)
+		}
+		failures = append(failures, f)
+	}
+	return failures, nil
+}
+
+// Games whose cover is not registered or whose registered file is gone
+func getMissingCovers() ([]missingCover, error) {
+	rows, err := readDB.Query(`SELECT gmd.UID, gmd.Name, COALESCE(a.Path, ''), COALESCE(a.SourceURL, '')
+		FROM GameMetaData gmd LEFT JOIN Artwork a ON gmd.UID = a.UID AND a.Type = ?`, artworkCover)
+	if err != nil {
+		return nil, fmt.Errorf("query error Artwork: %w", err)
+	}
+	defer rows.Close()
+
+	missing := []missingCover{}
+	for rows.Next() {
+		var m missingCover
+		err := rows.Scan(&m.UID, &m.Name, &m.Path, &m.SourceURL)
+		if err != nil {
+			return nil, fmt.Errorf("scan error Artwork: %w", err)
+		}
+		if m.Path != "" {
+			if _, err := os.Stat(filepath.FromSlash(m.Path)); err == nil {
+				continue
+			}
+		}
+		missing = append(missing, m)
+	}
+	return missing, nil
+}
+
+func getMissingArtworkReport() (map[string]interface{}, error) {
+	failures, err := getImageFailures()
+	if err != nil {
+		return nil, err
+	}
+	missingCovers, err := getMissingCovers()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"failures":      failures,
+		"missingCovers": missingCovers,
+	}, nil
+}
+
+// Retries recorded failures and missing covers with a known source, returns how many are still missing
+func retryMissingArtwork(progress func(done int, total int)) (int, int, error) {
+	failures, err := getImageFailures()
+	if err != nil {
+		return 0, 0, err
+	}
+	missingCovers, err := getMissingCovers()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	queued := make(map[string]bool)
+	var downloads []imageDownload
+	for _, f := range failures {
+		if f.SourceURL == "" {
+			continue
+		}
+		queued[f.Path] = true
+		downloads = append(downloads, imageDownload{
+			UID:       f.UID,
+			AssetType: f.AssetType,
+			Source:    f.SourceURL,
+			Location:  filepath.ToSlash(filepath.Dir(f.Path)) + "/",
+			Filename:  filepath.Base(f.Path),
+		})
+	}
+	for _, m := range missingCovers {
+		if m.SourceURL == "" || m.Path == "" || queued[m.Path] {
+			continue
+		}
+		downloads = append(downloads, imageDownload{
+			UID:       m.UID,
+			AssetType: artworkCover,
+			Source:    m.SourceURL,
+			Location:  filepath.ToSlash(filepath.Dir(m.Path)) + "/",
+			Filename:  filepath.Base(m.Path),
+		})
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	done, stillMissing := 0, 0
+	for _, d := range downloads {
+		wg.Add(1)
+		go func(d imageDownload) {
+			defer wg.Done()
+			err := downloadImage(d)
+			if err == nil && isArtworkType(d.AssetType) {
+				err = txWrite(func(tx *sql.Tx) error {
+					_, err := tx.Exec(`INSERT OR IGNORE INTO Artwork (UID, Type, Path, SourceURL, Provider) VALUES (?,?,?,?,?)`,
+						d.UID, d.AssetType, d.Location+d.Filename, d.Source, "retry")
+					return err
+				})
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				stillMissing++
+			}
+			done++
+			if progress != nil {
+				progress(done, len(downloads))
+			}
+		}(d)
+	}
+	wg.Wait()
+	return len(downloads), stillMissing, nil
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Event types. Long running tasks report <task>.progress while they run and <task>.done or
+// <task>.failed at the end, with progressEvent and failedEvent payloads. Steam and PlayStation
+// imports run as jobs, they send jobInfo and jobProgress instead and also import.started and
+// import.cancelled.
+const (
+	eventGameAdded     = "game.added"
+	eventGameUpdated   = "game.updated"
+	eventGameDeleted   = "game.deleted"
+	eventGameHidden    = "game.hidden"
+	eventGameUnhidden  = "game.unhidden"
+	eventFilterChanged = "filter.changed"
+
+	eventImportStarted   = "import.started"
+	eventImportProgress  = "import.progress"
+	eventImportDone      = "import.done"
+	eventImportFailed    = "import.failed"
+	eventImportCancelled = "import.cancelled"
+	eventExportProgress  = "export.progress"
+	eventExportDone      = "export.done"
+	eventExportFailed    = "export.failed"
+
+	eventBackupDone     = "backup.done"
+	eventBackupFailed   = "backup.failed"
+	eventRestoreStarted = "restore.started"
+	eventRestoreDone    = "restore.done"
+	eventRestoreFailed  = "restore.failed"
+
+	eventScreenshotAdded      = "screenshot.added"
+	eventReencodeProgress     = "screenshots.reencode.progress"
+	eventReencodeDone         = "screenshots.reencode.done"
+	eventReencodeFailed       = "screenshots.reencode.failed"
+	eventBackfillProgress     = "images.backfill.progress"
+	eventBackfillDone         = "images.backfill.done"
+	eventArtworkRetryProgress = "artwork.retry.progress"
+	eventArtworkRetryDone     = "artwork.retry.done"
+	eventArtworkRetryFailed   = "artwork.retry.failed"
+
+	eventSessionStarted = "session.started"
+	eventSessionProcess = "session.process"
+	eventSessionEnded   = "session.ended"
+	eventSessionFailed  = "session.failed"
+)
+
+// Sources of import events besides the import sources of the v1 API, and of export events
+const (
+	importSourceLibrary          = "library"
+	importSourceSteamScreenshots = "steamScreenshots"
+	exportSourceLibrary          = "library"
+	exportSourceScreenshots      = "screenshots"
+)
+
+const (
+	eventHistorySize     = 1024
+	eventClientBuffer    = 256
+	eventKeepAlive       = 30 * time.Second
+	eventRetry           = 2 * time.Second
+	eventStreamConnected = "connected"
+	eventStreamReset     = "reset"
+)
+
+// Sent as the data of every event, the SSE id and event fields repeat ID and Type
+type event struct {
+	ID   uint64    `json:"id"`
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	Data any       `json:"data,omitempty"`
+}
+
+type gameEvent struct {
+	UID    string   `json:"uid,omitempty"`
+	UIDs   []string `json:"uids,omitempty"`
+	Name   string   `json:"name,omitempty"`
+	Source string   `json:"source,omitempty"`
+}
+
+type screenshotEvent struct {
+	UID  string `json:"uid"`
+	Path string `json:"path"`
+}
+
+type importEvent struct {
+	Source string `json:"source"`
+	Added  int    `json:"added"`
+}
+
+type backupEvent struct {
+	ID     string `json:"id"`
+	Reason string `json:"reason"`
+}
+
+type exportEvent struct {
+	Source string `json:"source"`
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+}
+
+type steamScreenshotImportEvent struct {
+	Source string `json:"source"`
+	steamScreenshotImportResult
+}
+
+type progressEvent struct {
+	Source string `json:"source,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Done   int    `json:"done"`
+	Total  int    `json:"total"`
+}
+
+type failedEvent struct {
+	Source string `json:"source,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Error  string `json:"error"`
+}
+
+// A connected stream. Its buffer absorbs bursts, a client that still falls behind is disconnected
+// and catches up from the history when it reconnects with Last-Event-ID.
+type eventClient struct {
+	events  chan event
+	matches func(event) bool
+}
+
+// Events keep IDs that increase across restarts, so a Last-Event-ID from an earlier run of the
+// backend is recognised as too old rather than matched against new events
+type eventBus struct {
+	mu     sync.Mutex
+	nextID uint64
+	// Ring of the last eventHistorySize events, head is the oldest once it is full
+	history []event
+	head    int
+	clients map[*eventClient]bool
+}
+
+var events = &eventBus{
+	nextID:  uint64(time.Now().UnixMicro()),
+	clients: make(map[*eventClient]bool),
+}
+
+// Never blocks, the event is in the history before any client sees it
+func publishEvent(eventType string, data any) {
+	events.publish(eventType, data)
+}
+
+func (b *eventBus) publish(eventType string, data any) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nextID++
+	e := event{ID: b.nextID, Type: eventType, Time: time.Now(), Data: data}
+	if len(b.history) < eventHistorySize {
+		b.history = append(b.history, e)
+	} else {
+		b.history[b.head] = e
+		b.head = (b.head + 1) % eventHistorySize
+	}
+
+	for client := range b.clients {
+		if !client.matches(e) {
+			continue
+		}
+		select {
+		case client.events <- e:
+		default:
+			log.Printf("[Events] client fell %d events behind, disconnecting it", eventClientBuffer)
+			delete(b.clients, client)
+			close(client.events)
+		}
+	}
+}
+
+// Registers a client and returns the matching events after lastID. complete is false when events
+// after lastID already left the history, the client then has to reload its state.
+func (b *eventBus) subscribe(lastID uint64, matches func(event) bool) (client *eventClient, replay []event, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	client = &eventClient{events: make(chan event, eventClientBuffer), matches: matches}
+	b.clients[client] = true
+	if lastID == 0 {
+		return client, nil, true
+	}
+	oldest := b.nextID + 1
+	if len(b.history) > 0 {
+		oldest = b.history[b.head].ID
+	}
+	complete = lastID <= b.nextID && lastID+1 >= oldest
+	for i := range b.history {
+		e := b.history[(b.head+i)%len(b.history)]
+		if e.ID > lastID && matches(e) {
+			replay = append(replay, e)
+		}
+	}
+	return client, replay, complete
+}
+
+func (b *eventBus) unsubscribe(client *eventClient) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.clients[client] {
+		delete(b.clients, client)
+		close(client.events)
+	}
+}
+
+// Matches events whose type starts with one of the prefixes, or every event without prefixes
+func eventTypeFilter(prefixes []string) func(event) bool {
+	return func(e event) bool {
+		if len(prefixes) == 0 {
+			return true
+		}
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(e.Type, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// EventSource sends Last-Event-ID when it reconnects, other clients can pass lastEventId instead
+func requestLastEventID(c *gin.Context) uint64 {
+	value := c.GetHeader("Last-Event-ID")
+	if value == "" {
+		value = c.Query("lastEventId")
+	}
+	id, _ := strconv.ParseUint(value, 10, 64)
+	return id
+}
+
+func writeEvent(c *gin.Context, e event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+	return err
+}
+
+// Events without an id, so they don't move the client's Last-Event-ID
+func writeStreamEvent(c *gin.Context, eventType string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", eventType, payload)
+	return err
+}
+
+// Streams the matching events as server-sent events until the client goes away. A reconnecting
+// client first gets what it missed, or a reset event when the history no longer reaches back that
+// far. Clients starting from scratch, new or reset, get hello first, for state they need before
+// any events.
+func streamEvents(c *gin.Context, matches func(event) bool, hello func(c *gin.Context) error) {
+	lastID := requestLastEventID(c)
+	client, replay, complete := events.subscribe(lastID, matches)
+	defer events.unsubscribe(client)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Status(http.StatusOK)
+	fmt.Fprintf(c.Writer, "retry: %d\n\n", eventRetry.Milliseconds())
+	err := writeStreamEvent(c, eventStreamConnected, gin.H{"lastEventId": lastID})
+	if err == nil && !complete {
+		err = writeStreamEvent(c, eventStreamReset, gin.H{"reason": "missed events are no longer available"})
+	}
+	if err == nil && hello != nil && (lastID == 0 || !complete) {
+		err = hello(c)
+	}
+	for _, e := range replay {
+		if err != nil {
+			break
+		}
+		err = writeEvent(c, e)
+	}
+	if err != nil {
+		return
+	}
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case e, ok := <-client.events:
+			if !ok {
+				return
+			}
+			if err := writeEvent(c, e); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case <-keepAlive.C:
+			// Comment lines keep proxies and phones from dropping an idle stream
+			if _, err := fmt.Fprint(c.Writer, ": keepalive\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
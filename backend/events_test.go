@@ -0,0 +1,125 @@
+package main
+
+import (
+	"testing"
+)
+
+func newTestEventBus() *eventBus {
+	return &eventBus{nextID: 1000, clients: make(map[*eventClient]bool)}
+}
+
+func eventIDs(list []event) []uint64 {
+	ids := make([]uint64, len(list))
+	for i, e := range list {
+		ids[i] = e.ID
+	}
+	return ids
+}
+
+func TestEventBusDeliversMatchingEvents(t *testing.T) {
+	bus := newTestEventBus()
+	client, replay, complete := bus.subscribe(0, eventTypeFilter([]string{"import."}))
+	defer bus.unsubscribe(client)
+	if len(replay) != 0 || !complete {
+		t.Fatalf("a new client should get no replay, got %d events complete=%v", len(replay), complete)
+	}
+
+	bus.publish(eventGameAdded, gameEvent{UID: "a"})
+	bus.publish(eventImportStarted, nil)
+	bus.publish(eventImportDone, importEvent{Source: "steam", Added: 2})
+
+	for _, want := range []string{eventImportStarted, eventImportDone} {
+		select {
+		case e := <-client.events:
+			if e.Type != want {
+				t.Errorf("got %s, want %s", e.Type, want)
+			}
+		default:
+			t.Fatalf("missing %s", want)
+		}
+	}
+	select {
+	case e := <-client.events:
+		t.Errorf("unexpected event %s", e.Type)
+	default:
+	}
+}
+
+func TestEventBusReplaysMissedEvents(t *testing.T) {
+	bus := newTestEventBus()
+	bus.publish(eventGameAdded, nil)
+	bus.publish(eventImportStarted, nil)
+	bus.publish(eventGameDeleted, nil)
+	bus.publish(eventImportDone, nil)
+
+	// The client saw the first event before it disconnected
+	client, replay, complete := bus.subscribe(1001, eventTypeFilter([]string{"import."}))
+	defer bus.unsubscribe(client)
+	if !complete {
+		t.Error("replay should be complete while the history reaches back far enough")
+	}
+	ids := eventIDs(replay)
+	if len(ids) != 2 || ids[0] != 1002 || ids[1] != 1004 {
+		t.Errorf("replayed %v, want [1002 1004]", ids)
+	}
+
+	client2, replay, complete := bus.subscribe(1004, eventTypeFilter(nil))
+	defer bus.unsubscribe(client2)
+	if !complete || len(replay) != 0 {
+		t.Errorf("an up to date client should get nothing, got %v complete=%v", eventIDs(replay), complete)
+	}
+}
+
+func TestEventBusReportsGapsInTheHistory(t *testing.T) {
+	bus := newTestEventBus()
+	for i := 0; i < eventHistorySize+10; i++ {
+		bus.publish(eventGameUpdated, nil)
+	}
+	oldest := bus.history[bus.head].ID
+	if oldest != 1011 {
+		t.Fatalf("oldest kept event is %d, want 1011", oldest)
+	}
+
+	client, replay, complete := bus.subscribe(1005, eventTypeFilter(nil))
+	bus.unsubscribe(client)
+	if complete {
+		t.Error("events that left the history should make the replay incomplete")
+	}
+	ids := eventIDs(replay)
+	if len(ids) != eventHistorySize || ids[0] != oldest || ids[len(ids)-1] != bus.nextID {
+		t.Errorf("replay should be the whole history in order, got %d events from %d", len(ids), ids[0])
+	}
+
+	client, _, complete = bus.subscribe(oldest-1, eventTypeFilter(nil))
+	bus.unsubscribe(client)
+	if !complete {
+		t.Error("a client that saw the event right before the oldest one missed nothing")
+	}
+
+	// An ID from a later run, or made up, can't be matched
+	client, replay, complete = bus.subscribe(bus.nextID+50, eventTypeFilter(nil))
+	bus.unsubscribe(client)
+	if complete || len(replay) != 0 {
+		t.Errorf("an unknown future ID should reset the client, got %d events complete=%v", len(replay), complete)
+	}
+}
+
+func TestEventBusDisconnectsSlowClients(t *testing.T) {
+	bus := newTestEventBus()
+	client, _, _ := bus.subscribe(0, eventTypeFilter(nil))
+	for i := 0; i < eventClientBuffer+1; i++ {
+		bus.publish(eventGameUpdated, nil)
+	}
+	if bus.clients[client] {
+		t.Fatal("a client that fell behind should be dropped")
+	}
+	received := 0
+	for range client.events {
+		received++
+	}
+	if received != eventClientBuffer {
+		t.Errorf("received %d buffered events, want %d", received, eventClientBuffer)
+	}
+	// Unsubscribing a dropped client must not close its channel twice
+	bus.unsubscribe(client)
+}
@@ -1,6 +1,9 @@
 package main
 
-import "time"
+import (
+	"sync"
+	"time"
+)
 
 type FilterStruct struct {
 	Tags      []string `json:"tags"`
@@ -215,6 +218,7 @@ type igdbSearchResult []struct {
 	ExternalGames         []int   `json:"external_games"`
 	FirstReleaseDate      int     `json:"first_release_date,omitempty"`
 	Franchises            []int   `json:"franchises,omitempty"`
+	Franchise             int     `json:"franchise,omitempty"`
 	GameEngines           []int   `json:"game_engines,omitempty"`
 	Genres                []int   `json:"genres,omitempty"`
 	Hypes                 int     `json:"hypes,omitempty"`
@@ -279,10 +283,13 @@ type SteamWishlistStruct struct {
 	} `json:"response"`
 }
 
+// Set at build time or by initAPIKeys, read them through getIGDBCreds
 var clientID string
 var clientSecret string
+var igdbCredsMu sync.RWMutex
 
 type igdbMetaData struct {
+	IgdbID             int
 	Name               string
 	UID                string
 	Summary            string
@@ -295,6 +302,42 @@ type igdbMetaData struct {
 	PlayerPerspectives TagsStruct
 	Genres             TagsStruct
 	GameModes          TagsStruct
+	GameEngines        TagsStruct
+	Franchises         TagsStruct
+	Collections        TagsStruct
+	SimilarGames       TagsStruct
+	AgeRatings         []igdbAgeRating
+	Websites           []igdbWebsite
+}
+
+type igdbAgeRating struct {
+	Organization string `json:"organization"`
+	Rating       string `json:"rating"`
+}
+
+type igdbWebsite struct {
+	Category string `json:"category"`
+	URL      string `json:"url"`
+}
+
+// Typed IGDB metadata as handed to clients by /GetIgdbInfo and /GameDetails, and sent back with /addGameToDB
+type igdbTypedInfo struct {
+	IgdbID             int                    `json:"igdbID"`
+	Genres             []string               `json:"genres"`
+	GameModes          []string               `json:"gameModes"`
+	PlayerPerspectives []string               `json:"playerPerspectives"`
+	Themes             []string               `json:"themes"`
+	GameEngines        []string               `json:"gameEngines"`
+	Franchises         []string               `json:"franchises"`
+	Collections        []string               `json:"collections"`
+	AgeRatings         []igdbAgeRating        `json:"ageRatings"`
+	Websites           []igdbWebsite          `json:"websites"`
+	SimilarGames       []igdbTypedSimilarGame `json:"similarGames"`
+}
+
+type igdbTypedSimilarGame struct {
+	IgdbID int    `json:"igdbID"`
+	Name   string `json:"name"`
 }
 
 var PsGameStruct struct {
@@ -418,4 +461,7 @@ type IGDBInsertGameReturn struct {
 	CoverImage  string   `json:"coverImage"`
 	SSImage     []string `json:"ssImage"`
 	IsWishlist  int      `json:"isWishlist"`
+	IgdbID      int      `json:"igdbID"`
+	// Typed metadata from /GetIgdbInfo, saves a second IGDB lookup on insert
+	Igdb *igdbTypedInfo `json:"igdb"`
 }
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A candidate image from a search, URL can be handed to setCustomImage or setArtwork as is
+type imageCandidate struct {
+	URL    string `json:"url"`
+	Thumb  string `json:"thumb"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	Kind   string `json:"kind"`
+	Source string `json:"source"`
+}
+
+// UID resolves the game through stored IDs where possible, Term is used otherwise
+type imageSearchQuery struct {
+	UID   string
+	Term  string
+	Limit int
+}
+
+// An imageSearchProvider finds screenshots and artwork for a game
+type imageSearchProvider interface {
+	Name() string
+	SearchImages(query imageSearchQuery) ([]imageCandidate, error)
+}
+
+const imageSearchDefaultLimit = 30
+
+var imageSearchClient = &http.Client{Timeout: 15 * time.Second}
+
+// Providers that need credentials are skipped when they are not configured. The mock provider
+// only answers when asked for by name.
+func getImageSearchProviders(name string) ([]imageSearchProvider, error) {
+	if name == "mock" {
+		return []imageSearchProvider{mockImageSearch{}}, nil
+	}
+	var providers []imageSearchProvider
+	if name == "" || name == "igdb" {
+		if id, secret := getIGDBCreds(); id != "" && secret != "" {
+			providers = append(providers, igdbImageSearch{})
+		} else if name == "igdb" {
+			return nil, fmt.Errorf("igdb credentials not configured")
+		}
+	}
+	if name == "" || name == "steam" {
+		providers = append(providers, steamStoreImageSearch{})
+	}
+	if name == "" || name == "steamgriddb" {
+		provider, err := newSteamGridDBProvider()
+		if err == nil {
+			providers = append(providers, provider)
+		} else if name == "steamgriddb" {
+			return nil, err
+		}
+	}
+	if len(providers) == 0 {
+		return nil, fmt.Errorf("unknown image search provider %s", name)
+	}
+	return providers, nil
+}
+
+// Queries the providers in parallel, a failing provider is reported without failing the search
+func searchImages(providerName string, query imageSearchQuery) ([]imageCandidate, map[string]string, error) {
+	if query.UID == "" && query.Term == "" {
+		return nil, nil, fmt.Errorf("uid or term is required")
+	}
+	if query.Term == "" {
+		title, err := getGameTitle(query.UID)
+		if err != nil {
+			return nil, nil, err
+		}
+		query.Term = title
+	}
+	if query.Limit < 1 {
+		query.Limit = imageSearchDefaultLimit
+	}
+	providers, err := getImageSearchProviders(providerName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	results := make([][]imageCandidate, len(providers))
+	errs := make([]error, len(providers))
+	var wg sync.WaitGroup
+	for i, provider := range providers {
+		wg.Add(1)
+		go func(i int, provider imageSearchProvider) {
+			defer wg.Done()
+			results[i], errs[i] = provider.SearchImages(query)
+		}(i, provider)
+	}
+	wg.Wait()
+
+	candidates := []imageCandidate{}
+	failures := make(map[string]string)
+	for i, provider := range providers {
+		if errs[i] != nil {
+			failures[provider.Name()] = errs[i].Error()
+			continue
+		}
+		if len(results[i]) > query.Limit {
+			results[i] = results[i][:query.Limit]
+		}
+		candidates = append(candidates, results[i]...)
+	}
+	return candidates, failures, nil
+}
+
+func getGameTitle(uid string) (string, error) {
+	var name string
+	err := readDB.QueryRow(`SELECT CASE WHEN gp.UseCustomTitle = 1 THEN gp.CustomTitle ELSE gmd.Name END
+		FROM GameMetaData gmd LEFT JOIN GamePreferences gp ON gmd.UID = gp.UID WHERE gmd.UID = ?`, uid).Scan(&name)
+	if err != nil {
+		return "", fmt.Errorf("error getting game name: %w", err)
+	}
+	return name, nil
+}
+
+func getJSON(getURL string, result interface{}) error {
+	resp, err := imageSearchClient.Get(getURL)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	err = json.Unmarshal(body, result)
+	if err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
+
+// IGDB screenshots and artworks for the game's stored IGDB id, else the game is matched by title
+type igdbImageSearch struct{}
+
+func (igdbImageSearch) Name() string {
+	return "igdb"
+}
+
+func (p igdbImageSearch) SearchImages(query imageSearchQuery) ([]imageCandidate, error) {
+	ctx := context.Background()
+	accessToken, err := getAccessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	gameID := 0
+	if query.UID != "" {
+		gameID, err = getStoredIgdbID(query.UID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if gameID == 0 {
+		gameID, err = p.findGame(ctx, query.Term, accessToken)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	candidates := []imageCandidate{}
+	for _, endpoint := range []struct{ kind, url string }{
+		{"screenshot", "https://api.igdb.com/v4/screenshots"},
+		{"artwork", "https://api.igdb.com/v4/artworks"},
+	} {
+		body, err := post(ctx, endpoint.url, fmt.Sprintf(`fields image_id,width,height; where game=%d; limit %d;`, gameID, query.Limit), accessToken)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch igdb %ss: %w", endpoint.kind, err)
+		}
+		var images []struct {
+			ImageID string `json:"image_id"`
+			Width   int    `json:"width"`
+			Height  int    `json:"height"`
+		}
+		err = json.Unmarshal(body, &images)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal igdb %ss: %w", endpoint.kind, err)
+		}
+		for _, img := range images {
+			candidates = append(candidates, imageCandidate{
+				URL:    fmt.Sprintf("https://images.igdb.com/igdb/image/upload/t_1080p/%s.jpg", img.ImageID),
+				Thumb:  fmt.Sprintf("https://images.igdb.com/igdb/image/upload/t_screenshot_med/%s.jpg", img.ImageID),
+				Width:  img.Width,
+				Height: img.Height,
+				Kind:   endpoint.kind,
+				Source: p.Name(),
+			})
+		}
+	}
+	return candidates, nil
+}
+
+func (igdbImageSearch) findGame(ctx context.Context, term string, accessToken string) (int, error) {
+	term = strings.ReplaceAll(term, `"`, "")
+	body, err := post(ctx, "https://api.igdb.com/v4/games", fmt.Sprintf(`fields id; search "%s"; limit 1;`, term), accessToken)
+	if err != nil {
+		return 0, fmt.Errorf("failed to search igdb: %w", err)
+	}
+	var games []struct {
+		ID int `json:"id"`
+	}
+	err = json.Unmarshal(body, &games)
+	if err != nil {
+		return 0, fmt.Errorf("failed to unmarshal igdb search: %w", err)
+	}
+	if len(games) == 0 {
+		return 0, fmt.Errorf("no igdb match for %s", term)
+	}
+	return games[0].ID, nil
+}
+
+// Screenshots from the Steam store page, through the stored AppID or a store search
+type steamStoreImageSearch struct{}
+
+// STEAM_STORE_BASE_URL points the search at a local stub
+func steamStoreBaseURL() string {
+	if baseURL := os.Getenv("STEAM_STORE_BASE_URL"); baseURL != "" {
+		return strings.TrimSuffix(baseURL, "/")
+	}
+	return "https://store.steampowered.com"
+}
+
+func (steamStoreImageSearch) Name() string {
+	return "steam"
+}
+
+func (p steamStoreImageSearch) SearchImages(query imageSearchQuery) ([]imageCandidate, error) {
+	appid := 0
+	if query.UID != "" {
+		var err error
+		appid, err = getSteamAppID(query.UID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if appid == 0 {
+		var search struct {
+			Items []struct {
+				ID int `json:"id"`
+			} `json:"items"`
+		}
+		err := getJSON(steamStoreBaseURL()+"/api/storesearch/?l=english&cc=us&term="+url.QueryEscape(query.Term), &search)
+		if err != nil {
+			return nil, fmt.Errorf("steam store search error: %w", err)
+		}
+		if len(search.Items) == 0 {
+			return nil, fmt.Errorf("no steam match for %s", query.Term)
+		}
+		appid = search.Items[0].ID
+	}
+
+	var details map[string]SteamGameMetadataStruct
+	err := getJSON(fmt.Sprintf("%s/api/appdetails?appids=%d&l=english", steamStoreBaseURL(), appid), &details)
+	if err != nil {
+		return nil, fmt.Errorf("steam appdetails error: %w", err)
+	}
+	app, ok := details[fmt.Sprint(appid)]
+	if !ok || !app.Success {
+		return nil, fmt.Errorf("steam has no details for app %d", appid)
+	}
+
+	candidates := []imageCandidate{}
+	for _, screenshot := range app.Data.Screenshots {
+		candidates = append(candidates, imageCandidate{
+			URL:    screenshot.PathFull,
+			Thumb:  screenshot.PathThumbnail,
+			Kind:   "screenshot",
+			Source: p.Name(),
+		})
+	}
+	return candidates, nil
+}
+
+// Heroes and grids, SteamGridDB has no screenshots
+func (p *steamGridDBProvider) SearchImages(query imageSearchQuery) ([]imageCandidate, error) {
+	gameID := 0
+	if query.UID != "" {
+		id, err := p.gameIDForUID(query.UID)
+		if err == nil {
+			gameID = id
+		}
+	}
+	if gameID == 0 {
+		games, err := p.searchGames(query.Term)
+		if err != nil {
+			return nil, err
+		}
+		if len(games) == 0 {
+			return nil, fmt.Errorf("no steamgriddb match for %s", query.Term)
+		}
+		gameID = games[0].ID
+	}
+
+	candidates := []imageCandidate{}
+	for _, assetType := range []string{artworkHero, artworkCover} {
+		artwork, err := p.FindArtwork(query.UID, assetType, gameID)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range artwork {
+			candidates = append(candidates, imageCandidate{
+				URL:    a.URL,
+				Thumb:  a.Thumb,
+				Width:  a.Width,
+				Height: a.Height,
+				Kind:   assetType,
+				Source: p.Name(),
+			})
+		}
+	}
+	return candidates, nil
+}
+
+// Deterministic solid colour images served as data URLs, so the frontend and tests work offline
+type mockImageSearch struct{}
+
+func (mockImageSearch) Name() string {
+	return "mock"
+}
+
+func (p mockImageSearch) SearchImages(query imageSearchQuery) ([]imageCandidate, error) {
+	palette := []color.RGBA{{200, 40, 40, 255}, {40, 160, 60, 255}, {40, 80, 200, 255}}
+	candidates := []imageCandidate{}
+	for i := 0; i < min(query.Limit, len(palette)); i++ {
+		img := image.NewRGBA(image.Rect(0, 0, 64, 36))
+		for x := 0; x < 64; x++ {
+			for y := 0; y < 36; y++ {
+				img.Set(x, y, palette[i])
+			}
+		}
+		var buf bytes.Buffer
+		err := png.Encode(&buf, img)
+		if err != nil {
+			return nil, err
+		}
+		dataURL := "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+		candidates = append(candidates, imageCandidate{
+			URL:    dataURL,
+			Thumb:  dataURL,
+			Width:  64,
+			Height: 36,
+			Kind:   "screenshot",
+			Source: p.Name(),
+		})
+	}
+	return candidates, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestMockImageSearchReturnsDecodableImages(t *testing.T) {
+	candidates, failures, err := searchImages("mock", imageSearchQuery{Term: "Any Game", Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failures) != 0 {
+		t.Fatalf("unexpected provider failures %v", failures)
+	}
+	if len(candidates) != 2 {
+		t.Fatalf("got %d candidates, want 2", len(candidates))
+	}
+
+	colors := make(map[uint32]bool)
+	for _, candidate := range candidates {
+		if candidate.Source != "mock" || candidate.Kind != "screenshot" {
+			t.Errorf("unexpected candidate %+v", candidate)
+		}
+		data, ok := strings.CutPrefix(candidate.URL, "data:image/png;base64,")
+		if !ok {
+			t.Fatalf("expected a png data url, got %.40s", candidate.URL)
+		}
+		raw, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := png.Decode(bytes.NewReader(raw))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b := img.Bounds(); b.Dx() != candidate.Width || b.Dy() != candidate.Height {
+			t.Errorf("image is %dx%d, candidate says %dx%d", b.Dx(), b.Dy(), candidate.Width, candidate.Height)
+		}
+		r, g, b, _ := img.At(0, 0).RGBA()
+		colors[r<<16|g<<8|b] = true
+	}
+	if len(colors) != len(candidates) {
+		t.Errorf("mock candidates should have distinct colours")
+	}
+}
+
+func TestMockImageSearchLimit(t *testing.T) {
+	candidates, _, err := searchImages("mock", imageSearchQuery{Term: "Any Game", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != 3 {
+		t.Errorf("got %d candidates, the mock palette has 3", len(candidates))
+	}
+}
+
+func TestImageSearchNeedsUIDOrTerm(t *testing.T) {
+	_, _, err := searchImages("mock", imageSearchQuery{Limit: 2})
+	if err == nil {
+		t.Error("expected an error without uid or term")
+	}
+}
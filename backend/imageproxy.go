@@ -0,0 +1,620 @@
+package main
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const imageProxyCacheDir = "imageCache"
+
+// Settings keys for the image proxy
+const (
+	imageProxyHostsSetting   = "ImageProxyAllowedHosts"
+	imageProxyMaxSizeSetting = "ImageProxyCacheMB"
+)
+
+const (
+	imageProxyDefaultCacheMB = 256
+	imageProxyMaxImageBytes  = 10 << 20
+	imageProxyDefaultMaxAge  = 24 * time.Hour
+)
+
+// Hosts used by the IGDB, Steam, SteamGridDB, PlayStation and Google image results,
+// a leading dot matches every subdomain
+var imageProxyDefaultHosts = []string{
+	"images.igdb.com",
+	".steamstatic.com",
+	".akamaihd.net",
+	".steamgriddb.com",
+	".gstatic.com",
+	".googleusercontent.com",
+	"image.api.playstation.com",
+}
+
+type imageProxyEntry struct {
+	URL          string    `json:"url"`
+	ContentType  string    `json:"contentType"`
+	ETag         string    `json:"etag"`
+	LastModified string    `json:"lastModified"`
+	Size         int64     `json:"size"`
+	FetchedAt    time.Time `json:"fetchedAt"`
+	MaxAge       int64     `json:"maxAge"`
+	key          string
+}
+
+// Size bounded LRU over the files in imageCache/, the list front is the most recently used entry
+type imageProxyCache struct {
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+	size    int64
+	loaded  bool
+	keys    map[string]*imageProxyKeyState
+}
+
+// Held while a key is fetched or served. Eviction skips keys with a state so a file being
+// served stays on disk, the state is dropped when the last holder releases it.
+type imageProxyKeyState struct {
+	mu   sync.Mutex
+	refs int
+}
+
+var proxyCache = &imageProxyCache{order: list.New(), entries: make(map[string]*list.Element), keys: make(map[string]*imageProxyKeyState)}
+
+var imageProxyClient = &http.Client{
+	Timeout: 15 * time.Second,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 3 {
+			return fmt.Errorf("too many redirects")
+		}
+		if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+			return fmt.Errorf("invalid redirect scheme")
+		}
+		if !isAllowedImageHost(req.URL.Hostname()) {
+			return fmt.Errorf("redirect to host %s not allowed", req.URL.Hostname())
+		}
+		return nil
+	},
+}
+
+func imageProxyKey(imageURL string) string {
+	hash := sha256.Sum256([]byte(imageURL))
+	return hex.EncodeToString(hash[:])
+}
+
+func imageProxyDataPath(key string) string {
+	return filepath.Join(imageProxyCacheDir, key+".bin")
+}
+
+func imageProxyMetaPath(key string) string {
+	return filepath.Join(imageProxyCacheDir, key+".json")
+}
+
+func getImageProxyHosts() ([]string, error) {
+	value, err := getSetting(imageProxyHostsSetting)
+	if err != nil {
+		return nil, err
+	}
+	if value == "" {
+		return imageProxyDefaultHosts, nil
+	}
+	var hosts []string
+	for _, host := range strings.Split(value, ",") {
+		host = strings.ToLower(strings.TrimSpace(host))
+		if host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts, nil
+}
+
+func getImageProxyMaxBytes() int64 {
+	value, err := getSetting(imageProxyMaxSizeSetting)
+	if err != nil || value == "" {
+		return imageProxyDefaultCacheMB << 20
+	}
+	mb, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || mb <= 0 {
+		return imageProxyDefaultCacheMB << 20
+	}
+	return mb << 20
+}
+
+func isAllowedImageHost(host string) bool {
+	hosts, err := getImageProxyHosts()
+	if err != nil {
+		log.Printf("[ImageProxy] ERROR : %v", err)
+		return false
+	}
+	host = strings.ToLower(host)
+	for _, allowed := range hosts {
+		if allowed == "*" || host == allowed || host == strings.TrimPrefix(allowed, ".") {
+			return true
+		}
+		if strings.HasPrefix(allowed, ".") && strings.HasSuffix(host, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// Rebuilds the LRU from disk once, oldest fetch first
+func (pc *imageProxyCache) load() {
+	if pc.loaded {
+		return
+	}
+	pc.loaded = true
+
+	files, err := filepath.Glob(filepath.Join(imageProxyCacheDir, "*.json"))
+	if err != nil {
+		return
+	}
+	var entries []*imageProxyEntry
+	for _, file := range files {
+		entry, err := readImageProxyEntry(strings.TrimSuffix(filepath.Base(file), ".json"))
+		if err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].FetchedAt.Before(entries[j].FetchedAt)
+	})
+	for _, entry := range entries {
+		pc.entries[entry.key] = pc.order.PushFront(entry)
+		pc.size += entry.Size
+	}
+}
+
+func readImageProxyEntry(key string) (*imageProxyEntry, error) {
+	data, err := os.ReadFile(imageProxyMetaPath(key))
+	if err != nil {
+		return nil, err
+	}
+	var entry imageProxyEntry
+	err = json.Unmarshal(data, &entry)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(imageProxyDataPath(key)); err != nil {
+		return nil, err
+	}
+	entry.key = key
+	return &entry, nil
+}
+
+func (pc *imageProxyCache) get(key string) *imageProxyEntry {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.load()
+	elem, ok := pc.entries[key]
+	if !ok {
+		return nil
+	}
+	pc.order.MoveToFront(elem)
+	entry := *elem.Value.(*imageProxyEntry)
+	return &entry
+}
+
+func (pc *imageProxyCache) put(entry *imageProxyEntry) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.load()
+	if elem, ok := pc.entries[entry.key]; ok {
+		pc.size -= elem.Value.(*imageProxyEntry).Size
+		pc.order.Remove(elem)
+	}
+	pc.entries[entry.key] = pc.order.PushFront(entry)
+	pc.size += entry.Size
+
+	maxBytes := getImageProxyMaxBytes()
+	elem := pc.order.Back()
+	for pc.size > maxBytes && elem != pc.order.Front() {
+		prev := elem.Prev()
+		evicted := elem.Value.(*imageProxyEntry)
+		if _, inUse := pc.keys[evicted.key]; !inUse {
+			pc.order.Remove(elem)
+			delete(pc.entries, evicted.key)
+			pc.size -= evicted.Size
+			os.Remove(imageProxyDataPath(evicted.key))
+			os.Remove(imageProxyMetaPath(evicted.key))
+		}
+		elem = prev
+	}
+}
+
+func (pc *imageProxyCache) stats() map[string]interface{} {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.load()
+	return map[string]interface{}{
+		"entries":  pc.order.Len(),
+		"size":     pc.size,
+		"maxBytes": getImageProxyMaxBytes(),
+	}
+}
+
+func (pc *imageProxyCache) acquire(key string) *imageProxyKeyState {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	state, ok := pc.keys[key]
+	if !ok {
+		state = &imageProxyKeyState{}
+		pc.keys[key] = state
+	}
+	state.refs++
+	return state
+}
+
+func (pc *imageProxyCache) release(key string) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	state := pc.keys[key]
+	state.refs--
+	if state.refs == 0 {
+		delete(pc.keys, key)
+	}
+}
+
+// Pins the key against eviction until the returned func is called
+func (pc *imageProxyCache) pin(key string) func() {
+	pc.acquire(key)
+	return func() { pc.release(key) }
+}
+
+// Serializes fetches of one key, the key is pinned while it is locked
+func (pc *imageProxyCache) lock(key string) func() {
+	state := pc.acquire(key)
+	state.mu.Lock()
+	return func() {
+		state.mu.Unlock()
+		pc.release(key)
+	}
+}
+
+func writeImageProxyEntry(entry *imageProxyEntry, body io.Reader) error {
+	err := os.MkdirAll(imageProxyCacheDir, 0755)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(imageProxyCacheDir, ".proxy-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	size, err := io.Copy(tmp, io.LimitReader(body, imageProxyMaxImageBytes+1))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if size > imageProxyMaxImageBytes {
+		return fmt.Errorf("image larger than %d bytes", imageProxyMaxImageBytes)
+	}
+	entry.Size = size
+
+	err = os.Chmod(tmp.Name(), 0644)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmp.Name(), imageProxyDataPath(entry.key))
+	if err != nil {
+		return err
+	}
+	return saveImageProxyMeta(entry)
+}
+
+func saveImageProxyMeta(entry *imageProxyEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(imageProxyMetaPath(entry.key), data, 0644)
+}
+
+func upstreamMaxAge(header http.Header) int64 {
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		directive = strings.TrimSpace(directive)
+		if directive == "no-cache" || directive == "no-store" {
+			return 0
+		}
+		if value, ok := strings.CutPrefix(directive, "max-age="); ok {
+			if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+				return seconds
+			}
+		}
+	}
+	return int64(imageProxyDefaultMaxAge.Seconds())
+}
+
+// Returns a cached copy of the image opened for reading, fetching or revalidating it upstream
+// when needed. The file is opened before the key is unlocked so a concurrent refetch can't swap
+// it. The status is HIT, MISS, REVALIDATED or STALE for the X-Cache header.
+func fetchProxiedImage(imageURL string) (*imageProxyEntry, *os.File, string, error) {
+	key := imageProxyKey(imageURL)
+	unlock := proxyCache.lock(key)
+	defer unlock()
+
+	entry, cacheStatus, err := refreshProxiedImage(key, imageURL)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	file, err := os.Open(imageProxyDataPath(key))
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("%w: %v", errCachedImageMissing, err)
+	}
+	return entry, file, cacheStatus, nil
+}
+
+var errCachedImageMissing = fmt.Errorf("cached image missing")
+
+// Must be called with the key locked
+func refreshProxiedImage(key string, imageURL string) (*imageProxyEntry, string, error) {
+	cached := proxyCache.get(key)
+	if cached != nil && time.Since(cached.FetchedAt) < time.Duration(cached.MaxAge)*time.Second {
+		return cached, "HIT", nil
+	}
+
+	req, err := http.NewRequest("GET", imageURL, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header = http.Header{
+		"User-Agent":      {"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"},
+		"Referer":         {"https://www.google.com/"},
+		"Origin":          {"https://www.google.com"},
+		"Accept":          {"image/webp,image/apng,image/*,*/*;q=0.8"},
+		"Accept-Language": {"en-US,en;q=0.9"},
+		"Sec-Fetch-Dest":  {"image"},
+		"Sec-Fetch-Mode":  {"no-cors"},
+		"Sec-Fetch-Site":  {"cross-site"},
+	}
+	if cached != nil {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	resp, err := imageProxyClient.Do(req)
+	if err != nil {
+		if cached != nil {
+			log.Printf("[ImageProxy] serving stale %s: %v", imageURL, err)
+			return cached, "STALE", nil
+		}
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		cached.FetchedAt = time.Now()
+		cached.MaxAge = upstreamMaxAge(resp.Header)
+		err = saveImageProxyMeta(cached)
+		if err != nil {
+			log.Printf("[ImageProxy] ERROR : %v", err)
+		}
+		proxyCache.put(cached)
+		return cached, "REVALIDATED", nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		if cached != nil && resp.StatusCode >= 500 {
+			return cached, "STALE", nil
+		}
+		return nil, "", upstreamStatusError{resp.StatusCode}
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if !strings.HasPrefix(contentType, "image/") {
+		return nil, "", fmt.Errorf("url does not point to an image: %s", contentType)
+	}
+	if resp.ContentLength > imageProxyMaxImageBytes {
+		return nil, "", fmt.Errorf("image too large: %d bytes", resp.ContentLength)
+	}
+
+	entry := &imageProxyEntry{
+		URL:          imageURL,
+		ContentType:  contentType,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		FetchedAt:    time.Now(),
+		MaxAge:       upstreamMaxAge(resp.Header),
+		key:          key,
+	}
+	err = writeImageProxyEntry(entry, resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to cache image: %w", err)
+	}
+	proxyCache.put(entry)
+	return entry, "MISS", nil
+}
+
+type upstreamStatusError struct {
+	status int
+}
+
+func (e upstreamStatusError) Error() string {
+	return fmt.Sprintf("upstream server error: %d", e.status)
+}
+
+// Accepts the escaped forms the frontend produces and checks scheme and host
+func parseProxyURL(encodedURL string) (string, error) {
+	imageURL, err := url.QueryUnescape(encodedURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid url encoding")
+	}
+	imageURL = strings.ReplaceAll(imageURL, `\u003d`, "=")
+	imageURL = strings.ReplaceAll(imageURL, `\u0026`, "&")
+
+	parsedURL, err := url.ParseRequestURI(imageURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid url")
+	}
+	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+		return "", fmt.Errorf("invalid url scheme")
+	}
+	if !isAllowedImageHost(parsedURL.Hostname()) {
+		return "", errImageHostNotAllowed
+	}
+	return imageURL, nil
+}
+
+var errImageHostNotAllowed = fmt.Errorf("domain not allowed")
+
+func imageProxyHandler(c *gin.Context) {
+	encodedURL := c.Query("url")
+	if encodedURL == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing url parameter"})
+		return
+	}
+	imageURL, err := parseProxyURL(encodedURL)
+	if err == errImageHostNotAllowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Keeps the file on disk until it has been sent, eviction would delete it under an open handle
+	unpin := proxyCache.pin(imageProxyKey(imageURL))
+	defer unpin()
+
+	entry, file, cacheStatus, err := fetchProxiedImage(imageURL)
+	if err != nil {
+		log.Printf("[ImageProxy] ERROR %s: %v", imageURL, err)
+		if errors.Is(err, errCachedImageMissing) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "cached image missing"})
+		} else if statusErr, ok := err.(upstreamStatusError); ok {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "upstream server error", "status": statusErr.status})
+		} else if strings.Contains(err.Error(), "Timeout") || strings.Contains(err.Error(), "timeout") {
+			c.JSON(http.StatusGatewayTimeout, gin.H{"error": "upstream timeout"})
+		} else {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "failed to fetch image"})
+		}
+		return
+	}
+	defer file.Close()
+
+	etag := fmt.Sprintf(`"%s-%x"`, entry.key[:16], entry.Size)
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("X-Cache", cacheStatus)
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.DataFromReader(http.StatusOK, entry.Size, entry.ContentType, file, nil)
+}
+
+// Fetches a list of URLs into the cache in the background, returns how many were accepted
+func prewarmImageProxy(urls []string) int {
+	var accepted []string
+	for _, rawURL := range urls {
+		imageURL, err := parseProxyURL(url.QueryEscape(rawURL))
+		if err != nil {
+			continue
+		}
+		accepted = append(accepted, imageURL)
+	}
+
+	go func() {
+		slots := make(chan struct{}, 4)
+		var wg sync.WaitGroup
+		for _, imageURL := range accepted {
+			wg.Add(1)
+			slots <- struct{}{}
+			go func(imageURL string) {
+				defer wg.Done()
+				defer func() { <-slots }()
+				_, file, _, err := fetchProxiedImage(imageURL)
+				if err != nil {
+					log.Printf("[ImageProxy] prewarm failed %s: %v", imageURL, err)
+					return
+				}
+				file.Close()
+			}(imageURL)
+		}
+		wg.Wait()
+	}()
+	return len(accepted)
+}
+
+func registerImageProxyRoutes(r *gin.Engine) {
+	r.GET("/image-proxy", imageProxyHandler)
+
+	r.POST("/image-proxy/prewarm", func(c *gin.Context) {
+		var data struct {
+			URLs []string `json:"urls"`
+		}
+		if err := c.BindJSON(&data); err != nil {
+			log.Printf("[ImageProxyPrewarm] ERROR invalid req payload: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		accepted := prewarmImageProxy(data.URLs)
+		c.JSON(http.StatusAccepted, gin.H{"accepted": accepted, "rejected": len(data.URLs) - accepted})
+	})
+
+	r.GET("/image-proxy/settings", func(c *gin.Context) {
+		hosts, err := getImageProxyHosts()
+		if err != nil {
+			log.Printf("[ImageProxySettings] ERROR : %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get image proxy settings", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"allowedHosts": hosts, "cache": proxyCache.stats()})
+	})
+
+	r.POST("/image-proxy/settings", func(c *gin.Context) {
+		var data struct {
+			AllowedHosts []string `json:"allowedHosts"`
+			MaxCacheMB   int      `json:"maxCacheMB"`
+		}
+		if err := c.BindJSON(&data); err != nil {
+			log.Printf("[ImageProxySettings] ERROR invalid req payload: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if data.AllowedHosts != nil {
+			err := setSetting(imageProxyHostsSetting, strings.Join(data.AllowedHosts, ","))
+			if err != nil {
+				log.Printf("[ImageProxySettings] ERROR : %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save allowed hosts", "details": err.Error()})
+				return
+			}
+		}
+		if data.MaxCacheMB > 0 {
+			err := setSetting(imageProxyMaxSizeSetting, strconv.Itoa(data.MaxCacheMB))
+			if err != nil {
+				log.Printf("[ImageProxySettings] ERROR : %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save cache size", "details": err.Error()})
+				return
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "OK"})
+	})
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/HugoSmits86/nativewebp"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/image/draw"
+)
+
+// Folders holding original images, variants mirror this layout under thumbnails/<variant>/
+var imageRoots = []string{"coverArt", "screenshots", "artwork"}
+
+const imageVariantsDir = "thumbnails"
+
+// Variants are bounded boxes, images are never upscaled
+var imageVariants = map[string]image.Point{
+	"thumb":  {X: 320, Y: 480},
+	"medium": {X: 960, Y: 960},
+}
+
+var imageVariantLocks sync.Map
+
+func isImageRoot(root string) bool {
+	for _, r := range imageRoots {
+		if r == root {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the cleaned relative path of an original image or an error if it escapes the image roots
+func cleanImagePath(relPath string) (string, error) {
+	cleaned := path.Clean("/" + strings.TrimPrefix(relPath, "./backend/"))
+	cleaned = strings.TrimPrefix(cleaned, "/")
+	root := strings.SplitN(cleaned, "/", 2)[0]
+	if !isImageRoot(root) || cleaned == root {
+		return "", fmt.Errorf("invalid image path %s", relPath)
+	}
+	return cleaned, nil
+}
+
+func imageVariantPath(relPath string, variant string) string {
+	return filepath.Join(imageVariantsDir, variant, filepath.FromSlash(relPath))
+}
+
+func lockImageVariants(relPath string) func() {
+	lock, _ := imageVariantLocks.LoadOrStore(relPath, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	return lock.(*sync.Mutex).Unlock
+}
+
+// Writes every size variant of an already decoded original
+func generateImageVariants(relPath string, img image.Image) error {
+	relPath, err := cleanImagePath(relPath)
+	if err != nil {
+		return err
+	}
+	unlock := lockImageVariants(relPath)
+	defer unlock()
+
+	for variant, box := range imageVariants {
+		err := writeImageVariant(img, imageVariantPath(relPath, variant), box)
+		if err != nil {
+			return fmt.Errorf("error writing %s variant of %s: %w", variant, relPath, err)
+		}
+	}
+	return nil
+}
+
+func writeImageVariant(img image.Image, dest string, box image.Point) error {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return fmt.Errorf("empty image")
+	}
+
+	scaled := img
+	if width > box.X || height > box.Y {
+		scale := min(float64(box.X)/float64(width), float64(box.Y)/float64(height))
+		newWidth := max(1, int(float64(width)*scale))
+		newHeight := max(1, int(float64(height)*scale))
+		dst := image.NewNRGBA(image.Rect(0, 0, newWidth, newHeight))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+		scaled = dst
+	}
+
+	err := os.MkdirAll(filepath.Dir(dest), 0755)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".variant-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	err = nativewebp.Encode(tmp, scaled, nil)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	err = os.Chmod(tmp.Name(), 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dest)
+}
+
+// Regenerates variants of one original when they are missing or older than it
+func ensureImageVariants(relPath string) error {
+	relPath, err := cleanImagePath(relPath)
+	if err != nil {
+		return err
+	}
+	srcInfo, err := os.Stat(filepath.FromSlash(relPath))
+	if err != nil {
+		return err
+	}
+
+	stale := false
+	for variant := range imageVariants {
+		info, err := os.Stat(imageVariantPath(relPath, variant))
+		if err != nil || info.ModTime().Before(srcInfo.ModTime()) {
+			stale = true
+			break
+		}
+	}
+	if !stale {
+		return nil
+	}
+
+	file, err := os.Open(filepath.FromSlash(relPath))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return fmt.Errorf("error decoding %s: %w", relPath, err)
+	}
+	return generateImageVariants(relPath, img)
+}
+
+// Walks every image root, creating missing variants and removing orphaned ones
+func backfillImageVariants(progress func(done int, total int)) (int, []string) {
+	var originals []string
+	for _, root := range imageRoots {
+		filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			originals = append(originals, filepath.ToSlash(p))
+			return nil
+		})
+	}
+
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var failed []string
+	done := 0
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for relPath := range jobs {
+				err := ensureImageVariants(relPath)
+				mu.Lock()
+				if err != nil {
+					log.Printf("image backfill error %s: %v", relPath, err)
+					failed = append(failed, relPath)
+				}
+				done++
+				if progress != nil {
+					progress(done, len(originals))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, relPath := range originals {
+		jobs <- relPath
+	}
+	close(jobs)
+	wg.Wait()
+
+	removeOrphanedImageVariants()
+	return len(originals), failed
+}
+
+func removeOrphanedImageVariants() {
+	for variant := range imageVariants {
+		variantRoot := filepath.Join(imageVariantsDir, variant)
+		filepath.WalkDir(variantRoot, func(p string, d os.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+			rel, err := filepath.Rel(variantRoot, p)
+			if err != nil {
+				return nil
+			}
+			if _, err := os.Stat(rel); os.IsNotExist(err) {
+				os.Remove(p)
+			}
+			return nil
+		})
+	}
+}
+
+func removeImageVariantsForUID(uid string) error {
+	for variant := range imageVariants {
+		for _, root := range imageRoots {
+			err := os.RemoveAll(filepath.Join(imageVariantsDir, variant, root, uid))
+			if err != nil {
+				return fmt.Errorf("failed to delete %s variants for UID %s: %w", variant, uid, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Builds versioned URLs for an original and its variants, the version makes them safe to cache forever
+func imageURLs(relPath string) map[string]string {
+	relPath, err := cleanImagePath(relPath)
+	if err != nil {
+		return nil
+	}
+	info, err := os.Stat(filepath.FromSlash(relPath))
+	if err != nil {
+		return nil
+	}
+	version := strconv.FormatInt(info.ModTime().UnixNano(), 36)
+
+	urls := map[string]string{
+		"full": fmt.Sprintf("/images/full/%s?v=%s", relPath, version),
+	}
+	for variant := range imageVariants {
+		urls[variant] = fmt.Sprintf("/images/%s/%s?v=%s", variant, relPath, version)
+	}
+	return urls
+}
+
+// Serves originals and variants with ETag revalidation, versioned URLs are immutable
+func serveImage(c *gin.Context, variant string, relPath string) {
+	relPath, err := cleanImagePath(relPath)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "image not found"})
+		return
+	}
+
+	filePath := filepath.FromSlash(relPath)
+	if variant != "full" {
+		if _, ok := imageVariants[variant]; !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "unknown image variant"})
+			return
+		}
+		// Variants are created lazily for images that predate the pipeline
+		err = ensureImageVariants(relPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "image not found"})
+				return
+			}
+			log.Printf("[Images] ERROR : %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not create image variant", "details": err.Error()})
+			return
+		}
+		filePath = imageVariantPath(relPath, variant)
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil || info.IsDir() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "image not found"})
+		return
+	}
+
+	etag := fmt.Sprintf(`"%x-%x"`, info.Size(), info.ModTime().UnixNano())
+	c.Header("ETag", etag)
+	if c.Query("v") != "" {
+		c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		c.Header("Cache-Control", "public, no-cache")
+	}
+	c.Header("X-Content-Type-Options", "nosniff")
+
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.File(filePath)
+}
+
+func registerImageRoutes(r *gin.Engine) {
+	r.GET("/images/:variant/*path", func(c *gin.Context) {
+		serveImage(c, c.Param("variant"), c.Param("path"))
+	})
+
+	// Original folders under their historic names
+	r.GET("/cover-art/*path", func(c *gin.Context) {
+		serveImage(c, "full", "coverArt"+c.Param("path"))
+	})
+	r.GET("/screenshots/*path", func(c *gin.Context) {
+		serveImage(c, "full", "screenshots"+c.Param("path"))
+	})
+}
@@ -0,0 +1,514 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+const jobKindImport = "import"
+
+// Job states. Failed, cancelled and interrupted jobs can resume, and so can done jobs with failed items.
+const (
+	jobQueued      = "queued"
+	jobRunning     = "running"
+	jobDone        = "done"
+	jobFailed      = "failed"
+	jobCancelled   = "cancelled"
+	jobInterrupted = "interrupted"
+)
+
+// Item states, every state but pending and failed counts as done
+const (
+	jobItemPending   = "pending"
+	jobItemAdded     = "added"
+	jobItemUpdated   = "updated"
+	jobItemSkipped   = "skipped"
+	jobItemUnmatched = "unmatched"
+	jobItemFailed    = "failed"
+)
+
+const (
+	jobHistoryLimit = 20
+	jobStopTimeout  = 10 * time.Second
+)
+
+var (
+	errJobNotFound   = errors.New("job not found")
+	errJobRunning    = errors.New("job is already running")
+	errJobNotRunning = errors.New("job is not running")
+	errJobComplete   = errors.New("job has no items left to run")
+
+	// Causes of a cancelled job, the backend stopping leaves the job interrupted
+	errJobCancelled = errors.New("job cancelled")
+	errJobStopped   = errors.New("backend stopped the job")
+)
+
+type jobInfo struct {
+	ID              string `json:"id"`
+	Kind            string `json:"kind"`
+	Source          string `json:"source"`
+	Status          string `json:"status"`
+	ContinueOnError bool   `json:"continueOnError"`
+	Error           string `json:"error,omitempty"`
+	Total           int    `json:"total"`
+	Done            int    `json:"done"`
+	Failed          int    `json:"failed"`
+	Added           int    `json:"added"`
+	CreatedAt       string `json:"createdAt"`
+	StartedAt       string `json:"startedAt,omitempty"`
+	FinishedAt      string `json:"finishedAt,omitempty"`
+	params          string
+	planned         bool
+}
+
+// Data is what the importer needs to import the item, it isn't sent to clients
+type jobItem struct {
+	Position int             `json:"position"`
+	Key      string          `json:"key"`
+	Name     string          `json:"name"`
+	Status   string          `json:"status"`
+	Error    string          `json:"error,omitempty"`
+	Data     json.RawMessage `json:"-"`
+}
+
+type jobDetail struct {
+	jobInfo
+	Items []jobItem `json:"items"`
+}
+
+// Published as import.progress after every item
+type jobProgress struct {
+	JobID  string  `json:"jobId"`
+	Source string  `json:"source"`
+	Item   jobItem `json:"item"`
+	Done   int     `json:"done"`
+	Failed int     `json:"failed"`
+	Total  int     `json:"total"`
+}
+
+// Stored with an import job, credentials stay in the secret store
+type importJobParams struct {
+	SteamID string `json:"steamId,omitempty"`
+}
+
+// An import split into items. The plan is stored before any item runs, so a resumed job picks up
+// where it stopped without fetching the library again.
+type jobImporter interface {
+	plan(ctx context.Context) ([]jobItem, error)
+	importItem(ctx context.Context, item jobItem) (string, error)
+	finish() error
+}
+
+func newJobItem(key string, name string, data any) (jobItem, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return jobItem{}, fmt.Errorf("error encoding job item %s: %w", key, err)
+	}
+	return jobItem{Key: key, Name: name, Status: jobItemPending, Data: raw}, nil
+}
+
+func newJobImporter(job jobInfo) (jobImporter, error) {
+	var params importJobParams
+	if err := json.Unmarshal([]byte(job.params), &params); err != nil {
+		return nil, fmt.Errorf("invalid job params: %w", err)
+	}
+	switch job.Source {
+	case importSourceSteam:
+		return steamImporter{steamID: params.SteamID}, nil
+	case importSourcePlayStation:
+		return &psImporter{}, nil
+	}
+	return nil, fmt.Errorf("unknown import source %q", job.Source)
+}
+
+type runningJob struct {
+	source string
+	cancel context.CancelCauseFunc
+	done   chan struct{}
+}
+
+// Runs jobs in the background, at most one per import source
+type jobManager struct {
+	mu      sync.Mutex
+	running map[string]*runningJob
+}
+
+var jobs = &jobManager{running: make(map[string]*runningJob)}
+
+// Returns the running job instead, with errJobRunning, when the source is already importing
+func (m *jobManager) startImport(source string, params importJobParams, continueOnError bool) (jobInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, r := range m.running {
+		if r.source == source {
+			job, err := getJob(id)
+			if err != nil {
+				return job, err
+			}
+			return job, errJobRunning
+		}
+	}
+
+	rawParams, err := json.Marshal(params)
+	if err != nil {
+		return jobInfo{}, err
+	}
+	raw := make([]byte, 8)
+	if _, err := rand.Read(raw); err != nil {
+		return jobInfo{}, err
+	}
+	id := hex.EncodeToString(raw)
+	err = txWrite(func(tx *sql.Tx) error {
+		_, err := tx.Exec(`INSERT INTO Jobs (ID, Kind, Source, Params, Status, ContinueOnError, CreatedAt) VALUES (?,?,?,?,?,?,?)`,
+			id, jobKindImport, source, string(rawParams), jobQueued, continueOnError, time.Now().Format(time.RFC3339))
+		if err != nil {
+			return fmt.Errorf("error inserting job: %w", err)
+		}
+		// Only the latest jobs are kept, their items add up for big libraries
+		_, err = tx.Exec(`DELETE FROM Jobs WHERE Status NOT IN (?,?) AND ID NOT IN
+			(SELECT ID FROM Jobs ORDER BY CreatedAt DESC, rowid DESC LIMIT ?)`, jobQueued, jobRunning, jobHistoryLimit)
+		if err != nil {
+			return fmt.Errorf("error removing old jobs: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return jobInfo{}, err
+	}
+	return m.launch(id, source)
+}
+
+// Runs the items that are pending or failed again, on a job that isn't running
+func (m *jobManager) resume(id string) (jobInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, err := getJob(id)
+	if err != nil {
+		return job, err
+	}
+	if m.running[id] != nil {
+		return job, errJobRunning
+	}
+	for _, r := range m.running {
+		if r.source == job.Source {
+			return job, fmt.Errorf("another %s import is running: %w", job.Source, errJobRunning)
+		}
+	}
+	if job.planned && job.Done == job.Total {
+		return job, errJobComplete
+	}
+	return m.launch(id, job.Source)
+}
+
+// Aborts the request in flight, the item it was importing stays pending
+func (m *jobManager) cancel(id string) (jobInfo, error) {
+	m.mu.Lock()
+	r := m.running[id]
+	m.mu.Unlock()
+	if r != nil {
+		r.cancel(errJobCancelled)
+	}
+	job, err := getJob(id)
+	if err == nil && r == nil {
+		err = errJobNotRunning
+	}
+	return job, err
+}
+
+// Stops every job and waits for them to record where they stopped, before the backend exits or the
+// DB is swapped. The jobs are left interrupted.
+func (m *jobManager) stopAll() {
+	m.mu.Lock()
+	var stopping []*runningJob
+	for _, r := range m.running {
+		r.cancel(errJobStopped)
+		stopping = append(stopping, r)
+	}
+	m.mu.Unlock()
+
+	timeout := time.After(jobStopTimeout)
+	for _, r := range stopping {
+		select {
+		case <-r.done:
+		case <-timeout:
+			log.Printf("[Jobs] jobs did not stop within %s", jobStopTimeout)
+			return
+		}
+	}
+}
+
+// Callers hold m.mu
+func (m *jobManager) launch(id string, source string) (jobInfo, error) {
+	err := txWrite(func(tx *sql.Tx) error {
+		_, err := tx.Exec("UPDATE Jobs SET Status = ?, Error = '', FinishedAt = '' WHERE ID = ?", jobQueued, id)
+		return err
+	})
+	if err != nil {
+		return jobInfo{}, err
+	}
+	ctx, cancel := context.WithCancelCause(context.Background())
+	r := &runningJob{source: source, cancel: cancel, done: make(chan struct{})}
+	m.running[id] = r
+	go m.run(ctx, id, r)
+	return getJob(id)
+}
+
+func (m *jobManager) run(ctx context.Context, id string, r *runningJob) {
+	defer func() {
+		m.mu.Lock()
+		delete(m.running, id)
+		m.mu.Unlock()
+		r.cancel(nil)
+		close(r.done)
+	}()
+
+	job, err := getJob(id)
+	if err != nil {
+		log.Printf("[Jobs] ERROR : %v", err)
+		return
+	}
+	err = txWrite(func(tx *sql.Tx) error {
+		_, err := tx.Exec("UPDATE Jobs SET Status = ?, StartedAt = ? WHERE ID = ?", jobRunning, time.Now().Format(time.RFC3339), id)
+		return err
+	})
+	if err != nil {
+		log.Printf("[Jobs] ERROR : %v", err)
+		return
+	}
+	job.Status = jobRunning
+	publishEvent(eventImportStarted, job)
+
+	added, err := runJobItems(ctx, job)
+	status, message := jobDone, ""
+	switch {
+	case errors.Is(err, errJobCancelled):
+		status = jobCancelled
+	case errors.Is(err, errJobStopped):
+		status = jobInterrupted
+	case err != nil:
+		status, message = jobFailed, err.Error()
+		log.Printf("[Jobs] ERROR %s import %s : %v", job.Source, id, err)
+	}
+	err = txWrite(func(tx *sql.Tx) error {
+		_, err := tx.Exec("UPDATE Jobs SET Status = ?, Error = ?, FinishedAt = ? WHERE ID = ?", status, message, time.Now().Format(time.RFC3339), id)
+		return err
+	})
+	if err != nil {
+		log.Printf("[Jobs] ERROR : %v", err)
+	}
+	job, err = getJob(id)
+	if err != nil {
+		log.Printf("[Jobs] ERROR : %v", err)
+		job.Status, job.Error = status, message
+	}
+
+	switch status {
+	case jobDone:
+		publishEvent(eventImportDone, job)
+	case jobFailed:
+		publishEvent(eventImportFailed, job)
+	default:
+		publishEvent(eventImportCancelled, job)
+	}
+	if status != jobInterrupted {
+		go backupAfterImport(added)
+	}
+}
+
+// Plans the job on its first run, then imports the items that aren't done and returns how many
+// games were added. An item error stops the job unless it continues on errors.
+func runJobItems(ctx context.Context, job jobInfo) (int, error) {
+	importer, err := newJobImporter(job)
+	if err != nil {
+		return 0, err
+	}
+	if !job.planned {
+		items, err := importer.plan(ctx)
+		if ctx.Err() != nil {
+			return 0, context.Cause(ctx)
+		}
+		if err != nil {
+			return 0, err
+		}
+		err = saveJobPlan(job.ID, items)
+		if err != nil {
+			return 0, err
+		}
+	}
+	items, err := getJobItems(job.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	done, failed := 0, 0
+	for _, item := range items {
+		switch item.Status {
+		case jobItemPending:
+		case jobItemFailed:
+			failed++
+		default:
+			done++
+		}
+	}
+	added := 0
+	for _, item := range items {
+		if item.Status != jobItemPending && item.Status != jobItemFailed {
+			continue
+		}
+		if ctx.Err() != nil {
+			return added, context.Cause(ctx)
+		}
+		if item.Status == jobItemFailed {
+			failed--
+		}
+
+		status, itemErr := importer.importItem(ctx, item)
+		// An item cut off by a cancel or shutdown stays pending for a resume
+		if itemErr != nil && ctx.Err() != nil {
+			return added, context.Cause(ctx)
+		}
+		item.Status, item.Error = status, ""
+		if itemErr != nil {
+			item.Status, item.Error = jobItemFailed, itemErr.Error()
+			log.Printf("[Jobs] ERROR %s import of %s : %v", job.Source, item.Name, itemErr)
+			failed++
+		} else {
+			done++
+		}
+		if item.Status == jobItemAdded {
+			added++
+		}
+		err = txWrite(func(tx *sql.Tx) error {
+			_, err := tx.Exec("UPDATE JobItems SET Status = ?, Error = ? WHERE JobID = ? AND Position = ?", item.Status, item.Error, job.ID, item.Position)
+			return err
+		})
+		if err != nil {
+			return added, err
+		}
+		publishEvent(eventImportProgress, jobProgress{JobID: job.ID, Source: job.Source, Item: item, Done: done, Failed: failed, Total: len(items)})
+
+		if itemErr != nil && !job.ContinueOnError {
+			return added, fmt.Errorf("%s: %w", item.Name, itemErr)
+		}
+	}
+
+	err = importer.finish()
+	if err != nil {
+		log.Printf("[Jobs] ERROR finishing %s import : %v", job.Source, err)
+	}
+	return added, nil
+}
+
+func saveJobPlan(id string, items []jobItem) error {
+	values := make([][]any, len(items))
+	for i, item := range items {
+		values[i] = []any{id, i, item.Key, item.Name, string(item.Data), jobItemPending}
+	}
+	return txWrite(func(tx *sql.Tx) error {
+		_, err := tx.Exec("DELETE FROM JobItems WHERE JobID = ?", id)
+		if err != nil {
+			return fmt.Errorf("error clearing job items: %w", err)
+		}
+		if len(values) > 0 {
+			err = txBatchUpdate(tx, "INSERT INTO JobItems (JobID, Position, Key, Name, Data, Status) VALUES (?,?,?,?,?,?)", values)
+			if err != nil {
+				return fmt.Errorf("error inserting job items: %w", err)
+			}
+		}
+		_, err = tx.Exec("UPDATE Jobs SET Planned = 1 WHERE ID = ?", id)
+		return err
+	})
+}
+
+// Jobs still marked as running weren't stopped cleanly, by a crash or because a restored backup was
+// taken while they ran. Called when no jobs run, at startup and after a restore.
+func interruptStaleJobs() error {
+	return txWrite(func(tx *sql.Tx) error {
+		_, err := tx.Exec("UPDATE Jobs SET Status = ?, FinishedAt = ? WHERE Status IN (?,?)", jobInterrupted, time.Now().Format(time.RFC3339), jobQueued, jobRunning)
+		if err != nil {
+			return fmt.Errorf("error marking interrupted jobs: %w", err)
+		}
+		return nil
+	})
+}
+
+func queryJobs(where string, args ...any) ([]jobInfo, error) {
+	rows, err := readDB.Query(`SELECT j.ID, j.Kind, j.Source, j.Params, j.Status, j.ContinueOnError, j.Planned, j.Error,
+			j.CreatedAt, j.StartedAt, j.FinishedAt, COUNT(i.Position),
+			COALESCE(SUM(i.Status NOT IN ('pending', 'failed')), 0), COALESCE(SUM(i.Status = 'failed'), 0), COALESCE(SUM(i.Status = 'added'), 0)
+		FROM Jobs j LEFT JOIN JobItems i ON i.JobID = j.ID `+where+`
+		GROUP BY j.ID ORDER BY j.CreatedAt DESC, j.rowid DESC`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query error Jobs: %w", err)
+	}
+	defer rows.Close()
+
+	list := []jobInfo{}
+	for rows.Next() {
+		var job jobInfo
+		err := rows.Scan(&job.ID, &job.Kind, &job.Source, &job.params, &job.Status, &job.ContinueOnError, &job.planned, &job.Error,
+			&job.CreatedAt, &job.StartedAt, &job.FinishedAt, &job.Total, &job.Done, &job.Failed, &job.Added)
+		if err != nil {
+			return nil, fmt.Errorf("scan error Jobs: %w", err)
+		}
+		list = append(list, job)
+	}
+	return list, rows.Err()
+}
+
+// Newest first
+func listJobs() ([]jobInfo, error) {
+	return queryJobs("")
+}
+
+func getJob(id string) (jobInfo, error) {
+	list, err := queryJobs("WHERE j.ID = ?", id)
+	if err != nil {
+		return jobInfo{}, err
+	}
+	if len(list) == 0 {
+		return jobInfo{}, errJobNotFound
+	}
+	return list[0], nil
+}
+
+func getJobItems(id string) ([]jobItem, error) {
+	rows, err := readDB.Query("SELECT Position, Key, Name, Data, Status, Error FROM JobItems WHERE JobID = ? ORDER BY Position", id)
+	if err != nil {
+		return nil, fmt.Errorf("query error JobItems: %w", err)
+	}
+	defer rows.Close()
+
+	items := []jobItem{}
+	for rows.Next() {
+		var item jobItem
+		var data string
+		err := rows.Scan(&item.Position, &item.Key, &item.Name, &data, &item.Status, &item.Error)
+		if err != nil {
+			return nil, fmt.Errorf("scan error JobItems: %w", err)
+		}
+		item.Data = json.RawMessage(data)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func getJobDetail(id string) (jobDetail, error) {
+	job, err := getJob(id)
+	if err != nil {
+		return jobDetail{}, err
+	}
+	items, err := getJobItems(id)
+	if err != nil {
+		return jobDetail{}, err
+	}
+	return jobDetail{jobInfo: job, Items: items}, nil
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -34,6 +35,40 @@ func getGamePath(uid string) (string, error) {
 	return "", nil
 }
 
+// Launches through Steam when the game has an app ID, otherwise from its install path, and records
+// the play session. Returns false when there is no path to launch from.
+func launchGame(uid string) (bool, error) {
+	beginPlaySession(uid)
+	defer endPlaySession(uid)
+	appid, err := getSteamAppID(uid)
+	if err != nil {
+		return false, err
+	}
+	if appid != 0 {
+		return true, launchSteamGame(uid, appid)
+	}
+	path, err := getGamePath(uid)
+	if err != nil || path == "" {
+		return false, err
+	}
+	err = launchGameFromPath(path, uid)
+	if err != nil {
+		return false, err
+	}
+	publishEvent(eventGameUpdated, gameEvent{UID: uid})
+	return true, nil
+}
+
+// Whether launchGame has something to launch the game from
+func isLaunchable(uid string) (bool, error) {
+	appid, err := getSteamAppID(uid)
+	if err != nil || appid != 0 {
+		return appid != 0, err
+	}
+	path, err := getGamePath(uid)
+	return path != "", err
+}
+
 func setInstallPath(uid string, path string) error {
 	err := txWrite(func(tx *sql.Tx) error {
 		if path != "" {
@@ -140,6 +175,7 @@ func launchGameFromPath(path string, uid string) error {
 			if err != nil {
 				return fmt.Errorf("failed to start flatpak app: %w", err)
 			}
+			setPlaySessionPID(uid, cmd.Process.Pid)
 
 			fmt.Printf("Flatpak app %s started, polling for exit...\n", flatpakAppID)
 			for {
@@ -155,10 +191,19 @@ func launchGameFromPath(path string, uid string) error {
 
 			cmd.Process.Wait() // ensure cleanup
 		} else {
-			err := cmd.Run()
+			err := cmd.Start()
 			if err != nil {
 				return fmt.Errorf("error launching game on Linux: %w", err)
 			}
+			setPlaySessionPID(uid, cmd.Process.Pid)
+			// Games that crash or are stopped remotely still count as played
+			err = cmd.Wait()
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				fmt.Println("Game exited with", err)
+			} else if err != nil {
+				return fmt.Errorf("error launching game on Linux: %w", err)
+			}
 		}
 
 		playTime := time.Since(startTime)
@@ -213,7 +258,7 @@ func sendSteamInstallReq(appid int) error {
 	return nil
 }
 
-func launchSteamGame(appid int) error {
+func launchSteamGame(uid string, appid int) error {
 	currentOS := runtime.GOOS
 	fmt.Println("Launching Steam Game", appid)
 
@@ -246,13 +291,14 @@ func launchSteamGame(appid int) error {
 				continue
 			}
 			if looksLikeGameProcess(cmdline) {
+				setPlaySessionPID(uid, pid)
 				return monitorProcessLinux(pid)
 			}
 		}
 
 		return fmt.Errorf("could not detect game process after launch")
 	} else if currentOS == "windows" {
-		return launchAndMonitorSteamGame(appid)
+		return launchAndMonitorSteamGame(uid, appid)
 	} else {
 		return fmt.Errorf("error launching game: unsupported OS")
 	}
@@ -323,7 +369,7 @@ func monitorProcessLinux(pid int) error {
 	}
 }
 
-func launchAndMonitorSteamGame(appid int) error {
+func launchAndMonitorSteamGame(uid string, appid int) error {
 	// Launch game through Steam
 	cmd := exec.Command("cmd", "/C", "start", "", fmt.Sprintf("steam://rungameid/%d", appid))
 	if err := cmd.Run(); err != nil {
@@ -346,6 +392,7 @@ func launchAndMonitorSteamGame(appid int) error {
 	}
 
 	fmt.Printf("Successfully detected game PID: %d\n", gamePID)
+	setPlaySessionPID(uid, gamePID)
 	return monitorProcess(gamePID)
 }
 
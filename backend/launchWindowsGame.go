@@ -51,8 +51,15 @@ func launchWindowsGame(path string, uid string) error {
 	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
 	cmd.Dir = gameDir // Set correct working directory
 	startTime := time.Now()
-	err := cmd.Run()
-	if err != nil {
+	err := cmd.Start()
+	if err == nil {
+		setPlaySessionPID(uid, cmd.Process.Pid)
+		// Games that crash or are stopped remotely still count as played, only failing to start
+		// points to missing admin rights
+		if err := cmd.Wait(); err != nil {
+			fmt.Println("Game exited with", err)
+		}
+	} else {
 		fmt.Println("Normal launch failed, trying with admin privileges...")
 		cmd := exec.Command("powershell", "-Command",
 			fmt.Sprintf("Start-Process -FilePath '%s' -Verb RunAs -Wait", path))
@@ -0,0 +1,670 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	libraryArchiveFormat   = "quicksave-library"
+	libraryArchiveVersion  = 1
+	libraryManifestFile    = "manifest.json"
+	libraryImportMerge     = "merge"
+	libraryImportReplace   = "replace"
+	libraryScreenshotsRoot = "screenshots"
+)
+
+// A library archive is a single zip holding a DB snapshot, the image folders and a manifest
+// listing every other file with its checksum
+type libraryManifest struct {
+	Format              string               `json:"format"`
+	FormatVersion       int                  `json:"formatVersion"`
+	CreatedAt           time.Time            `json:"createdAt"`
+	DBVersion           int                  `json:"dbVersion"`
+	Games               int                  `json:"games"`
+	IncludesScreenshots bool                 `json:"includesScreenshots"`
+	SecretsExcluded     bool                 `json:"secretsExcluded"`
+	Files               []libraryArchiveFile `json:"files"`
+}
+
+type libraryArchiveFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// A passphrase encrypts the whole archive, ExcludeSecrets leaves the stored credentials out
+type libraryExportRequest struct {
+	IncludeScreenshots bool   `json:"includeScreenshots"`
+	ExcludeSecrets     bool   `json:"excludeSecrets"`
+	Passphrase         string `json:"passphrase"`
+}
+
+// Archive path and the file on disk it is read from
+type libraryArchiveSource struct {
+	name string
+	file string
+}
+
+// Image roots that go into or come out of an archive
+func libraryArchiveRoots(includeScreenshots bool) []string {
+	var roots []string
+	for _, root := range imageRoots {
+		if root == libraryScreenshotsRoot && !includeScreenshots {
+			continue
+		}
+		roots = append(roots, root)
+	}
+	return roots
+}
+
+// Archive paths are slash separated and either the DB file or inside one of the image roots
+func validLibraryArchivePath(name string) bool {
+	if name == backupDBFile {
+		return true
+	}
+	if name != path.Clean(name) || path.IsAbs(name) || strings.Contains(name, "\\") {
+		return false
+	}
+	parts := strings.Split(name, "/")
+	if len(parts) < 2 {
+		return false
+	}
+	for _, part := range parts {
+		if part == ".." || part == "." || part == "" {
+			return false
+		}
+	}
+	for _, root := range imageRoots {
+		if parts[0] == root {
+			return true
+		}
+	}
+	return false
+}
+
+// Writes the archive under exports/, where it is downloaded like screenshot bundles
+func exportLibrary(req libraryExportRequest, name string, progress func(done int, total int)) error {
+	defer releaseExportName(name)
+	err := os.MkdirAll(exportsDir, 0755)
+	if err != nil {
+		return fmt.Errorf("error creating exports folder: %w", err)
+	}
+	removeStaleExports()
+
+	tmpDir, err := os.MkdirTemp(exportsDir, ".library-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	dbPath := filepath.Join(tmpDir, backupDBFile)
+	_, err = readDB.Exec("VACUUM INTO ?", dbPath)
+	if err != nil {
+		return fmt.Errorf("error snapshotting database: %w", err)
+	}
+	if req.ExcludeSecrets {
+		err = stripSecrets(dbPath)
+		if err != nil {
+			return fmt.Errorf("error removing secrets from export: %w", err)
+		}
+	}
+
+	manifest := libraryManifest{
+		Format:              libraryArchiveFormat,
+		FormatVersion:       libraryArchiveVersion,
+		CreatedAt:           time.Now(),
+		IncludesScreenshots: req.IncludeScreenshots,
+		SecretsExcluded:     req.ExcludeSecrets,
+		Files:               []libraryArchiveFile{},
+	}
+	manifest.DBVersion, err = getDBVersion(readDB)
+	if err != nil {
+		return err
+	}
+	manifest.Games, err = countGames()
+	if err != nil {
+		return err
+	}
+
+	sources := []libraryArchiveSource{{backupDBFile, dbPath}}
+	for _, root := range libraryArchiveRoots(req.IncludeScreenshots) {
+		err := filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+			if os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			if err != nil {
+				return err
+			}
+			if d.Type().IsRegular() {
+				sources = append(sources, libraryArchiveSource{filepath.ToSlash(p), p})
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("error listing %s: %w", root, err)
+		}
+	}
+
+	return writeFileAtomic(filepath.Join(exportsDir, name), func(w io.Writer) error {
+		return writeMaybeEncrypted(w, req.Passphrase, func(w io.Writer) error {
+			return writeLibraryArchive(w, manifest, sources, progress)
+		})
+	})
+}
+
+func writeLibraryArchive(w io.Writer, manifest libraryManifest, sources []libraryArchiveSource, progress func(done int, total int)) error {
+	archive := zip.NewWriter(w)
+	for i, src := range sources {
+		file, err := addFileToLibraryArchive(archive, src.name, src.file)
+		if err != nil {
+			return fmt.Errorf("error archiving %s: %w", src.name, err)
+		}
+		manifest.Files = append(manifest.Files, file)
+		if progress != nil {
+			progress(i+1, len(sources))
+		}
+	}
+
+	manifestWriter, err := archive.CreateHeader(&zip.FileHeader{Name: libraryManifestFile, Method: zip.Deflate, Modified: manifest.CreatedAt})
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(manifestWriter)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(manifest)
+	if err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+// Images are already compressed so only the DB is deflated
+func addFileToLibraryArchive(archive *zip.Writer, name string, filePath string) (libraryArchiveFile, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return libraryArchiveFile{}, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return libraryArchiveFile{}, err
+	}
+
+	method := zip.Store
+	if name == backupDBFile {
+		method = zip.Deflate
+	}
+	w, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: method, Modified: info.ModTime()})
+	if err != nil {
+		return libraryArchiveFile{}, err
+	}
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(w, hash), file)
+	if err != nil {
+		return libraryArchiveFile{}, err
+	}
+	return libraryArchiveFile{Path: name, Size: size, SHA256: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+// Validates and extracts the archive, then merges it into the library or replaces the library with it.
+// Merging only adds games whose UID isn't in the library yet. Returns the number of games added.
+func importLibrary(archivePath string, mode string, passphrase string, progress func(done int, total int)) (int, error) {
+	if mode != libraryImportMerge && mode != libraryImportReplace {
+		return 0, fmt.Errorf("unknown import mode %s, use merge or replace", mode)
+	}
+	// Zip needs random access, so encrypted archives are decrypted to a temp file first
+	if isEncryptedFile(archivePath) {
+		if passphrase == "" {
+			return 0, fmt.Errorf("archive is encrypted, a passphrase is required")
+		}
+		decrypted, err := os.CreateTemp(".", ".library-decrypted-*.zip")
+		if err != nil {
+			return 0, err
+		}
+		decrypted.Close()
+		defer os.Remove(decrypted.Name())
+		err = decryptFile(archivePath, decrypted.Name(), passphrase)
+		if err != nil {
+			return 0, err
+		}
+		archivePath = decrypted.Name()
+	}
+	archive, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return 0, fmt.Errorf("error opening archive: %w", err)
+	}
+	defer archive.Close()
+
+	manifest, err := readLibraryManifest(&archive.Reader)
+	if err != nil {
+		return 0, err
+	}
+	currentVersion, err := getDBVersion(readDB)
+	if err != nil {
+		return 0, err
+	}
+	if manifest.DBVersion > currentVersion {
+		return 0, fmt.Errorf("archive is from a newer version of quicksave (db v%d)", manifest.DBVersion)
+	}
+
+	// Extracted next to the live folders so replacing them is a rename
+	extractDir, err := os.MkdirTemp(".", ".library-import-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(extractDir)
+	err = extractLibraryArchive(&archive.Reader, manifest, extractDir, progress)
+	if err != nil {
+		return 0, err
+	}
+	err = migrateImportedDatabase(filepath.Join(extractDir, backupDBFile))
+	if err != nil {
+		return 0, err
+	}
+
+	if mode == libraryImportReplace {
+		return replaceLibrary(extractDir, manifest)
+	}
+	return mergeLibrary(extractDir, manifest)
+}
+
+func readLibraryManifest(archive *zip.Reader) (libraryManifest, error) {
+	var manifest libraryManifest
+	file, err := archive.Open(libraryManifestFile)
+	if err != nil {
+		return manifest, fmt.Errorf("not a library archive, %s is missing", libraryManifestFile)
+	}
+	defer file.Close()
+	err = json.NewDecoder(file).Decode(&manifest)
+	if err != nil {
+		return manifest, fmt.Errorf("error reading %s: %w", libraryManifestFile, err)
+	}
+	if manifest.Format != libraryArchiveFormat {
+		return manifest, fmt.Errorf("not a library archive")
+	}
+	if manifest.FormatVersion < 1 || manifest.FormatVersion > libraryArchiveVersion {
+		return manifest, fmt.Errorf("unsupported library archive version %d", manifest.FormatVersion)
+	}
+	return manifest, nil
+}
+
+// Every entry must be listed in the manifest and match its checksum, nothing is written outside dest
+func extractLibraryArchive(archive *zip.Reader, manifest libraryManifest, dest string, progress func(done int, total int)) error {
+	listed := make(map[string]libraryArchiveFile)
+	for _, file := range manifest.Files {
+		if !validLibraryArchivePath(file.Path) {
+			return fmt.Errorf("invalid path in archive: %s", file.Path)
+		}
+		if !manifest.IncludesScreenshots && strings.HasPrefix(file.Path, libraryScreenshotsRoot+"/") {
+			return fmt.Errorf("archive without screenshots lists %s", file.Path)
+		}
+		listed[file.Path] = file
+	}
+	if _, ok := listed[backupDBFile]; !ok {
+		return fmt.Errorf("archive has no database")
+	}
+
+	extracted := 0
+	for _, entry := range archive.File {
+		if entry.Name == libraryManifestFile || strings.HasSuffix(entry.Name, "/") {
+			continue
+		}
+		expected, ok := listed[entry.Name]
+		if !ok {
+			return fmt.Errorf("archive entry %s is not in the manifest", entry.Name)
+		}
+		err := extractLibraryFile(entry, expected, filepath.Join(dest, filepath.FromSlash(entry.Name)))
+		if err != nil {
+			return err
+		}
+		delete(listed, entry.Name)
+		extracted++
+		if progress != nil {
+			progress(extracted, len(manifest.Files))
+		}
+	}
+	for missing := range listed {
+		return fmt.Errorf("archive is missing %s", missing)
+	}
+	return nil
+}
+
+func extractLibraryFile(entry *zip.File, expected libraryArchiveFile, dest string) error {
+	src, err := entry.Open()
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", entry.Name, err)
+	}
+	defer src.Close()
+	err = os.MkdirAll(filepath.Dir(dest), 0755)
+	if err != nil {
+		return err
+	}
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	hash := sha256.New()
+	// One byte past the listed size is enough to tell the entry is too large
+	size, err := io.Copy(io.MultiWriter(out, hash), io.LimitReader(src, expected.Size+1))
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("error extracting %s: %w", entry.Name, err)
+	}
+	if size != expected.Size || hex.EncodeToString(hash.Sum(nil)) != expected.SHA256 {
+		return fmt.Errorf("checksum mismatch for %s", entry.Name)
+	}
+	os.Chtimes(dest, entry.Modified, entry.Modified)
+	return nil
+}
+
+// Brings the extracted DB to the current schema before it touches the library
+func migrateImportedDatabase(dbPath string) error {
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return fmt.Errorf("error opening imported database: %w", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	err = migrateDatabase(db, func(fn func(tx *sql.Tx) error) error {
+		return txOn(db, fn)
+	}, false)
+	if err != nil {
+		return fmt.Errorf("error migrating imported database: %w", err)
+	}
+	return nil
+}
+
+// Swaps the DB and image folders like a backup restore, after taking a snapshot of the current library.
+// Screenshots stay untouched when the archive has none.
+func replaceLibrary(extractDir string, manifest libraryManifest) (int, error) {
+	_, err := createSnapshot("pre-import")
+	if err != nil {
+		return 0, fmt.Errorf("error taking pre-import snapshot: %w", err)
+	}
+
+	backupLock.Lock()
+	defer backupLock.Unlock()
+	maintenanceMode.Store(true)
+	defer maintenanceMode.Store(false)
+	jobs.stopAll()
+
+	var liveSecrets map[string]liveTableRows
+	if manifest.SecretsExcluded {
+		liveSecrets, err = readLiveSecrets()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	err = restoreSnapshotFiles(filepath.Join(extractDir, backupDBFile), extractDir, libraryArchiveRoots(manifest.IncludesScreenshots))
+	if err != nil {
+		return 0, err
+	}
+	err = migrateDatabase(readDB, txWrite, true)
+	if err != nil {
+		return 0, fmt.Errorf("error migrating imported database: %w", err)
+	}
+	if liveSecrets != nil {
+		err = carryLiveSecrets(liveSecrets)
+		if err != nil {
+			return 0, fmt.Errorf("error keeping live credentials: %w", err)
+		}
+	}
+	err = interruptStaleJobs()
+	if err != nil {
+		log.Printf("[ImportLibrary] ERROR : %v", err)
+	}
+	_, _, err = rescanScreenshots()
+	if err != nil {
+		log.Printf("[ImportLibrary] ERROR rescanning screenshots: %v", err)
+	}
+	return countGames()
+}
+
+// Tables with a UID column are merged for the new games. Screenshots and albums are remapped separately
+// since their IDs are local to each library, settings, credentials and filters of the library win.
+var libraryMergeSkipTables = map[string]bool{
+	"DBVersion":              true,
+	"Settings":               true,
+	"SteamCreds":             true,
+	"PlayStationNpsso":       true,
+	"SortState":              true,
+	"Screenshots":            true,
+	"ScreenshotTags":         true,
+	"Albums":                 true,
+	"AlbumScreenshots":       true,
+	"SteamScreenshotImports": true,
+}
+
+// Copies the games not yet in the library, with their metadata, images and screenshots
+func mergeLibrary(extractDir string, manifest libraryManifest) (int, error) {
+	dbPath, err := filepath.Abs(filepath.Join(extractDir, backupDBFile))
+	if err != nil {
+		return 0, err
+	}
+
+	added, err := mergeLibraryDatabase(dbPath, manifest.IncludesScreenshots)
+	if err != nil {
+		return 0, err
+	}
+
+	// Folders of games that already existed are left alone
+	for _, root := range libraryArchiveRoots(manifest.IncludesScreenshots) {
+		for _, uid := range added {
+			src := filepath.Join(extractDir, root, uid)
+			dest := filepath.Join(root, uid)
+			if _, err := os.Stat(src); err != nil {
+				continue
+			}
+			if _, err := os.Stat(dest); err == nil {
+				continue
+			}
+			err := os.MkdirAll(root, 0755)
+			if err == nil {
+				err = os.Rename(src, dest)
+			}
+			if err != nil {
+				return len(added), fmt.Errorf("error copying %s: %w", dest, err)
+			}
+		}
+	}
+	if manifest.IncludesScreenshots {
+		_, _, err = rescanScreenshots()
+		if err != nil {
+			log.Printf("[ImportLibrary] ERROR rescanning screenshots: %v", err)
+		}
+	}
+	return len(added), nil
+}
+
+// Attaches the imported DB to the write connection and copies the new games' rows in one transaction
+func mergeLibraryDatabase(dbPath string, withScreenshots bool) ([]string, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	ctx := context.Background()
+	conn, err := writeDB.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting db connection: %w", err)
+	}
+	defer conn.Close()
+	_, err = conn.ExecContext(ctx, "ATTACH DATABASE ? AS imported", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("error attaching imported database: %w", err)
+	}
+	defer conn.ExecContext(ctx, "DETACH DATABASE imported")
+
+	tables, err := libraryMergeTables(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`CREATE TEMP TABLE MergeUIDs AS
+		SELECT UID FROM imported.GameMetaData WHERE UID NOT IN (SELECT UID FROM main.GameMetaData)`)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting new games: %w", err)
+	}
+	rows, err := tx.Query("SELECT UID FROM temp.MergeUIDs")
+	if err != nil {
+		return nil, fmt.Errorf("query error MergeUIDs: %w", err)
+	}
+	var added []string
+	for rows.Next() {
+		var uid string
+		if err := rows.Scan(&uid); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan error MergeUIDs: %w", err)
+		}
+		added = append(added, uid)
+	}
+	rows.Close()
+
+	for table, columns := range tables {
+		cols := strings.Join(columns, ", ")
+		_, err := tx.Exec(fmt.Sprintf(`INSERT OR IGNORE INTO main.%s (%s) SELECT %s FROM imported.%s
+			WHERE UID IN (SELECT UID FROM temp.MergeUIDs)`, table, cols, cols, table))
+		if err != nil {
+			return nil, fmt.Errorf("error merging %s: %w", table, err)
+		}
+	}
+	if withScreenshots {
+		var screenshotColumns []string
+		screenshotColumns, err = sharedColumns(ctx, conn, "Screenshots")
+		if err != nil {
+			return nil, err
+		}
+		err = mergeLibraryScreenshots(tx, screenshotColumns)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = tx.Exec("DROP TABLE temp.MergeUIDs")
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return added, nil
+}
+
+// Columns shared by the live and imported copy of every table keyed by game UID
+func libraryMergeTables(ctx context.Context, conn *sql.Conn) (map[string][]string, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT name FROM main.sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'
+		AND name IN (SELECT name FROM imported.sqlite_master WHERE type = 'table')`)
+	if err != nil {
+		return nil, fmt.Errorf("error listing tables: %w", err)
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error listing tables: %w", err)
+		}
+		if !libraryMergeSkipTables[name] {
+			names = append(names, name)
+		}
+	}
+	rows.Close()
+
+	tables := make(map[string][]string)
+	for _, name := range names {
+		columns, err := sharedColumns(ctx, conn, name)
+		if err != nil {
+			return nil, err
+		}
+		if hasColumn(columns, "UID") {
+			tables[name] = columns
+		}
+	}
+	return tables, nil
+}
+
+func sharedColumns(ctx context.Context, conn *sql.Conn, table string) ([]string, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT m.name FROM pragma_table_info(?, 'main') m
+		JOIN pragma_table_info(?, 'imported') i ON m.name = i.name`, table, table)
+	if err != nil {
+		return nil, fmt.Errorf("error reading columns of %s: %w", table, err)
+	}
+	defer rows.Close()
+	var columns []string
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return nil, fmt.Errorf("error reading columns of %s: %w", table, err)
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+func hasColumn(columns []string, name string) bool {
+	for _, column := range columns {
+		if column == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Screenshot and album IDs differ between libraries, rows are matched by path and album name instead
+func mergeLibraryScreenshots(tx *sql.Tx, columns []string) error {
+	var kept []string
+	for _, column := range columns {
+		if column != "ID" {
+			kept = append(kept, column)
+		}
+	}
+	cols := strings.Join(kept, ", ")
+	statements := []struct{ table, query string }{
+		{"Screenshots", fmt.Sprintf(`INSERT OR IGNORE INTO main.Screenshots (%s) SELECT %s FROM imported.Screenshots
+			WHERE UID IN (SELECT UID FROM temp.MergeUIDs)`, cols, cols)},
+		{"ScreenshotTags", `INSERT OR IGNORE INTO main.ScreenshotTags (ScreenshotID, Tag)
+			SELECT ms.ID, it.Tag FROM imported.ScreenshotTags it
+			JOIN imported.Screenshots isc ON isc.ID = it.ScreenshotID
+			JOIN main.Screenshots ms ON ms.Path = isc.Path
+			WHERE isc.UID IN (SELECT UID FROM temp.MergeUIDs)`},
+		{"Albums", `INSERT OR IGNORE INTO main.Albums (Name, CreatedAt)
+			SELECT DISTINCT ia.Name, ia.CreatedAt FROM imported.Albums ia
+			JOIN imported.AlbumScreenshots ias ON ias.AlbumID = ia.ID
+			JOIN imported.Screenshots isc ON isc.ID = ias.ScreenshotID
+			WHERE isc.UID IN (SELECT UID FROM temp.MergeUIDs)`},
+		{"AlbumScreenshots", `INSERT OR IGNORE INTO main.AlbumScreenshots (AlbumID, ScreenshotID)
+			SELECT ma.ID, ms.ID FROM imported.AlbumScreenshots ias
+			JOIN imported.Albums ia ON ia.ID = ias.AlbumID
+			JOIN imported.Screenshots isc ON isc.ID = ias.ScreenshotID
+			JOIN main.Albums ma ON ma.Name = ia.Name
+			JOIN main.Screenshots ms ON ms.Path = isc.Path
+			WHERE isc.UID IN (SELECT UID FROM temp.MergeUIDs)`},
+	}
+	for _, statement := range statements {
+		_, err := tx.Exec(statement.query)
+		if err != nil {
+			return fmt.Errorf("error merging %s: %w", statement.table, err)
+		}
+	}
+	return nil
+}
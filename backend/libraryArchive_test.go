@@ -0,0 +1,155 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Zips files and lists each of them in a manifest with its real size and checksum
+func buildLibraryArchive(t *testing.T, files map[string]string) (*zip.Reader, libraryManifest) {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	manifest := libraryManifest{IncludesScreenshots: true}
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+		hash := sha256.Sum256([]byte(content))
+		manifest.Files = append(manifest.Files, libraryArchiveFile{Path: name, Size: int64(len(content)), SHA256: hex.EncodeToString(hash[:])})
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return archive, manifest
+}
+
+func TestValidLibraryArchivePath(t *testing.T) {
+	valid := []string{backupDBFile, "coverArt/uid/uid-0.webp", "screenshots/uid/generic-1.webp", "artwork/uid/hero.png"}
+	invalid := []string{
+		"", "/etc/passwd", "../quicksave.db", "coverArt/../../evil", "coverArt/uid/../../../evil",
+		`coverArt\uid\x.webp`, "coverArt//x.webp", "coverArt/./x.webp", "coverArt/", "coverArt",
+		"other/uid/x.webp", "manifest.json",
+	}
+	for _, name := range valid {
+		if !validLibraryArchivePath(name) {
+			t.Errorf("%q should be valid", name)
+		}
+	}
+	for _, name := range invalid {
+		if validLibraryArchivePath(name) {
+			t.Errorf("%q should be rejected", name)
+		}
+	}
+}
+
+func TestExtractLibraryArchive(t *testing.T) {
+	archive, manifest := buildLibraryArchive(t, map[string]string{
+		backupDBFile:              "database",
+		"coverArt/uid/uid-0.webp": "cover",
+		"screenshots/uid/a.webp":  "screenshot",
+	})
+	dest := t.TempDir()
+	calls := 0
+	err := extractLibraryArchive(archive, manifest, dest, func(done int, total int) { calls++ })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 {
+		t.Errorf("progress called %d times, want 3", calls)
+	}
+	data, err := os.ReadFile(filepath.Join(dest, "coverArt", "uid", "uid-0.webp"))
+	if err != nil || string(data) != "cover" {
+		t.Errorf("cover not extracted: %q %v", data, err)
+	}
+}
+
+func TestExtractLibraryArchiveRejectsBadPaths(t *testing.T) {
+	archive, manifest := buildLibraryArchive(t, map[string]string{
+		backupDBFile:           "database",
+		"coverArt/../../evil":  "escaped",
+		"coverArt/uid/ok.webp": "cover",
+	})
+	dest := t.TempDir()
+	err := extractLibraryArchive(archive, manifest, filepath.Join(dest, "extract"), nil)
+	if err == nil || !strings.Contains(err.Error(), "invalid path") {
+		t.Fatalf("expected an invalid path error, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "evil")); err == nil {
+		t.Error("file written outside the extract folder")
+	}
+}
+
+func TestExtractLibraryArchiveRejectsUnlistedEntries(t *testing.T) {
+	archive, manifest := buildLibraryArchive(t, map[string]string{
+		backupDBFile:       "database",
+		"coverArt/uid/x.a": "cover",
+	})
+	var listed []libraryArchiveFile
+	for _, file := range manifest.Files {
+		if file.Path == backupDBFile {
+			listed = append(listed, file)
+		}
+	}
+	manifest.Files = listed
+	err := extractLibraryArchive(archive, manifest, t.TempDir(), nil)
+	if err == nil || !strings.Contains(err.Error(), "not in the manifest") {
+		t.Fatalf("expected an unlisted entry error, got %v", err)
+	}
+}
+
+func TestExtractLibraryArchiveChecksums(t *testing.T) {
+	files := map[string]string{backupDBFile: "database", "coverArt/uid/x.webp": "cover"}
+	for name, tamper := range map[string]func(f *libraryArchiveFile){
+		"wrong hash":   func(f *libraryArchiveFile) { f.SHA256 = strings.Repeat("0", 64) },
+		"smaller size": func(f *libraryArchiveFile) { f.Size-- },
+		"larger size":  func(f *libraryArchiveFile) { f.Size++ },
+	} {
+		archive, manifest := buildLibraryArchive(t, files)
+		for i := range manifest.Files {
+			if manifest.Files[i].Path != backupDBFile {
+				tamper(&manifest.Files[i])
+			}
+		}
+		err := extractLibraryArchive(archive, manifest, t.TempDir(), nil)
+		if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+			t.Errorf("%s: expected a checksum mismatch, got %v", name, err)
+		}
+	}
+}
+
+func TestExtractLibraryArchiveMissingFiles(t *testing.T) {
+	archive, manifest := buildLibraryArchive(t, map[string]string{backupDBFile: "database"})
+	manifest.Files = append(manifest.Files, libraryArchiveFile{Path: "coverArt/uid/gone.webp", Size: 1, SHA256: strings.Repeat("0", 64)})
+	err := extractLibraryArchive(archive, manifest, t.TempDir(), nil)
+	if err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("expected a missing file error, got %v", err)
+	}
+
+	archive, manifest = buildLibraryArchive(t, map[string]string{"coverArt/uid/x.webp": "cover"})
+	err = extractLibraryArchive(archive, manifest, t.TempDir(), nil)
+	if err == nil || !strings.Contains(err.Error(), "no database") {
+		t.Errorf("expected a no database error, got %v", err)
+	}
+}
+
+func TestExtractLibraryArchiveWithoutScreenshots(t *testing.T) {
+	archive, manifest := buildLibraryArchive(t, map[string]string{backupDBFile: "database", "screenshots/uid/a.webp": "shot"})
+	manifest.IncludesScreenshots = false
+	err := extractLibraryArchive(archive, manifest, t.TempDir(), nil)
+	if err == nil {
+		t.Error("screenshots accepted in an archive that says it has none")
+	}
+}
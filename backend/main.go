@@ -5,24 +5,23 @@ import (
 	"context"
 	"crypto/md5"
 	"database/sql"
-	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"image"
 	_ "image/gif"
 	_ "image/jpeg"
 	_ "image/png"
 	"io"
-	"io/ioutil"
 	"log"
 	"net/http"
-	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -31,8 +30,6 @@ import (
 
 	_ "modernc.org/sqlite"
 
-	"github.com/HugoSmits86/nativewebp"
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
@@ -42,14 +39,12 @@ var (
 	mu      sync.Mutex
 )
 
-func bail(err error) {
+func initLogFile() {
+	err := os.MkdirAll(filepath.Dir(config.LogFile), 0755)
 	if err != nil {
-		panic(err)
+		log.Fatalf("Failed to create log folder: %v", err)
 	}
-}
-
-func initLogFile() {
-	logFile, err := os.OpenFile("server.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	logFile, err := os.OpenFile(config.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
 		log.Fatalf("Failed to open log file: %v", err)
 	}
@@ -63,16 +58,59 @@ func initLogFile() {
 }
 
 func main() {
+	backfillImages := flag.Bool("backfill-images", false, "generate missing thumbnail and medium image variants, then exit")
+	triggerScreenshot := flag.Bool("screenshot", false, "ask the running backend to capture the active game, for desktop hotkeys")
+	setPassword := flag.Bool("set-web-password", false, "read a new web password from stdin, then exit")
+	configFlags := registerConfigFlags()
+	flag.Parse()
+
+	if *triggerScreenshot {
+		path, err := sendTriggerCommand("screenshot")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Println(path)
+		return
+	}
+
+	initConfig(configFlags)
+	if *setPassword {
+		initSecrets()
+		if err := setWebPassword(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Println("Web password updated")
+		return
+	}
 	initLogFile()
+	logConfig()
+	checkWebFrontend()
 	checkAndCreateDB()
 	checkAndCreateFolders()
+	initSecrets()
 	initAPIKeys()
+	initAPIToken()
 
 	err := connectToDB()
 	if err != nil {
 		log.Fatalf("could not connect to DB %v", err)
 	}
 	handleDBVersion()
+	err = interruptStaleJobs()
+	if err != nil {
+		log.Printf("error marking interrupted jobs %v", err)
+	}
+	if *backfillImages {
+		total, failed := backfillImageVariants(nil)
+		log.Printf("image backfill finished: %d images, %d failed", total, len(failed))
+		closeDB()
+		return
+	}
+
+	startSteamScreenshotWatch()
+	startScreenshotTrigger()
 
 	ctx, cancel := context.WithCancel(context.Background())
 	go handleShutdown(cancel)
@@ -85,10 +123,12 @@ func main() {
 	if err != nil {
 		log.Printf("error checking manual installed validity %v", err)
 	}
-	go startSSEListener()
 	go routing()
+	startBackupScheduler()
 
 	<-ctx.Done()
+	jobs.stopAll()
+	backupOnExit()
 	closeDB()
 }
 
@@ -289,23 +329,140 @@ func getGameDetails(UID string) (map[string]interface{}, error) {
 		}
 	}
 
+	screenshotURLs := make(map[string]map[int]map[string]string)
+	screenshotURLs[UID] = make(map[int]map[string]string)
+
 	index := 0
 	for _, entry := range entries {
 		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".webp") {
 			screenshotPath := fmt.Sprintf("%s/%s", UID, entry.Name())
 			screenshots[UID][index] = screenshotPath
+			screenshotURLs[UID][index] = imageURLs("screenshots/" + screenshotPath)
 			index++
 		}
 	}
 
+	// Query 5: Typed IGDB metadata
+	igdbID, err := getStoredIgdbID(UID)
+	if err != nil {
+		return nil, err
+	}
+	igdb := igdbTypedInfo{IgdbID: igdbID}
+	namedLists := map[string]*[]string{
+		"Genres":             &igdb.Genres,
+		"GameModes":          &igdb.GameModes,
+		"PlayerPerspectives": &igdb.PlayerPerspectives,
+		"Themes":             &igdb.Themes,
+		"GameEngines":        &igdb.GameEngines,
+		"Franchises":         &igdb.Franchises,
+		"Collections":        &igdb.Collections,
+	}
+	for _, table := range igdbNamedTables {
+		names, err := getGameDetailsNamedList(table, UID)
+		if err != nil {
+			return nil, err
+		}
+		*namedLists[table] = names
+	}
+
+	ageRatings := []igdbAgeRating{}
+	rows, err = readDB.Query("SELECT Organization, Rating FROM AgeRatings WHERE UID = ?", UID)
+	if err != nil {
+		return nil, fmt.Errorf("query error AgeRatings: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var ageRating igdbAgeRating
+		err := rows.Scan(&ageRating.Organization, &ageRating.Rating)
+		if err != nil {
+			return nil, fmt.Errorf("scan error AgeRatings: %w", err)
+		}
+		ageRatings = append(ageRatings, ageRating)
+	}
+	igdb.AgeRatings = ageRatings
+
+	websites := []igdbWebsite{}
+	rows, err = readDB.Query("SELECT Category, URL FROM Websites WHERE UID = ?", UID)
+	if err != nil {
+		return nil, fmt.Errorf("query error Websites: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var website igdbWebsite
+		err := rows.Scan(&website.Category, &website.URL)
+		if err != nil {
+			return nil, fmt.Errorf("scan error Websites: %w", err)
+		}
+		websites = append(websites, website)
+	}
+	igdb.Websites = websites
+
+	similarGames := []igdbTypedSimilarGame{}
+	rows, err = readDB.Query("SELECT IgdbID, Name FROM SimilarGames WHERE UID = ?", UID)
+	if err != nil {
+		return nil, fmt.Errorf("query error SimilarGames: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var similarGame igdbTypedSimilarGame
+		err := rows.Scan(&similarGame.IgdbID, &similarGame.Name)
+		if err != nil {
+			return nil, fmt.Errorf("scan error SimilarGames: %w", err)
+		}
+		similarGames = append(similarGames, similarGame)
+	}
+	igdb.SimilarGames = similarGames
+
 	MetaData := make(map[string]interface{})
 	MetaData["m"] = m
 	MetaData["tags"] = tags
 	MetaData["companies"] = companies
 	MetaData["screenshots"] = screenshots
+	MetaData["igdb"] = igdb
+
+	artwork, err := getArtwork(UID)
+	if err != nil {
+		return nil, err
+	}
+	MetaData["artwork"] = artwork
+	MetaData["screenshotURLs"] = screenshotURLs
+
+	screenshotRecords, _, err := listScreenshots(screenshotFilter{UID: UID}, 1, screenshotMaxPageSize)
+	if err != nil {
+		return nil, err
+	}
+	MetaData["screenshotRecords"] = screenshotRecords
+
+	artworkURLs := make(map[string]map[string]string)
+	for assetType, path := range artwork {
+		artworkURLs[assetType] = imageURLs(path)
+	}
+	MetaData["artworkURLs"] = artworkURLs
+	if game, ok := m[UID]; ok {
+		game["CoverArtURLs"] = artworkURLs[artworkCover]
+	}
 	return MetaData, nil
 }
 
+func getGameDetailsNamedList(table string, UID string) ([]string, error) {
+	rows, err := readDB.Query(fmt.Sprintf("SELECT Name FROM %s WHERE UID = ?", table), UID)
+	if err != nil {
+		return nil, fmt.Errorf("query error %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		err := rows.Scan(&name)
+		if err != nil {
+			return nil, fmt.Errorf("scan error %s: %w", table, err)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
 func setFilter(FilterStruct FilterStruct) error {
 
 	err := txWrite(func(tx *sql.Tx) error {
@@ -504,17 +661,18 @@ func getFilterState() (map[string][]string, error) {
 }
 
 // Repeated Call Funcs
-func post(postString string, bodyString string, accessToken string) ([]byte, error) {
+func post(ctx context.Context, postString string, bodyString string, accessToken string) ([]byte, error) {
 	data := []byte(bodyString)
 
-	req, err := http.NewRequest("POST", postString, bytes.NewBuffer(data))
+	req, err := http.NewRequestWithContext(ctx, "POST", postString, bytes.NewBuffer(data))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	defer req.Body.Close()
 
 	accessTokenStr := fmt.Sprintf("Bearer %s", accessToken)
-	req.Header.Set("Client-ID", clientID)
+	igdbClientID, _ := getIGDBCreds()
+	req.Header.Set("Client-ID", igdbClientID)
 	req.Header.Set("Authorization", accessTokenStr)
 
 	client := &http.Client{}
@@ -536,57 +694,6 @@ func post(postString string, bodyString string, accessToken string) ([]byte, err
 
 	return body, nil
 }
-func getImageFromURL(getURL string, location string, filename string) {
-	fmt.Println(getURL, location, filename)
-	err := os.MkdirAll(filepath.Dir(location), 0755)
-	bail(err)
-
-	var img image.Image
-	var response *http.Response
-
-	if strings.HasPrefix(getURL, "data:image") {
-		// Case 1: If getURL is a base64-encoded image data string (starts with "data:image")
-		// Remove the prefix ("data:image/png;base64,") and decode the base64 data
-		encodedData := strings.SplitN(getURL, ",", 2)[1] // Get the base64 part
-		imgData, err := base64.StdEncoding.DecodeString(encodedData)
-		bail(err)
-
-		// Decode the image from the byte slice
-		img, _, _ = image.Decode(bytes.NewReader(imgData))
-	} else if strings.HasPrefix(getURL, "http://") || strings.HasPrefix(getURL, "https://") {
-		// Case 2: If getURL is a URL (starts with "http://" or "https://"), download the image
-		response, err = http.Get(getURL)
-		bail(err)
-		defer response.Body.Close()
-
-		img, _, err = image.Decode(response.Body)
-		if err != nil {
-			fmt.Println(err)
-		}
-	} else {
-		// Case 3: If getURL is not a base64 string or URL, assume it's a local file path
-		imgData, err := ioutil.ReadFile(getURL)
-		if err != nil {
-			fmt.Println(err)
-		}
-		// Decode the image from the byte slice
-		img, _, err = image.Decode(bytes.NewReader(imgData))
-		if err != nil {
-			fmt.Println(err)
-		}
-	}
-
-	file, err := os.Create(location + filename)
-	bail(err)
-	defer file.Close()
-
-	if img != nil {
-		err = nativewebp.Encode(file, img, nil)
-		if err != nil {
-			fmt.Println(err)
-		}
-	}
-}
 
 // MD5HASH
 func GetMD5Hash(text string) string {
@@ -630,7 +737,19 @@ func deleteGameFromDB(uid string) error {
 		if err != nil {
 			return fmt.Errorf("error deleting Tags: %w", err)
 		}
-		return nil
+		err = txDeleteIgdbMetaData(tx, uid)
+		if err != nil {
+			return err
+		}
+		err = txDeleteArtwork(tx, uid)
+		if err != nil {
+			return err
+		}
+		err = txDeleteImageFailures(tx, uid)
+		if err != nil {
+			return err
+		}
+		return txDeleteScreenshots(tx, uid)
 	})
 	if err != nil {
 		return err
@@ -642,7 +761,10 @@ func deleteGameFromDB(uid string) error {
 	if err := os.RemoveAll(filepath.Join("coverArt", uid)); err != nil {
 		return fmt.Errorf("failed to delete screenshots for UID %s: %w", uid, err)
 	}
-	return nil
+	if err := os.RemoveAll(filepath.Join("artwork", uid)); err != nil {
+		return fmt.Errorf("failed to delete artwork for UID %s: %w", uid, err)
+	}
+	return removeImageVariantsForUID(uid)
 }
 
 func hideGame(uid string) error {
@@ -832,6 +954,11 @@ func sortDB(sortType string, order string) (map[string]interface{}, error) {
 	metadata := make(map[int]map[string]interface{})
 	i := 0
 
+	coverPaths, err := getAllCoverPaths()
+	if err != nil {
+		return nil, err
+	}
+
 	// put data in map
 	for rows.Next() {
 		var UID, Name, ReleaseDate, CoverArtPath, Description, OwnedPlatform, CustomTitle, CustomReleaseDate string
@@ -849,6 +976,11 @@ func sortDB(sortType string, order string) (map[string]interface{}, error) {
 		metadata[i]["UID"] = UID
 		metadata[i]["ReleaseDate"] = CustomReleaseDate
 		metadata[i]["CoverArtPath"] = CoverArtPath
+		coverPath, ok := coverPaths[UID]
+		if !ok {
+			coverPath = "coverArt" + CoverArtPath
+		}
+		metadata[i]["CoverArtURLs"] = imageURLs(coverPath)
 		metadata[i]["isDLC"] = isDLC
 		metadata[i]["OwnedPlatform"] = OwnedPlatform
 		metadata[i]["TimePlayed"] = CustomTimePlayed
@@ -863,7 +995,9 @@ func sortDB(sortType string, order string) (map[string]interface{}, error) {
 
 	QueryString := "SELECT * FROM HiddenGames"
 	rows, err = readDB.Query(QueryString)
-	bail(err)
+	if err != nil {
+		return nil, fmt.Errorf("db query err HiddenGames %w", err)
+	}
 	defer rows.Close()
 
 	var hiddenUidArr []string
@@ -906,41 +1040,21 @@ func getPlatforms() ([]string, error) {
 }
 
 func getNpsso() (string, error) {
-	QueryString := "SELECT * FROM PlayStationNpsso"
-	rows, err := readDB.Query(QueryString)
-	if err != nil {
-		return "", fmt.Errorf("npsso query error: %w", err)
-	}
-	defer rows.Close()
-
-	var Npsso string
-	for rows.Next() {
-		err = rows.Scan(&Npsso)
-		if err != nil {
-			return "", fmt.Errorf("npsso query error: %w", err)
-		}
-	}
-	return Npsso, nil
+	return getSecret(secretPlayStationNpsso)
 }
 
-func getSteamCreds() ([]string, error) {
-	rows, err := readDB.Query("SELECT * FROM SteamCreds")
-	if err != nil {
-		return nil, fmt.Errorf("steamcreds query error: %w", err)
+// Returns the Steam ID and the API key from the secret store
+func getSteamCreds() (string, string, error) {
+	var steamID string
+	err := readDB.QueryRow("SELECT SteamID FROM SteamCreds").Scan(&steamID)
+	if err != nil && err != sql.ErrNoRows {
+		return "", "", fmt.Errorf("steamcreds query error: %w", err)
 	}
-	defer rows.Close()
-
-	creds := []string{}
-	for rows.Next() {
-		var steamID, steamAPIKey string
-		err = rows.Scan(&steamID, &steamAPIKey)
-		if err != nil {
-			return nil, fmt.Errorf("steamcreds scan err: %w", err)
-		}
-		creds = append(creds, steamID)
-		creds = append(creds, steamAPIKey)
+	apiKey, err := getSecret(secretSteamAPIKey)
+	if err != nil {
+		return "", "", err
 	}
-	return creds, nil
+	return steamID, apiKey, nil
 }
 
 func updatePreferences(uid string, checkedParams map[string]bool, params map[string]string) error {
@@ -1077,7 +1191,8 @@ func getPreferences(uid string) (map[string]interface{}, error) {
 	return preferences, nil
 }
 
-func setCustomImage(UID string, coverImage string, ssImage []string) error {
+// artwork holds hero/logo/icon sources, nil leaves an asset untouched and "" removes it
+func setCustomImage(UID string, coverImage string, ssImage []string, artwork map[string]*string) error {
 
 	var keepList []string
 	var wg sync.WaitGroup
@@ -1095,7 +1210,10 @@ func setCustomImage(UID string, coverImage string, ssImage []string) error {
 			defer wg.Done()
 			location := fmt.Sprintf(`%s/%s/`, "screenshots", UID)
 			fileName := fmt.Sprintf("User-%d.webp", idx)
-			getImageFromURL(img, location, fileName)
+			err := downloadImage(imageDownload{UID: UID, AssetType: "screenshot", Origin: screenshotSourceUser, Source: img, Location: location, Filename: fileName})
+			if err != nil {
+				return
+			}
 			mu.Lock()
 			keepList = append(keepList, location+fileName)
 			mu.Unlock()
@@ -1107,19 +1225,54 @@ func setCustomImage(UID string, coverImage string, ssImage []string) error {
 			keepList = append(keepList, strings.TrimPrefix(coverImage, "./backend/"))
 
 		} else {
-			getString := coverImage
-			location := fmt.Sprintf(`%s/%s/`, "coverArt", UID)
-			filename := fmt.Sprintf(`%s-%d.webp`, UID, 0)
-			getImageFromURL(getString, location, filename)
-			keepList = append(keepList, (location + filename))
+			path, err := setArtwork(UID, artworkCover, coverImage, "custom")
+			if err != nil {
+				return fmt.Errorf("error setting cover: %w", err)
+			}
+			keepList = append(keepList, path)
+		}
+	}
+
+	currentArtwork, err := getArtwork(UID)
+	if err != nil {
+		return err
+	}
+	for _, assetType := range []string{artworkHero, artworkLogo, artworkIcon} {
+		source := artwork[assetType]
+		switch {
+		case source == nil:
+			if path, ok := currentArtwork[assetType]; ok {
+				keepList = append(keepList, path)
+			}
+		case *source == "":
+			err := removeArtwork(UID, assetType)
+			if err != nil {
+				return err
+			}
+		case strings.HasPrefix(*source, "./backend"):
+			keepList = append(keepList, strings.TrimPrefix(*source, "./backend/"))
+		default:
+			path, err := setArtwork(UID, assetType, *source, "custom")
+			if err != nil {
+				return fmt.Errorf("error setting %s: %w", assetType, err)
+			}
+			keepList = append(keepList, path)
 		}
 	}
 
 	wg.Wait()
 
+	if coverImage == "" {
+		err := removeArtwork(UID, artworkCover)
+		if err != nil {
+			return err
+		}
+	}
+
 	allDirs := []string{
 		fmt.Sprintf("screenshots/%s", UID),
 		fmt.Sprintf("coverArt/%s", UID),
+		fmt.Sprintf("artwork/%s", UID),
 	}
 	keepSet := make(map[string]struct{})
 	for _, path := range keepList {
@@ -1139,6 +1292,10 @@ func setCustomImage(UID string, coverImage string, ssImage []string) error {
 			continue
 		}
 		for _, file := range files {
+			// Kept originals and variants live in subfolders and follow their preview
+			if file.IsDir() {
+				continue
+			}
 			fullPath := filepath.Join(dir, file.Name())
 			absFullPath, err := filepath.Abs(fullPath)
 			if err != nil {
@@ -1154,7 +1311,8 @@ func setCustomImage(UID string, coverImage string, ssImage []string) error {
 		}
 	}
 
-	return nil
+	_, _, err = reconcileScreenshots(UID)
+	return err
 }
 
 func normalizeReleaseDate(input string) string {
@@ -1201,62 +1359,6 @@ func normalizeReleaseDate(input string) string {
 	return output
 }
 
-var sseClients = make(map[chan string]bool) // List of clients for SSE notifications
-var sseBroadcast = make(chan string)        // Used to broadcast messages to all connected clients
-// Function runs indefinately, waits for a SSE messages and sends to all connected clients
-func handleSSEClients() {
-	for {
-		// Wait for broadcast message
-		msg := <-sseBroadcast
-		// Send message to all connected clients
-		for client := range sseClients {
-			client <- msg
-		}
-	}
-}
-
-// Starts SSE in a goRoutine
-func startSSEListener() {
-	go handleSSEClients()
-}
-
-func addSSEClient(c *gin.Context) {
-	// Set headers for SSE
-	c.Header("Content-Type", "text/event-stream")
-	c.Header("Cache-Control", "no-cache")
-	c.Header("Connection", "keep-alive")
-
-	// Create a new channel for client
-	clientChan := make(chan string)
-
-	// Register client channel
-	sseClients[clientChan] = true
-
-	// Listen for client closure
-	defer func() {
-		delete(sseClients, clientChan)
-		close(clientChan)
-	}()
-
-	c.SSEvent("message", "Connected to SSE server")
-
-	// Infinite loop to listen for messages
-	for {
-		msg := <-clientChan
-		c.SSEvent("message", msg)
-		c.Writer.Flush()
-	}
-}
-func sendSSEMessage(msg string) {
-	fmt.Println("Sending SSE:", msg)
-	select {
-	case sseBroadcast <- msg:
-		fmt.Println("SSE message sent successfully")
-	default:
-		log.Println("SSE channel blocked, dropping message")
-	}
-}
-
 func setupRouter() *gin.Engine {
 
 	var appID int
@@ -1270,9 +1372,18 @@ func setupRouter() *gin.Engine {
 	var gameStruct igdbSearchResult
 
 	r := gin.Default()
-	r.Use(cors.Default())
-
-	r.GET("/sse-steam-updates", addSSEClient)
+	r.Use(apiCORS())
+	r.Use(requireAPIToken())
+	r.Use(maintenanceGate())
+	r.NoRoute(serveWeb, apiNotFound)
+	registerWebRoutes(r)
+	setupAPIv1(r)
+	registerRemoteRoutes(r)
+
+	// Every event, for the desktop UI. Same stream as /api/v1/events without a filter.
+	r.GET("/sse-steam-updates", func(c *gin.Context) {
+		streamEvents(c, eventTypeFilter(nil), nil)
+	})
 
 	basicInfoHandler := func(c *gin.Context) {
 		c.Header("Cache-Control", "no-store")
@@ -1288,6 +1399,9 @@ func setupRouter() *gin.Engine {
 	}
 
 	r.GET("/", func(c *gin.Context) {
+		if serveWebIndex(c) {
+			return
+		}
 		c.JSON(200, gin.H{
 			"message": "Go server is up!",
 		})
@@ -1328,6 +1442,45 @@ func setupRouter() *gin.Engine {
 		c.JSON(http.StatusOK, gin.H{"platforms": PlatformList})
 	})
 
+	r.GET("/getIgdbFacets", func(c *gin.Context) {
+		fmt.Println("Recieved Get IGDB Facets")
+		facets, err := getIgdbFacets()
+		if err != nil {
+			log.Printf("[GetIgdbFacets] ERROR : %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get IGDB facets", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"facets": facets})
+	})
+
+	r.POST("/refreshIgdbMetadata", func(c *gin.Context) {
+		var data struct {
+			UID    string `json:"uid"`
+			IgdbID int    `json:"igdbID"`
+		}
+		if err := c.BindJSON(&data); err != nil {
+			log.Printf("[RefreshIgdbMetadata] ERROR invalid req payload: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		fmt.Println("Recieved Refresh IGDB Metadata", data.UID)
+
+		var err error
+		if data.IgdbID != 0 {
+			// Explicit id links (or relinks) the game to an IGDB entry
+			err = storeIgdbMetaDataForUID(c.Request.Context(), data.UID, data.IgdbID)
+		} else {
+			err = refreshIgdbMetaData(c.Request.Context(), data.UID)
+		}
+		if err != nil {
+			log.Printf("[RefreshIgdbMetadata] ERROR : %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh IGDB metadata", "details": err.Error()})
+			return
+		}
+		publishEvent(eventGameUpdated, gameEvent{UID: data.UID})
+		c.JSON(http.StatusOK, gin.H{"status": "OK"})
+	})
+
 	r.POST("/setFilter", func(c *gin.Context) {
 		// Define the structure of the filter data
 		var FilterStruct FilterStruct
@@ -1349,10 +1502,10 @@ func setupRouter() *gin.Engine {
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{"HttpStatus": "ok"})
-		sendSSEMessage("Set Filter")
+		publishEvent(eventFilterChanged, nil)
 	})
 
-	r.GET("/clearAllFilters", func(c *gin.Context) {
+	r.POST("/clearAllFilters", func(c *gin.Context) {
 		fmt.Println("Recieved Clear Filter")
 		err := clearFilter()
 		if err != nil {
@@ -1361,7 +1514,7 @@ func setupRouter() *gin.Engine {
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{"HttpStatus": "ok"})
-		sendSSEMessage("Clear Filter")
+		publishEvent(eventFilterChanged, nil)
 	})
 
 	r.POST("/deleteCurrentlyFiltered", func(c *gin.Context) {
@@ -1384,7 +1537,7 @@ func setupRouter() *gin.Engine {
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{"HttpStatus": "ok"})
-		sendSSEMessage("deleted games")
+		publishEvent(eventGameDeleted, gameEvent{UIDs: req.UIDs})
 	})
 
 	r.POST("/hideCurrentlyFiltered", func(c *gin.Context) {
@@ -1406,7 +1559,7 @@ func setupRouter() *gin.Engine {
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{"HttpStatus": "ok"})
-		sendSSEMessage("hidden games")
+		publishEvent(eventGameHidden, gameEvent{UIDs: req.UIDs})
 	})
 
 	r.POST("/unHideCurrentlyFiltered", func(c *gin.Context) {
@@ -1428,7 +1581,7 @@ func setupRouter() *gin.Engine {
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{"HttpStatus": "ok"})
-		sendSSEMessage("unhidden games")
+		publishEvent(eventGameUnhidden, gameEvent{UIDs: req.UIDs})
 	})
 
 	r.GET("/LoadFilters", func(c *gin.Context) {
@@ -1454,7 +1607,7 @@ func setupRouter() *gin.Engine {
 		c.JSON(http.StatusOK, gin.H{"metadata": metaData})
 	})
 
-	r.GET("/DeleteGame", func(c *gin.Context) {
+	r.DELETE("/DeleteGame", func(c *gin.Context) {
 		fmt.Println("Recieved Delete Game")
 		UID := c.Query("uid")
 		err := deleteGameFromDB(UID)
@@ -1463,11 +1616,11 @@ func setupRouter() *gin.Engine {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete game", "details": err.Error()})
 			return
 		}
-		sendSSEMessage("Deleted Game")
+		publishEvent(eventGameDeleted, gameEvent{UID: UID})
 		c.JSON(http.StatusOK, gin.H{"HttpStatus": "ok"})
 	})
 
-	r.GET("/HideGame", func(c *gin.Context) {
+	r.POST("/HideGame", func(c *gin.Context) {
 		fmt.Println("Recieved Hide Game")
 		UID := c.Query("uid")
 		err := hideGame(UID)
@@ -1476,11 +1629,11 @@ func setupRouter() *gin.Engine {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hide game", "details": err.Error()})
 			return
 		}
-		sendSSEMessage("Hidden Game")
+		publishEvent(eventGameHidden, gameEvent{UID: UID})
 		c.JSON(http.StatusOK, gin.H{"HttpStatus": "ok"})
 	})
 
-	r.GET("/unhideGame", func(c *gin.Context) {
+	r.POST("/unhideGame", func(c *gin.Context) {
 		fmt.Println("Recieved UnHide Game")
 		UID := c.Query("uid")
 		err := unhideGame(UID)
@@ -1489,72 +1642,82 @@ func setupRouter() *gin.Engine {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hide game", "details": err.Error()})
 			return
 		}
-		sendSSEMessage("Un-Hidden Game")
+		publishEvent(eventGameUnhidden, gameEvent{UID: UID})
 		c.JSON(http.StatusOK, gin.H{"HttpStatus": "ok"})
 	})
 
+	// Credential endpoints only say whether a secret is stored, never its value
 	r.GET("/Npsso", func(c *gin.Context) {
 		fmt.Println("Recieved Npsso")
-		Npsso, err := getNpsso()
+		configured, err := secretConfigured(secretPlayStationNpsso)
 		if err != nil {
 			log.Printf("[NPSSO] ERROR : %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get npsso", "details": err.Error()})
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"Npsso": Npsso})
+		c.JSON(http.StatusOK, gin.H{"configured": configured})
 	})
 
 	r.GET("/SteamCreds", func(c *gin.Context) {
 		fmt.Println("Recieved SteamCreds")
-		SteamCreds, err := getSteamCreds()
+		steamID, apiKey, err := getSteamCreds()
 		if err != nil {
 			log.Printf("[SteamCreds] ERROR : %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get steam credentials", "details": err.Error()})
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"SteamCreds": SteamCreds})
+		c.JSON(http.StatusOK, gin.H{"steamId": steamID, "configured": apiKey != ""})
+	})
+
+	r.GET("/credentials", func(c *gin.Context) {
+		status, err := getCredentialStatus()
+		if err != nil {
+			log.Printf("[Credentials] ERROR : %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not get credential status", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"store": secrets.Name(), "configured": status})
+	})
+
+	r.POST("/igdbCreds", func(c *gin.Context) {
+		var data struct {
+			ClientID     string `json:"clientId"`
+			ClientSecret string `json:"clientSecret"`
+		}
+		if err := c.BindJSON(&data); err != nil {
+			log.Printf("[IgdbCreds] ERROR invalid req payload: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		err := setSecret(secretIGDBClientID, data.ClientID)
+		if err == nil {
+			err = setSecret(secretIGDBClientSecret, data.ClientSecret)
+		}
+		if err != nil {
+			log.Printf("[IgdbCreds] ERROR : %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not save igdb credentials", "details": err.Error()})
+			return
+		}
+		initAPIKeys()
+		c.JSON(http.StatusOK, gin.H{"status": "OK"})
 	})
 
-	r.GET("/LaunchGame", func(c *gin.Context) {
+	r.POST("/LaunchGame", func(c *gin.Context) {
 		fmt.Println("Received Launch Game")
-		uid := c.Query("uid")
-		appid, err := getSteamAppID(uid)
+		launched, err := launchGame(c.Query("uid"))
 		if err != nil {
 			log.Printf("[LaunchGame] ERROR : %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to launch game", "details": err.Error()})
 			return
 		}
-		if appid != 0 {
-			err := launchSteamGame(appid)
-			if err != nil {
-				log.Printf("[LaunchGame] ERROR : %v", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to launch steam game", "details": err.Error()})
-				return
-			}
-			c.JSON(http.StatusOK, gin.H{"LaunchStatus": "Launched"})
-		} else {
-			path, err := getGamePath(uid)
-			if err != nil {
-				log.Printf("[LaunchGame] ERROR : %v", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to launch game", "details": err.Error()})
-				return
-			}
-			if path == "" {
-				c.JSON(http.StatusOK, gin.H{"LaunchStatus": "ToAddPath"})
-			} else {
-				err := launchGameFromPath(path, uid)
-				if err != nil {
-					log.Printf("[LaunchGame] ERROR : %v", err)
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to launch game", "details": err.Error()})
-					return
-				}
-				sendSSEMessage("Game quit, updated playtime")
-				c.JSON(http.StatusOK, gin.H{"LaunchStatus": "Launched"})
-			}
+		if !launched {
+			c.JSON(http.StatusOK, gin.H{"LaunchStatus": "ToAddPath"})
+			return
 		}
+		c.JSON(http.StatusOK, gin.H{"LaunchStatus": "Launched"})
 	})
 
-	r.GET("/steamInstallReq", func(c *gin.Context) {
+	r.POST("/steamInstallReq", func(c *gin.Context) {
 		fmt.Println("Received Steam Install Req")
 		uid := c.Query("uid")
 		appid, err := getSteamAppID(uid)
@@ -1574,7 +1737,7 @@ func setupRouter() *gin.Engine {
 		}
 	})
 
-	r.GET("/setGamePath", func(c *gin.Context) {
+	r.POST("/setGamePath", func(c *gin.Context) {
 		uid := c.Query("uid")
 		path := c.Query("path")
 		fmt.Println("Received Set Game Path", uid, path)
@@ -1584,7 +1747,7 @@ func setupRouter() *gin.Engine {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to set game path", "details": err.Error()})
 			return
 		}
-		sendSSEMessage("Set Game Path")
+		publishEvent(eventGameUpdated, gameEvent{UID: uid})
 		c.JSON(http.StatusOK, gin.H{"status": "ok"})
 	})
 
@@ -1600,12 +1763,29 @@ func setupRouter() *gin.Engine {
 		c.JSON(http.StatusOK, gin.H{"path": path})
 	})
 
-	//Not USED YET
+	// Candidates from IGDB, the Steam store and SteamGridDB, provider picks one of them or "mock"
+	r.GET("/searchImages", func(c *gin.Context) {
+		limit, _ := strconv.Atoi(c.Query("limit"))
+		query := imageSearchQuery{UID: c.Query("uid"), Term: c.Query("term"), Limit: limit}
+		candidates, failures, err := searchImages(c.Query("provider"), query)
+		if err != nil {
+			log.Printf("[SearchImages] ERROR : %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "could not search images", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"candidates": candidates, "errors": failures})
+	})
+
+	// Kept for older frontends, same as /searchImages?term=
 	r.GET("/AddScreenshot", func(c *gin.Context) {
 		fmt.Println("Received AddScreenshot")
-		screenshotString := c.Query("string")
-		findLinksForScreenshot(screenshotString)
-		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+		candidates, failures, err := searchImages("", imageSearchQuery{Term: c.Query("string")})
+		if err != nil {
+			log.Printf("[AddScreenshot] ERROR : %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "could not search images", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"candidates": candidates, "errors": failures})
 	})
 
 	r.POST("/IGDBsearch", func(c *gin.Context) {
@@ -1614,13 +1794,13 @@ func setupRouter() *gin.Engine {
 			return
 		}
 		gameToFind := data.NameToSearch
-		accessToken, err := getAccessToken(clientID, clientSecret)
+		accessToken, err := getAccessToken(c.Request.Context())
 		if err != nil {
 			log.Printf("[IGDBSearch] ERROR : %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to obtain IGDB access token", "details": err.Error()})
 			return
 		}
-		gameStruct, err = searchGame(accessToken, gameToFind)
+		gameStruct, err = searchGame(c.Request.Context(), accessToken, gameToFind)
 		if err != nil {
 			log.Printf("[IGDBSearch] ERROR : %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search on IGDB", "details": err.Error()})
@@ -1647,14 +1827,14 @@ func setupRouter() *gin.Engine {
 		fmt.Println("Received Get IGDB Info")
 		appID = data.Key
 
-		accessToken, err := getAccessToken(clientID, clientSecret)
+		accessToken, err := getAccessToken(c.Request.Context())
 		if err != nil {
 			log.Printf("[GetIGDBInfo] ERROR : %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to obtain IGDB access token", "details": err.Error()})
 			return
 		}
 
-		metaData, err := getMetaData(appID, gameStruct, accessToken, "PlayStation 4")
+		metaData, err := getMetaData(c.Request.Context(), appID, gameStruct, accessToken, "PlayStation 4")
 		if err != nil {
 			log.Printf("[GetIGDBInfo] ERROR : %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get game metadata", "details": err.Error()})
@@ -1698,14 +1878,25 @@ func setupRouter() *gin.Engine {
 
 		fmt.Println("Received Add Game To DB", title, releaseDate, platform, timePlayed, rating, "\n", devs, tags, descripton, coverImage, screenshots)
 
-		insertionStatus, err := addGameToDB(title, releaseDate, platform, timePlayed, rating, devs, tags, descripton, coverImage, screenshots, isWishlist)
+		// Typed metadata is optional, manually entered games have none
+		var typedMetaData igdbMetaData
+		if gameData.Igdb != nil {
+			typedMetaData = gameData.Igdb.metaData()
+		}
+		if gameData.IgdbID != 0 {
+			typedMetaData.IgdbID = gameData.IgdbID
+		}
+
+		insertionStatus, err := addGameToDB(title, releaseDate, platform, timePlayed, rating, devs, tags, descripton, coverImage, screenshots, isWishlist, typedMetaData)
 		if err != nil {
 			log.Printf("[AddGameToDB] ERROR : %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to insert game", "details": err.Error()})
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{"insertionStatus": insertionStatus})
-		sendSSEMessage("Inserted Game")
+		if insertionStatus {
+			publishEvent(eventGameAdded, gameEvent{Name: title})
+		}
 	})
 
 	r.POST("/SteamImport", func(c *gin.Context) {
@@ -1718,22 +1909,14 @@ func setupRouter() *gin.Engine {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		SteamID := data.SteamID
-		APIkey := data.APIkey
-
-		err := updateSteamCreds(SteamID, APIkey)
-		if err != nil {
-			log.Printf("[SteamImport] ERROR : %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update steam credentials", "details": err.Error()})
-			return
-		}
-		err = steamImportUserGames(SteamID, APIkey)
-		if err != nil {
+		// Runs as a job, an import that is already running is returned instead of a new one
+		job, err := startSourceImport(importRequest{Source: importSourceSteam, SteamID: data.SteamID, APIKey: data.APIkey})
+		if err != nil && !errors.Is(err, errJobRunning) {
 			log.Printf("[SteamImport] ERROR : %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Steam Import Failed", "details": err.Error()})
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"error": false})
+		c.JSON(http.StatusAccepted, job)
 	})
 
 	r.POST("/PlayStationImport", func(c *gin.Context) {
@@ -1745,20 +1928,13 @@ func setupRouter() *gin.Engine {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		npsso := data.Npsso
-		err := updateNpsso(npsso)
-		if err != nil {
-			log.Printf("[PlayStationImport] ERROR : %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update NPSSO", "details": err.Error()})
-			return
-		}
-		gamesNotMatched, err := playstationImportUserGames(npsso, clientID, clientSecret)
-		if err != nil {
+		job, err := startSourceImport(importRequest{Source: importSourcePlayStation, Npsso: data.Npsso})
+		if err != nil && !errors.Is(err, errJobRunning) {
 			log.Printf("[PlayStationImport] ERROR : %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "PSN Import Failed", "details": err.Error()})
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"gamesNotMatched": gamesNotMatched})
+		c.JSON(http.StatusAccepted, job)
 	})
 
 	r.GET("/LoadPreferences", func(c *gin.Context) {
@@ -1840,7 +2016,7 @@ func setupRouter() *gin.Engine {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not save preferences", "details": err.Error()})
 			return
 		}
-		sendSSEMessage("Game added: Saved Preferences")
+		publishEvent(eventGameUpdated, gameEvent{UID: uid})
 		c.JSON(http.StatusOK, gin.H{"status": "OK"})
 	})
 
@@ -1849,6 +2025,9 @@ func setupRouter() *gin.Engine {
 			UID        string   `json:"uid"`
 			CoverImage string   `json:"coverImage"`
 			SsImage    []string `json:"ssImage"`
+			HeroImage  *string  `json:"heroImage"`
+			LogoImage  *string  `json:"logoImage"`
+			IconImage  *string  `json:"iconImage"`
 		}
 		if err := c.BindJSON(&data); err != nil {
 			log.Printf("[SetCustomImage] ERROR invalid req payload: %v", err)
@@ -1856,160 +2035,375 @@ func setupRouter() *gin.Engine {
 			return
 		}
 		fmt.Println("Recieved Set Custom Image", data.UID)
-		err := setCustomImage(data.UID, data.CoverImage, data.SsImage)
+		artwork := map[string]*string{
+			artworkHero: data.HeroImage,
+			artworkLogo: data.LogoImage,
+			artworkIcon: data.IconImage,
+		}
+		err := setCustomImage(data.UID, data.CoverImage, data.SsImage, artwork)
 		if err != nil {
 			log.Printf("[SetCustomImage] ERROR : %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not set custom image", "details": err.Error()})
 			return
 		}
-		sendSSEMessage("Game added: Saved Preferences")
+		publishEvent(eventGameUpdated, gameEvent{UID: data.UID})
 		c.JSON(http.StatusOK, gin.H{"status": "OK"})
 	})
 
-	r.GET("/takeScreenshot", func(c *gin.Context) {
-		fmt.Println("Received Take Screenshot")
+	r.GET("/artwork", func(c *gin.Context) {
 		uid := c.Query("uid")
-		err := takeScreenshot(uid)
+		artwork, err := getArtwork(uid)
 		if err != nil {
-			log.Printf("[TakeScreenshot] ERROR : %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not get preferences", "details": err.Error()})
+			log.Printf("[Artwork] ERROR : %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not get artwork", "details": err.Error()})
 			return
 		}
-
-		c.JSON(http.StatusOK, gin.H{"status": http.StatusOK})
+		c.JSON(http.StatusOK, gin.H{"artwork": artwork})
 	})
 
-	r.GET("/image-proxy", func(c *gin.Context) {
-		encodedUrl := c.Query("url")
-		if encodedUrl == "" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "missing url parameter"})
+	r.GET("/artworkCandidates", func(c *gin.Context) {
+		uid := c.Query("uid")
+		assetType := c.Query("type")
+		sgdbID, _ := strconv.Atoi(c.Query("sgdbID"))
+		fmt.Println("Received Artwork Candidates", uid, assetType)
+		candidates, err := findArtworkCandidates(uid, assetType, sgdbID)
+		if err != nil {
+			log.Printf("[ArtworkCandidates] ERROR : %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not find artwork", "details": err.Error()})
 			return
 		}
+		c.JSON(http.StatusOK, gin.H{"candidates": candidates})
+	})
 
-		// Decode URL only once (you had duplicate ParseRequestURI calls)
-		imageUrl, err := url.QueryUnescape(encodedUrl)
+	r.GET("/searchSteamGridDB", func(c *gin.Context) {
+		term := c.Query("term")
+		games, err := searchSteamGridDB(term)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid url encoding"})
+			log.Printf("[SearchSteamGridDB] ERROR : %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not search steamgriddb", "details": err.Error()})
 			return
 		}
+		c.JSON(http.StatusOK, gin.H{"games": games})
+	})
 
-		imageUrl = strings.ReplaceAll(imageUrl, `\u003d`, "=")
-		imageUrl = strings.ReplaceAll(imageUrl, `\u0026`, "&")
-
-		// Validate URL more thoroughly
-		parsedUrl, err := url.ParseRequestURI(imageUrl)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid url"})
+	r.POST("/setArtwork", func(c *gin.Context) {
+		var data struct {
+			UID      string `json:"uid"`
+			Type     string `json:"type"`
+			URL      string `json:"url"`
+			Provider string `json:"provider"`
+		}
+		if err := c.BindJSON(&data); err != nil {
+			log.Printf("[SetArtwork] ERROR invalid req payload: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-
-		// Security: Only allow HTTP/HTTPS protocols
-		if parsedUrl.Scheme != "http" && parsedUrl.Scheme != "https" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid url scheme"})
+		if data.Provider == "" {
+			data.Provider = "custom"
+		}
+		path, err := setArtwork(data.UID, data.Type, data.URL, data.Provider)
+		if err != nil {
+			log.Printf("[SetArtwork] ERROR : %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not set artwork", "details": err.Error()})
 			return
 		}
+		publishEvent(eventGameUpdated, gameEvent{UID: data.UID})
+		c.JSON(http.StatusOK, gin.H{"path": path})
+	})
 
-		// Security: Add domain allowlist if needed (example)
-		// if !isAllowedDomain(parsedUrl.Host) {
-		//     c.JSON(http.StatusForbidden, gin.H{"error": "domain not allowed"})
-		//     return
-		// }
+	r.POST("/autoFillArtwork", func(c *gin.Context) {
+		var data struct {
+			UID string `json:"uid"`
+		}
+		if err := c.BindJSON(&data); err != nil {
+			log.Printf("[AutoFillArtwork] ERROR invalid req payload: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		artwork, err := autoFillArtwork(data.UID)
+		if err != nil {
+			log.Printf("[AutoFillArtwork] ERROR : %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not fill artwork", "details": err.Error()})
+			return
+		}
+		publishEvent(eventGameUpdated, gameEvent{UID: data.UID})
+		c.JSON(http.StatusOK, gin.H{"artwork": artwork})
+	})
 
-		client := &http.Client{
-			Timeout: 15 * time.Second,
-			// Add redirect policy
-			CheckRedirect: func(req *http.Request, via []*http.Request) error {
-				if len(via) >= 3 { // Limit redirects
-					return fmt.Errorf("too many redirects")
-				}
-				// Security: Verify redirect URLs
-				if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
-					return fmt.Errorf("invalid redirect scheme")
-				}
-				return nil
-			},
+	r.POST("/setSteamGridDBKey", func(c *gin.Context) {
+		var data struct {
+			APIKey  string `json:"apiKey"`
+			BaseURL string `json:"baseURL"`
+		}
+		if err := c.BindJSON(&data); err != nil {
+			log.Printf("[SetSteamGridDBKey] ERROR invalid req payload: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
+		err := setSecret(secretSteamGridDBKey, data.APIKey)
+		if err == nil {
+			err = setSetting(steamGridDBBaseURLSetting, data.BaseURL)
+		}
+		if err != nil {
+			log.Printf("[SetSteamGridDBKey] ERROR : %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not save steamgriddb key", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "OK"})
+	})
+
+	r.GET("/SteamGridDBStatus", func(c *gin.Context) {
+		_, err := newSteamGridDBProvider()
+		c.JSON(http.StatusOK, gin.H{"configured": err == nil})
+	})
 
-		req, err := http.NewRequest("GET", imageUrl, nil)
+	// Without a uid the shot is filed under the active play session
+	r.POST("/takeScreenshot", func(c *gin.Context) {
+		fmt.Println("Received Take Screenshot")
+		uid := c.Query("uid")
+		path, err := takeScreenshot(uid)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create request"})
+			log.Printf("[TakeScreenshot] ERROR : %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not take screenshot", "details": err.Error()})
 			return
 		}
 
-		req.Header = http.Header{
-			"User-Agent":      {"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"},
-			"Referer":         {"https://www.google.com/"},
-			"Origin":          {"https://www.google.com"},
-			"Accept":          {"image/webp,image/apng,image/*,*/*;q=0.8"},
-			"Accept-Language": {"en-US,en;q=0.9"},
-			"Sec-Fetch-Dest":  {"image"},
-			"Sec-Fetch-Mode":  {"no-cors"},
-			"Sec-Fetch-Site":  {"cross-site"},
+		c.JSON(http.StatusOK, gin.H{"status": http.StatusOK, "path": path})
+	})
+
+	r.GET("/playSessions", func(c *gin.Context) {
+		sessions := getPlaySessions()
+		active, ok := getActiveSession()
+		if !ok {
+			c.JSON(http.StatusOK, gin.H{"sessions": sessions, "active": nil})
+			return
 		}
+		c.JSON(http.StatusOK, gin.H{"sessions": sessions, "active": active})
+	})
 
-		// Log outgoing request details
-		log.Printf("Making request to: %s", imageUrl)
-		log.Printf("Headers: %+v", req.Header)
+	r.GET("/screenshotSettings", func(c *gin.Context) {
+		options, err := getCaptureSettings()
+		if err != nil {
+			log.Printf("[ScreenshotSettings] ERROR : %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not get screenshot settings", "details": err.Error()})
+			return
+		}
+		monitors, err := monitorRects()
+		if err != nil {
+			log.Printf("[ScreenshotSettings] ERROR : %v", err)
+		}
+		monitorList := []gin.H{}
+		for i, rect := range monitors {
+			monitorList = append(monitorList, gin.H{"index": i, "x": rect.Min.X, "y": rect.Min.Y, "width": rect.Dx(), "height": rect.Dy()})
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"monitor":        options.Monitor,
+			"window":         options.Window,
+			"monitors":       monitorList,
+			"triggerEnabled": isScreenshotTriggerEnabled(),
+			"triggerSocket":  triggerSocketPath(),
+		})
+	})
 
-		resp, err := client.Do(req)
+	r.POST("/screenshotSettings", func(c *gin.Context) {
+		var data struct {
+			Monitor        int   `json:"monitor"`
+			Window         bool  `json:"window"`
+			TriggerEnabled *bool `json:"triggerEnabled"`
+		}
+		if err := c.BindJSON(&data); err != nil {
+			log.Printf("[ScreenshotSettings] ERROR invalid req payload: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		err := setCaptureSettings(captureOptions{Monitor: data.Monitor, Window: data.Window})
+		if err == nil && data.TriggerEnabled != nil {
+			// Takes effect on the next start
+			err = setSetting(screenshotTriggerSetting, strconv.FormatBool(*data.TriggerEnabled))
+		}
 		if err != nil {
-			log.Printf("Request failed: %v", err)
-			// [existing error handling...]
+			log.Printf("[ScreenshotSettings] ERROR : %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not save screenshot settings", "details": err.Error()})
+			return
 		}
+		c.JSON(http.StatusOK, gin.H{"status": "OK"})
+	})
 
-		log.Printf("Response status: %d", resp.StatusCode)
-		log.Printf("Response headers: %+v", resp.Header)
+	r.GET("/screenshotStorage", func(c *gin.Context) {
+		storage, err := getScreenshotStorage()
+		if err != nil {
+			log.Printf("[ScreenshotStorage] ERROR : %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not get screenshot storage", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"storage": storage, "formats": screenshotFormats})
+	})
 
-		resp, err = client.Do(req)
+	r.POST("/screenshotStorage", func(c *gin.Context) {
+		var data screenshotStorage
+		if err := c.BindJSON(&data); err != nil {
+			log.Printf("[ScreenshotStorage] ERROR invalid req payload: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		err := setScreenshotStorage(data)
 		if err != nil {
-			// More specific error messages
-			if strings.Contains(err.Error(), "timeout") {
-				c.JSON(http.StatusGatewayTimeout, gin.H{"error": "upstream timeout"})
-			} else if strings.Contains(err.Error(), "redirects") {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "too many redirects"})
-			} else {
-				c.JSON(http.StatusBadGateway, gin.H{"error": "failed to fetch image"})
-			}
+			log.Printf("[ScreenshotStorage] ERROR : %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "could not save screenshot storage", "details": err.Error()})
 			return
 		}
-		defer resp.Body.Close()
+		c.JSON(http.StatusOK, gin.H{"status": "OK"})
+	})
 
-		if resp.StatusCode != http.StatusOK {
-			c.JSON(http.StatusBadGateway, gin.H{
-				"error":  "upstream server error",
-				"status": resp.StatusCode,
-			})
+	// Rewrites existing masters after the format changed, uid limits it to one game
+	r.POST("/reencodeScreenshots", func(c *gin.Context) {
+		var data struct {
+			UID string `json:"uid"`
+		}
+		if err := c.BindJSON(&data); err != nil {
+			log.Printf("[ReencodeScreenshots] ERROR invalid req payload: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		fmt.Println("Received Reencode Screenshots", data.UID)
+		go func() {
+			total, failed, err := reencodeScreenshots(data.UID, func(done int, total int) {
+				publishEvent(eventReencodeProgress, progressEvent{Done: done, Total: total})
+			})
+			if err != nil {
+				log.Printf("[ReencodeScreenshots] ERROR : %v", err)
+				publishEvent(eventReencodeFailed, failedEvent{Error: err.Error()})
+				return
+			}
+			publishEvent(eventReencodeDone, gin.H{"total": total, "failed": len(failed)})
+		}()
+		c.JSON(http.StatusAccepted, gin.H{"status": "started"})
+	})
 
-		contentType := resp.Header.Get("Content-Type")
-		if !strings.HasPrefix(contentType, "image/") {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error":       "url does not point to an image",
-				"contentType": contentType,
+	// Builds a zip in the background, progress and the finished name arrive over SSE
+	r.POST("/exportScreenshots", func(c *gin.Context) {
+		var data screenshotExportRequest
+		if err := c.BindJSON(&data); err != nil {
+			log.Printf("[ExportScreenshots] ERROR invalid req payload: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		export, err := prepareScreenshotExport(data)
+		if err != nil {
+			log.Printf("[ExportScreenshots] ERROR : %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "could not export screenshots", "details": err.Error()})
+			return
+		}
+		go func() {
+			err := export.write(func(done int, total int) {
+				publishEvent(eventExportProgress, progressEvent{Source: exportSourceScreenshots, Name: export.Name, Done: done, Total: total})
 			})
+			if err != nil {
+				log.Printf("[ExportScreenshots] ERROR : %v", err)
+				publishEvent(eventExportFailed, failedEvent{Source: exportSourceScreenshots, Name: export.Name, Error: err.Error()})
+				return
+			}
+			publishEvent(eventExportDone, exportEvent{Source: exportSourceScreenshots, Name: export.Name, URL: exportURL(export.Name)})
+		}()
+		c.JSON(http.StatusAccepted, gin.H{"status": "started", "name": export.Name, "url": exportURL(export.Name)})
+	})
+
+	r.GET("/exports/:name", func(c *gin.Context) {
+		filePath, err := exportFilePath(c.Param("name"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if _, err := os.Stat(filePath); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "export not found"})
 			return
 		}
+		c.FileAttachment(filePath, c.Param("name"))
+	})
 
-		// Security: Limit image size (e.g., 10MB max)
-		maxBytes := int64(10 * 1024 * 1024) // 10MB
-		c.Header("Content-Type", contentType)
-		c.Header("Cache-Control", "public, max-age=86400")
-		c.Header("X-Content-Type-Options", "nosniff") // Security header
+	r.POST("/exportLibrary", func(c *gin.Context) {
+		var data libraryExportRequest
+		if err := c.ShouldBindJSON(&data); err != nil {
+			log.Printf("[ExportLibrary] ERROR invalid req payload: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+			return
+		}
+		if data.Passphrase != "" {
+			if err := checkPassphrase(data.Passphrase); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		name := reserveExportName("Quicksave Library - "+time.Now().Format("2006-01-02 15-04-05"), exportExt(data.Passphrase))
+		go func() {
+			err := exportLibrary(data, name, func(done int, total int) {
+				publishEvent(eventExportProgress, progressEvent{Source: exportSourceLibrary, Name: name, Done: done, Total: total})
+			})
+			if err != nil {
+				log.Printf("[ExportLibrary] ERROR : %v", err)
+				publishEvent(eventExportFailed, failedEvent{Source: exportSourceLibrary, Name: name, Error: err.Error()})
+				return
+			}
+			publishEvent(eventExportDone, exportEvent{Source: exportSourceLibrary, Name: name, URL: exportURL(name)})
+		}()
+		c.JSON(http.StatusAccepted, gin.H{"status": "started", "name": name, "url": exportURL(name)})
+	})
 
-		// Stream with size limit
-		_, err = io.Copy(c.Writer, io.LimitReader(resp.Body, maxBytes))
-		if err != nil {
-			if err == io.EOF {
-				// Normal completion
+	// Takes an uploaded archive as multipart "archive", or a local file as JSON {path, mode, passphrase}
+	r.POST("/importLibrary", func(c *gin.Context) {
+		var data struct {
+			Path       string `json:"path"`
+			Mode       string `json:"mode"`
+			Passphrase string `json:"passphrase"`
+		}
+		uploaded := false
+		if file, err := c.FormFile("archive"); err == nil {
+			upload, err := os.CreateTemp(".", ".library-upload-*.zip")
+			if err == nil {
+				upload.Close()
+				err = c.SaveUploadedFile(file, upload.Name())
+			}
+			if err != nil {
+				log.Printf("[ImportLibrary] ERROR : %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "could not store upload", "details": err.Error()})
 				return
 			}
-			// Don't expose internal errors to client
-			log.Printf("Proxy error: %v", err)
+			data.Path = upload.Name()
+			data.Mode = c.PostForm("mode")
+			data.Passphrase = c.PostForm("passphrase")
+			uploaded = true
+		} else if err := c.ShouldBindJSON(&data); err != nil || data.Path == "" {
+			log.Printf("[ImportLibrary] ERROR invalid req payload: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
 			return
 		}
+		if data.Mode != libraryImportMerge && data.Mode != libraryImportReplace {
+			if uploaded {
+				os.Remove(data.Path)
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be merge or replace"})
+			return
+		}
+		go func() {
+			if uploaded {
+				defer os.Remove(data.Path)
+			}
+			added, err := importLibrary(data.Path, data.Mode, data.Passphrase, func(done int, total int) {
+				publishEvent(eventImportProgress, progressEvent{Source: importSourceLibrary, Done: done, Total: total})
+			})
+			if err != nil {
+				log.Printf("[ImportLibrary] ERROR : %v", err)
+				publishEvent(eventImportFailed, failedEvent{Source: importSourceLibrary, Error: err.Error()})
+				return
+			}
+			publishEvent(eventImportDone, importEvent{Source: importSourceLibrary, Added: added})
+		}()
+		c.JSON(http.StatusAccepted, gin.H{"status": "started"})
 	})
 
+	// Caching proxy for remote images shown while picking custom covers
+	registerImageProxyRoutes(r)
+
 	r.POST("/updateApp", func(c *gin.Context) {
 		var data struct {
 			Source string `json:"source"`
@@ -2022,6 +2416,17 @@ func setupRouter() *gin.Engine {
 		}
 		fmt.Println("Received Update App", data.Source, data.Target)
 
+		// The updater replaces the running app, snapshot first so a bad update can be rolled back
+		schedule, err := getBackupSchedule()
+		if err == nil && schedule.BeforeUpdate {
+			_, err = runBackup("update")
+		}
+		if err != nil {
+			log.Printf("[UpdateApp] ERROR backup before update: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Backup before update failed", "details": err.Error()})
+			return
+		}
+
 		// Get updater path (same directory as main exe)
 
 		var updaterName string
@@ -2032,7 +2437,13 @@ func setupRouter() *gin.Engine {
 			updaterName = "updater"
 		}
 
-		updaterPath := filepath.Join(filepath.Dir(os.Args[0]), updaterName)
+		exePath, err := os.Executable()
+		if err != nil {
+			log.Printf("[UpdateApp] ERROR : %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to locate updater", "details": err.Error()})
+			return
+		}
+		updaterPath := filepath.Join(filepath.Dir(exePath), updaterName)
 
 		// Verify updater exists
 		if _, err := os.Stat(updaterPath); os.IsNotExist(err) {
@@ -2066,28 +2477,379 @@ func setupRouter() *gin.Engine {
 		c.JSON(http.StatusOK, gin.H{"status": "Update started"})
 	})
 
-	r.GET("/backupNow", func(c *gin.Context) {
+	// Serve cover art, screenshots and their size variants with caching headers
+	registerImageRoutes(r)
+
+	r.POST("/backfillImages", func(c *gin.Context) {
+		fmt.Println("Received Backfill Images")
+		go func() {
+			lastReported := 0
+			total, failed := backfillImageVariants(func(done int, total int) {
+				if done-lastReported >= 100 || done == total {
+					lastReported = done
+					publishEvent(eventBackfillProgress, progressEvent{Done: done, Total: total})
+				}
+			})
+			publishEvent(eventBackfillDone, gin.H{"total": total, "failed": len(failed)})
+		}()
+		c.JSON(http.StatusAccepted, gin.H{"status": "started"})
+	})
+
+	r.GET("/listScreenshots", func(c *gin.Context) {
+		filter := screenshotFilter{
+			UID:      c.Query("uid"),
+			Source:   c.Query("source"),
+			Tag:      c.Query("tag"),
+			Favorite: c.Query("favorite") == "true",
+		}
+		filter.AlbumID, _ = strconv.ParseInt(c.Query("album"), 10, 64)
+		page, _ := strconv.Atoi(c.Query("page"))
+		pageSize, _ := strconv.Atoi(c.Query("pageSize"))
+
+		screenshots, total, err := listScreenshots(filter, page, pageSize)
+		if err != nil {
+			log.Printf("[ListScreenshots] ERROR : %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list screenshots", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"screenshots": screenshots, "total": total, "page": max(page, 1)})
+	})
+
+	r.POST("/updateScreenshot", func(c *gin.Context) {
+		var data struct {
+			ID       int64     `json:"id"`
+			Caption  *string   `json:"caption"`
+			Favorite *bool     `json:"favorite"`
+			Tags     *[]string `json:"tags"`
+		}
+		if err := c.BindJSON(&data); err != nil {
+			log.Printf("[UpdateScreenshot] ERROR invalid req payload: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		err := updateScreenshot(data.ID, data.Caption, data.Favorite)
+		if err == nil && data.Tags != nil {
+			err = setScreenshotTags(data.ID, *data.Tags)
+		}
+		if err != nil {
+			log.Printf("[UpdateScreenshot] ERROR : %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update screenshot", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "OK"})
+	})
+
+	r.GET("/getScreenshotTags", func(c *gin.Context) {
+		tags, err := getScreenshotTags()
+		if err != nil {
+			log.Printf("[GetScreenshotTags] ERROR : %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get screenshot tags", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"tags": tags})
+	})
+
+	r.POST("/moveScreenshot", func(c *gin.Context) {
+		var data struct {
+			ID        int64  `json:"id"`
+			TargetUID string `json:"targetUid"`
+		}
+		if err := c.BindJSON(&data); err != nil {
+			log.Printf("[MoveScreenshot] ERROR invalid req payload: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		path, err := moveScreenshot(data.ID, data.TargetUID)
+		if err != nil {
+			log.Printf("[MoveScreenshot] ERROR : %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move screenshot", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"path": path})
+	})
+
+	r.POST("/importSteamScreenshots", func(c *gin.Context) {
+		fmt.Println("Received Import Steam Screenshots")
+		go func() {
+			result, err := importSteamScreenshots(func(done int, total int) {
+				if done%25 == 0 || done == total {
+					publishEvent(eventImportProgress, progressEvent{Source: importSourceSteamScreenshots, Done: done, Total: total})
+				}
+			})
+			if err != nil {
+				log.Printf("[ImportSteamScreenshots] ERROR : %v", err)
+				publishEvent(eventImportFailed, failedEvent{Source: importSourceSteamScreenshots, Error: err.Error()})
+				return
+			}
+			publishEvent(eventImportDone, steamScreenshotImportEvent{Source: importSourceSteamScreenshots, steamScreenshotImportResult: result})
+			backupAfterImport(result.Imported)
+		}()
+		c.JSON(http.StatusAccepted, gin.H{"status": "started"})
+	})
+
+	r.GET("/steamScreenshotWatch", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"enabled": isSteamScreenshotWatchEnabled()})
+	})
+
+	r.POST("/steamScreenshotWatch", func(c *gin.Context) {
+		var data struct {
+			Enabled bool `json:"enabled"`
+		}
+		if err := c.BindJSON(&data); err != nil {
+			log.Printf("[SteamScreenshotWatch] ERROR invalid req payload: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		err := setSteamScreenshotWatch(data.Enabled)
+		if err != nil {
+			log.Printf("[SteamScreenshotWatch] ERROR : %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update watch setting", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"enabled": data.Enabled})
+	})
+
+	r.POST("/rescanScreenshots", func(c *gin.Context) {
+		fmt.Println("Received Rescan Screenshots")
+		added, removed, err := rescanScreenshots()
+		if err != nil {
+			log.Printf("[RescanScreenshots] ERROR : %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rescan screenshots", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"added": added, "removed": removed})
+	})
+
+	r.GET("/getAlbums", func(c *gin.Context) {
+		albums, err := getAlbums()
+		if err != nil {
+			log.Printf("[GetAlbums] ERROR : %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get albums", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"albums": albums})
+	})
+
+	r.POST("/createAlbum", func(c *gin.Context) {
+		var data struct {
+			Name string `json:"name"`
+		}
+		if err := c.BindJSON(&data); err != nil {
+			log.Printf("[CreateAlbum] ERROR invalid req payload: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		id, err := createAlbum(data.Name)
+		if err != nil {
+			log.Printf("[CreateAlbum] ERROR : %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create album", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"id": id})
+	})
+
+	r.POST("/deleteAlbum", func(c *gin.Context) {
+		var data struct {
+			ID int64 `json:"id"`
+		}
+		if err := c.BindJSON(&data); err != nil {
+			log.Printf("[DeleteAlbum] ERROR invalid req payload: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		err := deleteAlbum(data.ID)
+		if err != nil {
+			log.Printf("[DeleteAlbum] ERROR : %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete album", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "OK"})
+	})
+
+	r.POST("/setAlbumScreenshots", func(c *gin.Context) {
+		var data struct {
+			AlbumID       int64   `json:"albumId"`
+			ScreenshotIDs []int64 `json:"screenshotIds"`
+			Remove        bool    `json:"remove"`
+		}
+		if err := c.BindJSON(&data); err != nil {
+			log.Printf("[SetAlbumScreenshots] ERROR invalid req payload: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		err := setAlbumMembership(data.AlbumID, data.ScreenshotIDs, !data.Remove)
+		if err != nil {
+			log.Printf("[SetAlbumScreenshots] ERROR : %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update album", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "OK"})
+	})
+
+	r.GET("/missingArtwork", func(c *gin.Context) {
+		fmt.Println("Received Missing Artwork")
+		report, err := getMissingArtworkReport()
+		if err != nil {
+			log.Printf("[MissingArtwork] ERROR : %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get missing artwork", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, report)
+	})
+
+	r.POST("/retryMissingArtwork", func(c *gin.Context) {
+		fmt.Println("Received Retry Missing Artwork")
+		go func() {
+			total, stillMissing, err := retryMissingArtwork(func(done int, total int) {
+				publishEvent(eventArtworkRetryProgress, progressEvent{Done: done, Total: total})
+			})
+			if err != nil {
+				log.Printf("[RetryMissingArtwork] ERROR : %v", err)
+				publishEvent(eventArtworkRetryFailed, failedEvent{Error: err.Error()})
+				return
+			}
+			publishEvent(eventArtworkRetryDone, gin.H{"total": total, "stillMissing": stillMissing})
+		}()
+		c.JSON(http.StatusAccepted, gin.H{"status": "started"})
+	})
+
+	r.POST("/backupNow", func(c *gin.Context) {
 		fmt.Println("Received backup now")
-		doBackup()
-		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+		backup, err := runBackup("manual")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "backup failed", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "ok", "backup": backup})
+	})
+
+	r.GET("/backupSchedule", func(c *gin.Context) {
+		schedule, err := getBackupSchedule()
+		if err != nil {
+			log.Printf("[BackupSchedule] ERROR : %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not get backup schedule", "details": err.Error()})
+			return
+		}
+		root, err := backupRoot()
+		if err != nil {
+			log.Printf("[BackupSchedule] ERROR : %v", err)
+		}
+		c.JSON(http.StatusOK, gin.H{"schedule": schedule, "location": root})
 	})
 
+	// Snapshots already taken stay in the old destination, fields left out keep their current value
+	r.POST("/backupSchedule", func(c *gin.Context) {
+		data, err := getBackupSchedule()
+		if err != nil {
+			log.Printf("[BackupSchedule] ERROR : %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not read backup schedule", "details": err.Error()})
+			return
+		}
+		if err := c.BindJSON(&data); err != nil {
+			log.Printf("[BackupSchedule] ERROR invalid req payload: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		err = setBackupSchedule(data)
+		if err != nil {
+			log.Printf("[BackupSchedule] ERROR : %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "could not save backup schedule", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "OK"})
+	})
+
+	r.GET("/backups", func(c *gin.Context) {
+		backups, err := listBackups()
+		if err != nil {
+			log.Printf("[Backups] ERROR : %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not list backups", "details": err.Error()})
+			return
+		}
+		retention, err := getBackupRetention()
+		if err != nil {
+			log.Printf("[Backups] ERROR : %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not get backup retention", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"backups": backups, "retention": retention})
+	})
+
+	r.POST("/backupRetention", func(c *gin.Context) {
+		var data backupRetention
+		if err := c.BindJSON(&data); err != nil {
+			log.Printf("[BackupRetention] ERROR invalid req payload: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		err := setBackupRetention(data)
+		if err == nil {
+			err = pruneSnapshots()
+		}
+		if err != nil {
+			log.Printf("[BackupRetention] ERROR : %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not save backup retention", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "OK"})
+	})
+
+	r.GET("/backupProtection", func(c *gin.Context) {
+		protection, err := getBackupProtection()
+		if err != nil {
+			log.Printf("[BackupProtection] ERROR : %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not get backup protection", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, protection)
+	})
+
+	r.POST("/backupProtection", func(c *gin.Context) {
+		var data backupProtection
+		if err := c.ShouldBindJSON(&data); err != nil {
+			log.Printf("[BackupProtection] ERROR invalid req payload: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+			return
+		}
+		err := setBackupProtection(data)
+		if err != nil {
+			log.Printf("[BackupProtection] ERROR : %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "could not set backup protection", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "OK"})
+	})
+
+	// Other requests get 503 until the restore finished. Encrypted backups use the stored
+	// passphrase unless one is given.
+	r.POST("/restoreBackup", func(c *gin.Context) {
+		var data struct {
+			ID         string `json:"id"`
+			Passphrase string `json:"passphrase"`
+		}
+		if err := c.BindJSON(&data); err != nil {
+			log.Printf("[RestoreBackup] ERROR invalid req payload: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		fmt.Println("Received Restore Backup", data.ID)
+		publishEvent(eventRestoreStarted, gin.H{"id": data.ID})
+		err := restoreBackup(data.ID, data.Passphrase)
+		if err != nil {
+			log.Printf("[RestoreBackup] ERROR : %v", err)
+			publishEvent(eventRestoreFailed, failedEvent{Name: data.ID, Error: err.Error()})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not restore backup", "details": err.Error()})
+			return
+		}
+		publishEvent(eventRestoreDone, gin.H{"id": data.ID})
+		c.JSON(http.StatusOK, gin.H{"status": "OK"})
+	})
+
+	registerOpenAPIRoute(r)
 	return r
 }
 
 func routing() {
 	r := setupRouter()
-
-	// Serve cover art and screenshots with aggressive caching
-	// r.Use(func(c *gin.Context) {
-	// 	if strings.HasPrefix(c.Request.URL.Path, "/cover-art") || strings.HasPrefix(c.Request.URL.Path, "/screenshots") {
-	// 		c.Header("Cache-Control", "public, max-age=31536000, immutable")
-	// 	}
-	// 	c.Next()
-	// })
-
-	// r.StaticFS("/cover-art", http.Dir("./coverArt"))
-	// r.StaticFS("/screenshots", http.Dir("./screenshots"))
-
-	r.Run("localhost:50001")
+	r.Run(config.Listen)
 }
@@ -0,0 +1,536 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const openAPIVersion = "3.0.3"
+
+// Describes one route. Body and Response are either a Go value whose type is reflected into a
+// schema, or a schema built with object() for the anonymous payloads of the legacy handlers.
+type openAPIOperation struct {
+	Summary  string
+	Tag      string
+	Query    []openAPIParam
+	Body     any
+	Status   int
+	Response any
+	// Media type of non-JSON responses, like images, downloads or the event stream
+	Produces string
+	// Set for routes that also take multipart/form-data, with the names of the form fields
+	FormFields []string
+	// Media type of a body that isn't JSON, like the login form
+	Consumes string
+	// Reachable without the API token
+	Public bool
+}
+
+type openAPIParam struct {
+	Name        string
+	Type        string
+	Description string
+	Required    bool
+}
+
+type openAPISchema map[string]any
+
+// Every registered route, keyed as "METHOD /openapi/{path}". checkOpenAPICoverage fails for routes
+// missing here, so new routes must be described before they ship.
+var openAPIOperations = map[string]openAPIOperation{
+	// Library
+	"GET /": {Summary: "Health check", Tag: "system", Response: object("message", "string")},
+	"GET /getBasicInfo": {Summary: "Library with the UI's filter and sort state applied, saving the sort", Tag: "library",
+		Query:    []openAPIParam{{Name: "type", Description: "sort column, or default for the stored sort"}, {Name: "order", Description: "ASC or DESC"}},
+		Response: object("MetaData", "object", "SortOrder", "string", "SortType", "string", "HiddenUIDs", "[]string")},
+	"GET /getAllTags":       {Summary: "All tags in the library", Tag: "library", Response: object("tags", "[]string")},
+	"GET /getAllDevelopers": {Summary: "All developers and publishers in the library", Tag: "library", Response: object("devs", "[]string")},
+	"GET /getAllPlatforms":  {Summary: "All known platforms", Tag: "library", Response: object("platforms", "[]string")},
+	"GET /getIgdbFacets":    {Summary: "Values of the IGDB metadata tables, by table", Tag: "library", Response: object("facets", map[string][]string{})},
+	"GET /GameDetails": {Summary: "Details of one game", Tag: "library", Query: []openAPIParam{uidParam},
+		Response: object("metadata", "object")},
+	"DELETE /DeleteGame": {Summary: "Delete a game and its images", Tag: "library", Query: []openAPIParam{uidParam}, Response: legacyOK},
+	"POST /HideGame":     {Summary: "Hide a game", Tag: "library", Query: []openAPIParam{uidParam}, Response: legacyOK},
+	"POST /unhideGame":   {Summary: "Unhide a game", Tag: "library", Query: []openAPIParam{uidParam}, Response: legacyOK},
+	"POST /LaunchGame": {Summary: "Launch a game, blocks until a game launched from its path quits", Tag: "library", Query: []openAPIParam{uidParam},
+		Response: object("LaunchStatus", "string")},
+	"POST /steamInstallReq": {Summary: "Ask Steam to install a game", Tag: "library", Query: []openAPIParam{uidParam}, Response: statusOK},
+	"POST /setGamePath": {Summary: "Set the executable a game is launched from", Tag: "library",
+		Query: []openAPIParam{uidParam, {Name: "path", Description: "empty clears the path"}}, Response: statusOK},
+	"GET /getGamePath": {Summary: "Executable a game is launched from", Tag: "library", Query: []openAPIParam{uidParam}, Response: object("path", "string")},
+	"GET /LoadPreferences": {Summary: "Custom title, playtime, rating and release date of a game", Tag: "library", Query: []openAPIParam{uidParam},
+		Response: object("preferences", "object")},
+	"POST /SavePreferences": {Summary: "Save the custom values, tags and developers of a game", Tag: "library",
+		Body: object("UID", "string", "customTitleChecked", "boolean", "customTitle", "string", "customTimeChecked", "boolean", "customTime", "string",
+			"customTimeOffsetChecked", "boolean", "customTimeOffset", "string", "customRatingChecked", "boolean", "customRating", "string",
+			"customReleaseDateChecked", "boolean", "customReleaseDate", "string", "selectedTags", "[]string", "selectedDevs", "[]string"),
+		Response: statusOK},
+	"GET /playSessions": {Summary: "Recorded play sessions and the active one", Tag: "library",
+		Response: object("sessions", []playSession{}, "active", playSession{})},
+
+	// Filters
+	"POST /setFilter":       {Summary: "Set the library filter of the UI", Tag: "filters", Body: FilterStruct{}, Response: legacyOK},
+	"POST /clearAllFilters": {Summary: "Clear the library filter of the UI", Tag: "filters", Response: legacyOK},
+	"GET /LoadFilters": {Summary: "Library filter of the UI", Tag: "filters",
+		Response: object("name", "[]string", "platform", "[]string", "developers", "[]string", "tags", "[]string")},
+	"POST /deleteCurrentlyFiltered": {Summary: "Delete the given games", Tag: "filters", Body: object("uids", "[]string"), Response: legacyOK},
+	"POST /hideCurrentlyFiltered":   {Summary: "Hide the given games", Tag: "filters", Body: object("uids", "[]string"), Response: legacyOK},
+	"POST /unHideCurrentlyFiltered": {Summary: "Unhide the given games", Tag: "filters", Body: object("uids", "[]string"), Response: legacyOK},
+
+	// IGDB and manual adds
+	"POST /IGDBsearch": {Summary: "Search IGDB by name", Tag: "igdb", Body: object("NameToSearch", "string"),
+		Response: object("foundGames", "string")},
+	"POST /GetIgdbInfo": {Summary: "Metadata of an IGDB search result", Tag: "igdb", Body: object("key", "integer"),
+		Response: object("metadata", "object")},
+	"POST /addGameToDB": {Summary: "Add a game from IGDB metadata or manual input", Tag: "igdb", Body: IGDBInsertGameReturn{},
+		Response: object("insertionStatus", "boolean")},
+	"POST /refreshIgdbMetadata": {Summary: "Refetch the IGDB metadata of a game", Tag: "igdb", Body: object("uid", "string", "igdbID", "integer"), Response: statusOK},
+	"POST /igdbCreds": {Summary: "Store IGDB client credentials", Tag: "credentials",
+		Body: object("clientId", "string", "clientSecret", "string"), Response: statusOK},
+
+	// Credentials and imports
+	"GET /Npsso":      {Summary: "Whether a PlayStation NPSSO token is stored", Tag: "credentials", Response: object("configured", "boolean")},
+	"GET /SteamCreds": {Summary: "Stored Steam ID and whether an API key is stored", Tag: "credentials", Response: object("steamId", "string", "configured", "boolean")},
+	"GET /credentials": {Summary: "Secret store in use and which credentials it holds", Tag: "credentials",
+		Response: object("store", "string", "configured", map[string]bool{})},
+	"POST /SteamImport": {Summary: "Start a Steam import job, an empty key reuses the stored one. A running Steam import is returned instead.", Tag: "imports",
+		Body: object("SteamID", "string", "APIkey", "string"), Status: http.StatusAccepted, Response: jobInfo{}},
+	"POST /PlayStationImport": {Summary: "Start a PlayStation import job, an empty token reuses the stored one. A running PlayStation import is returned instead.", Tag: "imports",
+		Body: object("npsso", "string"), Status: http.StatusAccepted, Response: jobInfo{}},
+
+	// Images and artwork
+	"GET /images/{variant}/{path}": {Summary: "Image variant (thumb, medium or full) of a library image", Tag: "images", Produces: "image/*"},
+	"GET /cover-art/{path}":        {Summary: "Original cover art", Tag: "images", Produces: "image/*"},
+	"GET /screenshots/{path}":      {Summary: "Original screenshot", Tag: "images", Produces: "image/*"},
+	"GET /image-proxy": {Summary: "Fetch and cache a remote image from an allowed host", Tag: "images", Produces: "image/*",
+		Query: []openAPIParam{{Name: "url", Required: true, Description: "image URL, may be URL encoded"}}},
+	"POST /image-proxy/prewarm": {Summary: "Fetch remote images into the proxy cache", Tag: "images", Body: object("urls", "[]string"),
+		Status: http.StatusAccepted, Response: object("accepted", "integer", "rejected", "integer")},
+	"GET /image-proxy/settings": {Summary: "Allowed hosts and cache statistics of the image proxy", Tag: "images",
+		Response: object("allowedHosts", "[]string", "cache", "object")},
+	"POST /image-proxy/settings": {Summary: "Set the allowed hosts and cache size of the image proxy", Tag: "images",
+		Body: object("allowedHosts", "[]string", "maxCacheMB", "integer"), Response: statusOK},
+	"GET /searchImages": {Summary: "Image candidates from IGDB, the Steam store and SteamGridDB", Tag: "images",
+		Query:    []openAPIParam{{Name: "uid"}, {Name: "term"}, {Name: "limit", Type: "integer"}, {Name: "provider", Description: "one provider, or mock"}},
+		Response: object("candidates", []imageCandidate{}, "errors", map[string]string{})},
+	"GET /AddScreenshot": {Summary: "Same as /searchImages?term=, kept for older frontends", Tag: "images",
+		Query:    []openAPIParam{{Name: "string", Description: "search term"}},
+		Response: object("candidates", []imageCandidate{}, "errors", map[string]string{})},
+	"POST /setCustomImage": {Summary: "Replace the cover, screenshots or artwork of a game with downloaded images", Tag: "images",
+		Body:     object("uid", "string", "coverImage", "string", "ssImage", "[]string", "heroImage", "string", "logoImage", "string", "iconImage", "string"),
+		Response: statusOK},
+	"GET /artwork": {Summary: "Artwork paths of a game by type", Tag: "images", Query: []openAPIParam{uidParam},
+		Response: object("artwork", map[string]string{})},
+	"GET /artworkCandidates": {Summary: "Artwork candidates for a game and type", Tag: "images",
+		Query:    []openAPIParam{uidParam, {Name: "type", Required: true}, {Name: "sgdbID", Type: "integer", Description: "SteamGridDB game, found by name when left out"}},
+		Response: object("candidates", []artworkCandidate{})},
+	"GET /searchSteamGridDB": {Summary: "Search SteamGridDB games", Tag: "images", Query: []openAPIParam{{Name: "term", Required: true}},
+		Response: object("games", []steamGridDBGame{})},
+	"POST /setArtwork": {Summary: "Download and set one artwork asset", Tag: "images",
+		Body: object("uid", "string", "type", "string", "url", "string", "provider", "string"), Response: object("path", "string")},
+	"POST /autoFillArtwork": {Summary: "Fill missing artwork of a game from the best candidates", Tag: "images",
+		Body: object("uid", "string"), Response: object("artwork", map[string]string{})},
+	"POST /setSteamGridDBKey": {Summary: "Store the SteamGridDB API key", Tag: "credentials",
+		Body: object("apiKey", "string", "baseURL", "string"), Response: statusOK},
+	"GET /SteamGridDBStatus": {Summary: "Whether a SteamGridDB key is stored", Tag: "credentials", Response: object("configured", "boolean")},
+	"GET /missingArtwork":    {Summary: "Games with downloads that failed", Tag: "images", Response: openAPISchema{"type": "object"}},
+	"POST /retryMissingArtwork": {Summary: "Retry failed downloads, progress over the event stream", Tag: "images",
+		Status: http.StatusAccepted, Response: statusStarted},
+	"POST /backfillImages": {Summary: "Generate missing image variants, progress over the event stream", Tag: "images",
+		Status: http.StatusAccepted, Response: statusStarted},
+
+	// Screenshots
+	"POST /takeScreenshot": {Summary: "Capture a screenshot, filed under the active play session without a uid", Tag: "screenshots",
+		Query: []openAPIParam{{Name: "uid"}}, Response: object("status", "integer", "path", "string")},
+	"GET /screenshotSettings": {Summary: "Capture settings and monitors", Tag: "screenshots",
+		Response: object("monitor", "integer", "window", "boolean", "monitors", "[]object", "triggerEnabled", "boolean", "triggerSocket", "string")},
+	"POST /screenshotSettings": {Summary: "Set the capture settings", Tag: "screenshots",
+		Body: object("monitor", "integer", "window", "boolean", "triggerEnabled", "boolean"), Response: statusOK},
+	"GET /screenshotStorage": {Summary: "Screenshot storage format and the supported formats", Tag: "screenshots",
+		Response: object("storage", screenshotStorage{}, "formats", "[]string")},
+	"POST /screenshotStorage": {Summary: "Set the screenshot storage format", Tag: "screenshots", Body: screenshotStorage{}, Response: statusOK},
+	"POST /reencodeScreenshots": {Summary: "Re-encode screenshots to the storage format, all games without a uid", Tag: "screenshots",
+		Body: object("uid", "string"), Status: http.StatusAccepted, Response: statusStarted},
+	"GET /listScreenshots": {Summary: "Page through screenshots", Tag: "screenshots",
+		Query: []openAPIParam{{Name: "uid"}, {Name: "source"}, {Name: "tag"}, {Name: "album", Type: "integer"}, {Name: "favorite", Type: "boolean"},
+			{Name: "page", Type: "integer"}, {Name: "pageSize", Type: "integer"}},
+		Response: object("screenshots", []screenshotRecord{}, "total", "integer", "page", "integer")},
+	"POST /updateScreenshot": {Summary: "Set the caption, favorite flag or tags of a screenshot", Tag: "screenshots",
+		Body: object("id", "integer", "caption", "string", "favorite", "boolean", "tags", "[]string"), Response: statusOK},
+	"GET /getScreenshotTags": {Summary: "All screenshot tags", Tag: "screenshots", Response: object("tags", "[]string")},
+	"POST /moveScreenshot": {Summary: "Move a screenshot to another game", Tag: "screenshots",
+		Body: object("id", "integer", "targetUid", "string"), Response: object("path", "string")},
+	"POST /importSteamScreenshots": {Summary: "Import screenshots taken with Steam, progress over the event stream", Tag: "screenshots",
+		Status: http.StatusAccepted, Response: statusStarted},
+	"GET /steamScreenshotWatch":  {Summary: "Whether new Steam screenshots are imported automatically", Tag: "screenshots", Response: object("enabled", "boolean")},
+	"POST /steamScreenshotWatch": {Summary: "Turn the Steam screenshot watch on or off", Tag: "screenshots", Body: object("enabled", "boolean"), Response: object("enabled", "boolean")},
+	"POST /rescanScreenshots": {Summary: "Sync the screenshot index with the screenshot folders", Tag: "screenshots",
+		Response: object("added", "integer", "removed", "integer")},
+	"GET /getAlbums":    {Summary: "Screenshot albums", Tag: "screenshots", Response: object("albums", []screenshotAlbum{})},
+	"POST /createAlbum": {Summary: "Create a screenshot album", Tag: "screenshots", Body: object("name", "string"), Response: object("id", "integer")},
+	"POST /deleteAlbum": {Summary: "Delete a screenshot album", Tag: "screenshots", Body: object("id", "integer"), Response: statusOK},
+	"POST /setAlbumScreenshots": {Summary: "Add screenshots to or remove them from an album", Tag: "screenshots",
+		Body: object("albumId", "integer", "screenshotIds", "[]integer", "remove", "boolean"), Response: statusOK},
+
+	// Exports
+	"POST /exportScreenshots": {Summary: "Export screenshots as a zip, encrypted with a passphrase", Tag: "exports", Body: screenshotExportRequest{},
+		Status: http.StatusAccepted, Response: exportStarted},
+	"POST /exportLibrary": {Summary: "Export the library as a portable archive", Tag: "exports", Body: libraryExportRequest{},
+		Status: http.StatusAccepted, Response: exportStarted},
+	"POST /importLibrary": {Summary: "Merge or replace the library from an archive, by upload or path on disk", Tag: "exports",
+		Body: object("path", "string", "mode", "string", "passphrase", "string"), FormFields: []string{"archive", "mode", "passphrase"},
+		Status: http.StatusAccepted, Response: statusStarted},
+	"GET /exports/{name}": {Summary: "Download a finished export", Tag: "exports", Produces: "application/octet-stream"},
+
+	// Backups
+	"POST /backupNow": {Summary: "Take a backup snapshot", Tag: "backups", Response: object("status", "string", "backup", backupInfo{})},
+	"GET /backupSchedule": {Summary: "Backup schedule and where snapshots are kept", Tag: "backups",
+		Response: object("schedule", backupSchedule{}, "location", "string")},
+	"POST /backupSchedule": {Summary: "Set the backup schedule", Tag: "backups", Body: backupSchedule{}, Response: statusOK},
+	"GET /backups": {Summary: "Backup snapshots, newest first, and the retention policy", Tag: "backups",
+		Response: object("backups", []backupInfo{}, "retention", backupRetention{})},
+	"POST /backupRetention":  {Summary: "Set the backup retention policy", Tag: "backups", Body: backupRetention{}, Response: statusOK},
+	"GET /backupProtection":  {Summary: "Backup encryption and secret exclusion", Tag: "backups", Response: backupProtection{}},
+	"POST /backupProtection": {Summary: "Set backup encryption and secret exclusion, an empty passphrase keeps the stored one", Tag: "backups", Body: backupProtection{}, Response: statusOK},
+	"POST /restoreBackup":    {Summary: "Restore a backup snapshot", Tag: "backups", Body: object("id", "string", "passphrase", "string"), Response: statusOK},
+
+	// System
+	"GET /sse-steam-updates": {Summary: "Server-sent events announcing library changes and task progress, same as /api/v1/events", Tag: "system",
+		Query: []openAPIParam{lastEventIDParam}, Produces: "text/event-stream"},
+	"POST /updateApp": {Summary: "Replace the installed app with an extracted update", Tag: "system",
+		Body: object("source", "string", "target", "string"), Response: object("status", "string")},
+	"GET /login": {Summary: "Sign in page of the web frontend", Tag: "system", Produces: "text/html", Public: true},
+	"POST /login": {Summary: "Sign in with the API token or web password, sets a session cookie and redirects to the frontend", Tag: "system",
+		Body: object("secret", "string"), Consumes: "application/x-www-form-urlencoded", Status: http.StatusSeeOther, Produces: "text/html", Public: true},
+	"POST /logout":      {Summary: "End the browser session and redirect to the sign in page", Tag: "system", Status: http.StatusSeeOther, Produces: "text/html"},
+	"GET /openapi.json": {Summary: "This document", Tag: "system", Response: openAPISchema{"type": "object"}},
+
+	// Versioned API
+	"GET /api/v1/games": {Summary: "List games", Tag: "v1",
+		Query: []openAPIParam{{Name: "name", Description: "name prefix"}, {Name: "tag", Description: "repeatable, all must match"},
+			{Name: "platform", Description: "repeatable, any may match"}, {Name: "developer", Description: "repeatable, any may match"},
+			{Name: "hidden", Description: "exclude (default), include or only"}, {Name: "sort", Description: "name, releaseDate, timePlayed, rating or platform"},
+			{Name: "order", Description: "asc or desc"}},
+		Response: listResponse[gameSummary]{}},
+	"GET /api/v1/games/{uid}":         {Summary: "Get a game", Tag: "v1", Response: gameDetail{}},
+	"PATCH /api/v1/games/{uid}":       {Summary: "Hide, unhide or set the install path of a game", Tag: "v1", Body: gameUpdate{}, Response: gameSummary{}},
+	"DELETE /api/v1/games/{uid}":      {Summary: "Delete a game", Tag: "v1", Status: http.StatusNoContent},
+	"POST /api/v1/games/{uid}/launch": {Summary: "Launch a game, 409 when it has no install path", Tag: "v1", Response: launchResult{}},
+	"GET /api/v1/games/{uid}/screenshots": {Summary: "Page through the screenshots of a game", Tag: "v1",
+		Query:    []openAPIParam{{Name: "page", Type: "integer"}, {Name: "pageSize", Type: "integer"}},
+		Response: pagedResponse[screenshotRecord]{}},
+	"GET /api/v1/tags":       {Summary: "List tags", Tag: "v1", Response: listResponse[string]{}},
+	"GET /api/v1/platforms":  {Summary: "List platforms", Tag: "v1", Response: listResponse[string]{}},
+	"GET /api/v1/developers": {Summary: "List developers", Tag: "v1", Response: listResponse[string]{}},
+	"GET /api/v1/imports":    {Summary: "Import sources and whether their credentials are stored", Tag: "v1", Response: listResponse[importSource]{}},
+	"POST /api/v1/imports": {Summary: "Start an import job, 409 when the source is already importing. Follow it through import. events or GET /api/v1/jobs/{id}.", Tag: "v1",
+		Body: importRequest{}, Status: http.StatusAccepted, Response: jobInfo{}},
+	"GET /api/v1/jobs":      {Summary: "List the latest jobs, newest first", Tag: "v1", Response: listResponse[jobInfo]{}},
+	"GET /api/v1/jobs/{id}": {Summary: "Get a job with the status of each of its items", Tag: "v1", Response: jobDetail{}},
+	"POST /api/v1/jobs/{id}/cancel": {Summary: "Cancel a running job once its current item is done, 409 when it isn't running", Tag: "v1",
+		Status: http.StatusAccepted, Response: jobInfo{}},
+	"POST /api/v1/jobs/{id}/resume": {Summary: "Run the pending and failed items of a stopped job again, 409 when it is running or has nothing left", Tag: "v1",
+		Status: http.StatusAccepted, Response: jobInfo{}},
+	"GET /api/v1/events": {Summary: "Server-sent events with ids, each one's data is an event object. Reconnects replay missed events or start with a reset event.", Tag: "v1",
+		Query:    []openAPIParam{{Name: "type", Description: "repeatable type prefix, like import. or session."}, lastEventIDParam},
+		Produces: "text/event-stream"},
+
+	// Remote control. Device tokens from pairing reach games, sessions, screenshots, events and images.
+	"POST /api/v1/remote/pairings": {Summary: "Create a pairing code for a companion device, valid for five minutes", Tag: "remote",
+		Status: http.StatusCreated, Response: pairingCode{}},
+	"POST /api/v1/remote/pair": {Summary: "Trade a pairing code for a device token", Tag: "remote", Body: pairRequest{},
+		Status: http.StatusCreated, Response: pairResult{}, Public: true},
+	"GET /api/v1/remote/devices":         {Summary: "List paired devices", Tag: "remote", Response: listResponse[remoteDevice]{}},
+	"DELETE /api/v1/remote/devices/{id}": {Summary: "Revoke a paired device", Tag: "remote", Status: http.StatusNoContent},
+	"GET /api/v1/remote/games": {Summary: "Games with an install path or Steam app ID", Tag: "remote",
+		Query: []openAPIParam{{Name: "name", Description: "name prefix"}}, Response: listResponse[gameSummary]{}},
+	"GET /api/v1/remote/sessions": {Summary: "Running games", Tag: "remote", Response: listResponse[remoteSession]{}},
+	"POST /api/v1/remote/sessions": {Summary: "Launch a game without waiting for it, 409 when it is running or can't be launched", Tag: "remote",
+		Body: remoteLaunchRequest{}, Status: http.StatusAccepted, Response: launchResult{}},
+	"POST /api/v1/remote/sessions/{uid}/stop": {Summary: "Ask a running game to quit, 409 when its process is not known", Tag: "remote", Status: http.StatusAccepted},
+	"POST /api/v1/remote/sessions/{uid}/kill": {Summary: "End a running game right away, 409 when its process is not known", Tag: "remote", Status: http.StatusAccepted},
+	"POST /api/v1/remote/screenshots": {Summary: "Capture a screenshot of a running game, the foreground one without a uid. 404 for unknown games.", Tag: "remote",
+		Body: remoteScreenshotRequest{}, Status: http.StatusCreated, Response: remoteScreenshot{}},
+	"GET /api/v1/remote/events": {Summary: "Server-sent session and screenshot events like /api/v1/events, new streams start with the running sessions", Tag: "remote",
+		Query: []openAPIParam{lastEventIDParam}, Produces: "text/event-stream"},
+}
+
+var (
+	uidParam         = openAPIParam{Name: "uid", Required: true, Description: "game UID"}
+	lastEventIDParam = openAPIParam{Name: "lastEventId", Type: "integer", Description: "resume after this event, for clients that can't send Last-Event-ID"}
+	legacyOK         = object("HttpStatus", "string")
+	statusOK         = object("status", "string")
+	statusStarted    = object("status", "string")
+	exportStarted    = object("status", "string", "name", "string", "url", "string")
+)
+
+// Builds an inline object schema from name, type pairs. Types are "string", "integer", "number",
+// "boolean", "object", "[]string", "[]integer" and "[]object", or a Go value to reflect.
+func object(pairs ...any) openAPISchema {
+	properties := make(map[string]any)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		properties[pairs[i].(string)] = pairs[i+1]
+	}
+	return openAPISchema{"type": "object", "properties": properties}
+}
+
+var ginParamPattern = regexp.MustCompile(`[:*]([A-Za-z0-9_]+)`)
+
+// /games/:uid and /images/*path become /games/{uid} and /images/{path}
+func openAPIPath(ginPath string) string {
+	return ginParamPattern.ReplaceAllString(ginPath, "{$1}")
+}
+
+// Routes without a spec entry, and spec entries without a route
+func checkOpenAPICoverage(routes gin.RoutesInfo) (undocumented []string, stale []string) {
+	registered := make(map[string]bool)
+	for _, route := range routes {
+		key := route.Method + " " + openAPIPath(route.Path)
+		registered[key] = true
+		if _, ok := openAPIOperations[key]; !ok {
+			undocumented = append(undocumented, key)
+		}
+	}
+	for key := range openAPIOperations {
+		if !registered[key] {
+			stale = append(stale, key)
+		}
+	}
+	sort.Strings(undocumented)
+	sort.Strings(stale)
+	return undocumented, stale
+}
+
+type openAPIBuilder struct {
+	schemas map[string]any
+}
+
+func buildOpenAPIDocument() map[string]any {
+	b := &openAPIBuilder{schemas: make(map[string]any)}
+	b.schemas["legacyError"] = object("error", "string", "details", "string")
+	b.schemaFor(reflect.TypeOf(apiErrorResponse{}))
+
+	paths := make(map[string]map[string]any)
+	for key, op := range openAPIOperations {
+		method, path, _ := strings.Cut(key, " ")
+		if paths[path] == nil {
+			paths[path] = make(map[string]any)
+		}
+		paths[path][strings.ToLower(method)] = b.operation(method, path, op)
+	}
+
+	return map[string]any{
+		"openapi": openAPIVersion,
+		"info": map[string]any{
+			"title":       "quicksave backend",
+			"version":     "1",
+			"description": "Local API of the quicksave backend. Routes under /api/v1 are the stable surface, the others are used by the desktop UI.",
+		},
+		"servers":  []any{map[string]any{"url": "http://localhost:50001"}},
+		"security": []any{map[string]any{"bearerToken": []string{}}, map[string]any{"tokenHeader": []string{}}},
+		"paths":    paths,
+		"components": map[string]any{
+			"schemas": b.schemas,
+			"securitySchemes": map[string]any{
+				"bearerToken": map[string]any{"type": "http", "scheme": "bearer"},
+				"tokenHeader": map[string]any{"type": "apiKey", "in": "header", "name": apiTokenHeader},
+			},
+		},
+	}
+}
+
+func (b *openAPIBuilder) operation(method string, path string, op openAPIOperation) map[string]any {
+	out := map[string]any{
+		"summary":     op.Summary,
+		"tags":        []string{op.Tag},
+		"operationId": operationID(method, path),
+	}
+	if op.Public {
+		out["security"] = []any{}
+	}
+
+	var params []any
+	for _, name := range ginParamPattern.FindAllStringSubmatch(strings.NewReplacer("{", ":", "}", "").Replace(path), -1) {
+		params = append(params, map[string]any{"name": name[1], "in": "path", "required": true, "schema": openAPISchema{"type": "string"}})
+	}
+	for _, param := range op.Query {
+		paramType := param.Type
+		if paramType == "" {
+			paramType = "string"
+		}
+		query := map[string]any{"name": param.Name, "in": "query", "required": param.Required, "schema": openAPISchema{"type": paramType}}
+		if param.Description != "" {
+			query["description"] = param.Description
+		}
+		params = append(params, query)
+	}
+	if len(params) > 0 {
+		out["parameters"] = params
+	}
+
+	if op.Body != nil {
+		bodyType := "application/json"
+		if op.Consumes != "" {
+			bodyType = op.Consumes
+		}
+		content := map[string]any{bodyType: map[string]any{"schema": b.schema(op.Body)}}
+		if len(op.FormFields) > 0 {
+			form := make(map[string]any)
+			for _, field := range op.FormFields {
+				form[field] = openAPISchema{"type": "string"}
+			}
+			form[op.FormFields[0]] = openAPISchema{"type": "string", "format": "binary"}
+			content["multipart/form-data"] = map[string]any{"schema": openAPISchema{"type": "object", "properties": form}}
+		}
+		out["requestBody"] = map[string]any{"required": true, "content": content}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := map[string]any{"description": http.StatusText(status)}
+	switch {
+	case op.Produces != "":
+		success["content"] = map[string]any{op.Produces: map[string]any{"schema": openAPISchema{"type": "string", "format": "binary"}}}
+	case op.Response != nil:
+		success["content"] = map[string]any{"application/json": map[string]any{"schema": b.schema(op.Response)}}
+	}
+	errorSchema := openAPISchema{"$ref": "#/components/schemas/legacyError"}
+	if strings.HasPrefix(path, "/api/v1/") {
+		errorSchema = openAPISchema{"$ref": "#/components/schemas/apiErrorResponse"}
+	}
+	out["responses"] = map[string]any{
+		fmt.Sprint(status): success,
+		"401":              map[string]any{"description": "Missing or invalid API token"},
+		"default":          map[string]any{"description": "Error", "content": map[string]any{"application/json": map[string]any{"schema": errorSchema}}},
+	}
+	return out
+}
+
+// GET /api/v1/games/{uid} becomes getApiV1GamesUid
+func operationID(method string, path string) string {
+	id := strings.ToLower(method)
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool { return !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9') }) {
+		id += strings.ToUpper(part[:1]) + part[1:]
+	}
+	return id
+}
+
+func (b *openAPIBuilder) schema(value any) openAPISchema {
+	if s, ok := value.(openAPISchema); ok {
+		properties, _ := s["properties"].(map[string]any)
+		if properties == nil {
+			return s
+		}
+		resolved := make(map[string]any, len(properties))
+		for name, prop := range properties {
+			resolved[name] = b.schema(prop)
+		}
+		return openAPISchema{"type": "object", "properties": resolved}
+	}
+	if name, ok := value.(string); ok {
+		if item, isArray := strings.CutPrefix(name, "[]"); isArray {
+			return openAPISchema{"type": "array", "items": openAPISchema{"type": item}}
+		}
+		return openAPISchema{"type": name}
+	}
+	return b.schemaFor(reflect.TypeOf(value))
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Named structs go to components.schemas and are referenced, everything else is inlined
+func (b *openAPIBuilder) schemaFor(t reflect.Type) openAPISchema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return openAPISchema{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return openAPISchema{"type": "string"}
+	case reflect.Bool:
+		return openAPISchema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return openAPISchema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return openAPISchema{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return openAPISchema{"type": "array", "items": b.schemaFor(t.Elem())}
+	case reflect.Map:
+		return openAPISchema{"type": "object", "additionalProperties": b.schemaFor(t.Elem())}
+	case reflect.Struct:
+	default:
+		return openAPISchema{}
+	}
+
+	name := schemaName(t)
+	if name != "" {
+		if _, ok := b.schemas[name]; !ok {
+			// Placeholder first so self references terminate
+			b.schemas[name] = openAPISchema{}
+			b.schemas[name] = b.structSchema(t)
+		}
+		return openAPISchema{"$ref": "#/components/schemas/" + name}
+	}
+	return b.structSchema(t)
+}
+
+func (b *openAPIBuilder) structSchema(t reflect.Type) openAPISchema {
+	properties := make(map[string]any)
+	var required []string
+	b.addFields(t, properties, &required)
+	s := openAPISchema{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		sort.Strings(required)
+		s["required"] = required
+	}
+	return s
+}
+
+func (b *openAPIBuilder) addFields(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && tag == "" {
+			b.addFields(field.Type, properties, required)
+			continue
+		}
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = b.schemaFor(field.Type)
+		if strings.Contains(field.Tag.Get("binding"), "required") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+var genericArgPattern = regexp.MustCompile(`[\[\],]+`)
+
+// listResponse[main.gameSummary] becomes listResponse_gameSummary
+func schemaName(t reflect.Type) string {
+	name := strings.ReplaceAll(t.Name(), t.PkgPath()+".", "")
+	name = strings.ReplaceAll(name, "main.", "")
+	return strings.Trim(genericArgPattern.ReplaceAllString(name, "_"), "_")
+}
+
+func registerOpenAPIRoute(r *gin.Engine) {
+	document := buildOpenAPIDocument()
+	r.GET("/openapi.json", func(c *gin.Context) {
+		c.JSON(http.StatusOK, document)
+	})
+}
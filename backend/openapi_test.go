@@ -0,0 +1,18 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestOpenAPICoversAllRoutes(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	undocumented, stale := checkOpenAPICoverage(setupRouter().Routes())
+	for _, route := range undocumented {
+		t.Errorf("route without OpenAPI description: %s", route)
+	}
+	for _, route := range stale {
+		t.Errorf("OpenAPI description without route: %s", route)
+	}
+}
@@ -2,70 +2,211 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"net/url"
 	"regexp"
 	"strings"
-	"sync"
 	"time"
 )
 
 func updateNpsso(Npsso string) error {
-	err := txWrite(func(tx *sql.Tx) error {
-		QueryString := "DELETE FROM PlayStationNpsso"
-		_, err := tx.Exec(QueryString)
-		if err != nil {
-			return fmt.Errorf("failed to delete old Npsso: %w", err)
-		}
+	return setSecret(secretPlayStationNpsso, Npsso)
+}
 
-		QueryString = "INSERT INTO PlayStationNpsso (Npsso) VALUES (?)"
-		_, err = tx.Exec(QueryString, Npsso)
-		if err != nil {
-			return fmt.Errorf("failed to insert new Npsso: %w", err)
-		}
-		return nil
-	})
-	return err
+// An empty token reuses the stored one
+func useNpsso(npsso string) (string, error) {
+	var err error
+	if npsso == "" {
+		npsso, err = getNpsso()
+	}
+	if err == nil && npsso == "" {
+		err = fmt.Errorf("no npsso configured")
+	}
+	if err == nil {
+		err = updateNpsso(npsso)
+	}
+	return npsso, err
+}
+
+// A title of a PlayStation import job. Titles from the game list come with their playtime, titles
+// only found through trophies have none.
+type psImportItem struct {
+	Title    string `json:"title"`
+	Platform string `json:"platform"`
+	Playtime string `json:"playtime,omitempty"`
+	Trophy   bool   `json:"trophy,omitempty"`
 }
 
-func playstationImportUserGames(npsso string, clientID string, clientSecret string) ([]string, error) {
-	authCode, err := getAuthCode(npsso)
+// Needs PSN only to list the titles, matching them against IGDB happens per item
+type psImporter struct {
+	accessToken string
+}
+
+const psPlatforms = "('Sony PlayStation 4', 'Sony PlayStation 5', 'Sony PlayStation 3', 'Sony PlayStation x')"
+
+// The game list first, then trophy titles the game list doesn't have
+func (p *psImporter) plan(ctx context.Context) ([]jobItem, error) {
+	npsso, err := getNpsso()
+	if err != nil {
+		return nil, err
+	}
+	if npsso == "" {
+		return nil, fmt.Errorf("no npsso configured")
+	}
+	authCode, err := getAuthCode(ctx, npsso)
 	if err != nil {
 		return nil, fmt.Errorf("check your npsso, error getting auth code: %w", err)
 	}
-	authToken, err := getAuthToken(authCode)
+	authToken, err := getAuthToken(ctx, authCode)
 	if err != nil {
 		return nil, fmt.Errorf("error getting auth token: %w", err)
 	}
 
-	gamesList, err := getAndInsertPSGames_NormalAPI(authToken, clientID, clientSecret)
+	titles, err := getPSGameList(ctx, authToken)
 	if err != nil {
-		return nil, fmt.Errorf("error getting inserting psn games: %w", err)
+		return nil, fmt.Errorf("error getting psn games: %w", err)
+	}
+	var items []jobItem
+	var NormalAPIGamesList []string
+	for _, title := range titles {
+		item, err := newJobItem("game:"+title.Title, title.Title, title)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		NormalAPIGamesList = append(NormalAPIGamesList, normalizeTitleToStore(title.Title))
 	}
-	NormalAPIGamesList := gamesList["NormalApiGamesList"].([]string)
-	gamesNotMatched := gamesList["gamesNotMatched"].([]string)
 
-	TrophyAPIGamesList, err := getGameTrophyAPI(authToken)
+	TrophyAPIGamesList, err := getGameTrophyAPI(ctx, authToken)
 	if err != nil {
 		return nil, fmt.Errorf("error getting trophy API games: %w", err)
 	}
-	FilteredTrophyGames := RemoveDuplicatesFromTrophiesList(NormalAPIGamesList, TrophyAPIGamesList)
-	trophyApiGamesNotMatched, err := insertFilteredTrophyGames(FilteredTrophyGames, clientID, clientSecret)
+	for _, game := range RemoveDuplicatesFromTrophiesList(NormalAPIGamesList, TrophyAPIGamesList) {
+		platform := game["Platform"]
+		if platform == "PS3,PSVITA" {
+			platform = "Sony PlayStation 3"
+		}
+		item, err := newJobItem("trophy:"+game["Title"], game["Title"], psImportItem{Title: game["Title"], Platform: platform, Trophy: true})
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// Titles already in the library get their playtime updated, new ones are matched against IGDB by name
+func (p *psImporter) importItem(ctx context.Context, item jobItem) (string, error) {
+	var game psImportItem
+	if err := json.Unmarshal(item.Data, &game); err != nil {
+		return "", fmt.Errorf("invalid playstation import item: %w", err)
+	}
+	title := game.Title
+	titleToStoreInDB := normalizeTitleToStore(title)
+
+	var existing int
+	err := readDB.QueryRow("SELECT COUNT(*) FROM GameMetaData WHERE Name = ? AND OwnedPlatform IN "+psPlatforms, titleToStoreInDB).Scan(&existing)
 	if err != nil {
-		return nil, fmt.Errorf("error inserting trophy API games: %w", err)
+		return "", fmt.Errorf("database query error: %w", err)
+	}
+	if existing > 0 {
+		if game.Trophy {
+			return jobItemSkipped, nil
+		}
+		err := txWrite(func(tx *sql.Tx) error {
+			_, err := tx.Exec("UPDATE GameMetaData SET TimePlayed = ? WHERE Name = ? AND OwnedPlatform IN "+psPlatforms, game.Playtime, titleToStoreInDB)
+			if err != nil {
+				return fmt.Errorf("error updating playtime: %w", err)
+			}
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+		return jobItemUpdated, nil
+	}
+
+	titleToSendIGDB := normalizeTitleToSend(title)
+	gameStruct, err := p.searchGame(ctx, titleToSendIGDB)
+	if err != nil {
+		return "", fmt.Errorf("error searching for game: %w", err)
+	}
+	foundGames := returnFoundGames(gameStruct)
+	AppID, Match := matchPSTitle(foundGames, titleToSendIGDB, false)
+	// Trophy titles get a second, looser pass
+	if !Match && game.Trophy {
+		fmt.Println("Failed First Pass For : ", title)
+		AppID, Match = matchPSTitle(foundGames, titleToSendIGDB, true)
+	}
+	if !Match {
+		return jobItemUnmatched, nil
 	}
 
-	allGamesNotMatched := append(gamesNotMatched, trophyApiGamesNotMatched...)
-	fmt.Println("All Games Not Matched", allGamesNotMatched)
+	timePlayed := game.Playtime
+	if game.Trophy {
+		timePlayed = "-1"
+	}
+	gameMetaData, err := getMetaDataFromIGDBforPS3(ctx, titleToStoreInDB, AppID, gameStruct, p.accessToken, game.Platform)
+	if err != nil {
+		return "", fmt.Errorf("error getting game metadata: %w", err)
+	}
+	err = insertMetaDataInDB(gameMetaData, titleToStoreInDB, game.Platform, timePlayed)
+	if err != nil {
+		return "", fmt.Errorf("error inserting game to DB: %w", err)
+	}
+	publishEvent(eventGameAdded, gameEvent{Name: title, Source: importSourcePlayStation})
+	return jobItemAdded, nil
+}
 
-	return allGamesNotMatched, err
+func (p *psImporter) finish() error {
+	return nil
 }
 
-func getAuthCode(npsso string) (string, error) {
+// IGDB tokens expire, a failed search is retried once with a fresh one
+func (p *psImporter) searchGame(ctx context.Context, title string) (igdbSearchResult, error) {
+	var err error
+	if p.accessToken == "" {
+		p.accessToken, err = getAccessToken(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error getting IGDB access token: %w", err)
+		}
+	}
+	gameStruct, err := searchGame(ctx, p.accessToken, title)
+	if err == nil {
+		return gameStruct, nil
+	}
+	p.accessToken, err = getAccessToken(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting IGDB access token: %w", err)
+	}
+	return searchGame(ctx, p.accessToken, title)
+}
+
+// Returns the IGDB id of the first result named like title. The second pass also expands
+// abbreviations and ignores everything after the first number.
+func matchPSTitle(foundGames map[int]map[string]interface{}, titleToSendIGDB string, secondPass bool) (int, bool) {
+	if secondPass {
+		titleToSendIGDB = normalizePass2(titleToSendIGDB)
+	}
+	for _, foundGame := range foundGames {
+		IGDBtitleNormalized := normalizeTitleToSend(foundGame["name"].(string))
+		if secondPass {
+			IGDBtitleNormalized = normalizePass2(IGDBtitleNormalized)
+		}
+		if IGDBtitleNormalized == titleToSendIGDB {
+			return foundGame["appid"].(int), true
+		}
+	}
+	return 0, false
+}
+
+func getAuthCode(ctx context.Context, npsso string) (string, error) {
 	params := url.Values{}
 	params.Add("access_type", "offline")
 	params.Add("client_id", "09515159-7237-4370-9b40-3806e67c0891")
@@ -76,7 +217,7 @@ func getAuthCode(npsso string) (string, error) {
 	requestURL := "https://ca.account.sony.com/api/authz/v3/oauth/authorize?" + params.Encode()
 
 	client := &http.Client{}
-	req, err := http.NewRequest("GET", requestURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", requestURL, nil)
 	if err != nil {
 		return "", fmt.Errorf("failed to create request: %w", err)
 	}
@@ -102,7 +243,7 @@ func getAuthCode(npsso string) (string, error) {
 	defer resp.Body.Close()
 	return "", fmt.Errorf("PSN oauth did not redirect")
 }
-func getAuthToken(code string) (string, error) {
+func getAuthToken(ctx context.Context, code string) (string, error) {
 	body := url.Values{}
 	body.Add("code", code)
 	body.Add("redirect_uri", "com.scee.psxandroid.scecompcall://redirect")
@@ -112,7 +253,7 @@ func getAuthToken(code string) (string, error) {
 	contentType := "application/x-www-form-urlencoded"
 	tokenURL := "https://ca.account.sony.com/api/authz/v3/oauth/token"
 
-	req, err := http.NewRequest("POST", tokenURL, bytes.NewBufferString(body.Encode()))
+	req, err := http.NewRequestWithContext(ctx, "POST", tokenURL, bytes.NewBufferString(body.Encode()))
 	if err != nil {
 		return "", fmt.Errorf("failed to create request: %w", err)
 	}
@@ -145,38 +286,15 @@ func getAuthToken(code string) (string, error) {
 	return result.AccessToken, nil
 }
 
-func getAndInsertPSGames_NormalAPI(token string, clientID string, clientSecret string) (map[string]interface{}, error) {
-	returnMap := make(map[string]interface{})
-	var allGamesNotMatched []string
-	var allPSgameList_NormalAPI_Normalized []string
-
-	// Query database for existing PlayStation game titles
-	existingTitles := make(map[string]bool)
-	rows, err := readDB.Query("SELECT Name FROM GameMetaData WHERE OwnedPlatform IN ('Sony PlayStation 4', 'Sony PlayStation 5', 'Sony PlayStation 3', 'Sony PlayStation x')")
-	if err != nil {
-		return nil, fmt.Errorf("database query error: %w", err)
-	}
-	defer rows.Close()
-	for rows.Next() {
-		var titleDB string
-		if err := rows.Scan(&titleDB); err != nil {
-			return nil, fmt.Errorf("error scanning row: %w", err)
-		}
-		existingTitles[titleDB] = true
-	}
-
-	//IGDB access token
-	accessToken, err := getAccessToken(clientID, clientSecret)
-	if err != nil {
-		return nil, fmt.Errorf("error getting IGDB access token: %w", err)
-	}
-
+// Pages through the PSN game list
+func getPSGameList(ctx context.Context, token string) ([]psImportItem, error) {
+	var titles []psImportItem
 	offset := 0
 	limit := 200
 
 	for {
 		url := fmt.Sprintf("https://m.np.playstation.com/api/gamelist/v2/users/me/titles?categories=ps4_game,ps5_native_game&limit=%d&offset=%d", limit, offset)
-		req, err := http.NewRequest("GET", url, nil)
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 		if err != nil {
 			return nil, fmt.Errorf("error creating request: %w", err)
 		}
@@ -188,13 +306,11 @@ func getAndInsertPSGames_NormalAPI(token string, clientID string, clientSecret s
 		if err != nil {
 			return nil, fmt.Errorf("error sending request: %w", err)
 		}
-		defer resp.Body.Close()
-
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
 		if resp.StatusCode != http.StatusOK {
 			return nil, fmt.Errorf("unexpected response status: HTTP %d", resp.StatusCode)
 		}
-
-		body, err := io.ReadAll(resp.Body)
 		if err != nil {
 			return nil, fmt.Errorf("error reading response body: %w", err)
 		}
@@ -207,101 +323,30 @@ func getAndInsertPSGames_NormalAPI(token string, clientID string, clientSecret s
 			break
 		}
 
-		var gamesNotMatched []string
-		var PSgameList_NormalAPI_Normalized []string
-
 		for _, game := range PsGameStruct.Titles {
-			title := game.Name
-			titleToStoreInDB := normalizeTitleToStore(title)
-			PSgameList_NormalAPI_Normalized = append(PSgameList_NormalAPI_Normalized, titleToStoreInDB)
-
-			timePlayed := game.PlayDuration // Play time in format PT xH yM zS
-			timePlayedHours := convertToHours(timePlayed)
-
-			if existingTitles[titleToStoreInDB] {
-				err := txWrite(func(tx *sql.Tx) error {
-					_, err = tx.Exec("UPDATE GameMetaData SET TimePlayed = ? WHERE Name = ? AND OwnedPlatform IN ('Sony PlayStation 5', 'Sony PlayStation 4', 'Sony PlayStation 3', 'Sony PlayStation x')", timePlayedHours, titleToStoreInDB)
-					if err != nil {
-						return fmt.Errorf("error updating playtime: %w", err)
-					}
-					return nil
-				})
-				if err != nil {
-					return nil, err
-				}
-
-			} else {
-				platform := game.Category // ps4_game ps5_native_game can be unknown
-				if platform == "ps4_game" {
-					platform = "Sony PlayStation 4"
-				}
-				if platform == "ps5_native_game" {
-					platform = "Sony PlayStation 5"
-				}
-				if platform == "unknown" {
-					platform = "Sony PlayStation x"
-				}
-
-				titleToSendIGDB := normalizeTitleToSend(title)
-
-				gameStruct, err := searchGame(accessToken, titleToSendIGDB)
-				if err != nil {
-					accessToken, err = getAccessToken(clientID, clientSecret)
-					if err != nil {
-						return nil, fmt.Errorf("error getting IGDB access token: %w", err)
-					}
-					gameStruct, err = searchGame(accessToken, titleToSendIGDB)
-					if err != nil {
-						return nil, fmt.Errorf("error searching for game: %w", err)
-					}
-				}
-
-				foundGames := returnFoundGames(gameStruct)
-				Match := false
-				for _, game := range foundGames {
-					IGDBtitle := game["name"].(string)
-					AppID := game["appid"].(int)
-					IGDBtitleNormalized := normalizeTitleToSend(IGDBtitle)
-					if IGDBtitleNormalized == titleToSendIGDB {
-						igdbMetaData, err := getMetaDataFromIGDBforPS3(titleToStoreInDB, AppID, gameStruct, accessToken, platform)
-						if err != nil {
-							return nil, fmt.Errorf("error getting game metadata: %w", err)
-						}
-						err = insertMetaDataInDB(igdbMetaData, titleToStoreInDB, platform, timePlayedHours)
-						if err != nil {
-							return nil, fmt.Errorf("error inserting game to DB: %w", err)
-						}
-						Match = true
-						msg := fmt.Sprintf("Game added: %s", title)
-						sendSSEMessage(msg)
-						break
-					}
-				}
-				if !Match {
-					gamesNotMatched = append(gamesNotMatched, title)
-				}
-
+			platform := game.Category // ps4_game ps5_native_game can be unknown
+			if platform == "ps4_game" {
+				platform = "Sony PlayStation 4"
 			}
-
+			if platform == "ps5_native_game" {
+				platform = "Sony PlayStation 5"
+			}
+			if platform == "unknown" {
+				platform = "Sony PlayStation x"
+			}
+			// Play time in format PT xH yM zS
+			titles = append(titles, psImportItem{Title: game.Name, Platform: platform, Playtime: convertToHours(game.PlayDuration)})
 		}
-		// Add current batch results to final lists
-		allGamesNotMatched = append(allGamesNotMatched, gamesNotMatched...)
-		allPSgameList_NormalAPI_Normalized = append(allPSgameList_NormalAPI_Normalized, PSgameList_NormalAPI_Normalized...)
 
 		// Increase offset for the next batch
 		offset += limit
 	}
-
-	fmt.Println("Games Not Added:", allGamesNotMatched)
-
-	returnMap["gamesNotMatched"] = allGamesNotMatched
-	returnMap["NormalApiGamesList"] = allPSgameList_NormalAPI_Normalized
-	return returnMap, nil
+	return titles, nil
 }
 
-func getGameTrophyAPI(token string) ([]map[string]string, error) {
+func getGameTrophyAPI(ctx context.Context, token string) ([]map[string]string, error) {
 	newURL := "https://m.np.playstation.com/api/trophy/v1/users/me/trophyTitles?limit=800"
-	req, err := http.NewRequest("GET", newURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", newURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("error creating request: %w", err)
 	}
@@ -371,120 +416,7 @@ func RemoveDuplicatesFromTrophiesList(NormalAPIGamesList []string, TrophyAPIGame
 	return unmatchedTrophyGames
 }
 
-func insertFilteredTrophyGames(FilteredTrophyGames []map[string]string, clientID string, clientSecret string) ([]string, error) {
-	var gamesNotMatched []string
-
-	// Fetch all existing game names from DB once
-	existingGames := make(map[string]bool)
-	query := "SELECT Name FROM GameMetaData WHERE OwnedPlatform IN ('Sony PlayStation 4', 'Sony PlayStation 5', 'Sony PlayStation 3', 'Sony PlayStation x')"
-	rows, err := readDB.Query(query)
-	if err != nil {
-		return nil, fmt.Errorf("database query error: %w", err)
-	}
-	defer rows.Close()
-	for rows.Next() {
-		var name string
-		if err := rows.Scan(&name); err != nil {
-			return nil, fmt.Errorf("error scanning row: %w", err)
-		}
-		existingGames[name] = true
-	}
-
-	//gets IGDB token
-	accessToken, err := getAccessToken(clientID, clientSecret)
-	if err != nil {
-		return nil, fmt.Errorf("error getting IGDB access token: %w", err)
-	}
-
-	for _, game := range FilteredTrophyGames {
-		title := game["Title"]
-		platform := game["Platform"]
-		if platform == "PS3,PSVITA" {
-			platform = "Sony PlayStation 3"
-		}
-		titleToStoreInDB := normalizeTitleToStore(title)
-		fmt.Println("Trying to Insert", title, " ", platform)
-
-		// Skip if game already exists
-		if existingGames[titleToStoreInDB] {
-			continue
-		}
-
-		fmt.Println("Trying to Insert", title)
-		titleToSendIGDB := normalizeTitleToSend(title)
-
-		//IGDB search
-		gameStruct, err := searchGame(accessToken, titleToSendIGDB)
-		if err != nil {
-			//This is to refresh access token
-			accessToken, err = getAccessToken(clientID, clientSecret)
-			if err != nil {
-				return nil, fmt.Errorf("error getting IGDB access token: %w", err)
-			}
-			gameStruct, err = searchGame(accessToken, titleToSendIGDB)
-			if err != nil {
-				return nil, fmt.Errorf("error in game search: %w", err)
-			}
-		}
-		//Holds all matching games
-		foundGames := returnFoundGames(gameStruct)
-		Match := false
-
-		for _, foundGame := range foundGames {
-			IGDBtitle := foundGame["name"].(string)
-			AppID := foundGame["appid"].(int)
-
-			IGDBtitleNormalized := normalizeTitleToSend(IGDBtitle)
-			if IGDBtitleNormalized == titleToSendIGDB {
-				var gameMetaData igdbMetaData
-				if gameMetaData, err = getMetaDataFromIGDBforPS3(titleToStoreInDB, AppID, gameStruct, accessToken, platform); err != nil {
-					return nil, fmt.Errorf("error getting game metadata: %w", err)
-				}
-				if err := insertMetaDataInDB(gameMetaData, titleToStoreInDB, platform, "-1"); err != nil {
-					return nil, fmt.Errorf("error inserting game to DB: %w", err)
-				}
-				Match = true
-				msg := fmt.Sprintf("Game added: %s", title)
-				sendSSEMessage(msg)
-				break
-			}
-		}
-		if !Match {
-			fmt.Println("Failed First Pass For : ", title)
-			for _, foundGame := range foundGames {
-				IGDBtitle := foundGame["name"].(string)
-				AppID := foundGame["appid"].(int)
-
-				IGDBtitleNormalized := normalizeTitleToSend(IGDBtitle)
-				titleToSendIGDB = normalizePass2(titleToSendIGDB)
-				IGDBtitleNormalized = normalizePass2(IGDBtitleNormalized)
-				fmt.Println(IGDBtitleNormalized, " ", titleToSendIGDB)
-
-				if IGDBtitleNormalized == titleToSendIGDB {
-					fmt.Println("Second pass match for: ", AppID)
-					var gameMetaData igdbMetaData
-					if gameMetaData, err = getMetaDataFromIGDBforPS3(titleToStoreInDB, AppID, gameStruct, accessToken, platform); err != nil {
-						return nil, fmt.Errorf("error getting game metadata (2nd pass): %w", err)
-					}
-					if err := insertMetaDataInDB(gameMetaData, titleToStoreInDB, platform, "-1"); err != nil {
-						return nil, fmt.Errorf("error inserting game to DB (2nd pass): %w", err)
-					}
-					Match = true
-					msg := fmt.Sprintf("Game added: %s", title)
-					sendSSEMessage(msg)
-					break
-				}
-			}
-		}
-		if !Match {
-			gamesNotMatched = append(gamesNotMatched, title)
-		}
-	}
-	msg := fmt.Sprintf("Game added: %s", "finished")
-	sendSSEMessage(msg)
-	return gamesNotMatched, nil
-}
-func getMetaDataFromIGDBforPS3(Title string, gameID int, gameStruct igdbSearchResult, accessToken string, platform string) (igdbMetaData, error) {
+func getMetaDataFromIGDBforPS3(ctx context.Context, Title string, gameID int, gameStruct igdbSearchResult, accessToken string, platform string) (igdbMetaData, error) {
 
 	var gameIndex int = -1
 	for i := range gameStruct {
@@ -498,12 +430,7 @@ func getMetaDataFromIGDBforPS3(Title string, gameID int, gameStruct igdbSearchRe
 		return igdbMetaData{}, fmt.Errorf("game ID %d not found in IGDB data", gameID)
 	}
 
-	var playerPerspectiveStruct TagsStruct
-	var themeStruct TagsStruct
-	var genresStruct TagsStruct
-	var gameModesStruct TagsStruct
 	var involvedCompaniesStruct TagsStruct
-	var gameEngineStruct TagsStruct
 	var coverStruct ImgStruct
 	var screenshotStruct ImgStruct
 
@@ -527,71 +454,40 @@ func getMetaDataFromIGDBforPS3(Title string, gameID int, gameStruct igdbSearchRe
 	}
 
 	// Seperate Cause it needs 2 API calls
-	err := getMetaData_InvolvedCompanies(gameIndex, &involvedCompaniesStruct, gameStruct, accessToken)
+	err := getMetaData_InvolvedCompanies(ctx, gameIndex, &involvedCompaniesStruct, gameStruct, accessToken)
 	if err != nil {
 		return igdbMetaData{}, fmt.Errorf("error getting involved companies: %w", err)
 	}
-	// Tags
-	postString := "https://api.igdb.com/v4/player_perspectives"
-	passer := gameStruct[gameIndex].PlayerPerspectives
-	err = getMetaData_TagsAndEngine(accessToken, postString, passer, &playerPerspectiveStruct)
-	if err != nil {
-		return igdbMetaData{}, fmt.Errorf("error getting tags: %w", err)
-	}
-	postString = "https://api.igdb.com/v4/genres"
-	passer = gameStruct[gameIndex].Genres
-	err = getMetaData_TagsAndEngine(accessToken, postString, passer, &genresStruct)
-	if err != nil {
-		return igdbMetaData{}, fmt.Errorf("error getting tags: %w", err)
-	}
-	postString = "https://api.igdb.com/v4/themes"
-	passer = gameStruct[gameIndex].Themes
-	err = getMetaData_TagsAndEngine(accessToken, postString, passer, &themeStruct)
-	if err != nil {
-		return igdbMetaData{}, fmt.Errorf("error getting tags: %w", err)
-	}
-	postString = "https://api.igdb.com/v4/game_modes"
-	passer = gameStruct[gameIndex].GameModes
-	err = getMetaData_TagsAndEngine(accessToken, postString, passer, &gameModesStruct)
-	if err != nil {
-		return igdbMetaData{}, fmt.Errorf("error getting tags: %w", err)
-	}
-	postString = "https://api.igdb.com/v4/game_engines"
-	passer = gameStruct[gameIndex].GameEngines
-	err = getMetaData_TagsAndEngine(accessToken, postString, passer, &gameEngineStruct)
+	// Tags, engines, franchises, ratings and websites
+	typedMetaData, err := getIgdbTypedMetaData(ctx, accessToken, gameIndex, gameStruct)
 	if err != nil {
 		return igdbMetaData{}, fmt.Errorf("error getting tags: %w", err)
 	}
 
 	//Images
-	postString = "https://api.igdb.com/v4/screenshots"
+	postString := "https://api.igdb.com/v4/screenshots"
 	folderName := "screenshots"
-	screenshotStruct, err = getMetaData_ImagesPSN(accessToken, postString, UID, gameID, coverStruct, folderName)
+	screenshotStruct, err = getMetaData_ImagesPSN(ctx, accessToken, postString, UID, gameID, coverStruct, folderName)
 	if err != nil {
 		return igdbMetaData{}, fmt.Errorf("error getting PSN screenshots: %w", err)
 	}
 
 	postString = "https://api.igdb.com/v4/covers"
 	folderName = "coverArt"
-	coverStruct, err = getMetaData_ImagesPSN(accessToken, postString, UID, gameID, coverStruct, folderName)
+	coverStruct, err = getMetaData_ImagesPSN(ctx, accessToken, postString, UID, gameID, coverStruct, folderName)
 	if err != nil {
 		return igdbMetaData{}, fmt.Errorf("error getting PSN covers: %w", err)
 	}
 
-	igdbMetaData := igdbMetaData{
-		AggregatedRating:   AggregatedRating,
-		CoverArtPath:       coverStruct,
-		GameModes:          gameModesStruct,
-		Genres:             genresStruct,
-		InvolvedCompanies:  involvedCompaniesStruct,
-		Name:               Name,
-		UID:                UID,
-		Summary:            summary,
-		ReleaseDateTime:    releaseDateTime,
-		ScreenshotPaths:    screenshotStruct,
-		Themes:             themeStruct,
-		PlayerPerspectives: playerPerspectiveStruct,
-	}
+	igdbMetaData := typedMetaData
+	igdbMetaData.AggregatedRating = AggregatedRating
+	igdbMetaData.CoverArtPath = coverStruct
+	igdbMetaData.InvolvedCompanies = involvedCompaniesStruct
+	igdbMetaData.Name = Name
+	igdbMetaData.UID = UID
+	igdbMetaData.Summary = summary
+	igdbMetaData.ReleaseDateTime = releaseDateTime
+	igdbMetaData.ScreenshotPaths = screenshotStruct
 	return igdbMetaData, nil
 }
 func insertMetaDataInDB(igdbMetaData igdbMetaData, title string, platform string, time string) error {
@@ -671,14 +567,26 @@ func insertMetaDataInDB(igdbMetaData igdbMetaData, title string, platform string
 				return fmt.Errorf("error inserting into Tags: %w", err)
 			}
 		}
+
+		// Typed copies of the same metadata plus the IGDB id for later refreshes
+		err = txInsertIgdbMetaData(tx, UID, igdbMetaData)
+		if err != nil {
+			return fmt.Errorf("error inserting IGDB metadata: %w", err)
+		}
+		if len(igdbMetaData.CoverArtPath) > 0 {
+			err = txRegisterArtwork(tx, UID, artworkCover, fmt.Sprintf(`coverArt/%s/generic-0.webp`, UID), igdbMetaData.CoverArtPath[0].URL, "igdb")
+			if err != nil {
+				return fmt.Errorf("error inserting artwork: %w", err)
+			}
+		}
 		return nil
 	})
 	return err
 }
 
-func getMetaData_ImagesPSN(accessToken string, postString string, UID string, gameID int, GeneralStruct ImgStruct, folderName string) (ImgStruct, error) {
+func getMetaData_ImagesPSN(ctx context.Context, accessToken string, postString string, UID string, gameID int, GeneralStruct ImgStruct, folderName string) (ImgStruct, error) {
 	bodyString := fmt.Sprintf(`fields url; where game=%d;`, gameID)
-	body, err := post(postString, bodyString, accessToken)
+	body, err := post(ctx, postString, bodyString, accessToken)
 	if err != nil {
 		return nil, fmt.Errorf("error making request: %w", err)
 	}
@@ -687,21 +595,26 @@ func getMetaData_ImagesPSN(accessToken string, postString string, UID string, ga
 		return nil, fmt.Errorf("error unmarshalling response: %w", err)
 	}
 
-	var wg sync.WaitGroup
-
+	assetType := "screenshot"
+	if folderName == "coverArt" {
+		assetType = artworkCover
+	}
+	var downloads []imageDownload
 	for i := range len(GeneralStruct) {
 		GeneralStruct[i].URL = strings.Replace(GeneralStruct[i].URL, "t_thumb", "t_1080p", 1)
 		GeneralStruct[i].URL = "https:" + GeneralStruct[i].URL
-		wg.Add(1)
-		go func(i int, url string) {
-			defer wg.Done()
-			getString := url
-			location := fmt.Sprintf(`%s/%s/`, folderName, UID)
-			filename := fmt.Sprintf(`generic-%d.webp`, i)
-			getImageFromURL(getString, location, filename)
-		}(i, GeneralStruct[i].URL)
-	}
-	wg.Wait()
+		downloads = append(downloads, imageDownload{
+			UID:       UID,
+			AssetType: assetType,
+			Origin:    screenshotSourceIGDB,
+			Source:    GeneralStruct[i].URL,
+			Location:  fmt.Sprintf(`%s/%s/`, folderName, UID),
+			Filename:  fmt.Sprintf(`generic-%d.webp`, i),
+		})
+	}
+	if failed := downloadImages(downloads); len(failed) > 0 {
+		log.Printf("%d images for %s could not be downloaded", len(failed), UID)
+	}
 	return GeneralStruct, nil
 }
 
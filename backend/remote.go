@@ -0,0 +1,489 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Remote control for companion devices, like a phone on the couch starting a game on the gaming PC.
+// The owner, signed in with the API token or a web session, issues a short-lived pairing code. The
+// device trades it for a token of its own that only reaches the remote routes and images, and that
+// the owner can revoke at any time.
+const (
+	pairingCodeLength   = 8
+	pairingCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	pairingCodeLifetime = 5 * time.Minute
+	deviceNameMaxLength = 64
+	deviceSeenInterval  = time.Minute
+	remoteDeviceKey     = "remoteDevice"
+	remotePairRoute     = "/api/v1/remote/pair"
+)
+
+var remoteEventPrefixes = []string{"session.", "screenshot."}
+
+// Routes a device token may call, the rest of /api/v1/remote manages pairing and needs the owner
+var remoteDeviceRoutes = []string{"/api/v1/remote/games", "/api/v1/remote/sessions", "/api/v1/remote/screenshots", "/api/v1/remote/events", "/images/"}
+
+var errDeviceNotFound = errors.New("device not found")
+
+type remoteDevice struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	CreatedAt  string `json:"createdAt"`
+	LastSeenAt string `json:"lastSeenAt"`
+}
+
+type pairingCode struct {
+	Code      string    `json:"code"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type pairRequest struct {
+	Code string `json:"code" binding:"required"`
+	Name string `json:"name" binding:"required"`
+}
+
+// The token is only ever shown here
+type pairResult struct {
+	Device remoteDevice `json:"device"`
+	Token  string       `json:"token"`
+}
+
+// A running game with what a remote needs to show it
+type remoteSession struct {
+	playSession
+	Name  string            `json:"name"`
+	Cover map[string]string `json:"cover"`
+}
+
+type remoteLaunchRequest struct {
+	UID string `json:"uid" binding:"required"`
+}
+
+// Without a uid the shot goes to the game in the foreground
+type remoteScreenshotRequest struct {
+	UID string `json:"uid"`
+}
+
+type remoteScreenshot struct {
+	UID   string            `json:"uid"`
+	Path  string            `json:"path"`
+	Image map[string]string `json:"image"`
+}
+
+var pairingCodes = struct {
+	sync.Mutex
+	expires map[string]time.Time
+}{expires: make(map[string]time.Time)}
+
+// Last seen times are written at most once per deviceSeenInterval
+var deviceSeen = struct {
+	sync.Mutex
+	written map[string]time.Time
+}{written: make(map[string]time.Time)}
+
+func newPairingCode() (pairingCode, error) {
+	code := make([]byte, pairingCodeLength)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(pairingCodeAlphabet))))
+		if err != nil {
+			return pairingCode{}, err
+		}
+		code[i] = pairingCodeAlphabet[n.Int64()]
+	}
+	pairing := pairingCode{Code: string(code), ExpiresAt: time.Now().Add(pairingCodeLifetime)}
+
+	pairingCodes.Lock()
+	defer pairingCodes.Unlock()
+	for existing, expires := range pairingCodes.expires {
+		if time.Now().After(expires) {
+			delete(pairingCodes.expires, existing)
+		}
+	}
+	pairingCodes.expires[pairing.Code] = pairing.ExpiresAt
+	return pairing, nil
+}
+
+// Codes work once. Case, spaces and dashes don't matter, so "abcd-efgh" matches ABCDEFGH.
+func claimPairingCode(code string) bool {
+	code = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	pairingCodes.Lock()
+	defer pairingCodes.Unlock()
+	expires, ok := pairingCodes.expires[code]
+	if !ok {
+		return false
+	}
+	delete(pairingCodes.expires, code)
+	return time.Now().Before(expires)
+}
+
+func hashDeviceToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func addRemoteDevice(name string) (remoteDevice, string, error) {
+	raw := make([]byte, 40)
+	if _, err := rand.Read(raw); err != nil {
+		return remoteDevice{}, "", err
+	}
+	device := remoteDevice{
+		ID:        hex.EncodeToString(raw[:8]),
+		Name:      name,
+		CreatedAt: time.Now().Format(time.RFC3339),
+	}
+	token := hex.EncodeToString(raw[8:])
+	err := txWrite(func(tx *sql.Tx) error {
+		_, err := tx.Exec("INSERT INTO RemoteDevices (ID, Name, TokenHash, CreatedAt) VALUES (?,?,?,?)",
+			device.ID, device.Name, hashDeviceToken(token), device.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("error inserting into RemoteDevices: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return remoteDevice{}, "", err
+	}
+	return device, token, nil
+}
+
+func getRemoteDevices() ([]remoteDevice, error) {
+	rows, err := readDB.Query("SELECT ID, Name, CreatedAt, LastSeenAt FROM RemoteDevices ORDER BY CreatedAt")
+	if err != nil {
+		return nil, fmt.Errorf("query error RemoteDevices: %w", err)
+	}
+	defer rows.Close()
+	devices := []remoteDevice{}
+	for rows.Next() {
+		var device remoteDevice
+		if err := rows.Scan(&device.ID, &device.Name, &device.CreatedAt, &device.LastSeenAt); err != nil {
+			return nil, fmt.Errorf("scan error RemoteDevices: %w", err)
+		}
+		devices = append(devices, device)
+	}
+	return devices, rows.Err()
+}
+
+// Takes effect with the device's next request
+func revokeRemoteDevice(id string) error {
+	err := txWrite(func(tx *sql.Tx) error {
+		result, err := tx.Exec("DELETE FROM RemoteDevices WHERE ID = ?", id)
+		if err != nil {
+			return fmt.Errorf("error deleting from RemoteDevices: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return errDeviceNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	deviceSeen.Lock()
+	delete(deviceSeen.written, id)
+	deviceSeen.Unlock()
+	return nil
+}
+
+func deviceForToken(token string) (remoteDevice, bool) {
+	var device remoteDevice
+	err := readDB.QueryRow("SELECT ID, Name, CreatedAt, LastSeenAt FROM RemoteDevices WHERE TokenHash = ?", hashDeviceToken(token)).
+		Scan(&device.ID, &device.Name, &device.CreatedAt, &device.LastSeenAt)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("[Remote] ERROR : %v", err)
+		}
+		return remoteDevice{}, false
+	}
+	markDeviceSeen(device.ID)
+	return device, true
+}
+
+func markDeviceSeen(id string) {
+	deviceSeen.Lock()
+	if time.Since(deviceSeen.written[id]) < deviceSeenInterval {
+		deviceSeen.Unlock()
+		return
+	}
+	deviceSeen.written[id] = time.Now()
+	deviceSeen.Unlock()
+
+	err := txWrite(func(tx *sql.Tx) error {
+		_, err := tx.Exec("UPDATE RemoteDevices SET LastSeenAt = ? WHERE ID = ?", time.Now().Format(time.RFC3339), id)
+		return err
+	})
+	if err != nil {
+		log.Printf("[Remote] ERROR updating last seen: %v", err)
+	}
+}
+
+// Whether a device token may call the route of this request
+func remoteDeviceAllowed(c *gin.Context) bool {
+	route := c.FullPath()
+	for _, prefix := range remoteDeviceRoutes {
+		if strings.HasPrefix(route, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Names the caller in the log, devices by name and everyone else as the owner
+func remoteCaller(c *gin.Context) string {
+	if device, ok := c.Get(remoteDeviceKey); ok {
+		return fmt.Sprintf("device %q", device.(remoteDevice).Name)
+	}
+	return "owner"
+}
+
+func remoteSessions() ([]remoteSession, error) {
+	sessions := []remoteSession{}
+	for _, session := range getPlaySessions() {
+		remote := remoteSession{playSession: session}
+		game, err := getGameSummary(session.UID)
+		if err != nil && !errors.Is(err, errGameNotFound) {
+			return nil, err
+		}
+		remote.Name = game.Name
+		remote.Cover = game.Cover
+		sessions = append(sessions, remote)
+	}
+	return sessions, nil
+}
+
+// Games a remote can start, hidden ones left out
+func remoteGames(name string) ([]gameSummary, error) {
+	games, err := listGames(gameQuery{Name: name})
+	if err != nil {
+		return nil, err
+	}
+	appids, err := getSteamAppIDMap()
+	if err != nil {
+		return nil, err
+	}
+	steamGames := make(map[string]bool)
+	for _, uid := range appids {
+		steamGames[uid] = true
+	}
+	launchable := []gameSummary{}
+	for _, game := range games {
+		if game.InstallPath != "" || steamGames[game.UID] {
+			launchable = append(launchable, game)
+		}
+	}
+	return launchable, nil
+}
+
+// Starts the game and returns once it's on its way, session events tell how it goes from there
+func remoteLaunch(c *gin.Context, uid string) {
+	if _, running := getPlaySession(uid); running {
+		writeAPIError(c, http.StatusConflict, apiErrConflict, "game is already running", nil)
+		return
+	}
+	launchable, err := isLaunchable(uid)
+	if err != nil {
+		writeAPIError(c, http.StatusInternalServerError, apiErrInternal, "failed to launch game", err)
+		return
+	}
+	if !launchable {
+		writeAPIError(c, http.StatusConflict, apiErrConflict, "game has no install path or Steam app ID", nil)
+		return
+	}
+	log.Printf("[Remote] %s launched %s", remoteCaller(c), uid)
+	go func() {
+		launched, err := launchGame(uid)
+		if err == nil && !launched {
+			err = fmt.Errorf("game has no install path")
+		}
+		if err != nil {
+			log.Printf("[Remote] ERROR launching %s: %v", uid, err)
+			publishEvent(eventSessionFailed, sessionEvent{UID: uid, Error: err.Error()})
+		}
+	}()
+	c.JSON(http.StatusAccepted, launchResult{Launched: true})
+}
+
+func remoteStop(force bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid := c.Param("uid")
+		err := stopPlaySession(uid, force)
+		if errors.Is(err, errSessionNotRunning) {
+			writeAPIError(c, http.StatusNotFound, apiErrNotFound, err.Error(), nil)
+			return
+		}
+		if errors.Is(err, errSessionNoProcess) {
+			writeAPIError(c, http.StatusConflict, apiErrConflict, err.Error(), nil)
+			return
+		}
+		if err != nil {
+			writeAPIError(c, http.StatusInternalServerError, apiErrInternal, "failed to stop game", err)
+			return
+		}
+		log.Printf("[Remote] %s stopped %s (force %t)", remoteCaller(c), uid, force)
+		c.Status(http.StatusAccepted)
+	}
+}
+
+// Session and screenshot events, starting with the running sessions
+func remoteEvents(c *gin.Context) {
+	streamEvents(c, eventTypeFilter(remoteEventPrefixes), func(c *gin.Context) error {
+		sessions, err := remoteSessions()
+		if err != nil {
+			return err
+		}
+		return writeStreamEvent(c, "sessions", listResponse[remoteSession]{Items: sessions, Total: len(sessions)})
+	})
+}
+
+// Writes a 404 when uid isn't a game in the library, devices only reach games by their UID
+func remoteGameExists(c *gin.Context, uid string) bool {
+	_, err := getGameSummary(uid)
+	if errors.Is(err, errGameNotFound) {
+		writeAPIError(c, http.StatusNotFound, apiErrNotFound, "game not found", nil)
+		return false
+	}
+	if err != nil {
+		writeAPIError(c, http.StatusInternalServerError, apiErrInternal, "failed to load game", err)
+		return false
+	}
+	return true
+}
+
+func registerRemoteRoutes(r *gin.Engine) {
+	remote := r.Group("/api/v1/remote")
+
+	remote.POST("/pairings", func(c *gin.Context) {
+		pairing, err := newPairingCode()
+		if err != nil {
+			writeAPIError(c, http.StatusInternalServerError, apiErrInternal, "failed to create pairing code", err)
+			return
+		}
+		c.JSON(http.StatusCreated, pairing)
+	})
+
+	// Reached without a token, the pairing code is the credential
+	remote.POST("/pair", func(c *gin.Context) {
+		var req pairRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			writeAPIError(c, http.StatusBadRequest, apiErrInvalidRequest, "invalid pairing request", err)
+			return
+		}
+		name := strings.TrimSpace(req.Name)
+		if name == "" || len(name) > deviceNameMaxLength {
+			writeAPIError(c, http.StatusBadRequest, apiErrInvalidRequest, fmt.Sprintf("name must be 1 to %d characters", deviceNameMaxLength), nil)
+			return
+		}
+		if !claimPairingCode(req.Code) {
+			// Same pace as failed sign ins, so codes can't be guessed before they expire
+			loginLimiter.Lock()
+			time.Sleep(loginFailedDelay)
+			loginLimiter.Unlock()
+			log.Printf("[Remote] failed pairing from %s", c.ClientIP())
+			writeAPIError(c, http.StatusUnauthorized, apiErrUnauthorized, "invalid or expired pairing code", nil)
+			return
+		}
+		device, token, err := addRemoteDevice(name)
+		if err != nil {
+			writeAPIError(c, http.StatusInternalServerError, apiErrInternal, "failed to pair device", err)
+			return
+		}
+		log.Printf("[Remote] paired %q from %s", device.Name, c.ClientIP())
+		c.JSON(http.StatusCreated, pairResult{Device: device, Token: token})
+	})
+
+	remote.GET("/devices", func(c *gin.Context) {
+		devices, err := getRemoteDevices()
+		if err != nil {
+			writeAPIError(c, http.StatusInternalServerError, apiErrInternal, "failed to list devices", err)
+			return
+		}
+		c.JSON(http.StatusOK, listResponse[remoteDevice]{Items: devices, Total: len(devices)})
+	})
+
+	remote.DELETE("/devices/:id", func(c *gin.Context) {
+		err := revokeRemoteDevice(c.Param("id"))
+		if errors.Is(err, errDeviceNotFound) {
+			writeAPIError(c, http.StatusNotFound, apiErrNotFound, "device not found", nil)
+			return
+		}
+		if err != nil {
+			writeAPIError(c, http.StatusInternalServerError, apiErrInternal, "failed to revoke device", err)
+			return
+		}
+		log.Printf("[Remote] revoked device %s", c.Param("id"))
+		c.Status(http.StatusNoContent)
+	})
+
+	remote.GET("/games", func(c *gin.Context) {
+		games, err := remoteGames(c.Query("name"))
+		if err != nil {
+			writeAPIError(c, http.StatusInternalServerError, apiErrInternal, "failed to list games", err)
+			return
+		}
+		c.JSON(http.StatusOK, listResponse[gameSummary]{Items: games, Total: len(games)})
+	})
+
+	remote.GET("/sessions", func(c *gin.Context) {
+		sessions, err := remoteSessions()
+		if err != nil {
+			writeAPIError(c, http.StatusInternalServerError, apiErrInternal, "failed to list sessions", err)
+			return
+		}
+		c.JSON(http.StatusOK, listResponse[remoteSession]{Items: sessions, Total: len(sessions)})
+	})
+
+	remote.POST("/sessions", func(c *gin.Context) {
+		var req remoteLaunchRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			writeAPIError(c, http.StatusBadRequest, apiErrInvalidRequest, "invalid launch request", err)
+			return
+		}
+		if !remoteGameExists(c, req.UID) {
+			return
+		}
+		remoteLaunch(c, req.UID)
+	})
+
+	remote.POST("/sessions/:uid/stop", remoteStop(false))
+	remote.POST("/sessions/:uid/kill", remoteStop(true))
+
+	remote.POST("/screenshots", func(c *gin.Context) {
+		var req remoteScreenshotRequest
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			writeAPIError(c, http.StatusBadRequest, apiErrInvalidRequest, "invalid screenshot request", err)
+			return
+		}
+		uid := req.UID
+		if uid == "" {
+			active, ok := getActiveSession()
+			if !ok {
+				writeAPIError(c, http.StatusConflict, apiErrConflict, "no game is running", nil)
+				return
+			}
+			uid = active.UID
+		} else if !remoteGameExists(c, uid) {
+			return
+		}
+		path, err := takeScreenshot(uid)
+		if err != nil {
+			writeAPIError(c, http.StatusInternalServerError, apiErrInternal, "failed to take screenshot", err)
+			return
+		}
+		c.JSON(http.StatusCreated, remoteScreenshot{UID: uid, Path: path, Image: imageURLs(path)})
+	})
+
+	remote.GET("/events", remoteEvents)
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"archive/zip"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Finished bundles wait here until they are downloaded, stale ones are pruned on the next export
+const (
+	exportsDir      = "exports"
+	exportRetention = 24 * time.Hour
+)
+
+// What to export, set one of ScreenshotIDs, UID or AlbumID. Format is empty to keep the best stored file,
+// a passphrase encrypts the bundle.
+type screenshotExportRequest struct {
+	ScreenshotIDs []int64 `json:"screenshotIds"`
+	UID           string  `json:"uid"`
+	AlbumID       int64   `json:"albumId"`
+	Format        string  `json:"format"`
+	Quality       int     `json:"quality"`
+	Passphrase    string  `json:"passphrase"`
+}
+
+type screenshotManifest struct {
+	ExportedAt  string                            `json:"exportedAt"`
+	Album       string                            `json:"album,omitempty"`
+	Games       map[string]screenshotManifestGame `json:"games"`
+	Screenshots []screenshotManifestEntry         `json:"screenshots"`
+}
+
+type screenshotManifestGame struct {
+	Title       string `json:"title"`
+	ReleaseDate string `json:"releaseDate"`
+	Platform    string `json:"platform"`
+}
+
+type screenshotManifestEntry struct {
+	File       string   `json:"file"`
+	ID         int64    `json:"id"`
+	UID        string   `json:"uid"`
+	Source     string   `json:"source"`
+	CapturedAt string   `json:"capturedAt"`
+	Width      int      `json:"width"`
+	Height     int      `json:"height"`
+	Caption    string   `json:"caption"`
+	Favorite   bool     `json:"favorite"`
+	Tags       []string `json:"tags"`
+}
+
+var unsafeFilenameChars = regexp.MustCompile(`[<>:"/\\|?*\x00-\x1f]+`)
+
+func sanitizeFilename(name string) string {
+	name = strings.TrimSpace(unsafeFilenameChars.ReplaceAllString(name, ""))
+	name = strings.TrimRight(name, ". ")
+	if name == "" {
+		return "Untitled"
+	}
+	return name
+}
+
+func collectExportScreenshots(req screenshotExportRequest) ([]screenshotRecord, string, error) {
+	var records []screenshotRecord
+	albumName := ""
+	switch {
+	case len(req.ScreenshotIDs) > 0:
+		for _, id := range req.ScreenshotIDs {
+			shot, err := getScreenshot(id)
+			if err != nil {
+				return nil, "", err
+			}
+			shot.Tags = []string{}
+			shot.Albums = []int64{}
+			records = append(records, shot)
+		}
+		err := fillScreenshotMemberships(records)
+		if err != nil {
+			return nil, "", err
+		}
+	case req.UID != "" || req.AlbumID != 0:
+		if req.AlbumID != 0 {
+			err := readDB.QueryRow("SELECT Name FROM Albums WHERE ID = ?", req.AlbumID).Scan(&albumName)
+			if err == sql.ErrNoRows {
+				return nil, "", fmt.Errorf("album %d not found", req.AlbumID)
+			}
+			if err != nil {
+				return nil, "", fmt.Errorf("query error Albums: %w", err)
+			}
+		}
+		filter := screenshotFilter{UID: req.UID, AlbumID: req.AlbumID}
+		for page := 1; ; page++ {
+			batch, total, err := listScreenshots(filter, page, screenshotMaxPageSize)
+			if err != nil {
+				return nil, "", err
+			}
+			records = append(records, batch...)
+			if len(batch) == 0 || len(records) >= total {
+				break
+			}
+		}
+	default:
+		return nil, "", fmt.Errorf("select screenshots, a game or an album to export")
+	}
+	if len(records) == 0 {
+		return nil, "", fmt.Errorf("nothing to export")
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].CapturedAt < records[j].CapturedAt
+	})
+	return records, albumName, nil
+}
+
+func getManifestGame(uid string) (screenshotManifestGame, error) {
+	var game screenshotManifestGame
+	err := readDB.QueryRow(`SELECT
+			CASE WHEN gp.UseCustomTitle = 1 THEN gp.CustomTitle ELSE gmd.Name END,
+			CASE WHEN gp.UseCustomReleaseDate = 1 THEN gp.CustomReleaseDate ELSE gmd.ReleaseDate END,
+			gmd.OwnedPlatform
+		FROM GameMetaData gmd LEFT JOIN GamePreferences gp ON gmd.UID = gp.UID
+		WHERE gmd.UID = ?`, uid).Scan(&game.Title, &game.ReleaseDate, &game.Platform)
+	if err == sql.ErrNoRows {
+		return screenshotManifestGame{Title: uid}, nil
+	}
+	if err != nil {
+		return game, fmt.Errorf("query error GameMetaData: %w", err)
+	}
+	return game, nil
+}
+
+// Kept original first, then the master, then the preview. Only used for raw copies, a
+// re-encode decodes screenshotDecodeSource instead.
+func bestScreenshotFile(shot screenshotRecord) string {
+	for _, candidate := range []string{shot.OriginalPath, shot.MasterPath} {
+		if candidate == "" {
+			continue
+		}
+		if _, err := os.Stat(filepath.FromSlash(candidate)); err == nil {
+			return candidate
+		}
+	}
+	return shot.Path
+}
+
+func removeStaleExports() {
+	entries, err := os.ReadDir(exportsDir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err == nil && time.Since(info.ModTime()) > exportRetention {
+			os.Remove(filepath.Join(exportsDir, entry.Name()))
+		}
+	}
+}
+
+// Extension of an export, encrypted bundles get .zip.enc
+func exportExt(passphrase string) string {
+	if passphrase != "" {
+		return ".zip" + encryptedExt
+	}
+	return ".zip"
+}
+
+// Resolves a bundle name from the download route, anything outside exports/ is rejected
+func exportFilePath(name string) (string, error) {
+	if name != filepath.Base(name) || strings.HasPrefix(name, ".") ||
+		!(strings.HasSuffix(name, ".zip") || strings.HasSuffix(name, ".zip"+encryptedExt)) {
+		return "", fmt.Errorf("invalid export name")
+	}
+	return filepath.Join(exportsDir, name), nil
+}
+
+// Where a finished export is downloaded from
+func exportURL(name string) string {
+	return "/exports/" + url.PathEscape(name)
+}
+
+// Names of bundles still being written, so two exports started in the same second don't collide
+var pendingExports = struct {
+	sync.Mutex
+	names map[string]bool
+}{names: make(map[string]bool)}
+
+func reserveExportName(base string, ext string) string {
+	pendingExports.Lock()
+	defer pendingExports.Unlock()
+	name := base + ext
+	for i := 2; ; i++ {
+		_, err := os.Stat(filepath.Join(exportsDir, name))
+		if !pendingExports.names[name] && os.IsNotExist(err) {
+			break
+		}
+		name = fmt.Sprintf("%s (%d)%s", base, i, ext)
+	}
+	pendingExports.names[name] = true
+	return name
+}
+
+func releaseExportName(name string) {
+	pendingExports.Lock()
+	defer pendingExports.Unlock()
+	delete(pendingExports.names, name)
+}
+
+// A validated export, prepared up front so bad selections are reported before the bundle is built
+type screenshotExport struct {
+	Name     string
+	request  screenshotExportRequest
+	records  []screenshotRecord
+	manifest screenshotManifest
+}
+
+func prepareScreenshotExport(req screenshotExportRequest) (*screenshotExport, error) {
+	switch req.Format {
+	case "", screenshotFormatPNG, screenshotFormatJPEG:
+	default:
+		return nil, fmt.Errorf("unsupported export format %s", req.Format)
+	}
+	if req.Quality < 1 || req.Quality > 100 {
+		req.Quality = screenshotDefaultQuality
+	}
+	if req.Passphrase != "" {
+		if err := checkPassphrase(req.Passphrase); err != nil {
+			return nil, err
+		}
+	}
+
+	records, albumName, err := collectExportScreenshots(req)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := screenshotManifest{
+		ExportedAt:  time.Now().Format(time.RFC3339),
+		Album:       albumName,
+		Games:       make(map[string]screenshotManifestGame),
+		Screenshots: []screenshotManifestEntry{},
+	}
+	for _, shot := range records {
+		if _, ok := manifest.Games[shot.UID]; ok {
+			continue
+		}
+		game, err := getManifestGame(shot.UID)
+		if err != nil {
+			return nil, err
+		}
+		manifest.Games[shot.UID] = game
+	}
+
+	bundleTitle := albumName
+	if bundleTitle == "" && len(manifest.Games) == 1 {
+		bundleTitle = manifest.Games[records[0].UID].Title
+	}
+	if bundleTitle == "" {
+		bundleTitle = "Screenshots"
+	}
+	name := reserveExportName(fmt.Sprintf("%s - %s", sanitizeFilename(bundleTitle), time.Now().Format("2006-01-02 15-04-05")), exportExt(req.Passphrase))
+
+	return &screenshotExport{Name: name, request: req, records: records, manifest: manifest}, nil
+}
+
+// Builds the zip under exports/. Files are named "<title> - <date> - <index>" with the index counting
+// per game, bundles spanning several games get a folder per game. manifest.json describes every file.
+func (e *screenshotExport) write(progress func(done int, total int)) error {
+	defer releaseExportName(e.Name)
+	err := os.MkdirAll(exportsDir, 0755)
+	if err != nil {
+		return fmt.Errorf("error creating exports folder: %w", err)
+	}
+	removeStaleExports()
+
+	return writeFileAtomic(filepath.Join(exportsDir, e.Name), func(w io.Writer) error {
+		return writeMaybeEncrypted(w, e.request.Passphrase, func(w io.Writer) error {
+			return e.writeZip(w, progress)
+		})
+	})
+}
+
+func (e *screenshotExport) writeZip(w io.Writer, progress func(done int, total int)) error {
+	folders := len(e.manifest.Games) > 1
+	archive := zip.NewWriter(w)
+	indexes := make(map[string]int)
+	for i, shot := range e.records {
+		indexes[shot.UID]++
+		file, err := addScreenshotToZip(archive, shot, e.manifest.Games[shot.UID].Title, indexes[shot.UID], folders, e.request)
+		if err != nil {
+			return fmt.Errorf("error exporting %s: %w", shot.Path, err)
+		}
+		e.manifest.Screenshots = append(e.manifest.Screenshots, screenshotManifestEntry{
+			File:       file,
+			ID:         shot.ID,
+			UID:        shot.UID,
+			Source:     shot.Source,
+			CapturedAt: shot.CapturedAt,
+			Width:      shot.Width,
+			Height:     shot.Height,
+			Caption:    shot.Caption,
+			Favorite:   shot.Favorite,
+			Tags:       shot.Tags,
+		})
+		if progress != nil {
+			progress(i+1, len(e.records))
+		}
+	}
+
+	manifestWriter, err := archive.Create("manifest.json")
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(manifestWriter)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(e.manifest)
+	if err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+func addScreenshotToZip(archive *zip.Writer, shot screenshotRecord, title string, index int, folder bool, req screenshotExportRequest) (string, error) {
+	source := bestScreenshotFile(shot)
+	ext := path.Ext(source)
+	if req.Format != "" {
+		source = screenshotDecodeSource(shot.Path, shot.OriginalPath, shot.MasterPath)
+		ext = screenshotFormatExt(req.Format)
+	}
+
+	captured := shot.CapturedAt
+	if t, err := time.Parse(time.RFC3339, shot.CapturedAt); err == nil {
+		captured = t.Local().Format("2006-01-02 15-04-05")
+	}
+	name := fmt.Sprintf("%s - %s - %03d%s", sanitizeFilename(title), sanitizeFilename(captured), index, ext)
+	if folder {
+		name = sanitizeFilename(title) + "/" + name
+	}
+
+	header := &zip.FileHeader{Name: name, Method: zip.Store}
+	if t, err := time.Parse(time.RFC3339, shot.CapturedAt); err == nil {
+		header.Modified = t
+	}
+	w, err := archive.CreateHeader(header)
+	if err != nil {
+		return "", err
+	}
+
+	if req.Format == "" {
+		file, err := os.Open(filepath.FromSlash(source))
+		if err != nil {
+			return "", err
+		}
+		defer file.Close()
+		_, err = io.Copy(w, file)
+		return name, err
+	}
+	img, err := decodeImageFile(source)
+	if err != nil {
+		return "", err
+	}
+	return name, encodeScreenshot(w, img, req.Format, req.Quality)
+}
@@ -0,0 +1,565 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"image"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Where a screenshot came from
+const (
+	screenshotSourceUser  = "user"
+	screenshotSourceSteam = "steam"
+	screenshotSourceIGDB  = "igdb"
+)
+
+const (
+	screenshotDefaultPageSize = 50
+	screenshotMaxPageSize     = 200
+)
+
+type screenshotRecord struct {
+	ID         int64  `json:"id"`
+	UID        string `json:"uid"`
+	Path       string `json:"path"`
+	Source     string `json:"source"`
+	CapturedAt string `json:"capturedAt"`
+	Width      int    `json:"width"`
+	Height     int    `json:"height"`
+	Caption    string `json:"caption"`
+	Favorite   bool   `json:"favorite"`
+	// Full quality copies under originals/, empty when not kept
+	OriginalPath string            `json:"originalPath"`
+	MasterPath   string            `json:"masterPath"`
+	Tags         []string          `json:"tags"`
+	Albums       []int64           `json:"albums"`
+	URLs         map[string]string `json:"urls"`
+}
+
+type screenshotFilter struct {
+	UID      string
+	Source   string
+	Tag      string
+	AlbumID  int64
+	Favorite bool
+}
+
+type screenshotAlbum struct {
+	ID        int64  `json:"id"`
+	Name      string `json:"name"`
+	CreatedAt string `json:"createdAt"`
+	Count     int    `json:"count"`
+}
+
+// Guesses the source of a file that was written before the index existed
+func guessScreenshotSource(uid string, filename string) string {
+	if strings.HasPrefix(filename, "User-") {
+		return screenshotSourceUser
+	}
+	if strings.HasPrefix(filename, steamScreenshotPrefix) {
+		return screenshotSourceSteam
+	}
+	appid, err := getSteamAppID(uid)
+	if err == nil && appid != 0 {
+		return screenshotSourceSteam
+	}
+	return screenshotSourceIGDB
+}
+
+func screenshotDimensions(relPath string) (int, int, error) {
+	file, err := os.Open(filepath.FromSlash(relPath))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return 0, 0, fmt.Errorf("error reading dimensions of %s: %w", relPath, err)
+	}
+	return config.Width, config.Height, nil
+}
+
+// Adds or refreshes the index row for a screenshot file, user edits like captions are kept
+func registerScreenshot(uid string, relPath string, source string, capturedAt time.Time) error {
+	width, height, err := screenshotDimensions(relPath)
+	if err != nil {
+		return err
+	}
+	return txWrite(func(tx *sql.Tx) error {
+		return txRegisterScreenshot(tx, uid, relPath, source, capturedAt, width, height)
+	})
+}
+
+func txRegisterScreenshot(tx *sql.Tx, uid string, relPath string, source string, capturedAt time.Time, width int, height int) error {
+	_, err := tx.Exec(`INSERT INTO Screenshots (UID, Path, Source, CapturedAt, Width, Height) VALUES (?,?,?,?,?,?)
+		ON CONFLICT(Path) DO UPDATE SET UID=excluded.UID, Source=excluded.Source, CapturedAt=excluded.CapturedAt,
+		Width=excluded.Width, Height=excluded.Height`,
+		uid, relPath, source, capturedAt.Format(time.RFC3339), width, height)
+	if err != nil {
+		return fmt.Errorf("error inserting into Screenshots: %w", err)
+	}
+	return nil
+}
+
+func txDeleteScreenshotRows(tx *sql.Tx, where string, args ...any) error {
+	queries := []string{
+		"DELETE FROM ScreenshotTags WHERE ScreenshotID IN (SELECT ID FROM Screenshots WHERE " + where + ")",
+		"DELETE FROM AlbumScreenshots WHERE ScreenshotID IN (SELECT ID FROM Screenshots WHERE " + where + ")",
+		"DELETE FROM Screenshots WHERE " + where,
+	}
+	for _, query := range queries {
+		_, err := tx.Exec(query, args...)
+		if err != nil {
+			return fmt.Errorf("error deleting screenshots: %w", err)
+		}
+	}
+	return nil
+}
+
+func txDeleteScreenshots(tx *sql.Tx, uid string) error {
+	return txDeleteScreenshotRows(tx, "UID = ?", uid)
+}
+
+// Reconciles the index with the files under screenshots/, returns how many rows were added and removed
+func rescanScreenshots() (int, int, error) {
+	return reconcileScreenshots("")
+}
+
+// Limits the reconcile to one game when uid is set
+func reconcileScreenshots(uid string) (int, int, error) {
+	query := "SELECT Path, OriginalPath, MasterPath FROM Screenshots"
+	var args []any
+	if uid != "" {
+		query += " WHERE UID = ?"
+		args = append(args, uid)
+	}
+	rows, err := readDB.Query(query, args...)
+	if err != nil {
+		return 0, 0, fmt.Errorf("query error Screenshots: %w", err)
+	}
+	indexed := make(map[string][2]string)
+	for rows.Next() {
+		var path, originalPath, masterPath string
+		if err := rows.Scan(&path, &originalPath, &masterPath); err != nil {
+			rows.Close()
+			return 0, 0, fmt.Errorf("scan error Screenshots: %w", err)
+		}
+		indexed[path] = [2]string{originalPath, masterPath}
+	}
+	rows.Close()
+
+	root := "screenshots"
+	if uid != "" {
+		root = filepath.Join("screenshots", uid)
+	}
+	onDisk := make(map[string]bool)
+	added := 0
+	filepath.WalkDir(root, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(d.Name(), ".webp") {
+			return nil
+		}
+		relPath := filepath.ToSlash(p)
+		parts := strings.Split(relPath, "/")
+		if len(parts) != 3 {
+			return nil
+		}
+		onDisk[relPath] = true
+		if _, ok := indexed[relPath]; ok {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		err = registerScreenshot(parts[1], relPath, guessScreenshotSource(parts[1], parts[2]), info.ModTime())
+		if err != nil {
+			return nil
+		}
+		added++
+		return nil
+	})
+
+	var removedPaths []any
+	for path := range indexed {
+		if !onDisk[path] {
+			removedPaths = append(removedPaths, path)
+		}
+	}
+	if len(removedPaths) > 0 {
+		err = txWrite(func(tx *sql.Tx) error {
+			for _, path := range removedPaths {
+				err := txDeleteScreenshotRows(tx, "Path = ?", path)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return added, 0, err
+		}
+		// Deleting the preview deletes the screenshot, its full quality copies go with it
+		for _, path := range removedPaths {
+			copies := indexed[path.(string)]
+			removeScreenshotCopies(copies[0], copies[1])
+		}
+	}
+	return added, len(removedPaths), nil
+}
+
+func screenshotFilterClause(filter screenshotFilter) (string, []any) {
+	clauses := []string{"1=1"}
+	var args []any
+	if filter.UID != "" {
+		clauses = append(clauses, "s.UID = ?")
+		args = append(args, filter.UID)
+	}
+	if filter.Source != "" {
+		clauses = append(clauses, "s.Source = ?")
+		args = append(args, filter.Source)
+	}
+	if filter.Favorite {
+		clauses = append(clauses, "s.Favorite = 1")
+	}
+	if filter.Tag != "" {
+		clauses = append(clauses, "s.ID IN (SELECT ScreenshotID FROM ScreenshotTags WHERE Tag = ?)")
+		args = append(args, filter.Tag)
+	}
+	if filter.AlbumID != 0 {
+		clauses = append(clauses, "s.ID IN (SELECT ScreenshotID FROM AlbumScreenshots WHERE AlbumID = ?)")
+		args = append(args, filter.AlbumID)
+	}
+	return strings.Join(clauses, " AND "), args
+}
+
+// Newest first, page numbers start at 1
+func listScreenshots(filter screenshotFilter, page int, pageSize int) ([]screenshotRecord, int, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = screenshotDefaultPageSize
+	}
+	pageSize = min(pageSize, screenshotMaxPageSize)
+
+	where, args := screenshotFilterClause(filter)
+
+	var total int
+	err := readDB.QueryRow("SELECT COUNT(*) FROM Screenshots s WHERE "+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("count error Screenshots: %w", err)
+	}
+
+	rows, err := readDB.Query(`SELECT s.ID, s.UID, s.Path, s.Source, s.CapturedAt, s.Width, s.Height, s.Caption, s.Favorite, s.OriginalPath, s.MasterPath
+		FROM Screenshots s WHERE `+where+` ORDER BY s.CapturedAt DESC, s.ID DESC LIMIT ? OFFSET ?`,
+		append(args, pageSize, (page-1)*pageSize)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("query error Screenshots: %w", err)
+	}
+	defer rows.Close()
+
+	records := []screenshotRecord{}
+	for rows.Next() {
+		var r screenshotRecord
+		err := rows.Scan(&r.ID, &r.UID, &r.Path, &r.Source, &r.CapturedAt, &r.Width, &r.Height, &r.Caption, &r.Favorite, &r.OriginalPath, &r.MasterPath)
+		if err != nil {
+			return nil, 0, fmt.Errorf("scan error Screenshots: %w", err)
+		}
+		r.Tags = []string{}
+		r.Albums = []int64{}
+		r.URLs = imageURLs(r.Path)
+		records = append(records, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	err = fillScreenshotMemberships(records)
+	if err != nil {
+		return nil, 0, err
+	}
+	return records, total, nil
+}
+
+func fillScreenshotMemberships(records []screenshotRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	byID := make(map[int64]*screenshotRecord)
+	placeholders := make([]string, len(records))
+	ids := make([]any, len(records))
+	for i := range records {
+		byID[records[i].ID] = &records[i]
+		placeholders[i] = "?"
+		ids[i] = records[i].ID
+	}
+	in := strings.Join(placeholders, ",")
+
+	rows, err := readDB.Query("SELECT ScreenshotID, Tag FROM ScreenshotTags WHERE ScreenshotID IN ("+in+") ORDER BY Tag", ids...)
+	if err != nil {
+		return fmt.Errorf("query error ScreenshotTags: %w", err)
+	}
+	for rows.Next() {
+		var id int64
+		var tag string
+		if err := rows.Scan(&id, &tag); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan error ScreenshotTags: %w", err)
+		}
+		byID[id].Tags = append(byID[id].Tags, tag)
+	}
+	rows.Close()
+
+	rows, err = readDB.Query("SELECT ScreenshotID, AlbumID FROM AlbumScreenshots WHERE ScreenshotID IN ("+in+")", ids...)
+	if err != nil {
+		return fmt.Errorf("query error AlbumScreenshots: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, albumID int64
+		if err := rows.Scan(&id, &albumID); err != nil {
+			return fmt.Errorf("scan error AlbumScreenshots: %w", err)
+		}
+		byID[id].Albums = append(byID[id].Albums, albumID)
+	}
+	return nil
+}
+
+func getScreenshot(id int64) (screenshotRecord, error) {
+	var r screenshotRecord
+	err := readDB.QueryRow(`SELECT ID, UID, Path, Source, CapturedAt, Width, Height, Caption, Favorite, OriginalPath, MasterPath
+		FROM Screenshots WHERE ID = ?`, id).
+		Scan(&r.ID, &r.UID, &r.Path, &r.Source, &r.CapturedAt, &r.Width, &r.Height, &r.Caption, &r.Favorite, &r.OriginalPath, &r.MasterPath)
+	if err == sql.ErrNoRows {
+		return r, fmt.Errorf("screenshot %d not found", id)
+	}
+	if err != nil {
+		return r, fmt.Errorf("query error Screenshots: %w", err)
+	}
+	return r, nil
+}
+
+func updateScreenshot(id int64, caption *string, favorite *bool) error {
+	if _, err := getScreenshot(id); err != nil {
+		return err
+	}
+	return txWrite(func(tx *sql.Tx) error {
+		if caption != nil {
+			_, err := tx.Exec("UPDATE Screenshots SET Caption = ? WHERE ID = ?", *caption, id)
+			if err != nil {
+				return fmt.Errorf("error updating caption: %w", err)
+			}
+		}
+		if favorite != nil {
+			_, err := tx.Exec("UPDATE Screenshots SET Favorite = ? WHERE ID = ?", *favorite, id)
+			if err != nil {
+				return fmt.Errorf("error updating favorite: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// Replaces the tags of a screenshot
+func setScreenshotTags(id int64, tags []string) error {
+	if _, err := getScreenshot(id); err != nil {
+		return err
+	}
+	return txWrite(func(tx *sql.Tx) error {
+		_, err := tx.Exec("DELETE FROM ScreenshotTags WHERE ScreenshotID = ?", id)
+		if err != nil {
+			return fmt.Errorf("error deleting ScreenshotTags: %w", err)
+		}
+		var values [][]any
+		for _, tag := range tags {
+			tag = strings.TrimSpace(tag)
+			if tag != "" {
+				values = append(values, []any{id, tag})
+			}
+		}
+		if len(values) == 0 {
+			return nil
+		}
+		return txBatchUpdate(tx, "INSERT OR IGNORE INTO ScreenshotTags (ScreenshotID, Tag) VALUES (?,?)", values)
+	})
+}
+
+func getScreenshotTags() ([]string, error) {
+	rows, err := readDB.Query("SELECT DISTINCT Tag FROM ScreenshotTags ORDER BY Tag")
+	if err != nil {
+		return nil, fmt.Errorf("query error ScreenshotTags: %w", err)
+	}
+	defer rows.Close()
+	tags := []string{}
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, fmt.Errorf("scan error ScreenshotTags: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// Moves the file into the target game's folder, renaming it when the name is taken
+func moveScreenshot(id int64, targetUID string) (string, error) {
+	shot, err := getScreenshot(id)
+	if err != nil {
+		return "", err
+	}
+	if shot.UID == targetUID {
+		return shot.Path, nil
+	}
+	var exists bool
+	err = readDB.QueryRow("SELECT EXISTS(SELECT 1 FROM GameMetaData WHERE UID = ?)", targetUID).Scan(&exists)
+	if err != nil {
+		return "", fmt.Errorf("db query error: %w", err)
+	}
+	if !exists {
+		return "", fmt.Errorf("game %s not found", targetUID)
+	}
+
+	targetDir := filepath.Join("screenshots", targetUID)
+	err = os.MkdirAll(targetDir, 0755)
+	if err != nil {
+		return "", fmt.Errorf("error creating screenshot folder: %w", err)
+	}
+	filename := filepath.Base(shot.Path)
+	if _, err := os.Stat(filepath.Join(targetDir, filename)); err == nil {
+		nextIndex, err := getNextScreenshotIndex(targetUID)
+		if err != nil {
+			return "", err
+		}
+		filename = fmt.Sprintf("User-%d.webp", nextIndex)
+	}
+	newPath := filepath.ToSlash(filepath.Join(targetDir, filename))
+
+	err = os.Rename(filepath.FromSlash(shot.Path), filepath.FromSlash(newPath))
+	if err != nil {
+		return "", fmt.Errorf("error moving screenshot: %w", err)
+	}
+	for variant := range imageVariants {
+		os.Remove(imageVariantPath(shot.Path, variant))
+	}
+	originalPath := moveScreenshotCopy(shot.OriginalPath, shot.Path, newPath)
+	masterPath := shot.MasterPath
+	if masterPath == shot.OriginalPath {
+		masterPath = originalPath
+	} else {
+		masterPath = moveScreenshotCopy(shot.MasterPath, shot.Path, newPath)
+	}
+
+	err = txWrite(func(tx *sql.Tx) error {
+		_, err := tx.Exec("UPDATE Screenshots SET UID = ?, Path = ?, OriginalPath = ?, MasterPath = ? WHERE ID = ?",
+			targetUID, newPath, originalPath, masterPath, id)
+		if err != nil {
+			return fmt.Errorf("error updating Screenshots: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return newPath, nil
+}
+
+// Follows a preview to its new name, a copy that can't be moved is dropped from the record
+func moveScreenshotCopy(copyPath string, oldPreview string, newPreview string) string {
+	if copyPath == "" {
+		return ""
+	}
+	oldBase := strings.TrimSuffix(path.Base(oldPreview), path.Ext(oldPreview))
+	suffix := strings.TrimPrefix(path.Base(copyPath), oldBase)
+	newCopy := screenshotCopyPath(newPreview, "") + suffix
+	err := os.MkdirAll(filepath.Dir(filepath.FromSlash(newCopy)), 0755)
+	if err == nil {
+		err = os.Rename(filepath.FromSlash(copyPath), filepath.FromSlash(newCopy))
+	}
+	if err != nil {
+		log.Printf("[Screenshots] could not move %s: %v", copyPath, err)
+		return ""
+	}
+	return newCopy
+}
+
+func getAlbums() ([]screenshotAlbum, error) {
+	rows, err := readDB.Query(`SELECT a.ID, a.Name, a.CreatedAt, COUNT(s.ScreenshotID)
+		FROM Albums a LEFT JOIN AlbumScreenshots s ON a.ID = s.AlbumID
+		GROUP BY a.ID ORDER BY a.Name`)
+	if err != nil {
+		return nil, fmt.Errorf("query error Albums: %w", err)
+	}
+	defer rows.Close()
+	albums := []screenshotAlbum{}
+	for rows.Next() {
+		var album screenshotAlbum
+		if err := rows.Scan(&album.ID, &album.Name, &album.CreatedAt, &album.Count); err != nil {
+			return nil, fmt.Errorf("scan error Albums: %w", err)
+		}
+		albums = append(albums, album)
+	}
+	return albums, nil
+}
+
+func createAlbum(name string) (int64, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return 0, fmt.Errorf("album name is empty")
+	}
+	var id int64
+	err := txWrite(func(tx *sql.Tx) error {
+		result, err := tx.Exec("INSERT INTO Albums (Name, CreatedAt) VALUES (?,?)", name, time.Now().Format(time.RFC3339))
+		if err != nil {
+			return fmt.Errorf("error inserting into Albums: %w", err)
+		}
+		id, err = result.LastInsertId()
+		return err
+	})
+	return id, err
+}
+
+func deleteAlbum(id int64) error {
+	return txWrite(func(tx *sql.Tx) error {
+		_, err := tx.Exec("DELETE FROM AlbumScreenshots WHERE AlbumID = ?", id)
+		if err != nil {
+			return fmt.Errorf("error deleting AlbumScreenshots: %w", err)
+		}
+		_, err = tx.Exec("DELETE FROM Albums WHERE ID = ?", id)
+		if err != nil {
+			return fmt.Errorf("error deleting Albums: %w", err)
+		}
+		return nil
+	})
+}
+
+func setAlbumMembership(albumID int64, screenshotIDs []int64, add bool) error {
+	var exists bool
+	err := readDB.QueryRow("SELECT EXISTS(SELECT 1 FROM Albums WHERE ID = ?)", albumID).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("db query error: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("album %d not found", albumID)
+	}
+
+	query := "DELETE FROM AlbumScreenshots WHERE AlbumID = ? AND ScreenshotID = ?"
+	if add {
+		query = "INSERT OR IGNORE INTO AlbumScreenshots (AlbumID, ScreenshotID) SELECT ?, ID FROM Screenshots WHERE ID = ?"
+	}
+	var values [][]any
+	for _, id := range screenshotIDs {
+		values = append(values, []any{albumID, id})
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	return txWrite(func(tx *sql.Tx) error {
+		return txBatchUpdate(tx, query, values)
+	})
+}
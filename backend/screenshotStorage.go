@@ -0,0 +1,400 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/HugoSmits86/nativewebp"
+)
+
+// Settings keys for screenshot storage
+const (
+	screenshotFormatSetting       = "ScreenshotFormat"
+	screenshotQualitySetting      = "ScreenshotQuality"
+	screenshotKeepOriginalSetting = "ScreenshotKeepOriginal"
+)
+
+// Formats for the full quality master. The webp preview used by the gallery is always written
+// and is lossless, so "webp" means the preview is the master. nativewebp has no lossy encoder,
+// "webp-lossy" masters are encoded by cwebp from libwebp which must be on the PATH.
+const (
+	screenshotFormatWebp      = "webp"
+	screenshotFormatWebpLossy = "webp-lossy"
+	screenshotFormatPNG       = "png"
+	screenshotFormatJPEG      = "jpeg"
+)
+
+var screenshotFormats = []string{screenshotFormatWebp, screenshotFormatWebpLossy, screenshotFormatPNG, screenshotFormatJPEG}
+
+// Masters and kept originals live in screenshots/<uid>/originals/ under the preview's base name
+const screenshotOriginalsDir = "originals"
+
+const screenshotDefaultQuality = 92
+
+type screenshotStorage struct {
+	Format       string `json:"format"`
+	Quality      int    `json:"quality"`
+	KeepOriginal bool   `json:"keepOriginal"`
+}
+
+func getScreenshotStorage() (screenshotStorage, error) {
+	storage := screenshotStorage{Format: screenshotFormatWebp, Quality: screenshotDefaultQuality}
+	format, err := getSetting(screenshotFormatSetting)
+	if err != nil {
+		return storage, err
+	}
+	if format != "" {
+		storage.Format = format
+	}
+	quality, err := getSetting(screenshotQualitySetting)
+	if err != nil {
+		return storage, err
+	}
+	if q, err := strconv.Atoi(quality); err == nil {
+		storage.Quality = q
+	}
+	keep, err := getSetting(screenshotKeepOriginalSetting)
+	if err != nil {
+		return storage, err
+	}
+	storage.KeepOriginal = keep == "true"
+	return storage, nil
+}
+
+func setScreenshotStorage(storage screenshotStorage) error {
+	valid := false
+	for _, format := range screenshotFormats {
+		if storage.Format == format {
+			valid = true
+		}
+	}
+	if !valid {
+		return fmt.Errorf("unsupported screenshot format %s, use one of %s", storage.Format, strings.Join(screenshotFormats, ", "))
+	}
+	if storage.Quality < 1 || storage.Quality > 100 {
+		return fmt.Errorf("quality must be between 1 and 100")
+	}
+	if storage.Format == screenshotFormatWebpLossy {
+		if _, err := exec.LookPath(cwebpBinary); err != nil {
+			return fmt.Errorf("lossy webp needs cwebp from libwebp installed: %w", err)
+		}
+	}
+	err := setSetting(screenshotFormatSetting, storage.Format)
+	if err != nil {
+		return err
+	}
+	err = setSetting(screenshotQualitySetting, strconv.Itoa(storage.Quality))
+	if err != nil {
+		return err
+	}
+	return setSetting(screenshotKeepOriginalSetting, strconv.FormatBool(storage.KeepOriginal))
+}
+
+func screenshotCopyPath(previewPath string, ext string) string {
+	dir, file := path.Split(previewPath)
+	return dir + screenshotOriginalsDir + "/" + strings.TrimSuffix(file, path.Ext(file)) + ext
+}
+
+func screenshotFormatExt(format string) string {
+	switch format {
+	case screenshotFormatPNG:
+		return ".png"
+	case screenshotFormatJPEG:
+		return ".jpg"
+	}
+	return ".webp"
+}
+
+// Extension of the untouched source bytes
+func sniffImageExt(data []byte) string {
+	switch http.DetectContentType(data) {
+	case "image/png":
+		return ".png"
+	case "image/jpeg":
+		return ".jpg"
+	case "image/gif":
+		return ".gif"
+	case "image/webp":
+		return ".webp"
+	}
+	return ".bin"
+}
+
+// Writes through a temp file so readers never see a partial image
+func writeFileAtomic(dest string, write func(w io.Writer) error) error {
+	err := os.MkdirAll(filepath.Dir(dest), 0755)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".write-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	err = write(tmp)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	err = os.Chmod(tmp.Name(), 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dest)
+}
+
+func encodeScreenshot(w io.Writer, img image.Image, format string, quality int) error {
+	switch format {
+	case screenshotFormatPNG:
+		return png.Encode(w, img)
+	case screenshotFormatJPEG:
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+	case screenshotFormatWebpLossy:
+		return encodeLossyWebp(w, img, quality)
+	default:
+		return nativewebp.Encode(w, img, nil)
+	}
+}
+
+const cwebpBinary = "cwebp"
+
+// cwebp only reads and writes files, so the image goes through a PNG in a temp dir
+func encodeLossyWebp(w io.Writer, img image.Image, quality int) error {
+	cwebp, err := exec.LookPath(cwebpBinary)
+	if err != nil {
+		return fmt.Errorf("lossy webp needs cwebp from libwebp installed: %w", err)
+	}
+	dir, err := os.MkdirTemp("", "quicksave-cwebp-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "in.png")
+	output := filepath.Join(dir, "out.webp")
+	file, err := os.Create(input)
+	if err != nil {
+		return err
+	}
+	err = png.Encode(file, img)
+	file.Close()
+	if err != nil {
+		return err
+	}
+
+	out, err := exec.Command(cwebp, "-quiet", "-q", strconv.Itoa(quality), input, "-o", output).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("cwebp failed: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	encoded, err := os.Open(output)
+	if err != nil {
+		return err
+	}
+	defer encoded.Close()
+	_, err = io.Copy(w, encoded)
+	return err
+}
+
+// Writes the master in the configured format and, when enabled, the untouched source next to the preview.
+// original is nil for live captures, their original is stored as PNG.
+func storeScreenshotCopies(previewPath string, img image.Image, original []byte) error {
+	storage, err := getScreenshotStorage()
+	if err != nil {
+		return err
+	}
+
+	originalPath := ""
+	if storage.KeepOriginal {
+		if original != nil {
+			originalPath = screenshotCopyPath(previewPath, sniffImageExt(original))
+			err = writeFileAtomic(filepath.FromSlash(originalPath), func(w io.Writer) error {
+				_, err := w.Write(original)
+				return err
+			})
+		} else {
+			originalPath = screenshotCopyPath(previewPath, ".png")
+			err = writeFileAtomic(filepath.FromSlash(originalPath), func(w io.Writer) error {
+				return png.Encode(w, img)
+			})
+		}
+		if err != nil {
+			return fmt.Errorf("error keeping original screenshot: %w", err)
+		}
+	}
+
+	masterPath, err := writeScreenshotMaster(previewPath, img, storage, originalPath)
+	if err != nil {
+		return err
+	}
+
+	// A preview written over an older one leaves that one's copies behind
+	var oldOriginal, oldMaster string
+	err = readDB.QueryRow("SELECT OriginalPath, MasterPath FROM Screenshots WHERE Path = ?", previewPath).Scan(&oldOriginal, &oldMaster)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("query error Screenshots: %w", err)
+	}
+	for _, old := range []string{oldOriginal, oldMaster} {
+		if old != "" && old != originalPath && old != masterPath {
+			os.Remove(filepath.FromSlash(old))
+		}
+	}
+	return setScreenshotCopyPaths(previewPath, originalPath, masterPath)
+}
+
+// Returns "" when the preview itself is the master
+func writeScreenshotMaster(previewPath string, img image.Image, storage screenshotStorage, originalPath string) (string, error) {
+	if storage.Format == screenshotFormatWebp {
+		return "", nil
+	}
+	masterPath := screenshotCopyPath(previewPath, screenshotFormatExt(storage.Format))
+	// A kept PNG original already is a lossless master
+	if masterPath == originalPath && storage.Format == screenshotFormatPNG {
+		return masterPath, nil
+	}
+	if masterPath == originalPath {
+		masterPath = screenshotCopyPath(previewPath, ".master"+screenshotFormatExt(storage.Format))
+	}
+	err := writeFileAtomic(filepath.FromSlash(masterPath), func(w io.Writer) error {
+		return encodeScreenshot(w, img, storage.Format, storage.Quality)
+	})
+	if err != nil {
+		return "", fmt.Errorf("error writing %s screenshot: %w", storage.Format, err)
+	}
+	return masterPath, nil
+}
+
+func setScreenshotCopyPaths(previewPath string, originalPath string, masterPath string) error {
+	return txWrite(func(tx *sql.Tx) error {
+		_, err := tx.Exec("UPDATE Screenshots SET OriginalPath = ?, MasterPath = ? WHERE Path = ?", originalPath, masterPath, previewPath)
+		if err != nil {
+			return fmt.Errorf("error updating Screenshots: %w", err)
+		}
+		return nil
+	})
+}
+
+func removeScreenshotCopies(originalPath string, masterPath string) {
+	for _, p := range []string{originalPath, masterPath} {
+		if p != "" {
+			os.Remove(filepath.FromSlash(p))
+		}
+	}
+}
+
+func decodeImageFile(relPath string) (image.Image, error) {
+	file, err := os.Open(filepath.FromSlash(relPath))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding %s: %w", relPath, err)
+	}
+	return img, nil
+}
+
+// Rewrites every master in the current format from the best source available, see
+// screenshotDecodeSource. An empty uid re-encodes the whole library.
+func reencodeScreenshots(uid string, progress func(done int, total int)) (int, []string, error) {
+	storage, err := getScreenshotStorage()
+	if err != nil {
+		return 0, nil, err
+	}
+	_, _, err = reconcileScreenshots(uid)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	query := "SELECT Path, OriginalPath, MasterPath FROM Screenshots"
+	var args []any
+	if uid != "" {
+		query += " WHERE UID = ?"
+		args = append(args, uid)
+	}
+	rows, err := readDB.Query(query, args...)
+	if err != nil {
+		return 0, nil, fmt.Errorf("query error Screenshots: %w", err)
+	}
+	type shot struct{ path, originalPath, masterPath string }
+	var shots []shot
+	for rows.Next() {
+		var s shot
+		if err := rows.Scan(&s.path, &s.originalPath, &s.masterPath); err != nil {
+			rows.Close()
+			return 0, nil, fmt.Errorf("scan error Screenshots: %w", err)
+		}
+		shots = append(shots, s)
+	}
+	rows.Close()
+
+	var failed []string
+	for i, s := range shots {
+		err := reencodeScreenshot(s.path, s.originalPath, s.masterPath, storage)
+		if err != nil {
+			log.Printf("[Reencode] ERROR %s: %v", s.path, err)
+			failed = append(failed, s.path)
+		}
+		if progress != nil {
+			progress(i+1, len(shots))
+		}
+	}
+	return len(shots), failed, nil
+}
+
+func reencodeScreenshot(previewPath string, originalPath string, masterPath string, storage screenshotStorage) error {
+	newMaster, err := reencodeScreenshotMaster(previewPath, originalPath, masterPath, storage)
+	if err != nil {
+		return err
+	}
+	return setScreenshotCopyPaths(previewPath, originalPath, newMaster)
+}
+
+// Writes the new master and drops the old one, leaving the Screenshots row to the caller
+func reencodeScreenshotMaster(previewPath string, originalPath string, masterPath string, storage screenshotStorage) (string, error) {
+	img, err := decodeImageFile(screenshotDecodeSource(previewPath, originalPath, masterPath))
+	if err != nil {
+		return "", err
+	}
+
+	newMaster, err := writeScreenshotMaster(previewPath, img, storage, originalPath)
+	if err != nil {
+		return "", err
+	}
+	if masterPath != "" && masterPath != newMaster && masterPath != originalPath {
+		os.Remove(filepath.FromSlash(masterPath))
+	}
+	return newMaster, nil
+}
+
+// Picks the file to decode when a screenshot is re-encoded: the kept original, then the master
+// only when it is a PNG, then the preview, which is always lossless webp. Decoding a JPEG or lossy
+// webp master would add a second round of compression loss.
+func screenshotDecodeSource(previewPath string, originalPath string, masterPath string) string {
+	if originalPath != "" {
+		if _, err := os.Stat(filepath.FromSlash(originalPath)); err == nil {
+			return originalPath
+		}
+	}
+	if strings.EqualFold(path.Ext(masterPath), ".png") {
+		if _, err := os.Stat(filepath.FromSlash(masterPath)); err == nil {
+			return masterPath
+		}
+	}
+	return previewPath
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Screenshot paths are relative to the data dir, so the test runs from a temp one
+func chdirTemp(t *testing.T) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+// Fills a small image with one colour so the source a re-encode used can be told apart
+func writeSolidScreenshot(t *testing.T, relPath string, format string, c color.RGBA) {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(filepath.FromSlash(relPath)), 0755); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Create(filepath.FromSlash(relPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := encodeScreenshot(file, img, format, 95); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// JPEG drifts a little, so colours only need to be close
+func assertScreenshotColour(t *testing.T, relPath string, want color.RGBA) {
+	t.Helper()
+	img, err := decodeImageFile(relPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, g, b, _ := img.At(8, 8).RGBA()
+	got := []int{int(r >> 8), int(g >> 8), int(b >> 8)}
+	for i, w := range []int{int(want.R), int(want.G), int(want.B)} {
+		if got[i] < w-12 || got[i] > w+12 {
+			t.Fatalf("%s has colour %v, want about %v", relPath, got, want)
+		}
+	}
+}
+
+var (
+	previewColour  = color.RGBA{200, 30, 30, 255}
+	masterColour   = color.RGBA{30, 30, 200, 255}
+	originalColour = color.RGBA{30, 200, 30, 255}
+)
+
+func TestReencodeJPEGMasterToPNGUsesPreview(t *testing.T) {
+	chdirTemp(t)
+	preview := "screenshots/uid/shot.webp"
+	master := screenshotCopyPath(preview, ".jpg")
+	writeSolidScreenshot(t, preview, screenshotFormatWebp, previewColour)
+	writeSolidScreenshot(t, master, screenshotFormatJPEG, masterColour)
+
+	if got := screenshotDecodeSource(preview, "", master); got != preview {
+		t.Fatalf("decode source is %s, want the preview over a lossy master", got)
+	}
+	newMaster, err := reencodeScreenshotMaster(preview, "", master, screenshotStorage{Format: screenshotFormatPNG})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newMaster != screenshotCopyPath(preview, ".png") {
+		t.Fatalf("new master is %s", newMaster)
+	}
+	assertScreenshotColour(t, newMaster, previewColour)
+	if _, err := os.Stat(filepath.FromSlash(master)); !os.IsNotExist(err) {
+		t.Fatalf("old JPEG master should be removed, stat error %v", err)
+	}
+}
+
+func TestReencodePNGMasterIsUsedAsSource(t *testing.T) {
+	chdirTemp(t)
+	preview := "screenshots/uid/shot.webp"
+	master := screenshotCopyPath(preview, ".png")
+	writeSolidScreenshot(t, preview, screenshotFormatWebp, previewColour)
+	writeSolidScreenshot(t, master, screenshotFormatPNG, masterColour)
+
+	if got := screenshotDecodeSource(preview, "", master); got != master {
+		t.Fatalf("decode source is %s, want the lossless master", got)
+	}
+	newMaster, err := reencodeScreenshotMaster(preview, "", master, screenshotStorage{Format: screenshotFormatJPEG, Quality: 95})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertScreenshotColour(t, newMaster, masterColour)
+}
+
+func TestReencodeKeptOriginalComesFirst(t *testing.T) {
+	chdirTemp(t)
+	preview := "screenshots/uid/shot.webp"
+	original := screenshotCopyPath(preview, ".jpg")
+	master := screenshotCopyPath(preview, ".master.png")
+	writeSolidScreenshot(t, preview, screenshotFormatWebp, previewColour)
+	writeSolidScreenshot(t, original, screenshotFormatJPEG, originalColour)
+	writeSolidScreenshot(t, master, screenshotFormatPNG, masterColour)
+
+	if got := screenshotDecodeSource(preview, original, master); got != original {
+		t.Fatalf("decode source is %s, want the kept original", got)
+	}
+	newMaster, err := reencodeScreenshotMaster(preview, original, master, screenshotStorage{Format: screenshotFormatPNG})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertScreenshotColour(t, newMaster, originalColour)
+	if _, err := os.Stat(filepath.FromSlash(original)); err != nil {
+		t.Fatalf("kept original should stay: %v", err)
+	}
+}
@@ -1,124 +1,139 @@
 package main
 
 import (
+	"database/sql"
 	"fmt"
 	"image"
-	"io"
 	"log"
-	"net/http"
-	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/HugoSmits86/nativewebp"
-	"github.com/PuerkitoBio/goquery"
 	"github.com/vova616/screenshot"
 )
 
-// Function to extract the high-resolution image URL from the redirect page
-func getHighResImageFromRedirect(redirectURL string) (string, error) {
-	// Send a GET request to the redirect URL
-	resp, err := http.Get(redirectURL)
+// Settings keys for screenshot capture
+const (
+	screenshotMonitorSetting = "ScreenshotMonitor"
+	screenshotWindowSetting  = "ScreenshotCaptureWindow"
+)
+
+// Monitor -1 captures the whole desktop, Window crops to the game's window where supported
+type captureOptions struct {
+	UID     string `json:"uid"`
+	Monitor int    `json:"monitor"`
+	Window  bool   `json:"window"`
+}
+
+func getCaptureSettings() (captureOptions, error) {
+	options := captureOptions{Monitor: -1}
+	monitor, err := getSetting(screenshotMonitorSetting)
 	if err != nil {
-		return "", err
+		return options, err
+	}
+	if monitor != "" {
+		options.Monitor, err = strconv.Atoi(monitor)
+		if err != nil {
+			options.Monitor = -1
+		}
 	}
-	defer resp.Body.Close()
+	window, err := getSetting(screenshotWindowSetting)
+	if err != nil {
+		return options, err
+	}
+	options.Window = window == "true"
+	return options, nil
+}
 
-	// Parse the HTML response
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+func setCaptureSettings(options captureOptions) error {
+	err := setSetting(screenshotMonitorSetting, strconv.Itoa(options.Monitor))
 	if err != nil {
-		return "", err
+		return err
 	}
+	return setSetting(screenshotWindowSetting, strconv.FormatBool(options.Window))
+}
 
-	// Look for the 'imgurl' parameter in the script tags
-	var highResImageURL string
-	doc.Find("script").Each(func(index int, item *goquery.Selection) {
-		// Check if the script contains 'imgurl' key
-		scriptText := item.Text()
-		if strings.Contains(scriptText, "imgurl") {
-			// Extract the value of 'imgurl' from the script
-			start := strings.Index(scriptText, `"imgurl":"`)
-			if start != -1 {
-				start += len(`"imgurl":"`)
-				end := strings.Index(scriptText[start:], `"`)
-				if end != -1 {
-					highResImageURL = scriptText[start : start+end]
-				}
+// Picks the area to capture, falling back from the window to the monitor to the whole screen
+func captureRect(options captureOptions, pid int) (image.Rectangle, bool) {
+	if options.Window {
+		rect, err := gameWindowRect(pid)
+		if err == nil && !rect.Empty() {
+			if screen, err := screenshot.ScreenRect(); err == nil {
+				rect = rect.Intersect(screen)
+			}
+			if !rect.Empty() {
+				return rect, true
 			}
 		}
-	})
-
-	// Return the extracted high-resolution image URL
-	if highResImageURL != "" {
-		return highResImageURL, nil
+		log.Printf("window capture unavailable, capturing screen instead: %v", err)
+	}
+	if options.Monitor >= 0 {
+		rects, err := monitorRects()
+		if err == nil && options.Monitor < len(rects) {
+			return rects[options.Monitor], true
+		}
+		log.Printf("monitor %d unavailable, capturing screen instead", options.Monitor)
 	}
-	return "", fmt.Errorf("could not find high-res image URL")
+	return image.Rectangle{}, false
 }
 
-// Function to get image links for a given search query
-func findLinksForScreenshot(screenshotString string) {
-	encodedQuery := url.QueryEscape(screenshotString)
-	searchURL := "https://www.google.com/search?hl=en&tbm=isch&q=" + encodedQuery
-
-	// Send a GET request to Google Image search
-	resp, err := http.Get(searchURL)
-	if err != nil {
-		panic(err)
+// The UID names the screenshot folder, so it has to be a game in the library and can't leave
+// the screenshots folder
+func checkScreenshotUID(uid string) error {
+	if strings.ContainsAny(uid, `/\`) || strings.Contains(uid, "..") {
+		return fmt.Errorf("invalid game uid %q", uid)
 	}
-	defer resp.Body.Close()
-
-	// Print the HTML of the page to debug the structure
-	bodyBytes, err := io.ReadAll(resp.Body)
+	var count int
+	err := readDB.QueryRow("SELECT COUNT(*) FROM GameMetaData WHERE UID = ?", uid).Scan(&count)
 	if err != nil {
-		log.Fatalf("Error reading response body: %v", err)
+		return fmt.Errorf("error looking up game: %w", err)
 	}
-	body := string(bodyBytes)
-
-	// Debug: Print the full HTML of the response
-	fmt.Println("Full HTML of the Google Images Search page:")
-	fmt.Println(body)
-
-	// Parse the HTML response using goquery
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
-		panic(err)
+	if count == 0 {
+		return fmt.Errorf("game %s not found", uid)
 	}
+	return nil
+}
 
-	// Iterate through <a> tags to find redirect URLs
-	doc.Find("a").Each(func(index int, item *goquery.Selection) {
-		// Check if the <a> tag contains the href with "/imgres?q="
-		href, exists := item.Attr("href")
-		if exists && strings.Contains(href, "/imgres?q=") {
-			// Construct the full redirect URL by prepending the base URL
-			fullRedirectURL := "https://www.google.com" + href
-			// Print the redirect URL for debugging
-			fmt.Println("Found redirect URL:", fullRedirectURL)
-
-			// Fetch the high-res image from the redirect URL
-			highResImageURL, err := getHighResImageFromRedirect(fullRedirectURL)
-			if err != nil {
-				log.Println("Error fetching high-res image:", err)
-			} else {
-				// Print the high-res image URL
-				fmt.Println("High-Res Image URL:", highResImageURL)
-			}
+// Captures into the given game or the game of the active play session, returns the screenshot path
+func captureScreenshot(options captureOptions) (string, error) {
+	uid := options.UID
+	pid := 0
+	if session, ok := getActiveSession(); ok {
+		if uid == "" || uid == session.UID {
+			uid = session.UID
+			pid = session.PID
 		}
-	})
-}
+	}
+	if uid == "" {
+		return "", fmt.Errorf("no game is running")
+	}
+	err := checkScreenshotUID(uid)
+	if err != nil {
+		return "", err
+	}
 
-func takeScreenshot(uid string) error {
-	img, err := screenshot.CaptureScreen()
+	var img *image.RGBA
+	if rect, ok := captureRect(options, pid); ok {
+		img, err = screenshot.CaptureRect(rect)
+	} else {
+		img, err = screenshot.CaptureScreen()
+	}
 	if err != nil {
-		return fmt.Errorf("error taking screenshot: %w", err)
+		return "", fmt.Errorf("error taking screenshot: %w", err)
 	}
 	myImg := image.Image(img)
 
+	err = os.MkdirAll(filepath.Join("screenshots", uid), 0755)
+	if err != nil {
+		return "", fmt.Errorf("error creating screenshot folder: %w", err)
+	}
 	nextIndex, err := getNextScreenshotIndex(uid)
 	if err != nil {
-		return fmt.Errorf("error getting next screenshot index: %w", err)
+		return "", fmt.Errorf("error getting next screenshot index: %w", err)
 	}
 
 	fileName := fmt.Sprintf("User-%d.webp", nextIndex)
@@ -126,15 +141,41 @@ func takeScreenshot(uid string) error {
 
 	file, err := os.Create(filePath)
 	if err != nil {
-		return fmt.Errorf("error creating screenshot: %w", err)
+		return "", fmt.Errorf("error creating screenshot: %w", err)
 	}
 	defer file.Close()
 
 	err = nativewebp.Encode(file, myImg, nil)
 	if err != nil {
-		return fmt.Errorf("error creating screenshot file: %w", err)
+		return "", fmt.Errorf("error creating screenshot file: %w", err)
 	}
-	return nil
+	err = generateImageVariants(filepath.ToSlash(filePath), myImg)
+	if err != nil {
+		log.Printf("error generating screenshot variants: %v", err)
+	}
+	bounds := myImg.Bounds()
+	err = txWrite(func(tx *sql.Tx) error {
+		return txRegisterScreenshot(tx, uid, filepath.ToSlash(filePath), screenshotSourceUser, time.Now(), bounds.Dx(), bounds.Dy())
+	})
+	if err != nil {
+		return "", err
+	}
+	err = storeScreenshotCopies(filepath.ToSlash(filePath), myImg, nil)
+	if err != nil {
+		return "", err
+	}
+	publishEvent(eventScreenshotAdded, screenshotEvent{UID: uid, Path: filepath.ToSlash(filePath)})
+	return filepath.ToSlash(filePath), nil
+}
+
+// Uses the saved monitor and window preferences
+func takeScreenshot(uid string) (string, error) {
+	options, err := getCaptureSettings()
+	if err != nil {
+		return "", err
+	}
+	options.UID = uid
+	return captureScreenshot(options)
 }
 
 func getNextScreenshotIndex(uid string) (int, error) {
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Names of the stored secrets
+const (
+	secretSteamAPIKey       = "steamApiKey"
+	secretPlayStationNpsso  = "playStationNpsso"
+	secretSteamGridDBKey    = "steamGridDBApiKey"
+	secretIGDBClientID      = "igdbClientId"
+	secretIGDBClientSecret  = "igdbClientSecret"
+	secretBackupPassphrase  = "backupPassphrase"
+	secretWebPassword       = "webPasswordHash"
+	secretsFile             = "secrets.enc"
+	secretsKeyFile          = "secrets.key"
+	secretsBackendEnv       = "QUICKSAVE_SECRET_BACKEND"
+	secretServiceAttribute  = "quicksave"
+	secretsAdditionalData   = "quicksave-secrets"
+	secretServiceToolBinary = "secret-tool"
+)
+
+// A secretStore keeps credentials out of the database. Get returns "" for secrets that are not set,
+// setting "" removes the secret.
+type secretStore interface {
+	Name() string
+	Get(name string) (string, error)
+	Set(name string, value string) error
+}
+
+var secrets secretStore
+
+// Uses the desktop keyring through the Secret Service API when a session has one, the encrypted
+// file otherwise. QUICKSAVE_SECRET_BACKEND=file forces the file store.
+func initSecrets() {
+	if os.Getenv(secretsBackendEnv) != "file" && secretServiceAvailable() {
+		secrets = secretServiceStore{}
+	} else {
+		secrets = &fileSecretStore{path: secretsFile, keyPath: secretsKeyFile}
+	}
+	log.Printf("[Secrets] using %s store", secrets.Name())
+}
+
+func getSecret(name string) (string, error) {
+	value, err := secrets.Get(name)
+	if err != nil {
+		return "", fmt.Errorf("error reading secret %s: %w", name, err)
+	}
+	return value, nil
+}
+
+func setSecret(name string, value string) error {
+	err := secrets.Set(name, value)
+	if err != nil {
+		return fmt.Errorf("error storing secret %s: %w", name, err)
+	}
+	return nil
+}
+
+func secretConfigured(name string) (bool, error) {
+	value, err := getSecret(name)
+	return value != "", err
+}
+
+// Secret Service through libsecret's secret-tool, which talks to the keyring over D-Bus
+type secretServiceStore struct{}
+
+func secretServiceAvailable() bool {
+	if os.Getenv("DBUS_SESSION_BUS_ADDRESS") == "" {
+		return false
+	}
+	if _, err := exec.LookPath(secretServiceToolBinary); err != nil {
+		return false
+	}
+	// A lookup of a missing item exits 1 without output, a missing service prints an error
+	_, err := secretServiceStore{}.Get("probe")
+	return err == nil
+}
+
+func (secretServiceStore) Name() string {
+	return "secret service"
+}
+
+func (secretServiceStore) Get(name string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(secretServiceToolBinary, "lookup", "application", secretServiceAttribute, "name", name)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 && stderr.Len() == 0 {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s lookup failed: %w %s", secretServiceToolBinary, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+func (secretServiceStore) Set(name string, value string) error {
+	var cmd *exec.Cmd
+	if value == "" {
+		cmd = exec.Command(secretServiceToolBinary, "clear", "application", secretServiceAttribute, "name", name)
+	} else {
+		cmd = exec.Command(secretServiceToolBinary, "store", "--label", "Quicksave "+name, "application", secretServiceAttribute, "name", name)
+		cmd.Stdin = strings.NewReader(value)
+	}
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s failed: %w %s", secretServiceToolBinary, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// Secrets as JSON sealed with AES-GCM under a random master key in secrets.key. Both files are
+// private to the user and never go into backups or exports.
+type fileSecretStore struct {
+	mu      sync.Mutex
+	path    string
+	keyPath string
+}
+
+func (*fileSecretStore) Name() string {
+	return "encrypted file"
+}
+
+func (s *fileSecretStore) Get(name string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	values, err := s.load()
+	if err != nil {
+		return "", err
+	}
+	return values[name], nil
+}
+
+func (s *fileSecretStore) Set(name string, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	values, err := s.load()
+	if err != nil {
+		return err
+	}
+	if value == "" {
+		delete(values, name)
+	} else {
+		values[name] = value
+	}
+	return s.save(values)
+}
+
+// Creates the master key on first use
+func (s *fileSecretStore) cipher() (cipher.AEAD, error) {
+	key, err := os.ReadFile(s.keyPath)
+	if os.IsNotExist(err) {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		file, err := os.OpenFile(s.keyPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("error creating master key: %w", err)
+		}
+		_, err = file.Write(key)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error writing master key: %w", err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading master key: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("master key %s is damaged", s.keyPath)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (s *fileSecretStore) load() (map[string]string, error) {
+	values := make(map[string]string)
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return values, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	aead, err := s.cipher()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, fmt.Errorf("%s is damaged", s.path)
+	}
+	plain, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], []byte(secretsAdditionalData))
+	if err != nil {
+		return nil, fmt.Errorf("%s can't be decrypted with %s", s.path, s.keyPath)
+	}
+	err = json.Unmarshal(plain, &values)
+	if err != nil {
+		return nil, fmt.Errorf("%s is damaged: %w", s.path, err)
+	}
+	return values, nil
+}
+
+func (s *fileSecretStore) save(values map[string]string) error {
+	aead, err := s.cipher()
+	if err != nil {
+		return err
+	}
+	plain, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	err = writeFileAtomic(s.path, func(w io.Writer) error {
+		_, err := w.Write(aead.Seal(nonce, nonce, plain, []byte(secretsAdditionalData)))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return os.Chmod(s.path, 0600)
+}
+
+// Moves credentials that older versions kept in the DB into the store. Secrets already in the store
+// win, so restoring an old backup doesn't bring back old credentials.
+func migrateLegacySecrets(db *sql.DB) error {
+	legacy := make(map[string]string)
+	queries := map[string]string{
+		secretSteamAPIKey:      "SELECT SteamAPIKey FROM SteamCreds",
+		secretPlayStationNpsso: "SELECT Npsso FROM PlayStationNpsso",
+		secretSteamGridDBKey:   "SELECT Value FROM Settings WHERE Key = 'SteamGridDBAPIKey'",
+		secretBackupPassphrase: "SELECT Value FROM Settings WHERE Key = 'BackupPassphrase'",
+	}
+	for name, query := range queries {
+		var value string
+		err := db.QueryRow(query).Scan(&value)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("error reading legacy secret %s: %w", name, err)
+		}
+		legacy[name] = value
+	}
+	for name, value := range legacy {
+		if value == "" {
+			continue
+		}
+		configured, err := secretConfigured(name)
+		if err != nil {
+			return err
+		}
+		if configured {
+			continue
+		}
+		err = setSecret(name, value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Configured status of every credential, values never leave the backend. IGDB counts as configured
+// when .env provides it.
+func getCredentialStatus() (map[string]bool, error) {
+	id, secret := getIGDBCreds()
+	status := map[string]bool{"igdb": id != "" && secret != ""}
+	for key, name := range map[string]string{
+		"steam":            secretSteamAPIKey,
+		"playStation":      secretPlayStationNpsso,
+		"steamGridDB":      secretSteamGridDBKey,
+		"backupPassphrase": secretBackupPassphrase,
+	} {
+		configured, err := secretConfigured(name)
+		if err != nil {
+			return nil, err
+		}
+		status[key] = configured
+	}
+	return status, nil
+}
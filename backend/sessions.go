@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// A game launched through quicksave that has not exited yet
+type playSession struct {
+	UID       string    `json:"uid"`
+	StartedAt time.Time `json:"startedAt"`
+	PID       int       `json:"pid"`
+}
+
+// Payload of the session events
+type sessionEvent struct {
+	UID   string `json:"uid"`
+	PID   int    `json:"pid,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+var playSessions = struct {
+	sync.Mutex
+	active map[string]*playSession
+}{active: make(map[string]*playSession)}
+
+var (
+	errSessionNotRunning = errors.New("game is not running")
+	errSessionNoProcess  = errors.New("game process is not known")
+)
+
+func beginPlaySession(uid string) {
+	playSessions.Lock()
+	defer playSessions.Unlock()
+	playSessions.active[uid] = &playSession{UID: uid, StartedAt: time.Now()}
+	publishEvent(eventSessionStarted, sessionEvent{UID: uid})
+}
+
+// Launchers report the game process once they know it, 0 means unknown
+func setPlaySessionPID(uid string, pid int) {
+	playSessions.Lock()
+	defer playSessions.Unlock()
+	if session, ok := playSessions.active[uid]; ok {
+		session.PID = pid
+		publishEvent(eventSessionProcess, sessionEvent{UID: uid, PID: pid})
+	}
+}
+
+func endPlaySession(uid string) {
+	playSessions.Lock()
+	defer playSessions.Unlock()
+	delete(playSessions.active, uid)
+	publishEvent(eventSessionEnded, sessionEvent{UID: uid})
+}
+
+func getPlaySession(uid string) (playSession, bool) {
+	playSessions.Lock()
+	defer playSessions.Unlock()
+	session, ok := playSessions.active[uid]
+	if !ok {
+		return playSession{}, false
+	}
+	return *session, true
+}
+
+// Oldest first
+func getPlaySessions() []playSession {
+	playSessions.Lock()
+	defer playSessions.Unlock()
+	sessions := []playSession{}
+	for _, session := range playSessions.active {
+		sessions = append(sessions, *session)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].StartedAt.Before(sessions[j].StartedAt)
+	})
+	return sessions
+}
+
+// The most recently started session is the one in the foreground
+func getActiveSession() (playSession, bool) {
+	sessions := getPlaySessions()
+	if len(sessions) == 0 {
+		return playSession{}, false
+	}
+	return sessions[len(sessions)-1], true
+}
+
+// Asks the game to quit, or ends it right away when force is set. The launcher that started it
+// notices the exit and ends the session as if the game had quit on its own.
+func stopPlaySession(uid string, force bool) error {
+	session, ok := getPlaySession(uid)
+	if !ok {
+		return errSessionNotRunning
+	}
+	if session.PID == 0 {
+		return errSessionNoProcess
+	}
+	if runtime.GOOS == "windows" {
+		// Windows has no SIGTERM, taskkill without /F sends the windows a close message
+		args := []string{"/PID", strconv.Itoa(session.PID), "/T"}
+		if force {
+			args = append(args, "/F")
+		}
+		output, err := exec.Command("taskkill", args...).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("taskkill failed: %w: %s", err, output)
+		}
+		return nil
+	}
+	process, err := os.FindProcess(session.PID)
+	if err != nil {
+		return err
+	}
+	if force {
+		return process.Kill()
+	}
+	return process.Signal(syscall.SIGTERM)
+}
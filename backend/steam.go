@@ -1,25 +1,32 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"strconv"
 	"strings"
-	"sync"
 
 	"github.com/PuerkitoBio/goquery"
 )
 
+// The Steam ID stays in the DB, the API key goes to the secret store
 func updateSteamCreds(steamID string, steamAPIKey string) error {
-	err := txWrite(func(tx *sql.Tx) error {
+	err := setSecret(secretSteamAPIKey, steamAPIKey)
+	if err != nil {
+		return err
+	}
+	err = txWrite(func(tx *sql.Tx) error {
 		_, err := tx.Exec("DELETE FROM SteamCreds")
 		if err != nil {
 			return fmt.Errorf("error deleting old steam creds %w", err)
 		}
 
-		_, err = tx.Exec("INSERT INTO SteamCreds (SteamID, SteamAPIKey) VALUES (?, ?)", steamID, steamAPIKey)
+		_, err = tx.Exec("INSERT INTO SteamCreds (SteamID, SteamAPIKey) VALUES (?, '')", steamID)
 		if err != nil {
 			return fmt.Errorf("error inserting steam creds %w", err)
 		}
@@ -28,175 +35,189 @@ func updateSteamCreds(steamID string, steamAPIKey string) error {
 	return err
 }
 
-func steamImportUserGames(SteamID string, APIkey string) error {
+// An empty key reuses the stored one. Stores the credentials the import will use.
+func useSteamCreds(steamID string, apiKey string) (string, error) {
+	var err error
+	if apiKey == "" {
+		_, apiKey, err = getSteamCreds()
+	}
+	if err == nil && apiKey == "" {
+		err = fmt.Errorf("no steam api key configured")
+	}
+	if err == nil {
+		err = updateSteamCreds(steamID, apiKey)
+	}
+	return apiKey, err
+}
+
+// GET against the Steam API, cancelled with the import
+func steamGet(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return http.DefaultClient.Do(req)
+}
+
+// An owned or wishlisted game of a Steam import job
+type steamImportItem struct {
+	AppID    int     `json:"appId"`
+	Playtime float32 `json:"playtime"`
+	Wishlist bool    `json:"wishlist,omitempty"`
+}
+
+type steamImporter struct {
+	steamID string
+}
+
+// Owned games first, then the wishlist
+func (s steamImporter) plan(ctx context.Context) ([]jobItem, error) {
+	_, APIkey, err := getSteamCreds()
+	if err != nil {
+		return nil, err
+	}
+	if APIkey == "" {
+		return nil, fmt.Errorf("no steam api key configured")
+	}
 
 	var allSteamGamesStruct allSteamGamesStruct
 
-	getString := fmt.Sprintf(`https://api.steampowered.com/IPlayerService/GetOwnedGames/v1/?key=%s&steamid=%s&include_appinfo=true&include_played_free_games=true`, APIkey, SteamID)
-	resp, err := http.Get(getString)
+	getString := fmt.Sprintf(`https://api.steampowered.com/IPlayerService/GetOwnedGames/v1/?key=%s&steamid=%s&include_appinfo=true&include_played_free_games=true`, APIkey, s.steamID)
+	resp, err := steamGet(ctx, getString)
 	if err != nil {
-		return fmt.Errorf("failed to fetch Steam user games")
+		return nil, fmt.Errorf("failed to fetch Steam user games")
 	}
 	defer resp.Body.Close()
 
 	// IF BAD REQ (Wrong ID / API Key)
 	if resp.StatusCode != http.StatusOK {
 		if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnauthorized {
-			return fmt.Errorf("invalid Steam ID or API key (HTTP %d)", resp.StatusCode)
+			return nil, fmt.Errorf("invalid Steam ID or API key (HTTP %d)", resp.StatusCode)
 		}
-		return fmt.Errorf("unexpected HTTP status: %d", resp.StatusCode)
+		return nil, fmt.Errorf("unexpected HTTP status: %d", resp.StatusCode)
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("failed to read Steam API response: %w", err)
+		return nil, fmt.Errorf("failed to read Steam API response: %w", err)
 	}
 
 	if err := json.Unmarshal(body, &allSteamGamesStruct); err != nil {
-		return fmt.Errorf("failed to parse Steam API response: %w", err)
+		return nil, fmt.Errorf("failed to parse Steam API response: %w", err)
+	}
+
+	var items []jobItem
+	for _, game := range allSteamGamesStruct.Response.Games {
+		item, err := newJobItem(strconv.Itoa(game.Appid), game.Name, steamImportItem{AppID: game.Appid, Playtime: game.PlaytimeForever})
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
 	}
 
-	rows, err := readDB.Query("SELECT AppID FROM SteamAppIds")
+	// Insert Steam Wishlist Games
+	var steamWishlistStruct SteamWishlistStruct
+
+	getString = fmt.Sprintf(`https://api.steampowered.com/IWishlistService/GetWishlist/v1/?key=%s&steamid=%s`, APIkey, s.steamID)
+	resp, err = steamGet(ctx, getString)
 	if err != nil {
-		return fmt.Errorf("DB read Error - SteamAppIds: %w", err)
+		return nil, fmt.Errorf("error getting player wishlist")
 	}
-	defer rows.Close()
+	defer resp.Body.Close()
 
-	var AppIDsInDB []int
-	for rows.Next() {
-		var AppID int
-		if err := rows.Scan(&AppID); err != nil {
-			return fmt.Errorf("DB scan error - SteamAppIds row: %w", err)
-		}
-		AppIDsInDB = append(AppIDsInDB, AppID)
+	body, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse wishlist response: %w", err)
 	}
 
-	rows, err = readDB.Query("SELECT AppID FROM SteamAppIdsSkip")
+	err = json.Unmarshal(body, &steamWishlistStruct)
 	if err != nil {
-		return fmt.Errorf("DB read Error - SteamAppIdsSkip: %w", err)
+		return nil, fmt.Errorf("failed to unmarshal wishlist games: %w", err)
 	}
-	defer rows.Close()
 
-	var AppIDsSkip []int
-	for rows.Next() {
-		var AppID int
-		if err := rows.Scan(&AppID); err != nil {
-			return fmt.Errorf("DB scan error - SteamAppIds row: %w", err)
+	// The wishlist only has app ids, the name is known once the metadata is fetched
+	for _, wished := range steamWishlistStruct.Response.Items {
+		appID := strconv.Itoa(wished.Appid)
+		item, err := newJobItem("wishlist:"+appID, "app "+appID, steamImportItem{AppID: wished.Appid, Wishlist: true})
+		if err != nil {
+			return nil, err
 		}
-		AppIDsSkip = append(AppIDsSkip, AppID)
+		items = append(items, item)
 	}
+	return items, nil
+}
 
-	for i, game := range allSteamGamesStruct.Response.Games {
-		insert := true
-		AppID := game.Appid
+// Games already in the library get their playtime updated, skipped app ids are left out
+func (s steamImporter) importItem(ctx context.Context, item jobItem) (string, error) {
+	var game steamImportItem
+	if err := json.Unmarshal(item.Data, &game); err != nil {
+		return "", fmt.Errorf("invalid steam import item: %w", err)
+	}
 
-		for _, skippedID := range AppIDsSkip {
-			if AppID == skippedID {
-				insert = false
-				break
-			}
+	var skipped int
+	err := readDB.QueryRow("SELECT COUNT(*) FROM SteamAppIdsSkip WHERE AppID = ?", game.AppID).Scan(&skipped)
+	if err != nil {
+		return "", fmt.Errorf("DB read Error - SteamAppIdsSkip: %w", err)
+	}
+	if skipped > 0 {
+		return jobItemSkipped, nil
+	}
+
+	var UID string
+	err = readDB.QueryRow("SELECT UID FROM SteamAppIds WHERE AppID = ?", game.AppID).Scan(&UID)
+	if err == sql.ErrNoRows {
+		fmt.Println("Inserting ", item.Name)
+		isWishlist := 0
+		if game.Wishlist {
+			isWishlist = 1
 		}
-		if !insert {
-			continue
+		err = getAndInsertSteamGameMetaData(ctx, game.AppID, game.Playtime, isWishlist)
+		if err != nil {
+			return "", fmt.Errorf("error getting steam games metadata: %w", err)
 		}
-		insert = true
-
-		for j := range AppIDsInDB {
-			AppIDinDB := AppIDsInDB[j]
-			if AppIDinDB == AppID {
-				insert = false
-				break
-			}
+		// Apps without store metadata go to the skip list instead
+		err = readDB.QueryRow("SELECT COUNT(*) FROM SteamAppIdsSkip WHERE AppID = ?", game.AppID).Scan(&skipped)
+		if err != nil {
+			return "", fmt.Errorf("DB read Error - SteamAppIdsSkip: %w", err)
 		}
-		if !insert {
-			var UID string
-			err := readDB.QueryRow("SELECT UID FROM SteamAppIds WHERE AppID = ?", AppID).Scan(&UID)
-			if err != nil {
-				if err == sql.ErrNoRows {
-					return fmt.Errorf("no UID found for AppID %d", AppID)
-				}
-				return fmt.Errorf("DB read error - SteamAppIds: %w", err)
-			}
-
-			err = txWrite(func(tx *sql.Tx) error {
-				_, err = tx.Exec("UPDATE GameMetaData SET TimePlayed = ? WHERE UID = ?", allSteamGamesStruct.Response.Games[i].PlaytimeForever/60, UID)
-				if err != nil {
-					return fmt.Errorf("error updating time played: %w", err)
-				}
-				// This forces games to become non wishlist items incase found in library
-				_, err = tx.Exec("UPDATE GameMetaData SET isDLC = ? WHERE UID = ?", 0, UID)
-				if err != nil {
-					return fmt.Errorf("error switching wishlisted game to library: %w", err)
-				}
-				return nil
-			})
-			if err != nil {
-				return err
-			}
-
-		} else {
-			fmt.Println("Inserting ", game.Name)
-			Appid := game.Appid
-			err = getAndInsertSteamGameMetaData(Appid, game.PlaytimeForever, 0)
-			if err != nil {
-				return fmt.Errorf("error getting steam games metadata: %w", err)
-			}
+		if skipped > 0 {
+			return jobItemSkipped, nil
 		}
+		return jobItemAdded, nil
 	}
-
-	// Insert Steam Wishlist Games
-	var steamWishlistStruct SteamWishlistStruct
-
-	getString = fmt.Sprintf(`https://api.steampowered.com/IWishlistService/GetWishlist/v1/?key=%s&steamid=%s`, APIkey, SteamID)
-	resp, err = http.Get(getString)
 	if err != nil {
-		return fmt.Errorf("error getting player wishlist")
+		return "", fmt.Errorf("DB read error - SteamAppIds: %w", err)
 	}
-	defer resp.Body.Close()
-
-	body, err = io.ReadAll(resp.Body)
-	if err != nil {
-		return fmt.Errorf("failed to parse wishlist response: %w", err)
+	if game.Wishlist {
+		return jobItemSkipped, nil
 	}
 
-	err = json.Unmarshal(body, &steamWishlistStruct)
-	if err != nil {
-		return fmt.Errorf("failed to unmarshal wishlist games: %w", err)
-	}
-
-	for _, item := range steamWishlistStruct.Response.Items {
-		insert := true
-		AppID := item.Appid
-
-		for j := range AppIDsInDB {
-			AppIDinDB := AppIDsInDB[j]
-			if AppIDinDB == AppID {
-				insert = false
-				break
-			}
-		}
-		for _, skippedID := range AppIDsSkip {
-			if AppID == skippedID {
-				insert = false
-				break
-			}
+	err = txWrite(func(tx *sql.Tx) error {
+		_, err = tx.Exec("UPDATE GameMetaData SET TimePlayed = ? WHERE UID = ?", game.Playtime/60, UID)
+		if err != nil {
+			return fmt.Errorf("error updating time played: %w", err)
 		}
-		if insert {
-			fmt.Println("Inserting ", item.Appid)
-			err = getAndInsertSteamGameMetaData(AppID, 0, 1)
-			if err != nil {
-				return fmt.Errorf("error getting steam wishlist games metadata: %w", err)
-			}
+		// This forces games to become non wishlist items incase found in library
+		_, err = tx.Exec("UPDATE GameMetaData SET isDLC = ? WHERE UID = ?", 0, UID)
+		if err != nil {
+			return fmt.Errorf("error switching wishlisted game to library: %w", err)
 		}
+		return nil
+	})
+	if err != nil {
+		return "", err
 	}
-	checkSteamInstalledValidity()
-	return (nil)
+	return jobItemUpdated, nil
 }
 
-func getAndInsertSteamGameMetaData(Appid int, timePlayed float32, isWishlist int) error {
+func (s steamImporter) finish() error {
+	return checkSteamInstalledValidity()
+}
+
+func getAndInsertSteamGameMetaData(ctx context.Context, Appid int, timePlayed float32, isWishlist int) error {
 	var SteamGameMetadataStruct SteamGameMetadataStruct
 	getURL := fmt.Sprintf(`https://store.steampowered.com/api/appdetails?appids=%d&l=%s`, Appid, "english")
-	resp, err := http.Get(getURL)
+	resp, err := steamGet(ctx, getURL)
 	if err != nil {
 		return fmt.Errorf("failed to fetch Steam API metadata: %w", err)
 	}
@@ -227,7 +248,7 @@ func getAndInsertSteamGameMetaData(Appid int, timePlayed float32, isWishlist int
 	// For User Defined Tags
 	url := fmt.Sprintf(`https://store.steampowered.com/app/%d`, Appid)
 	client := &http.Client{}
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create HTTP request for Steam store page: %w", err)
 	}
@@ -262,7 +283,7 @@ func getAndInsertSteamGameMetaData(Appid int, timePlayed float32, isWishlist int
 		}
 	} else {
 		err := txWrite(func(tx *sql.Tx) error {
-			_, err := tx.Exec("INSERT INTO SteamAppIdsSkip (AppID) VALUES (?)", Appid)
+			_, err := tx.Exec("INSERT INTO SteamAppIdsSkip (AppID) VALUES (?) ON CONFLICT DO NOTHING", Appid)
 			if err != nil {
 				return fmt.Errorf("tx write error to steamSkipAppIDs: %w", err)
 			}
@@ -306,25 +327,23 @@ func InsertSteamGameMetaData(Appid int, timePlayed float32, SteamGameMetadataStr
 	location := fmt.Sprintf(`coverArt/%s/`, UID)
 	filename := fmt.Sprintf(UID + "-0.webp")
 	coverArtPath := fmt.Sprintf(`/%s/%s-0.webp`, UID, UID)
-	getImageFromURL(coverArtURL, location, filename)
-	//Download Screenshots outside transaction
+	//Download Cover Art and Screenshots outside transaction, failures are recorded for a later retry
+	downloads := []imageDownload{{UID: UID, AssetType: artworkCover, Source: coverArtURL, Location: location, Filename: filename}}
 	var screenshotPaths []string
-	var wg sync.WaitGroup
-	var mu sync.Mutex
 	for i, screenshot := range SteamGameMetadataStruct.Data.Screenshots {
-		wg.Add(1)
-		go func(i int, screenshot SteamScreenshotStruct) {
-			defer wg.Done()
-			location := fmt.Sprintf(`screenshots/%s/`, UID)
-			filename := fmt.Sprintf(`generic-%d.webp`, i)
-			screenshotPath := fmt.Sprintf(`/%s/%s-%d.webp`, UID, UID, i)
-			getImageFromURL(screenshot.PathFull, location, filename)
-			mu.Lock()
-			screenshotPaths = append(screenshotPaths, screenshotPath)
-			mu.Unlock()
-		}(i, screenshot)
-	}
-	wg.Wait()
+		downloads = append(downloads, imageDownload{
+			UID:       UID,
+			AssetType: "screenshot",
+			Origin:    screenshotSourceSteam,
+			Source:    screenshot.PathFull,
+			Location:  fmt.Sprintf(`screenshots/%s/`, UID),
+			Filename:  fmt.Sprintf(`generic-%d.webp`, i),
+		})
+		screenshotPaths = append(screenshotPaths, fmt.Sprintf(`/%s/%s-%d.webp`, UID, UID, i))
+	}
+	if failed := downloadImages(downloads); len(failed) > 0 {
+		log.Printf("%d images for %s could not be downloaded", len(failed), name)
+	}
 
 	err = txWrite(func(tx *sql.Tx) error {
 		//Insert to GameMetaData Table
@@ -380,13 +399,12 @@ func InsertSteamGameMetaData(Appid int, timePlayed float32, SteamGameMetadataStr
 		if err != nil {
 			return fmt.Errorf("failed to insert into SteamAppIds: %w", err)
 		}
-		return nil
+		return txRegisterArtwork(tx, UID, artworkCover, location+filename, coverArtURL, "steam")
 	})
 	if err != nil {
 		return fmt.Errorf("transaction failed: %w", err)
 	}
-	msg := fmt.Sprintf("Game added: %s", SteamGameMetadataStruct.Data.Name)
-	sendSSEMessage(msg)
+	publishEvent(eventGameAdded, gameEvent{UID: UID, Name: SteamGameMetadataStruct.Data.Name, Source: importSourceSteam})
 
 	return nil
 }
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Imported files are named Steam-<original name>.webp so they never clash with other screenshots
+const steamScreenshotPrefix = "Steam-"
+
+const steamScreenshotWatchSetting = "SteamScreenshotWatch"
+
+const steamScreenshotWatchInterval = 15 * time.Second
+
+type steamScreenshotSource struct {
+	Path  string
+	AppID int
+}
+
+type steamScreenshotImportResult struct {
+	Imported int `json:"imported"`
+	Skipped  int `json:"skipped"`
+	Unmapped int `json:"unmapped"`
+	Failed   int `json:"failed"`
+}
+
+var steamScreenshotImportMu sync.Mutex
+
+var steamScreenshotWatcher struct {
+	mu   sync.Mutex
+	stop chan struct{}
+}
+
+// Lists every screenshot Steam saved under userdata/<id>/760/remote/<appid>/screenshots
+func findSteamScreenshots(steamPath string) ([]steamScreenshotSource, error) {
+	userdata := filepath.Join(steamPath, "userdata")
+	users, err := os.ReadDir(userdata)
+	if err != nil {
+		return nil, fmt.Errorf("error reading steam userdata: %w", err)
+	}
+
+	var sources []steamScreenshotSource
+	for _, user := range users {
+		if !user.IsDir() {
+			continue
+		}
+		remote := filepath.Join(userdata, user.Name(), "760", "remote")
+		apps, err := os.ReadDir(remote)
+		if err != nil {
+			continue
+		}
+		for _, app := range apps {
+			appid, err := strconv.Atoi(app.Name())
+			if err != nil || !app.IsDir() {
+				continue
+			}
+			// The thumbnails subfolder is skipped since only files are listed
+			files, err := os.ReadDir(filepath.Join(remote, app.Name(), "screenshots"))
+			if err != nil {
+				continue
+			}
+			for _, file := range files {
+				ext := strings.ToLower(filepath.Ext(file.Name()))
+				if file.IsDir() || (ext != ".jpg" && ext != ".jpeg" && ext != ".png") {
+					continue
+				}
+				sources = append(sources, steamScreenshotSource{
+					Path:  filepath.Join(remote, app.Name(), "screenshots", file.Name()),
+					AppID: appid,
+				})
+			}
+		}
+	}
+	return sources, nil
+}
+
+// Steam names screenshots YYYYMMDDHHMMSS_N in local time, the file time is the fallback
+func steamScreenshotCaptureTime(path string) time.Time {
+	name := filepath.Base(path)
+	if len(name) >= 14 {
+		captured, err := time.ParseInLocation("20060102150405", name[:14], time.Local)
+		if err == nil {
+			return captured
+		}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Now()
+	}
+	return info.ModTime()
+}
+
+func getSteamAppIDMap() (map[int]string, error) {
+	rows, err := readDB.Query("SELECT UID, AppID FROM SteamAppIds")
+	if err != nil {
+		return nil, fmt.Errorf("SteamAppID query error: %w", err)
+	}
+	defer rows.Close()
+	appids := make(map[int]string)
+	for rows.Next() {
+		var uid string
+		var appid int
+		if err := rows.Scan(&uid, &appid); err != nil {
+			return nil, fmt.Errorf("SteamAppID scan error: %w", err)
+		}
+		appids[appid] = uid
+	}
+	return appids, nil
+}
+
+func getImportedSteamScreenshots() (map[string]bool, error) {
+	rows, err := readDB.Query("SELECT SourcePath FROM SteamScreenshotImports")
+	if err != nil {
+		return nil, fmt.Errorf("query error SteamScreenshotImports: %w", err)
+	}
+	defer rows.Close()
+	imported := make(map[string]bool)
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, fmt.Errorf("scan error SteamScreenshotImports: %w", err)
+		}
+		imported[path] = true
+	}
+	return imported, nil
+}
+
+// Converts new Steam screenshots to webp in the matching game's folder
+func importSteamScreenshots(progress func(done int, total int)) (steamScreenshotImportResult, error) {
+	steamScreenshotImportMu.Lock()
+	defer steamScreenshotImportMu.Unlock()
+
+	var result steamScreenshotImportResult
+	steamPath, err := getSteamPath()
+	if err != nil {
+		return result, err
+	}
+	if steamPath == "no steam" || steamPath == "" {
+		return result, fmt.Errorf("steam installation not found")
+	}
+
+	sources, err := findSteamScreenshots(steamPath)
+	if err != nil {
+		return result, err
+	}
+	appids, err := getSteamAppIDMap()
+	if err != nil {
+		return result, err
+	}
+	imported, err := getImportedSteamScreenshots()
+	if err != nil {
+		return result, err
+	}
+
+	for i, source := range sources {
+		uid, ok := appids[source.AppID]
+		switch {
+		case imported[source.Path]:
+			result.Skipped++
+		case !ok:
+			result.Unmapped++
+		default:
+			err := importSteamScreenshot(uid, source)
+			if err != nil {
+				log.Printf("[SteamScreenshots] ERROR %s: %v", source.Path, err)
+				result.Failed++
+			} else {
+				result.Imported++
+			}
+		}
+		if progress != nil {
+			progress(i+1, len(sources))
+		}
+	}
+	return result, nil
+}
+
+func importSteamScreenshot(uid string, source steamScreenshotSource) error {
+	location := fmt.Sprintf(`screenshots/%s/`, uid)
+	base := strings.TrimSuffix(filepath.Base(source.Path), filepath.Ext(source.Path))
+	filename := steamScreenshotPrefix + base + ".webp"
+	relPath := location + filename
+
+	imageDownloadSlots <- struct{}{}
+	data, img, err := fetchAndStoreImage(source.Path, location, filename)
+	<-imageDownloadSlots
+	if err != nil {
+		return err
+	}
+
+	capturedAt := steamScreenshotCaptureTime(source.Path)
+	err = os.Chtimes(filepath.FromSlash(relPath), capturedAt, capturedAt)
+	if err != nil {
+		log.Printf("[SteamScreenshots] could not keep capture time of %s: %v", relPath, err)
+	}
+	err = registerScreenshot(uid, relPath, screenshotSourceSteam, capturedAt)
+	if err != nil {
+		return err
+	}
+	err = storeScreenshotCopies(relPath, img, data)
+	if err != nil {
+		return err
+	}
+	return txWrite(func(tx *sql.Tx) error {
+		_, err := tx.Exec("INSERT OR REPLACE INTO SteamScreenshotImports (SourcePath, Path, ImportedAt) VALUES (?,?,?)",
+			source.Path, relPath, time.Now().Format(time.RFC3339))
+		if err != nil {
+			return fmt.Errorf("error inserting into SteamScreenshotImports: %w", err)
+		}
+		return nil
+	})
+}
+
+func isSteamScreenshotWatchEnabled() bool {
+	value, err := getSetting(steamScreenshotWatchSetting)
+	return err == nil && value == "true"
+}
+
+// Polls the Steam folders so screenshots taken with F12 show up without a manual import
+func setSteamScreenshotWatch(enabled bool) error {
+	err := setSetting(steamScreenshotWatchSetting, strconv.FormatBool(enabled))
+	if err != nil {
+		return err
+	}
+
+	steamScreenshotWatcher.mu.Lock()
+	defer steamScreenshotWatcher.mu.Unlock()
+	if steamScreenshotWatcher.stop != nil {
+		close(steamScreenshotWatcher.stop)
+		steamScreenshotWatcher.stop = nil
+	}
+	if !enabled {
+		return nil
+	}
+
+	stop := make(chan struct{})
+	steamScreenshotWatcher.stop = stop
+	go func() {
+		ticker := time.NewTicker(steamScreenshotWatchInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				result, err := importSteamScreenshots(nil)
+				if err != nil {
+					log.Printf("[SteamScreenshots] watch error: %v", err)
+					continue
+				}
+				if result.Imported > 0 {
+					publishEvent(eventImportDone, steamScreenshotImportEvent{Source: importSourceSteamScreenshots, steamScreenshotImportResult: result})
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func startSteamScreenshotWatch() {
+	if !isSteamScreenshotWatchEnabled() {
+		return
+	}
+	err := setSteamScreenshotWatch(true)
+	if err != nil {
+		log.Printf("[SteamScreenshots] could not start watcher: %v", err)
+	}
+}
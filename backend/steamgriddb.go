@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const steamGridDBDefaultBaseURL = "https://www.steamgriddb.com/api/v2"
+
+// Settings keys for the SteamGridDB provider
+const (
+	steamGridDBBaseURLSetting = "SteamGridDBBaseURL"
+)
+
+type steamGridDBProvider struct {
+	apiKey  string
+	baseURL string
+	client  *http.Client
+}
+
+type steamGridDBGame struct {
+	ID       int      `json:"id"`
+	Name     string   `json:"name"`
+	Types    []string `json:"types"`
+	Verified bool     `json:"verified"`
+}
+
+// Base URL can point to a local stub through the settings table or STEAMGRIDDB_BASE_URL
+func newSteamGridDBProvider() (*steamGridDBProvider, error) {
+	apiKey, err := getSecret(secretSteamGridDBKey)
+	if err != nil {
+		return nil, err
+	}
+	if apiKey == "" {
+		apiKey = os.Getenv("STEAMGRIDDB_API_KEY")
+	}
+	if apiKey == "" {
+		return nil, fmt.Errorf("steamgriddb api key not configured")
+	}
+
+	baseURL, err := getSetting(steamGridDBBaseURLSetting)
+	if err != nil {
+		return nil, err
+	}
+	if baseURL == "" {
+		baseURL = os.Getenv("STEAMGRIDDB_BASE_URL")
+	}
+	if baseURL == "" {
+		baseURL = steamGridDBDefaultBaseURL
+	}
+
+	return &steamGridDBProvider{
+		apiKey:  apiKey,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  &http.Client{Timeout: 15 * time.Second},
+	}, nil
+}
+
+func (p *steamGridDBProvider) Name() string {
+	return "steamgriddb"
+}
+
+func (p *steamGridDBProvider) get(path string, result interface{}) error {
+	req, err := http.NewRequest("GET", p.baseURL+path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		return fmt.Errorf("invalid steamgriddb api key")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code: %d - %s", resp.StatusCode, string(body))
+	}
+
+	var envelope struct {
+		Success bool            `json:"success"`
+		Data    json.RawMessage `json:"data"`
+		Errors  []string        `json:"errors"`
+	}
+	err = json.Unmarshal(body, &envelope)
+	if err != nil {
+		return fmt.Errorf("failed to parse steamgriddb response: %w", err)
+	}
+	if !envelope.Success {
+		return fmt.Errorf("steamgriddb request failed: %s", strings.Join(envelope.Errors, ", "))
+	}
+	err = json.Unmarshal(envelope.Data, result)
+	if err != nil {
+		return fmt.Errorf("failed to parse steamgriddb data: %w", err)
+	}
+	return nil
+}
+
+func (p *steamGridDBProvider) searchGames(term string) ([]steamGridDBGame, error) {
+	var games []steamGridDBGame
+	err := p.get("/search/autocomplete/"+url.PathEscape(term), &games)
+	if err != nil {
+		return nil, fmt.Errorf("steamgriddb search error: %w", err)
+	}
+	return games, nil
+}
+
+// Resolves through the Steam AppID when known, otherwise by searching the game's title
+func (p *steamGridDBProvider) gameIDForUID(uid string) (int, error) {
+	appid, err := getSteamAppID(uid)
+	if err != nil {
+		return 0, err
+	}
+	if appid != 0 {
+		var game steamGridDBGame
+		err = p.get(fmt.Sprintf("/games/steam/%d", appid), &game)
+		if err == nil && game.ID != 0 {
+			return game.ID, nil
+		}
+	}
+
+	name, err := getGameTitle(uid)
+	if err != nil {
+		return 0, err
+	}
+	games, err := p.searchGames(name)
+	if err != nil {
+		return 0, err
+	}
+	if len(games) == 0 {
+		return 0, fmt.Errorf("no steamgriddb match for %s", name)
+	}
+	return games[0].ID, nil
+}
+
+func (p *steamGridDBProvider) FindArtwork(uid string, assetType string, providerGameID int) ([]artworkCandidate, error) {
+	if providerGameID == 0 {
+		var err error
+		providerGameID, err = p.gameIDForUID(uid)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var path string
+	switch assetType {
+	case artworkCover:
+		path = fmt.Sprintf("/grids/game/%d?dimensions=600x900,342x482,660x930&mimes=image/png,image/jpeg,image/webp", providerGameID)
+	case artworkHero:
+		path = fmt.Sprintf("/heroes/game/%d?mimes=image/png,image/jpeg,image/webp", providerGameID)
+	case artworkLogo:
+		path = fmt.Sprintf("/logos/game/%d?mimes=image/png,image/webp", providerGameID)
+	case artworkIcon:
+		path = fmt.Sprintf("/icons/game/%d?mimes=image/png", providerGameID)
+	default:
+		return nil, fmt.Errorf("unknown artwork type %s", assetType)
+	}
+
+	var assets []struct {
+		ID     int    `json:"id"`
+		Score  int    `json:"score"`
+		Style  string `json:"style"`
+		URL    string `json:"url"`
+		Thumb  string `json:"thumb"`
+		Width  int    `json:"width"`
+		Height int    `json:"height"`
+		Author struct {
+			Name string `json:"name"`
+		} `json:"author"`
+	}
+	err := p.get(path, &assets)
+	if err != nil {
+		return nil, fmt.Errorf("steamgriddb %s error: %w", assetType, err)
+	}
+
+	candidates := []artworkCandidate{}
+	for _, asset := range assets {
+		candidates = append(candidates, artworkCandidate{
+			URL:      asset.URL,
+			Thumb:    asset.Thumb,
+			Width:    asset.Width,
+			Height:   asset.Height,
+			Style:    asset.Style,
+			Author:   asset.Author.Name,
+			Score:    asset.Score,
+			Provider: p.Name(),
+		})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+	return candidates, nil
+}
+
+func searchSteamGridDB(term string) ([]steamGridDBGame, error) {
+	provider, err := newSteamGridDBProvider()
+	if err != nil {
+		return nil, err
+	}
+	return provider.searchGames(term)
+}
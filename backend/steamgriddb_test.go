@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Serves canned SteamGridDB responses keyed by request path and query
+func newSteamGridDBStub(t *testing.T, responses map[string]string) *steamGridDBProvider {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, ok := responses[r.URL.RequestURI()]
+		if !ok {
+			t.Errorf("unexpected request %s", r.URL.RequestURI())
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return &steamGridDBProvider{apiKey: "test-key", baseURL: srv.URL, client: srv.Client()}
+}
+
+func TestSteamGridDBFindArtworkSortsByScore(t *testing.T) {
+	p := newSteamGridDBStub(t, map[string]string{
+		"/grids/game/42?dimensions=600x900,342x482,660x930&mimes=image/png,image/jpeg,image/webp": `{"success":true,"data":[
+			{"id":1,"score":2,"style":"alternate","url":"https://cdn/low.png","thumb":"https://cdn/low_t.png","width":600,"height":900,"author":{"name":"a"}},
+			{"id":2,"score":9,"style":"official","url":"https://cdn/high.png","thumb":"https://cdn/high_t.png","width":600,"height":900,"author":{"name":"b"}}]}`,
+	})
+
+	candidates, err := p.FindArtwork("", artworkCover, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != 2 {
+		t.Fatalf("got %d candidates, want 2", len(candidates))
+	}
+	first := candidates[0]
+	if first.URL != "https://cdn/high.png" || first.Score != 9 || first.Author != "b" || first.Style != "official" || first.Provider != "steamgriddb" {
+		t.Errorf("highest score should come first, got %+v", first)
+	}
+}
+
+func TestSteamGridDBSearchImagesByTerm(t *testing.T) {
+	p := newSteamGridDBStub(t, map[string]string{
+		"/search/autocomplete/Hollow%20Knight":                                                   `{"success":true,"data":[{"id":7,"name":"Hollow Knight"}]}`,
+		"/heroes/game/7?mimes=image/png,image/jpeg,image/webp":                                   `{"success":true,"data":[{"id":3,"url":"https://cdn/hero.png","width":1920,"height":620}]}`,
+		"/grids/game/7?dimensions=600x900,342x482,660x930&mimes=image/png,image/jpeg,image/webp": `{"success":true,"data":[{"id":4,"url":"https://cdn/grid.png","width":600,"height":900}]}`,
+	})
+
+	candidates, err := p.SearchImages(imageSearchQuery{Term: "Hollow Knight", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != 2 {
+		t.Fatalf("got %d candidates, want 2", len(candidates))
+	}
+	if candidates[0].Kind != artworkHero || candidates[0].URL != "https://cdn/hero.png" {
+		t.Errorf("heroes should come first, got %+v", candidates[0])
+	}
+	if candidates[1].Kind != artworkCover || candidates[1].Width != 600 {
+		t.Errorf("unexpected grid candidate %+v", candidates[1])
+	}
+}
+
+func TestSteamGridDBNoMatch(t *testing.T) {
+	p := newSteamGridDBStub(t, map[string]string{
+		"/search/autocomplete/nothing": `{"success":true,"data":[]}`,
+	})
+	_, err := p.SearchImages(imageSearchQuery{Term: "nothing", Limit: 10})
+	if err == nil || !strings.Contains(err.Error(), "no steamgriddb match") {
+		t.Errorf("expected a no match error, got %v", err)
+	}
+}
+
+func TestSteamGridDBErrors(t *testing.T) {
+	p := newSteamGridDBStub(t, map[string]string{
+		"/search/autocomplete/broken": `{"success":false,"errors":["Game not found"]}`,
+	})
+	_, err := p.searchGames("broken")
+	if err == nil || !strings.Contains(err.Error(), "Game not found") {
+		t.Errorf("expected the API error message, got %v", err)
+	}
+
+	p.apiKey = "wrong-key"
+	_, err = p.searchGames("broken")
+	if err == nil || !strings.Contains(err.Error(), "invalid steamgriddb api key") {
+		t.Errorf("expected an invalid key error, got %v", err)
+	}
+}
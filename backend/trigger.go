@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const screenshotTriggerSetting = "ScreenshotTrigger"
+
+// Desktop hotkeys run `quicksaveService -screenshot`, which talks to the running backend over this socket.
+// QUICKSAVE_SOCKET overrides the location.
+func triggerSocketPath() string {
+	if path := os.Getenv("QUICKSAVE_SOCKET"); path != "" {
+		return path
+	}
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "quicksave.sock")
+}
+
+func isScreenshotTriggerEnabled() bool {
+	value, err := getSetting(screenshotTriggerSetting)
+	return err != nil || value != "false"
+}
+
+// Listens for "screenshot [uid]" lines and answers with "ok <path>" or "error <message>"
+func startScreenshotTrigger() {
+	if !isScreenshotTriggerEnabled() {
+		return
+	}
+	path := triggerSocketPath()
+
+	// A socket left behind by a crashed backend would block the listener
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		log.Printf("[Trigger] socket %s is already in use", path)
+		return
+	}
+	os.Remove(path)
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		log.Printf("[Trigger] could not listen on %s: %v", path, err)
+		return
+	}
+	os.Chmod(path, 0600)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				log.Printf("[Trigger] accept error: %v", err)
+				return
+			}
+			go handleTriggerConn(conn)
+		}
+	}()
+}
+
+func handleTriggerConn(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil && line == "" {
+		return
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		fmt.Fprintln(conn, "error empty command")
+		return
+	}
+
+	switch fields[0] {
+	case "screenshot":
+		uid := ""
+		if len(fields) > 1 {
+			uid = fields[1]
+		}
+		path, err := takeScreenshot(uid)
+		if err != nil {
+			log.Printf("[Trigger] ERROR : %v", err)
+			fmt.Fprintln(conn, "error", err.Error())
+			return
+		}
+		fmt.Fprintln(conn, "ok", path)
+	default:
+		fmt.Fprintln(conn, "error unknown command", fields[0])
+	}
+}
+
+// Client side of the trigger, used by the -screenshot flag
+func sendTriggerCommand(command string) (string, error) {
+	conn, err := net.DialTimeout("unix", triggerSocketPath(), 2*time.Second)
+	if err != nil {
+		return "", fmt.Errorf("quicksave backend is not running: %w", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+
+	_, err = fmt.Fprintln(conn, command)
+	if err != nil {
+		return "", err
+	}
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil && reply == "" {
+		return "", fmt.Errorf("no reply from backend: %w", err)
+	}
+	reply = strings.TrimSpace(reply)
+	if msg, ok := strings.CutPrefix(reply, "error "); ok {
+		return "", fmt.Errorf("%s", msg)
+	}
+	return strings.TrimPrefix(reply, "ok "), nil
+}
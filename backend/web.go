@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/subtle"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// The build copies the frontend into web/dist before compiling, builds without it only have the
+// login page and leave the web frontend off.
+//
+//go:embed web
+var webFiles embed.FS
+
+const (
+	webDistDir       = "web/dist"
+	sessionCookie    = "quicksave_session"
+	sessionLifetime  = 30 * 24 * time.Hour
+	loginFailedDelay = time.Second
+)
+
+var loginPage = template.Must(template.ParseFS(webFiles, "web/login.html"))
+
+// Browsers can't send the API token with page loads, images or EventSource, so signing in trades
+// the token or web password for a session cookie. Sessions live in memory and end with the backend.
+type webSessions struct {
+	mu      sync.Mutex
+	expires map[string]time.Time
+}
+
+var sessions = &webSessions{expires: make(map[string]time.Time)}
+
+// Failed sign ins wait their turn, so guessing is one try per second however many run at once
+var loginLimiter sync.Mutex
+
+func (s *webSessions) create() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(raw)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for existing, expires := range s.expires {
+		if now.After(expires) {
+			delete(s.expires, existing)
+		}
+	}
+	s.expires[id] = now.Add(sessionLifetime)
+	return id, nil
+}
+
+func (s *webSessions) valid(id string) bool {
+	if id == "" {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expires, ok := s.expires[id]
+	return ok && time.Now().Before(expires)
+}
+
+func (s *webSessions) remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.expires, id)
+}
+
+func requestSession(c *gin.Context) bool {
+	id, err := c.Cookie(sessionCookie)
+	return err == nil && sessions.valid(id)
+}
+
+// The built frontend, nil when this binary was compiled without it
+func webFrontend() fs.FS {
+	dist, err := fs.Sub(webFiles, webDistDir)
+	if err != nil {
+		return nil
+	}
+	if _, err := fs.Stat(dist, "index.html"); err != nil {
+		return nil
+	}
+	return dist
+}
+
+func checkWebFrontend() {
+	if config.Web && webFrontend() == nil {
+		log.Printf("[Web] this build has no frontend, only the API is served")
+	}
+}
+
+// Page loads of / get the frontend, other requests keep getting the health check
+func serveWebIndex(c *gin.Context) bool {
+	dist := webFrontend()
+	if !config.Web || dist == nil || !strings.Contains(c.GetHeader("Accept"), "text/html") {
+		return false
+	}
+	c.Header("Cache-Control", "no-cache")
+	c.FileFromFS("/", http.FS(dist))
+	return true
+}
+
+// Serves frontend assets for paths no route claims, the rest falls through to the 404 handler
+func serveWeb(c *gin.Context) {
+	dist := webFrontend()
+	if !config.Web || dist == nil || (c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead) {
+		c.Next()
+		return
+	}
+	name := strings.TrimPrefix(path.Clean(c.Request.URL.Path), "/")
+	info, err := fs.Stat(dist, name)
+	if err != nil || info.IsDir() {
+		c.Next()
+		return
+	}
+	// Vite puts a content hash in asset names
+	if strings.HasPrefix(name, "assets/") {
+		c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	}
+	c.FileFromFS(name, http.FS(dist))
+	c.Abort()
+}
+
+func webPasswordSet() bool {
+	configured, err := secretConfigured(secretWebPassword)
+	if err != nil {
+		log.Printf("[Web] ERROR : %v", err)
+	}
+	return configured
+}
+
+// Accepts the API token or the web password
+func checkLoginSecret(secret string) bool {
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(apiToken)) == 1 {
+		return true
+	}
+	hash, err := getSecret(secretWebPassword)
+	if err != nil {
+		log.Printf("[Web] ERROR : %v", err)
+		return false
+	}
+	return hash != "" && bcrypt.CompareHashAndPassword([]byte(hash), []byte(secret)) == nil
+}
+
+func renderLogin(c *gin.Context, status int, failed bool) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(status)
+	err := loginPage.Execute(c.Writer, gin.H{"Failed": failed, "HasPassword": webPasswordSet()})
+	if err != nil {
+		log.Printf("[Web] ERROR rendering login page: %v", err)
+	}
+}
+
+func registerWebRoutes(r *gin.Engine) {
+	r.GET("/login", func(c *gin.Context) {
+		renderLogin(c, http.StatusOK, false)
+	})
+
+	r.POST("/login", func(c *gin.Context) {
+		if !checkLoginSecret(c.PostForm("secret")) {
+			loginLimiter.Lock()
+			time.Sleep(loginFailedDelay)
+			loginLimiter.Unlock()
+			log.Printf("[Web] failed sign in from %s", c.ClientIP())
+			renderLogin(c, http.StatusUnauthorized, true)
+			return
+		}
+		id, err := sessions.create()
+		if err != nil {
+			log.Printf("[Web] ERROR : %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not sign in", "details": err.Error()})
+			return
+		}
+		log.Printf("[Web] signed in from %s", c.ClientIP())
+		c.SetSameSite(http.SameSiteStrictMode)
+		c.SetCookie(sessionCookie, id, int(sessionLifetime.Seconds()), "/", "", c.Request.TLS != nil, true)
+		c.Redirect(http.StatusSeeOther, "/")
+	})
+
+	r.POST("/logout", func(c *gin.Context) {
+		if id, err := c.Cookie(sessionCookie); err == nil {
+			sessions.remove(id)
+		}
+		c.SetSameSite(http.SameSiteStrictMode)
+		c.SetCookie(sessionCookie, "", -1, "/", "", c.Request.TLS != nil, true)
+		c.Redirect(http.StatusSeeOther, "/login")
+	})
+}
+
+// Run by -set-web-password. Reads the password from stdin so it works over SSH and in scripts,
+// an empty password removes it and leaves sign in to the API token.
+func setWebPassword() error {
+	fmt.Fprint(os.Stderr, "New web password (empty to remove): ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return fmt.Errorf("error reading password: %w", err)
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return setSecret(secretWebPassword, "")
+	}
+	if len(password) < 8 {
+		return fmt.Errorf("the password needs at least 8 characters")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	return setSecret(secretWebPassword, string(hash))
+}
@@ -20,6 +20,10 @@ module.exports = {
           from: "backend/updater/updater.exe",
           to: "../win-unpacked/backend/updater.exe",
         },
+        {
+          from: "backend/cli/quicksave.exe",
+          to: "../win-unpacked/backend/quicksave.exe",
+        },
       ]
     : isLinux
       ? [
@@ -31,6 +35,10 @@ module.exports = {
             from: "backend/updater/updater",
             to: "../linux-unpacked/backend/updater",
           },
+          {
+            from: "backend/cli/quicksave",
+            to: "../linux-unpacked/backend/quicksave",
+          },
         ]
       : [],
   mac: {
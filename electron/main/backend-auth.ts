@@ -0,0 +1,39 @@
+import { session } from "electron";
+import { randomBytes } from "node:crypto";
+
+export const BACKEND_URL = "http://localhost:50001";
+
+// Fresh on every launch, the backend gets it through QUICKSAVE_API_TOKEN and
+// rejects requests without it
+export const apiToken = randomBytes(32).toString("hex");
+
+export const backendHeaders = (): Record<string, string> => ({
+  Authorization: `Bearer ${apiToken}`,
+});
+
+// The renderer loads covers and screenshots from the backend folder, so the
+// data directory stays there whatever the backend would pick by default
+export const backendEnv = (backendDir: string, devServerUrl?: string) => {
+  const env: Record<string, string> = {
+    ...(process.env as Record<string, string>),
+    QUICKSAVE_API_TOKEN: apiToken,
+    QUICKSAVE_DATA_DIR: backendDir,
+  };
+  if (devServerUrl) {
+    env.QUICKSAVE_ALLOWED_ORIGINS = new URL(devServerUrl).origin;
+  }
+  return env;
+};
+
+// Adds the token to every renderer request for the backend, including images
+// and the SSE stream, so the renderer never needs to know it
+export function attachBackendAuth() {
+  session.defaultSession.webRequest.onBeforeSendHeaders(
+    { urls: [`${BACKEND_URL}/*`] },
+    (details, callback) => {
+      callback({
+        requestHeaders: { ...details.requestHeaders, ...backendHeaders() },
+      });
+    }
+  );
+}
@@ -4,6 +4,7 @@ import fs from "fs-extra";
 import { exec } from "child_process";
 
 import { promisify } from "util";
+import { BACKEND_URL, backendHeaders } from "./backend-auth";
 
 // Properly declare execAsync
 const execAsync = promisify(exec);
@@ -169,9 +170,9 @@ async function downloadUpdate(zipUrl: string, win: BrowserWindow) {
     const source = rootDir;
 
     try {
-      const response = await fetch("http://localhost:50001/updateApp", {
+      const response = await fetch(`${BACKEND_URL}/updateApp`, {
         method: "POST",
-        headers: { "Content-Type": "application/json" },
+        headers: { "Content-Type": "application/json", ...backendHeaders() },
         body: JSON.stringify({
           source: source,
           target: appDir,
@@ -3,6 +3,12 @@ import { createRequire } from "node:module";
 import { fileURLToPath } from "node:url";
 import path from "node:path";
 import { promptUpdate } from "./github-updater";
+import {
+  BACKEND_URL,
+  attachBackendAuth,
+  backendEnv,
+  backendHeaders,
+} from "./backend-auth";
 const require = createRequire(import.meta.url);
 import { Tray, nativeImage, Menu } from "electron";
 const { globalShortcut } = require("electron");
@@ -323,6 +329,7 @@ async function createWindow() {
 }
 
 app.whenReady().then(() => {
+  attachBackendAuth();
   createWindow();
 
   app.on("will-quit", () => {
@@ -352,6 +359,7 @@ app.on("ready", () => {
     cwd: path.dirname(serverPath),
     shell: false,
     detached: true,
+    env: backendEnv(path.dirname(serverPath), VITE_DEV_SERVER_URL),
     stdio: ["ignore", "pipe", "pipe"], // Capture stdout and stderr
   });
 
@@ -461,7 +469,8 @@ function registerScreenshotShortcut(uid: string, screenshotBind: string) {
       playShutterSound();
 
       const response = await fetch(
-        `http://localhost:50001/takeScreenshot?uid=${uid}`
+        `${BACKEND_URL}/takeScreenshot?uid=${uid}`,
+        { method: "POST", headers: backendHeaders() }
       );
       const data = await response.text();
       console.log("Screenshot request sent, response:", data);
@@ -531,6 +540,8 @@ ipcMain.handle("image-search", async (_event, query, page) => {
   const win = new BrowserWindow({
     show: false,
     webPreferences: {
+      // Own session so these headers don't replace the backend token listener
+      partition: "image-search",
       offscreen: true,
       contextIsolation: true,
       nodeIntegration: false,
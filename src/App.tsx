@@ -20,7 +20,7 @@ import {
   importPlaystationLibrary,
   importSteamLibrary,
 } from "./lib/api/libraryImports";
-import { getNpsso, getSteamCreds } from "./lib/api/getCreds";
+import { getNpssoConfigured, getSteamCreds } from "./lib/api/getCreds";
 import {
   deleteCurrentlyFiltered,
   hideCurrentlyFiltered,
@@ -142,21 +142,25 @@ function App() {
         getIntegrateOnLaunchEnabled()
       ) {
         const steamCreds = await getSteamCreds();
-        const npsso = await getNpsso();
-        importSteamLibrary(
-          steamCreds?.ID,
-          steamCreds?.APIKey,
-          () => {},
-          setIntegrationLoadCount,
-          () => {}
-        );
-        importPlaystationLibrary(
-          npsso,
-          () => {},
-          () => {},
-          setIntegrationLoadCount,
-          () => {}
-        );
+        const npssoConfigured = await getNpssoConfigured();
+        if (steamCreds?.configured) {
+          importSteamLibrary(
+            steamCreds.ID,
+            "",
+            () => {},
+            setIntegrationLoadCount,
+            () => {}
+          );
+        }
+        if (npssoConfigured) {
+          importPlaystationLibrary(
+            "",
+            () => {},
+            () => {},
+            setIntegrationLoadCount,
+            () => {}
+          );
+        }
       }
     };
     initFunc();
@@ -1,5 +1,9 @@
 import React from "react";
+import { isElectron } from "@/lib/backend";
 export default function WindowButtons() {
+  // The browser has its own window controls
+  if (!isElectron) return null;
+
   const closeWindow = async () => {
     window.windowFunctions.closeApp();
   };
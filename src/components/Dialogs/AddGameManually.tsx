@@ -43,6 +43,7 @@ import {
 } from "@/lib/api/addGameManuallyAPI";
 import { DateTimePicker } from "../ui/datetime-picker";
 import ImageSearchDialog from "./ImageSearchDialog";
+import { BACKEND_URL } from "@/lib/backend";
 
 export default function AddGameManuallyDialog() {
   const { isAddGameDialogOpen, setIsAddGameDialogOpen } = useSortContext();
@@ -84,6 +85,7 @@ function MetaDataView() {
   const [rating, setRating] = useState<any>("");
   const [timePlayed, setTimePlayed] = useState<any>("");
   const [description, setDescription] = useState<any>("");
+  const [igdb, setIgdb] = useState<any>(null);
 
   useEffect(() => {
     fetchTagsDevsPlatforms(setTagOptions, setDevOptions, setPlatformOptions);
@@ -206,6 +208,7 @@ function MetaDataView() {
       coverImage,
       ssImage,
       0, // For wishlist
+      igdb,
       setAddGameLoading,
       toast
     );
@@ -665,6 +668,7 @@ function MetaDataView() {
             setSelectedDevs={setSelectedDevs}
             setCoverImage={setCoverImage}
             setSsImage={setSsImage}
+            setIgdb={setIgdb}
           />
         </div>
       </div>
@@ -685,6 +689,7 @@ function FoundGames({
   setSelectedDevs,
   setCoverImage,
   setSsImage,
+  setIgdb,
 }: {
   data: any;
   setData: React.Dispatch<React.SetStateAction<string | null>>;
@@ -698,6 +703,7 @@ function FoundGames({
   setSelectedDevs: React.Dispatch<React.SetStateAction<any>>;
   setCoverImage: React.Dispatch<React.SetStateAction<any>>;
   setSsImage: React.Dispatch<React.SetStateAction<any>>;
+  setIgdb: React.Dispatch<React.SetStateAction<any>>;
 }) {
   const [loadingAppId, setLoadingAppId] = useState<string | null>(null);
 
@@ -706,7 +712,7 @@ function FoundGames({
   const IgdbGameClicked = async (appid: any) => {
     try {
       setLoadingAppId(appid);
-      const response = await fetch("http://localhost:50001/GetIgdbInfo", {
+      const response = await fetch(`${BACKEND_URL}/GetIgdbInfo`, {
         method: "POST",
         headers: {
           "Content-Type": "application/json",
@@ -751,6 +757,7 @@ function FoundGames({
       setDescription(data.metadata.description);
       setCoverImage(data.metadata.cover);
       setSsImage(data.metadata.screenshots);
+      setIgdb(data.metadata.igdb);
       setData(null);
       setLoadingAppId(null);
     } catch (error: any) {
@@ -12,6 +12,7 @@ import { Input } from "../ui/input";
 import { Loader2 } from "lucide-react";
 import { showErrorToast } from "@/lib/toastService";
 import React from "react";
+import { BACKEND_URL } from "@/lib/backend";
 
 interface GoogleImage {
   ImageUrl: string;
@@ -62,9 +63,9 @@ export default function ImageSearchDialog({
     if (!originalUrl) return "";
     try {
       const decoded = decodeURIComponent(originalUrl);
-      return `http://localhost:50001/image-proxy?url=${encodeURIComponent(decoded)}`;
+      return `${BACKEND_URL}/image-proxy?url=${encodeURIComponent(decoded)}`;
     } catch {
-      return `http://localhost:50001/image-proxy?url=${encodeURIComponent(originalUrl)}`;
+      return `${BACKEND_URL}/image-proxy?url=${encodeURIComponent(originalUrl)}`;
     }
   };
 
@@ -11,7 +11,7 @@ import {
   importPlaystationLibrary,
   importSteamLibrary,
 } from "@/lib/api/libraryImports";
-import { getSteamCreds, getNpsso } from "@/lib/api/getCreds";
+import { getSteamCreds, getNpssoConfigured } from "@/lib/api/getCreds";
 
 export default function Integrations() {
   const {
@@ -24,9 +24,11 @@ export default function Integrations() {
   const [apiKeyEmpty, setAPIKeyEmpty] = useState(false);
   const [steamID, setSteamID] = useState("");
   const [apiKey, setApiKey] = useState("");
+  const [apiKeyConfigured, setApiKeyConfigured] = useState(false);
   const { toast } = useToast();
   const [npsso, setNpsso] = useState("");
   const [npssoEmpty, setNpssoEmpty] = useState(false);
+  const [npssoConfigured, setNpssoConfigured] = useState(false);
   const [psnGamesNotMatched, setPsnGamesNotMatched] = useState<string[]>([]);
   const [steamLoading, setSteamLoading] = useState<boolean>(false);
   const [psnLoading, setPsnLoading] = useState<boolean>(false);
@@ -36,7 +38,7 @@ export default function Integrations() {
       setSteamIDEmpty(true);
       return;
     }
-    if (!apiKey) {
+    if (!apiKey && !apiKeyConfigured) {
       setAPIKeyEmpty(true);
       return;
     }
@@ -50,7 +52,7 @@ export default function Integrations() {
   };
 
   const PlayStationLibraryImportHandler = () => {
-    if (!npsso) {
+    if (!npsso && !npssoConfigured) {
       setNpssoEmpty(true);
       return;
     }
@@ -66,10 +68,9 @@ export default function Integrations() {
   useEffect(() => {
     const initFuncs = async () => {
       const steamCreds = await getSteamCreds();
-      setSteamID(steamCreds?.ID);
-      setApiKey(steamCreds?.APIKey);
-      const npsso = await getNpsso();
-      setNpsso(npsso);
+      setSteamID(steamCreds?.ID ?? "");
+      setApiKeyConfigured(!!steamCreds?.configured);
+      setNpssoConfigured(!!(await getNpssoConfigured()));
     };
     initFuncs();
   }, []);
@@ -141,6 +142,7 @@ export default function Integrations() {
                             id="apikey"
                             className="h-8 w-full"
                             value={apiKey}
+                            placeholder={apiKeyConfigured ? "Saved" : ""}
                             onChange={(e) => setApiKey(e.target.value)}
                           />
                           <a href="https://steamcommunity.com/dev/apikey">
@@ -177,6 +179,7 @@ export default function Integrations() {
                         </label>
                         <Input
                           value={npsso}
+                          placeholder={npssoConfigured ? "Saved" : ""}
                           onChange={(e) => setNpsso(e.target.value)}
                           id="npsso"
                           className="h-8 w-full"
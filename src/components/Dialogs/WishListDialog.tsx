@@ -49,6 +49,7 @@ import { ScrollArea } from "@/components/ui/scroll-area";
 import { searchGame } from "@/lib/api/addGameManuallyAPI";
 import { DateTimePicker } from "../ui/datetime-picker";
 import ImageSearchDialog from "./ImageSearchDialog";
+import { BACKEND_URL } from "@/lib/backend";
 
 export default function WishlistDialog() {
   const { isWishlistAddDialogOpen, setIsWishlistAddDialogOpen } =
@@ -82,6 +83,7 @@ function MetaDataView() {
   const [rating, setRating] = useState<any>("");
   const [developers, setDevelopers] = useState<any>("");
   const [description, setDescription] = useState<any>("");
+  const [igdb, setIgdb] = useState<any>(null);
   const [tagOptions, setTagOptions] = useState([]);
   const [devOptions, setDevOptions] = useState([]);
   const [platformOptions, setPlatformOptions] = useState([]);
@@ -214,6 +216,7 @@ function MetaDataView() {
       coverImage,
       ssImage,
       1, // For wishlist
+      igdb,
       setAddGameLoading,
       toast
     );
@@ -663,6 +666,7 @@ function MetaDataView() {
             setSelectedDevs={setSelectedDevs}
             setCoverImage={setCoverImage}
             setSsImage={setSsImage}
+            setIgdb={setIgdb}
           />
         </div>
       </div>
@@ -691,6 +695,7 @@ function FoundGames({
   setSelectedDevs,
   setCoverImage,
   setSsImage,
+  setIgdb,
 }: {
   data: any;
   setData: React.Dispatch<React.SetStateAction<string | null>>;
@@ -712,6 +717,7 @@ function FoundGames({
   setSelectedDevs: React.Dispatch<React.SetStateAction<any>>;
   setCoverImage: React.Dispatch<React.SetStateAction<any>>;
   setSsImage: React.Dispatch<React.SetStateAction<any>>;
+  setIgdb: React.Dispatch<React.SetStateAction<any>>;
 }) {
   const [gameInfoLoading, setGameInfoLoading] = useState(false);
   const [loadingAppId, setLoadingAppId] = useState<string | null>(null);
@@ -720,7 +726,7 @@ function FoundGames({
     try {
       setGameInfoLoading(true);
       setLoadingAppId(appid);
-      const response = await fetch("http://localhost:50001/GetIgdbInfo", {
+      const response = await fetch(`${BACKEND_URL}/GetIgdbInfo`, {
         method: "POST",
         headers: {
           "Content-Type": "application/json",
@@ -757,6 +763,7 @@ function FoundGames({
       setDescription(data.metadata.description);
       setCoverImage(data.metadata.cover);
       setSsImage(data.metadata.screenshots);
+      setIgdb(data.metadata.igdb);
       setData(null);
       setGameInfoLoading(false);
       setLoadingAppId(null);
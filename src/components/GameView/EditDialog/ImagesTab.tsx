@@ -3,6 +3,7 @@ import { Card, CardContent } from "@/components/ui/card";
 import { Input } from "@/components/ui/input";
 import { TabsContent } from "@/components/ui/tabs";
 import { useNavigate } from "react-router-dom";
+import { backendFolderPath, coverArtURL } from "@/lib/backend";
 import {
   Globe,
   Link,
@@ -41,9 +42,7 @@ export function ImagesTab({
   const [coverArtLinkClicked, setCoverArtLinkClicked] = useState(false);
   const { exePath } = useExePathContext();
   const [currentCover, setCurrentCover] = useState<string | null>(
-    import.meta.env.MODE === "production"
-      ? `${exePath}/backend/coverArt${coverArtPath}?t=${cacheBuster}`
-      : `./backend/coverArt${coverArtPath}?t=${cacheBuster}`
+    `${coverArtURL(exePath, coverArtPath)}?t=${cacheBuster}`
   );
 
   const handleCoverImageChange = (e: React.ChangeEvent<HTMLInputElement>) => {
@@ -72,7 +71,7 @@ export function ImagesTab({
     if (backendIndex !== -1) {
       return "./" + normalized.slice(backendIndex);
     }
-    return normalized;
+    return backendFolderPath(normalized);
   };
 
   const saveClickHandler = () => {
@@ -16,6 +16,7 @@ import { useEffect, useState } from "react";
 import MultipleSelector from "@/components/ui/multiple-selector";
 import { loadPreferences } from "@/lib/api/GameViewAPI";
 import { DateTimePicker } from "@/components/ui/datetime-picker";
+import { BACKEND_URL } from "@/lib/backend";
 
 export function MetadataTab({ uid, fetchData, tags, companies }: any) {
   const [selectedTags, setSelectedTags] = useState<
@@ -150,7 +151,7 @@ export function MetadataTab({ uid, fetchData, tags, companies }: any) {
   const savePreferences = async (postData: any) => {
     setLoading(true);
     try {
-      const response = await fetch(`http://localhost:50001/SavePreferences`, {
+      const response = await fetch(`${BACKEND_URL}/SavePreferences`, {
         method: "POST",
         headers: {
           "Content-Type": "application/json",
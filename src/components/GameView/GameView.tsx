@@ -1,5 +1,6 @@
 import React, { useCallback, useEffect, useState } from "react";
 import { useLocation, useNavigate } from "react-router-dom";
+import { screenshotURL } from "@/lib/backend";
 import { Button } from "@/components/ui/button";
 import { FaPlay } from "react-icons/fa";
 import { HideDialog } from "./HideDialog";
@@ -160,13 +161,9 @@ function GameView() {
   screenshotsArray = screenshotsArray.sort(enhancedSort);
   const { cacheBuster } = useSortContext();
   const { exePath } = useExePathContext();
-  screenshotsArray = screenshotsArray.map((screenshot) => {
-    if (import.meta.env.MODE === "production") {
-      return `${exePath}/backend/screenshots/${screenshot}?t=${cacheBuster}`;
-    } else {
-      return `./backend/screenshots/${screenshot}?t=${cacheBuster}`;
-    }
-  });
+  screenshotsArray = screenshotsArray.map(
+    (screenshot) => `${screenshotURL(exePath, screenshot)}?t=${cacheBuster}`
+  );
   console.log("ccc", screenshotsArray);
   let timePlayed = metadata?.TimePlayed?.toFixed(1);
   if (timePlayed < 0) timePlayed = "0.0";
@@ -1,5 +1,6 @@
 import React, { useState } from "react";
 import { useNavigate } from "react-router-dom";
+import { BACKEND_URL } from "@/lib/backend";
 
 interface DetailsMakerProps {
   cleanedName: string;
@@ -55,7 +56,7 @@ export default function DetialsMaker({
     try {
       console.log("Sending Get Game Details");
       const response = await fetch(
-        `http://localhost:50001/GameDetails?uid=${uid}`
+        `${BACKEND_URL}/GameDetails?uid=${uid}`
       );
       const json = await response.json();
       console.log(json);
@@ -1,4 +1,5 @@
 import { useSortContext } from "@/hooks/useSortContex";
+import { coverThumbURL } from "@/lib/backend";
 import React, { useState, useEffect, useCallback } from "react";
 import { useNavigate } from "react-router-dom";
 import {
@@ -26,7 +27,6 @@ import {
   DialogTitle,
   DialogTrigger,
 } from "../ui/dialog";
-import { useExePathContext } from "@/hooks/useExePathContext";
 
 interface GridMakerProps {
   data: any;
@@ -83,36 +83,21 @@ export default function GridMaker({
 
   const { cacheBuster } = useSortContext();
 
-  const { exePath } = useExePathContext();
-
-  let imageUrl = `${exePath}/backend/coverArt${cover}`;
+  const imageUrl = coverThumbURL(cover);
   console.log("Image Url", imageUrl);
-  if (import.meta.env.MODE !== "production") {
-    imageUrl = `./backend/coverArt${cover}`;
-  }
 
-  //Check if image exists & is loadable
-  const checkImageLoadable = async (url: string) => {
-    try {
-      const response = await fetch(url, { method: "HEAD" });
-      if (response.ok) {
-        const img = new Image();
-        img.src = `${url}?t=${cacheBuster}`;
-        img.onload = () => {
-          setImageSrc(img.src);
-          setImageLoadFailed(false);
-        };
-        img.onerror = () => {
-          console.error("Image load failed:", url);
-          setImageLoadFailed(true);
-        };
-      } else {
-        setImageLoadFailed(true);
-      }
-    } catch (error) {
-      console.error("Image check error:", error);
+  //Check if image exists & is loadable, the image routes only answer GET
+  const checkImageLoadable = (url: string) => {
+    const img = new Image();
+    img.src = `${url}?t=${cacheBuster}`;
+    img.onload = () => {
+      setImageSrc(img.src);
+      setImageLoadFailed(false);
+    };
+    img.onerror = () => {
+      console.error("Image load failed:", url);
       setImageLoadFailed(true);
-    }
+    };
   };
 
   useEffect(() => {
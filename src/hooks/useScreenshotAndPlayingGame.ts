@@ -10,10 +10,11 @@ export function useScreenshotAndPlayingGame() {
 
   const updateSteam = async () => {
     const steamCreds = await getSteamCreds();
+    if (!steamCreds?.configured) return;
 
     await importSteamLibrary(
-      steamCreds?.ID,
-      steamCreds?.APIKey,
+      steamCreds.ID,
+      "",
       () => {},
       setIntegrationLoadCount,
       () => {}
@@ -2,6 +2,7 @@ import { CheckedState } from "@radix-ui/react-checkbox";
 import { showErrorToast } from "../toastService";
 import { handleApiError } from "./apiErrors";
 import { useNavigate } from "react-router-dom";
+import { BACKEND_URL } from "@/lib/backend";
 
 interface GameData {
   companies: string[];
@@ -24,7 +25,7 @@ export const getGameDetails = async (
   console.log("Sending Get Game Details");
   try {
     const response = await fetch(
-      `http://localhost:50001/GameDetails?uid=${uid}`
+      `${BACKEND_URL}/GameDetails?uid=${uid}`
     );
     if (!response.ok) await handleApiError(response);
     const json = await response.json();
@@ -45,7 +46,7 @@ export const doDataPreload = async (
 ) => {
   try {
     const response = await fetch(
-      `http://localhost:50001/GameDetails?uid=${uid}`
+      `${BACKEND_URL}/GameDetails?uid=${uid}`
     );
     if (!response.ok) await handleApiError(response);
     const json = await response.json();
@@ -67,7 +68,8 @@ export const hardDelete = async (uid: string, navigate: any) => {
   console.log("Sending Delete Game");
   try {
     const response = await fetch(
-      `http://localhost:50001/DeleteGame?uid=${uid}`
+      `${BACKEND_URL}/DeleteGame?uid=${uid}`,
+      { method: "DELETE" }
     );
     if (!response.ok) await handleApiError(response);
   } catch (error) {
@@ -80,7 +82,9 @@ export const hardDelete = async (uid: string, navigate: any) => {
 export const hideGame = async (uid: string, navigate: any) => {
   console.log("Sending Hide Game");
   try {
-    const response = await fetch(`http://localhost:50001/HideGame?uid=${uid}`);
+    const response = await fetch(`${BACKEND_URL}/HideGame?uid=${uid}`, {
+      method: "POST",
+    });
     if (!response.ok) await handleApiError(response);
   } catch (error) {
     console.error(error);
@@ -93,7 +97,8 @@ export const unhideGame = async (uid: string, navigate: any) => {
   try {
     console.log("Sending unhide game");
     const response = await fetch(
-      `http://localhost:50001/unhideGame?uid=${uid}`
+      `${BACKEND_URL}/unhideGame?uid=${uid}`,
+      { method: "POST" }
     );
     if (!response.ok) await handleApiError(response);
   } catch (error) {
@@ -114,7 +119,8 @@ export const launchGame = async (
   setPlayingGame(uid);
   try {
     const response = await fetch(
-      `http://localhost:50001/LaunchGame?uid=${uid}`
+      `${BACKEND_URL}/LaunchGame?uid=${uid}`,
+      { method: "POST" }
     );
     if (!response.ok) await handleApiError(response);
     const json = await response.json();
@@ -134,7 +140,8 @@ export const sendSteamInstallReq = async (uid: string) => {
   console.log("Play Game Clicked");
   try {
     const response = await fetch(
-      `http://localhost:50001/steamInstallReq?uid=${uid}`
+      `${BACKEND_URL}/steamInstallReq?uid=${uid}`,
+      { method: "POST" }
     );
     if (!response.ok) await handleApiError(response);
   } catch (error) {
@@ -147,7 +154,8 @@ export const gamePathSaveHandler = async (uid: string, gamePath: string) => {
   console.log("Saving Game Path", gamePath);
   try {
     const response = await fetch(
-      `http://localhost:50001/setGamePath?uid=${uid}&path=${gamePath}`
+      `${BACKEND_URL}/setGamePath?uid=${uid}&path=${encodeURIComponent(gamePath)}`,
+      { method: "POST" }
     );
     if (!response.ok) await handleApiError(response);
   } catch (error) {
@@ -163,7 +171,7 @@ export const getGamePath = async (
   console.log("Loading Game Path");
   try {
     const response = await fetch(
-      `http://localhost:50001/getGamePath?uid=${uid}`
+      `${BACKEND_URL}/getGamePath?uid=${uid}`
     );
     if (!response.ok) await handleApiError(response);
     const json = await response.json();
@@ -185,7 +193,7 @@ export const saveCustomImage = async (
 ) => {
   setLoading(true);
   try {
-    const response = await fetch(`http://localhost:50001/setCustomImage`, {
+    const response = await fetch(`${BACKEND_URL}/setCustomImage`, {
       method: "POST",
       headers: { "Content-type": "application/json" },
       body: JSON.stringify({
@@ -242,7 +250,7 @@ export const loadPreferences = async (
 ) => {
   try {
     const response = await fetch(
-      `http://localhost:50001/LoadPreferences?uid=${uid}`
+      `${BACKEND_URL}/LoadPreferences?uid=${uid}`
     );
     if (!response.ok) await handleApiError(response);
     const json = await response.json();
@@ -283,7 +291,7 @@ export const loadPreferences = async (
     showErrorToast("Failed to load preferences!", String(error));
   }
   try {
-    const tagsResponse = await fetch("http://localhost:50001/getAllTags");
+    const tagsResponse = await fetch(`${BACKEND_URL}/getAllTags`);
     if (!tagsResponse.ok) await handleApiError(tagsResponse);
     const tagsData = await tagsResponse.json();
 
@@ -298,7 +306,7 @@ export const loadPreferences = async (
     showErrorToast("Failed to load preferences!", String(error));
   }
   try {
-    const devsResponse = await fetch("http://localhost:50001/getAllDevelopers");
+    const devsResponse = await fetch(`${BACKEND_URL}/getAllDevelopers`);
     if (!devsResponse.ok) await handleApiError(devsResponse);
     const devsData = await devsResponse.json();
     console.log(devsData);
@@ -1,6 +1,7 @@
 import { Toast } from "@/components/ui/toast";
 import { handleApiError } from "./apiErrors";
 import { showErrorToast } from "../toastService";
+import { BACKEND_URL } from "@/lib/backend";
 
 export const sendGameToDB = async (
   title: string,
@@ -14,12 +15,13 @@ export const sendGameToDB = async (
   coverImage: any,
   ssImage: any,
   isWishlist: number,
+  igdb: any,
   setAddGameLoading: React.Dispatch<React.SetStateAction<boolean>>,
   toast: any
 ) => {
   try {
     setAddGameLoading(true);
-    const response = await fetch(`http://localhost:50001/addGameToDB`, {
+    const response = await fetch(`${BACKEND_URL}/addGameToDB`, {
       method: "POST",
       headers: { "Content-type": "application/json" },
       body: JSON.stringify({
@@ -34,6 +36,8 @@ export const sendGameToDB = async (
         coverImage: coverImage,
         ssImage: ssImage,
         isWishlist: isWishlist,
+        igdbID: igdb?.igdbID ?? 0,
+        igdb: igdb,
       }),
     });
     if (!response.ok) await handleApiError(response);
@@ -76,7 +80,7 @@ export const searchGame = async (
 
   setLoading(true);
   try {
-    const response = await fetch(`http://localhost:50001/IGDBsearch`, {
+    const response = await fetch(`${BACKEND_URL}/IGDBsearch`, {
       method: "POST",
       headers: { "Content-Type": "application/json" },
       body: JSON.stringify({ NameToSearch: title }),
@@ -100,7 +104,7 @@ export const fetchTagsDevsPlatforms = async (
   setPlatformOptions: React.Dispatch<React.SetStateAction<any>>
 ) => {
   try {
-    const response = await fetch("http://localhost:50001/getAllTags");
+    const response = await fetch(`${BACKEND_URL}/getAllTags`);
     if (!response.ok) await handleApiError(response);
     const resp = await response.json();
 
@@ -117,7 +121,7 @@ export const fetchTagsDevsPlatforms = async (
     showErrorToast("Failed to get tags!", String(error));
   }
   try {
-    const response = await fetch("http://localhost:50001/getAllDevelopers");
+    const response = await fetch(`${BACKEND_URL}/getAllDevelopers`);
     if (!response.ok) await handleApiError(response);
     const resp = await response.json();
     console.log(resp);
@@ -134,7 +138,7 @@ export const fetchTagsDevsPlatforms = async (
     showErrorToast("Failed to get developers!", String(error));
   }
   try {
-    const response = await fetch("http://localhost:50001/getAllPlatforms");
+    const response = await fetch(`${BACKEND_URL}/getAllPlatforms`);
     if (!response.ok) await handleApiError(response);
     const resp = await response.json();
     console.log(resp);
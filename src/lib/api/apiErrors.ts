@@ -1,4 +1,11 @@
+import { isElectron } from "@/lib/backend";
+
 export const handleApiError = async (response: Response) => {
+  // The browser session ended, sign in again
+  if (response.status === 401 && !isElectron) {
+    window.location.assign("/login");
+  }
+
   let errorMessage = `HTTP Error: ${response.status} - ${response.statusText}`;
   let errorDetails = "";
 
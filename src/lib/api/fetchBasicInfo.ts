@@ -5,6 +5,7 @@ import {
 } from "../generalSettings";
 import { showErrorToast } from "../toastService";
 import { handleApiError } from "./apiErrors";
+import { BACKEND_URL } from "@/lib/backend";
 
 export const fetchData = async (
   sortType: string,
@@ -20,7 +21,7 @@ export const fetchData = async (
   console.log("Sending Get Basic Info");
   try {
     const response = await fetch(
-      `http://localhost:50001/getBasicInfo?type=${sortType}&order=${sortOrder}`
+      `${BACKEND_URL}/getBasicInfo?type=${sortType}&order=${sortOrder}`
     );
     if (!response.ok) await handleApiError(response);
     const json = await response.json();
@@ -65,7 +66,9 @@ export const checkBackup = async ({ setBackingUp }: any) => {
     setBackingUp(true);
     console.log("inside");
     try {
-      const resp = await fetch(`http://localhost:50001/backupNow`);
+      const resp = await fetch(`${BACKEND_URL}/backupNow`, {
+        method: "POST",
+      });
       if (!resp.ok) await handleApiError(resp);
     } catch (error) {
       console.error(error);
@@ -1,5 +1,6 @@
 import { showErrorToast } from "../toastService";
 import { handleApiError } from "./apiErrors";
+import { BACKEND_URL } from "@/lib/backend";
 
 export const loadFilterState = async (
   setIsLoaded: React.Dispatch<React.SetStateAction<boolean>>,
@@ -19,7 +20,7 @@ export const loadFilterState = async (
   setIsLoaded(false);
   try {
     console.log("Sending Load Filters");
-    const response = await fetch("http://localhost:50001/LoadFilters");
+    const response = await fetch(`${BACKEND_URL}/LoadFilters`);
     if (!response.ok) await handleApiError(response);
     const data = await response.json();
     if (data.developers) {
@@ -91,7 +92,7 @@ export const handleFilterChange = async (
 
   try {
     console.log("Sending Set Filter");
-    const response = await fetch("http://localhost:50001/setFilter", {
+    const response = await fetch(`${BACKEND_URL}/setFilter`, {
       method: "POST",
       headers: {
         "Content-Type": "application/json",
@@ -118,7 +119,9 @@ export const clearAllFilters = async (
   try {
     console.log("Sending Clear All Filters");
     // Send the filter as a POST request
-    const response = await fetch("http://localhost:50001/clearAllFilters");
+    const response = await fetch(`${BACKEND_URL}/clearAllFilters`, {
+      method: "POST",
+    });
 
     if (!response.ok) await handleApiError(response);
   } catch (error: any) {
@@ -131,7 +134,7 @@ export const deleteCurrentlyFiltered = async (games: any[]) => {
   const uids = games.map((game) => game.UID);
   try {
     const response = await fetch(
-      `http://localhost:50001/deleteCurrentlyFiltered`,
+      `${BACKEND_URL}/deleteCurrentlyFiltered`,
       {
         method: "POST",
         headers: {
@@ -152,7 +155,7 @@ export const hideCurrentlyFiltered = async (games: any[]) => {
   const uids = games.map((game) => game.UID);
   try {
     const response = await fetch(
-      `http://localhost:50001/hideCurrentlyFiltered`,
+      `${BACKEND_URL}/hideCurrentlyFiltered`,
       {
         method: "POST",
         headers: {
@@ -172,7 +175,7 @@ export const unHideCurrentlyFiltered = async (games: any[]) => {
   const uids = games.map((game) => game.UID);
   try {
     const response = await fetch(
-      `http://localhost:50001/unHideCurrentlyFiltered`,
+      `${BACKEND_URL}/unHideCurrentlyFiltered`,
       {
         method: "POST",
         headers: {
@@ -1,17 +1,19 @@
 import { showErrorToast } from "../toastService";
 import { handleApiError } from "./apiErrors";
+import { BACKEND_URL } from "@/lib/backend";
 
+// Secrets stay in the backend, it only reports whether they are stored
 export const getSteamCreds = async () => {
   console.log("Getting Steam Creds");
 
   try {
-    const response = await fetch(`http://localhost:50001/SteamCreds`);
+    const response = await fetch(`${BACKEND_URL}/SteamCreds`);
     if (!response.ok) await handleApiError(response);
     const json = await response.json();
 
     return {
-      ID: json.SteamCreds[0],
-      APIKey: json.SteamCreds[1],
+      ID: json.steamId as string,
+      configured: json.configured as boolean,
     };
   } catch (error) {
     console.error("Error fetching Steam credentials:", error);
@@ -20,14 +22,13 @@ export const getSteamCreds = async () => {
   }
 };
 
-export const getNpsso = async () => {
+export const getNpssoConfigured = async () => {
   console.log("Getting Npsso");
   try {
-    const response = await fetch(`http://localhost:50001/Npsso`);
+    const response = await fetch(`${BACKEND_URL}/Npsso`);
     if (!response.ok) await handleApiError(response);
     const json = await response.json();
-    console.log(json.Npsso);
-    return json.Npsso;
+    return json.configured as boolean;
   } catch (error) {
     console.error(error);
     showErrorToast("Failed to get npsso!", String(error));
@@ -0,0 +1,63 @@
+import { BACKEND_URL } from "@/lib/backend";
+
+export type JobItem = {
+  position: number;
+  key: string;
+  name: string;
+  status: "pending" | "added" | "updated" | "skipped" | "unmatched" | "failed";
+  error?: string;
+};
+
+export type Job = {
+  id: string;
+  kind: string;
+  source: string;
+  status: "queued" | "running" | "done" | "failed" | "cancelled" | "interrupted";
+  continueOnError: boolean;
+  error?: string;
+  total: number;
+  done: number;
+  failed: number;
+  added: number;
+  items?: JobItem[];
+};
+
+const activeStatuses = ["queued", "running"];
+const endEvents = ["import.done", "import.failed", "import.cancelled"];
+
+export const getJob = async (id: string): Promise<Job> => {
+  const response = await fetch(`${BACKEND_URL}/api/v1/jobs/${id}`);
+  if (!response.ok) {
+    throw new Error(`Failed to load job ${id}: HTTP ${response.status}`);
+  }
+  return response.json();
+};
+
+// Resolves with the job and its items once it stops running. The job is also
+// checked on every (re)connect of the event stream, so an end missed while
+// disconnected or before the stream opened still resolves.
+export const waitForJob = (id: string): Promise<Job> =>
+  new Promise((resolve, reject) => {
+    const eventSource = new EventSource(`${BACKEND_URL}/sse-steam-updates`);
+    const check = async () => {
+      try {
+        const job = await getJob(id);
+        if (!activeStatuses.includes(job.status)) {
+          eventSource.close();
+          resolve(job);
+        }
+      } catch (error) {
+        eventSource.close();
+        reject(error);
+      }
+    };
+
+    eventSource.addEventListener("connected", check);
+    for (const type of endEvents) {
+      eventSource.addEventListener(type, (event: MessageEvent) => {
+        if (JSON.parse(event.data).data?.id === id) {
+          check();
+        }
+      });
+    }
+  });
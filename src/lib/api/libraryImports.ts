@@ -1,5 +1,19 @@
 import { useSortContext } from "@/hooks/useSortContex";
 import { fetchData } from "./fetchBasicInfo";
+import { BACKEND_URL } from "@/lib/backend";
+import { Job, waitForJob } from "./jobs";
+
+// Imports run as jobs on the backend, a job that stopped early is reported as
+// an error. It can be resumed from where it stopped through /api/v1/jobs.
+const jobOutcome = (job: Job) => {
+  if (job.status === "cancelled" || job.status === "interrupted") {
+    throw `Import ${job.status} after ${job.done} of ${job.total} games`;
+  }
+  if (job.status === "failed") {
+    throw job.error || "Import failed";
+  }
+  return job.failed > 0 ? ` ${job.failed} games could not be imported.` : "";
+};
 
 export const importSteamLibrary = async (
   steamID: string,
@@ -8,7 +22,8 @@ export const importSteamLibrary = async (
   setIntegrationLoadCount: (fn: (prev: number) => number) => void,
   toast: any
 ) => {
-  if (!steamID || !apiKey) {
+  // An empty key makes the backend use the stored one
+  if (!steamID) {
     return;
   }
 
@@ -20,7 +35,7 @@ export const importSteamLibrary = async (
       title: "Steam Integration Started!",
       description: "You can safely leave this page now.",
     });
-    const response = await fetch("http://localhost:50001/SteamImport", {
+    const response = await fetch(`${BACKEND_URL}/SteamImport`, {
       method: "POST",
       headers: {
         "Content-Type": "application/json",
@@ -37,10 +52,13 @@ export const importSteamLibrary = async (
       const errorDetails = errorResp.details || "";
       throw errorMessage + " -- " + errorDetails;
     }
+    const job = await waitForJob((await response.json()).id);
+    const failedNote = jobOutcome(job);
     toast({
       variant: "default",
       title: "Library Integrated!",
-      description: "Your Steam library has been successfully integrated.",
+      description:
+        "Your Steam library has been successfully integrated." + failedNote,
     });
     setSteamLoading(false);
   } catch (error) {
@@ -62,12 +80,8 @@ export const importPlaystationLibrary = async (
   setIntegrationLoadCount: (fn: (prev: number) => number) => void,
   toast: any
 ) => {
-  if (!npsso) {
-    console.error("NPSSO token is required.");
-    return;
-  }
-
-  console.log("Sending PlayStation Import Req", npsso);
+  // An empty token makes the backend use the stored one
+  console.log("Sending PlayStation Import Req");
 
   setIntegrationLoadCount((prev: number) => prev + 1);
   setPsnLoading(true);
@@ -77,7 +91,7 @@ export const importPlaystationLibrary = async (
       title: "PSN Integration Started!",
       description: "You can safely leave this page now.",
     });
-    const response = await fetch("http://localhost:50001/PlayStationImport", {
+    const response = await fetch(`${BACKEND_URL}/PlayStationImport`, {
       method: "POST",
       headers: {
         "Content-Type": "application/json",
@@ -95,16 +109,21 @@ export const importPlaystationLibrary = async (
       throw errorMessage + " -- " + errorDetails;
     }
 
-    const resp = await response.json();
-    console.log("PSN Games Not Matched:", resp.gamesNotMatched);
+    const job = await waitForJob((await response.json()).id);
+    const failedNote = jobOutcome(job);
+    const gamesNotMatched = (job.items || [])
+      .filter((item) => item.status === "unmatched")
+      .map((item) => item.name);
+    console.log("PSN Games Not Matched:", gamesNotMatched);
 
     toast({
       variant: "default",
       title: "Library Integrated!",
-      description: "Your PSN library has been successfully integrated.",
+      description:
+        "Your PSN library has been successfully integrated." + failedNote,
     });
     setPsnLoading(false);
-    setPsnGamesNotMatched(resp.gamesNotMatched);
+    setPsnGamesNotMatched(gamesNotMatched);
   } catch (error) {
     setPsnLoading(false);
     console.error("Error:", error);
@@ -1,15 +1,32 @@
+import { BACKEND_URL } from "@/lib/backend";
+
+// Events that change what the library shows. The backend sends "reset" when
+// it can't replay what was missed while disconnected, so anything may have
+// changed. EventSource reconnects with Last-Event-ID on its own.
+const libraryEvents = [
+  "game.added",
+  "game.updated",
+  "game.deleted",
+  "game.hidden",
+  "game.unhidden",
+  "filter.changed",
+  "import.done",
+  "restore.done",
+  "reset",
+];
+
 export function attachSSEListener(fetchData: () => void) {
   const eventSource = new EventSource(
-    "http://localhost:50001/sse-steam-updates"
+    `${BACKEND_URL}/sse-steam-updates`
   );
 
-  eventSource.onmessage = (event) => {
-    console.log("SSE message received:", event.data);
-    console.log("Current location:", location.pathname);
-
-    // Prevent updates if user is on /gameview
+  const onLibraryEvent = (event: MessageEvent) => {
+    console.log("SSE event received:", event.type, event.data);
     fetchData();
   };
+  for (const type of libraryEvents) {
+    eventSource.addEventListener(type, onLibraryEvent);
+  }
 
   eventSource.onerror = (error) => {
     console.error("SSE Error:", error);
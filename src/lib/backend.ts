@@ -0,0 +1,46 @@
+// The desktop app talks to its own backend on localhost. In a browser the page
+// is served by the backend, so requests go to the page's origin and are
+// authenticated by the session cookie from signing in.
+export const isElectron = "electron" in window;
+
+export const BACKEND_URL = isElectron
+  ? "http://localhost:50001"
+  : window.location.origin;
+
+// The desktop app reads covers and screenshots from the backend folder, a
+// browser gets them from the backend
+const backendFolderURL = (exePath: string, folder: string) =>
+  import.meta.env.MODE === "production"
+    ? `${exePath}/backend/${folder}`
+    : `./backend/${folder}`;
+
+export const coverArtURL = (exePath: string, coverArtPath: string) =>
+  isElectron
+    ? `${backendFolderURL(exePath, "coverArt")}${coverArtPath}`
+    : `${BACKEND_URL}/cover-art${coverArtPath}`;
+
+// Library grid tiles use the thumb variant, which only the backend generates,
+// so the desktop app loads it over HTTP as well
+export const coverThumbURL = (coverArtPath: string) =>
+  `${BACKEND_URL}/images/thumb/coverArt${coverArtPath}`;
+
+export const screenshotURL = (exePath: string, screenshot: string) =>
+  isElectron
+    ? `${backendFolderURL(exePath, "screenshots")}/${screenshot}`
+    : `${BACKEND_URL}/screenshots/${screenshot}`;
+
+// Turns a cover or screenshot URL from the backend back into the backend
+// folder path that saving images expects
+export const backendFolderPath = (url: string) => {
+  const routes: [string, string][] = [
+    ["/cover-art/", "coverArt"],
+    ["/screenshots/", "screenshots"],
+  ];
+  for (const [route, folder] of routes) {
+    const prefix = BACKEND_URL + route;
+    if (url.startsWith(prefix)) {
+      return `./backend/${folder}/${url.slice(prefix.length)}`;
+    }
+  }
+  return url;
+};
@@ -0,0 +1,33 @@
+import { isElectron } from "./backend";
+
+// In a browser the preload bridges don't exist. Desktop only features (window
+// controls, file dialogs, image search, updates) do nothing there, so the rest
+// of the UI works unchanged.
+if (!isElectron) {
+  const noop = () => {};
+  window.windowFunctions = {
+    nukeCache: noop,
+    closeApp: noop,
+    minimize: noop,
+    maximize: noop,
+    updatePlayingGame: noop,
+  };
+  window.electron = {
+    openFolder: async () => {},
+    // The backend checks the path when it launches the game
+    validateGamePath: async (gamePath: string) => ({
+      isValid: true,
+      message: gamePath,
+    }),
+    browseFileHandler: async () => ({ canceled: true, filePaths: [] }),
+    onUpdateAvailable: noop,
+    sendUpdateResponse: async () => {},
+    onProgress: noop,
+    imageSearch: async () => [],
+    fetchImageBuffer: async () => null,
+    updateMinimizeSetting: noop,
+    onRequestMinimizeSetting: noop,
+    sendMinimizeSetting: noop,
+  };
+  window.appPaths = { exePath: async () => "" };
+}
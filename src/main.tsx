@@ -1,3 +1,4 @@
+import "./lib/browserShims";
 import React from "react";
 import ReactDOM from "react-dom/client";
 import App from "./App";